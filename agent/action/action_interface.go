@@ -22,3 +22,9 @@ type Action interface {
 	Resume() (interface{}, error)
 	Cancel() error
 }
+
+// ProgressReporter is implemented by asynchronous actions that report
+// their progress through get_task while they are running
+type ProgressReporter interface {
+	Progress() interface{}
+}
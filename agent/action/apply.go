@@ -22,6 +22,7 @@ const (
 type ApplyAction struct {
 	applier         boshappl.Applier
 	specService     boshas.V1Service
+	specHistory     boshas.SpecHistory
 	settingsService boshsettings.Service
 	instanceDir     string
 	fs              boshsys.FileSystem
@@ -30,12 +31,14 @@ type ApplyAction struct {
 func NewApply(
 	applier boshappl.Applier,
 	specService boshas.V1Service,
+	specHistory boshas.SpecHistory,
 	settingsService boshsettings.Service,
 	dirProvider directories.Provider,
 	fs boshsys.FileSystem,
 ) (action ApplyAction) {
 	action.applier = applier
 	action.specService = specService
+	action.specHistory = specHistory
 	action.settingsService = settingsService
 	action.instanceDir = dirProvider.InstanceDir()
 	action.fs = fs
@@ -63,6 +66,11 @@ func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
 	}
 
 	if desiredSpec.ConfigurationHash != "" {
+		err = a.recordCurrentSpec(resolvedDesiredSpec)
+		if err != nil {
+			return "", err
+		}
+
 		err = a.applier.Apply(resolvedDesiredSpec)
 		if err != nil {
 			return "", bosherr.WrapError(err, "Applying")
@@ -82,6 +90,36 @@ func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
 	return "applied", nil
 }
 
+// recordCurrentSpec keeps the current spec to roll back to before it is
+// replaced. It is recorded before applying so that an instance can also
+// be rolled back after the apply failed.
+func (a ApplyAction) recordCurrentSpec(desiredSpec boshas.V1ApplySpec) error {
+	currentSpec, err := a.specService.Get()
+	if err != nil {
+		return bosherr.WrapError(err, "Getting current apply spec")
+	}
+
+	if currentSpec.ConfigurationHash == "" {
+		return nil
+	}
+
+	same, err := boshas.SameSpecs(currentSpec, desiredSpec)
+	if err != nil {
+		return err
+	}
+
+	if same {
+		return nil
+	}
+
+	err = a.specHistory.Push(currentSpec)
+	if err != nil {
+		return bosherr.WrapError(err, "Recording current apply spec")
+	}
+
+	return nil
+}
+
 func (a ApplyAction) writeInstanceData(spec boshas.V1ApplySpec) error {
 	err := a.writeInstanceField("id", spec.NodeID)
 	if err != nil {
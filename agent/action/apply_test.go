@@ -22,6 +22,7 @@ var _ = Describe("ApplyAction", func() {
 	var (
 		applier         *fakeappl.FakeApplier
 		specService     *fakeas.FakeV1Service
+		specHistory     *fakeas.FakeSpecHistory
 		settingsService *fakesettings.FakeSettingsService
 		dirProvider     boshdir.Provider
 		action          ApplyAction
@@ -31,10 +32,11 @@ var _ = Describe("ApplyAction", func() {
 	BeforeEach(func() {
 		applier = fakeappl.NewFakeApplier()
 		specService = fakeas.NewFakeV1Service()
+		specHistory = fakeas.NewFakeSpecHistory()
 		settingsService = &fakesettings.FakeSettingsService{}
 		dirProvider = boshdir.NewProvider("/var/vcap")
 		fs = fakesys.NewFakeFileSystem()
-		action = NewApply(applier, specService, settingsService, dirProvider, fs)
+		action = NewApply(applier, specService, specHistory, settingsService, dirProvider, fs)
 	})
 
 	AssertActionIsAsynchronous(action)
@@ -135,11 +137,48 @@ var _ = Describe("ApplyAction", func() {
 						})
 					})
 
+					It("records the current spec to roll back to before applying", func() {
+						_, err := action.Run(desiredApplySpec)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(specHistory.PushedSpecs).To(Equal([]boshas.V1ApplySpec{currentApplySpec}))
+					})
+
+					It("does not record the current spec when it is applied again", func() {
+						specService.Spec = populatedDesiredApplySpec
+
+						_, err := action.Run(desiredApplySpec)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(specHistory.PushedSpecs).To(BeEmpty())
+					})
+
+					It("does not record the current spec when it was never applied", func() {
+						specService.Spec = boshas.V1ApplySpec{}
+
+						_, err := action.Run(desiredApplySpec)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(specHistory.PushedSpecs).To(BeEmpty())
+					})
+
+					It("returns error without applying when the current spec cannot be recorded", func() {
+						specHistory.PushErr = errors.New("fake-push-error")
+
+						_, err := action.Run(desiredApplySpec)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("fake-push-error"))
+						Expect(applier.Applied).To(BeFalse())
+					})
+
 					Context("when applier fails applying desired spec", func() {
 						BeforeEach(func() {
 							applier.ApplyError = errors.New("fake-apply-error")
 						})
 
+						It("keeps the recorded current spec to roll back to", func() {
+							_, err := action.Run(desiredApplySpec)
+							Expect(err).To(HaveOccurred())
+							Expect(specHistory.Specs).To(Equal([]boshas.V1ApplySpec{currentApplySpec}))
+						})
+
 						It("returns error", func() {
 							_, err := action.Run(desiredApplySpec)
 							Expect(err).To(HaveOccurred())
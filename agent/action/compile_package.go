@@ -51,15 +51,16 @@ func (a CompilePackageAction) Run(blobID string, multiDigest boshcrypto.Multiple
 		})
 	}
 
-	uploadedBlobID, uploadedDigest, err := a.compiler.Compile(pkg, modelsDeps)
+	compiled, err := a.compiler.Compile(pkg, modelsDeps)
 	if err != nil {
 		err = bosherr.WrapErrorf(err, "Compiling package %s", pkg.Name)
 		return
 	}
 
-	result := map[string]string{
-		"blobstore_id": uploadedBlobID,
-		"sha1":         uploadedDigest.String(),
+	result := map[string]interface{}{
+		"blobstore_id": compiled.BlobID,
+		"sha1":         compiled.Digest.String(),
+		"cache_hit":    compiled.CacheHit,
 	}
 
 	val = map[string]interface{}{
@@ -86,9 +86,10 @@ var _ = Describe("CompilePackageAction", func() {
 			}
 
 			expectedValue := map[string]interface{}{
-				"result": map[string]string{
+				"result": map[string]interface{}{
 					"blobstore_id": "my-blob-id",
 					"sha1":         "some checksum",
+					"cache_hit":    false,
 				},
 			}
 
@@ -121,6 +122,22 @@ var _ = Describe("CompilePackageAction", func() {
 			Expect(compiler.CompileDeps).To(ConsistOf(expectedDeps))
 		})
 
+		It("reports when the compiled package was taken from the compile cache", func() {
+			compiler.CompileBlobID = "my-blob-id"
+			compiler.CompileDigest = boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "some checksum")
+			compiler.CompileCacheHit = true
+
+			value, err := action.Run(getCompileActionArguments())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal(map[string]interface{}{
+				"result": map[string]interface{}{
+					"blobstore_id": "my-blob-id",
+					"sha1":         "some checksum",
+					"cache_hit":    true,
+				},
+			}))
+		})
+
 		It("returns error when compile fails", func() {
 			compiler.CompileErr = errors.New("fake-compile-error")
 
@@ -55,13 +55,14 @@ func (a CompilePackageWithSignedURL) Run(request CompilePackageWithSignedURLRequ
 		})
 	}
 
-	_, uploadedDigest, err := a.compiler.Compile(pkg, modelsDeps)
+	compiled, err := a.compiler.Compile(pkg, modelsDeps)
 	if err != nil {
 		return map[string]interface{}{}, bosherr.WrapErrorf(err, "Compiling package %s", pkg.Name)
 	}
 
-	result := map[string]string{
-		"sha1": uploadedDigest.String(),
+	result := map[string]interface{}{
+		"sha1":      compiled.Digest.String(),
+		"cache_hit": compiled.CacheHit,
 	}
 
 	return map[string]interface{}{
@@ -100,8 +100,9 @@ var _ = Describe("CompilePackageWithSignedURL", func() {
 			}
 
 			expectedValue := map[string]interface{}{
-				"result": map[string]string{
-					"sha1": "some checksum",
+				"result": map[string]interface{}{
+					"sha1":      "some checksum",
+					"cache_hit": false,
 				},
 			}
 			expectedDeps := []boshmodels.Package{
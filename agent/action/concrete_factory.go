@@ -1,10 +1,15 @@
 package action
 
 import (
+	"path/filepath"
+
+	"code.cloudfoundry.org/clock"
+
 	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	bosherrand "github.com/cloudfoundry/bosh-agent/agent/errand"
 	blobdelegator "github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator"
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
@@ -33,6 +38,7 @@ func NewFactory(
 	compiler boshcomp.Compiler,
 	jobSupervisor boshjobsuper.JobSupervisor,
 	specService boshas.V1Service,
+	specHistory boshas.SpecHistory,
 	jobScriptProvider boshscript.JobScriptProvider,
 	logger boshlog.Logger,
 	blobstoreDelegator blobdelegator.BlobstoreDelegator) (factory Factory) {
@@ -41,6 +47,7 @@ func NewFactory(
 	dirProvider := platform.GetDirProvider()
 	vitalsService := platform.GetVitalsService()
 	certManager := platform.GetCertManager()
+	errandRunHistory := bosherrand.NewFileRunHistory(platform.GetFs(), filepath.Join(dirProvider.BoshDir(), "errand_runs.json"), 100)
 
 	factory = concreteFactory{
 		availableActions: map[string]Action{
@@ -60,14 +67,21 @@ func NewFactory(
 			"shutdown":                   NewShutdown(platform),
 
 			// Job management
-			"prepare":    NewPrepare(applier),
-			"apply":      NewApply(applier, specService, settingsService, dirProvider, platform.GetFs()),
-			"start":      NewStart(jobSupervisor, applier, specService),
-			"stop":       NewStop(jobSupervisor),
-			"drain":      NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, logger),
-			"get_state":  NewGetState(settingsService, specService, jobSupervisor, vitalsService),
-			"run_errand": NewRunErrand(specService, dirProvider.JobsDir(), platform.GetRunner(), logger),
-			"run_script": NewRunScript(jobScriptProvider, specService, logger),
+			"prepare":     NewPrepare(applier),
+			"apply":       NewApply(applier, specService, specHistory, settingsService, dirProvider, platform.GetFs()),
+			"rollback":    NewRollback(applier, specService, specHistory),
+			"start":       NewStart(jobSupervisor, applier, specService),
+			"stop":        NewStop(jobSupervisor),
+			"start_job":   NewStartJob(jobSupervisor),
+			"stop_job":    NewStopJob(jobSupervisor),
+			"restart_job": NewRestartJob(jobSupervisor, clock.NewClock()),
+			"drain":       NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, settingsService, clock.NewClock(), logger),
+			"get_state":   NewGetState(settingsService, specService, jobSupervisor, vitalsService),
+			"run_errand":  NewRunErrand(specService, dirProvider.JobsDir(), dirProvider.LogsDir(), platform.GetRunner(), platform.GetFs(), blobstoreDelegator, errandRunHistory, clock.NewClock(), logger),
+			"run_script":  NewRunScript(jobScriptProvider, specService, settingsService, logger),
+
+			// Errand history
+			"list_errand_runs": NewListErrandRuns(errandRunHistory),
 
 			// Compilation
 			"compile_package":                 NewCompilePackage(compiler),
@@ -1,6 +1,8 @@
 package action_test
 
 import (
+	"code.cloudfoundry.org/clock"
+
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -37,6 +39,7 @@ var _ = Describe("concreteFactory", func() {
 		compiler          *fakecomp.FakeCompiler
 		jobSupervisor     *fakejobsuper.FakeJobSupervisor
 		specService       *fakeas.FakeV1Service
+		specHistory       *fakeas.FakeSpecHistory
 		jobScriptProvider boshscript.JobScriptProvider
 		factory           Factory
 		logger            boshlog.Logger
@@ -59,6 +62,7 @@ var _ = Describe("concreteFactory", func() {
 		compiler = fakecomp.NewFakeCompiler()
 		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
 		specService = fakeas.NewFakeV1Service()
+		specHistory = fakeas.NewFakeSpecHistory()
 		jobScriptProvider = &scriptfakes.FakeJobScriptProvider{}
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 		blobDelegator = &fakeblobdelegator.FakeBlobstoreDelegator{}
@@ -73,6 +77,7 @@ var _ = Describe("concreteFactory", func() {
 			compiler,
 			jobSupervisor,
 			specService,
+			specHistory,
 			jobScriptProvider,
 			logger,
 			blobDelegator,
@@ -91,11 +96,17 @@ var _ = Describe("concreteFactory", func() {
 		Expect(action).To(BeEquivalentTo(NewApply(
 			applier,
 			specService,
+			specHistory,
 			settingsService,
 			boshdir.NewProvider("/var/vcap"),
 			fileSystem,
 		)))
 	})
+	It("rollback", func() {
+		action, err := factory.Create("rollback")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewRollback(applier, specService, specHistory)))
+	})
 
 	It("drain", func() {
 		action, err := factory.Create("drain")
@@ -183,6 +194,24 @@ var _ = Describe("concreteFactory", func() {
 		Expect(action).To(Equal(NewStop(jobSupervisor)))
 	})
 
+	It("start_job", func() {
+		action, err := factory.Create("start_job")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewStartJob(jobSupervisor)))
+	})
+
+	It("stop_job", func() {
+		action, err := factory.Create("stop_job")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewStopJob(jobSupervisor)))
+	})
+
+	It("restart_job", func() {
+		action, err := factory.Create("restart_job")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewRestartJob(jobSupervisor, clock.NewClock())))
+	})
+
 	It("remove_persistent_disk", func() {
 		action, err := factory.Create("remove_persistent_disk")
 		Expect(err).ToNot(HaveOccurred())
@@ -215,10 +244,16 @@ var _ = Describe("concreteFactory", func() {
 		Expect(action).To(BeAssignableToTypeOf(RunErrandAction{}))
 	})
 
+	It("list_errand_runs", func() {
+		action, err := factory.Create("list_errand_runs")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(BeAssignableToTypeOf(ListErrandRunsAction{}))
+	})
+
 	It("run_script", func() {
 		action, err := factory.Create("run_script")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewRunScript(jobScriptProvider, specService, logger)))
+		Expect(action).To(Equal(NewRunScript(jobScriptProvider, specService, settingsService, logger)))
 	})
 
 	It("prepare", func() {
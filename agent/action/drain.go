@@ -2,12 +2,18 @@ package action
 
 import (
 	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	"github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
 	boshdrain "github.com/cloudfoundry/bosh-agent/agent/script/drain"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
@@ -17,12 +23,50 @@ type DrainAction struct {
 	notifier          boshnotif.Notifier
 	specService       boshas.V1Service
 	jobSupervisor     boshjobsuper.JobSupervisor
+	settingsService   boshsettings.Service
+	timeService       clock.Clock
 
 	logTag   string
 	logger   boshlog.Logger
 	cancelCh chan struct{}
+	progress *drainProgress
+}
+
+// drainProgress keeps the drain scripts of the running drain
+// so that their progress can be reported while they run
+type drainProgress struct {
+	lock    sync.Mutex
+	scripts map[string]boshdrain.ProgressReporter
+}
+
+// DrainActionResult is returned to directors that understand
+// per-job drain results (protocol version > 3)
+type DrainActionResult struct {
+	Value int              `json:"value"`
+	Jobs  []DrainJobResult `json:"jobs"`
+}
+
+type DrainJobResult struct {
+	Job      string       `json:"job"`
+	Outcome  DrainOutcome `json:"outcome"`
+	Duration float64      `json:"duration"`
+	TimedOut bool         `json:"timed_out"`
+	Error    string       `json:"error,omitempty"`
 }
 
+type DrainOutcome string
+
+const (
+	DrainOutcomeSucceeded DrainOutcome = "succeeded"
+	DrainOutcomeFailed    DrainOutcome = "failed"
+	DrainOutcomeTimedOut  DrainOutcome = "timed_out"
+	DrainOutcomeSkipped   DrainOutcome = "skipped"
+)
+
+// Drain scripts are given 10 seconds to exit once cancelled before they are
+// killed, see drain.ConcreteScript
+const drainCancelGracePeriod = 15 * time.Second
+
 type DrainType string
 
 const (
@@ -36,6 +80,8 @@ func NewDrain(
 	specService boshas.V1Service,
 	jobScriptProvider boshscript.JobScriptProvider,
 	jobSupervisor boshjobsuper.JobSupervisor,
+	settingsService boshsettings.Service,
+	timeService clock.Clock,
 	logger boshlog.Logger,
 ) DrainAction {
 	return DrainAction{
@@ -43,10 +89,13 @@ func NewDrain(
 		specService:       specService,
 		jobScriptProvider: jobScriptProvider,
 		jobSupervisor:     jobSupervisor,
+		settingsService:   settingsService,
+		timeService:       timeService,
 
 		logTag:   "Drain Action",
 		logger:   logger,
 		cancelCh: make(chan struct{}, 1),
+		progress: &drainProgress{},
 	}
 }
 
@@ -62,32 +111,71 @@ func (a DrainAction) IsLoggable() bool {
 	return true
 }
 
-func (a DrainAction) Run(drainType DrainType, newSpecs ...boshas.V1ApplySpec) (int, error) {
+// Run returns a DrainActionResult with the outcome of every job's drain
+// script to directors that understand it (protocol version > 3)
+func (a DrainAction) Run(protocolVersion ProtocolVersion, drainType DrainType, newSpecs ...boshas.V1ApplySpec) (interface{}, error) {
+	results, err := a.drain(drainType, newSpecs)
+
+	if protocolVersion <= 3 {
+		return 0, err
+	}
+
+	return DrainActionResult{Value: 0, Jobs: results}, err
+}
+
+func (a DrainAction) drain(drainType DrainType, newSpecs []boshas.V1ApplySpec) ([]DrainJobResult, error) {
+	results := []DrainJobResult{}
+
 	currentSpec, err := a.specService.Get()
 	if err != nil {
-		return 0, bosherr.WrapError(err, "Getting current spec")
+		return results, bosherr.WrapError(err, "Getting current spec")
 	}
 
 	params, err := a.determineParams(drainType, currentSpec, newSpecs)
 	if err != nil {
-		return 0, err
+		return results, err
 	}
 
 	a.logger.Debug(a.logTag, "Unmonitoring")
 
 	err = a.jobSupervisor.Unmonitor()
 	if err != nil {
-		return 0, bosherr.WrapError(err, "Unmonitoring services")
+		return results, bosherr.WrapError(err, "Unmonitoring services")
 	}
 	//TODO write health.json
 
+	jobs := currentSpec.Jobs()
+	timeouts := a.drainTimeouts(jobs, newSpecs)
+
 	var scripts []boshscript.Script
+	var jobScripts []*deadlineScript
 
-	for _, job := range currentSpec.Jobs() {
+	reporters := map[string]boshdrain.ProgressReporter{}
+
+	for _, job := range jobs {
 		script := a.jobScriptProvider.NewDrainScript(job.BundleName(), params)
-		scripts = append(scripts, script)
+
+		jobScript := &deadlineScript{
+			CancellableScript: script,
+			timeout:           timeouts[job.Name],
+			timeService:       a.timeService,
+			logTag:            a.logTag,
+			logger:            a.logger,
+			result:            DrainJobResult{Job: job.BundleName(), Outcome: DrainOutcomeSkipped},
+		}
+
+		scripts = append(scripts, jobScript)
+		jobScripts = append(jobScripts, jobScript)
+
+		if reporter, ok := script.(boshdrain.ProgressReporter); ok {
+			reporters[job.BundleName()] = reporter
+		}
 	}
 
+	a.progress.lock.Lock()
+	a.progress.scripts = reporters
+	a.progress.lock.Unlock()
+
 	script := a.jobScriptProvider.NewParallelScript("drain", scripts)
 
 	resultsCh := make(chan error, 1)
@@ -95,13 +183,63 @@ func (a DrainAction) Run(drainType DrainType, newSpecs ...boshas.V1ApplySpec) (i
 	select {
 	case result := <-resultsCh:
 		a.logger.Debug(a.logTag, "Got a result")
-		return 0, result
+
+		for _, jobScript := range jobScripts {
+			results = append(results, jobScript.Result())
+		}
+
+		return results, result
 	case <-a.cancelCh:
 		a.logger.Debug(a.logTag, "Got a cancel request")
-		return 0, script.Cancel()
+
+		cancelErr := script.Cancel()
+
+		timer := a.timeService.NewTimer(drainCancelGracePeriod)
+		defer timer.Stop()
+
+		select {
+		case <-resultsCh:
+		case <-timer.C():
+			a.logger.Warn(a.logTag, "Drain scripts did not exit within %s of being cancelled", drainCancelGracePeriod)
+		}
+
+		for _, jobScript := range jobScripts {
+			results = append(results, jobScript.Result())
+		}
+
+		return results, cancelErr
 	}
 }
 
+// drainTimeouts returns the drain deadline of every job by name. Deadlines
+// of the new spec take precedence over the ones of the current spec, jobs
+// without a deadline in either use the default from the settings.
+func (a DrainAction) drainTimeouts(jobs []models.Job, newSpecs []boshas.V1ApplySpec) map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+
+	for _, job := range jobs {
+		timeouts[job.Name] = job.DrainTimeout
+	}
+
+	if len(newSpecs) > 0 {
+		for _, job := range newSpecs[0].Jobs() {
+			if _, found := timeouts[job.Name]; found && job.DrainTimeout > 0 {
+				timeouts[job.Name] = job.DrainTimeout
+			}
+		}
+	}
+
+	defaultTimeout := a.settingsService.GetSettings().Env.GetDrainTimeout()
+
+	for name, timeout := range timeouts {
+		if timeout <= 0 {
+			timeouts[name] = defaultTimeout
+		}
+	}
+
+	return timeouts
+}
+
 func (a DrainAction) determineParams(drainType DrainType, currentSpec boshas.V1ApplySpec, newSpecs []boshas.V1ApplySpec) (boshdrain.ScriptParams, error) {
 	var newSpec *boshas.V1ApplySpec
 	var params boshdrain.ScriptParams
@@ -135,6 +273,27 @@ func (a DrainAction) determineParams(drainType DrainType, currentSpec boshas.V1A
 	return params, nil
 }
 
+// Progress returns the latest status reported by the drain script of
+// every job that uses the JSON line protocol, keyed by job name
+func (a DrainAction) Progress() interface{} {
+	a.progress.lock.Lock()
+	defer a.progress.lock.Unlock()
+
+	jobs := map[string]boshdrain.Progress{}
+
+	for job, reporter := range a.progress.scripts {
+		if progress, reported := reporter.Progress(); reported {
+			jobs[job] = progress
+		}
+	}
+
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	return jobs
+}
+
 func (a DrainAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
@@ -147,3 +306,87 @@ func (a DrainAction) Cancel() error {
 	}
 	return nil
 }
+
+// deadlineScript cancels the drain script of a job once its deadline
+// is reached. A job that timed out does not fail the drain so that a
+// single hanging drain script cannot block the whole update.
+type deadlineScript struct {
+	boshscript.CancellableScript
+
+	timeout     time.Duration
+	timeService clock.Clock
+
+	logTag string
+	logger boshlog.Logger
+
+	lock   sync.Mutex
+	result DrainJobResult
+}
+
+func (s *deadlineScript) Run() error {
+	startedAt := s.timeService.Now()
+
+	if s.timeout <= 0 {
+		err := s.CancellableScript.Run()
+		s.record(startedAt, err, false)
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.CancellableScript.Run() }()
+
+	timer := s.timeService.NewTimer(s.timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-errCh:
+		s.record(startedAt, err, false)
+		return err
+
+	case <-timer.C():
+		s.logger.Warn(s.logTag, "Cancelling drain script of job '%s' after %s", s.Tag(), s.timeout)
+
+		err := s.CancellableScript.Cancel()
+		if err != nil {
+			s.logger.Error(s.logTag, "Failed to cancel drain script of job '%s': %s", s.Tag(), err.Error())
+		}
+
+		// The job is only stopped once its drain script exited
+		graceTimer := s.timeService.NewTimer(drainCancelGracePeriod)
+		defer graceTimer.Stop()
+
+		select {
+		case <-errCh:
+		case <-graceTimer.C():
+			s.logger.Warn(s.logTag, "Drain script of job '%s' did not exit within %s of being cancelled", s.Tag(), drainCancelGracePeriod)
+		}
+
+		s.record(startedAt, nil, true)
+		return nil
+	}
+}
+
+func (s *deadlineScript) Result() DrainJobResult {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.result
+}
+
+func (s *deadlineScript) record(startedAt time.Time, err error, timedOut bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.result.Duration = s.timeService.Since(startedAt).Seconds()
+	s.result.TimedOut = timedOut
+
+	switch {
+	case timedOut:
+		s.result.Outcome = DrainOutcomeTimedOut
+	case err != nil:
+		s.result.Outcome = DrainOutcomeFailed
+		s.result.Error = err.Error()
+	default:
+		s.result.Outcome = DrainOutcomeSucceeded
+	}
+}
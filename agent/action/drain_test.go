@@ -2,7 +2,9 @@ package action_test
 
 import (
 	"errors"
+	"time"
 
+	"code.cloudfoundry.org/clock/fakeclock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -15,10 +17,23 @@ import (
 	"github.com/cloudfoundry/bosh-agent/agent/script/scriptfakes"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	fakenotif "github.com/cloudfoundry/bosh-agent/notification/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	"github.com/cloudfoundry/bosh-utils/crypto"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
+// progressReportingScript is a drain script using the JSON line protocol
+type progressReportingScript struct {
+	*scriptfakes.FakeCancellableScript
+
+	progress boshdrain.Progress
+}
+
+func (s *progressReportingScript) Progress() (boshdrain.Progress, bool) {
+	return s.progress, s.progress != boshdrain.Progress{}
+}
+
 var _ = Describe("DrainAction", func() {
 	var (
 		notifier          *fakenotif.FakeNotifier
@@ -26,6 +41,8 @@ var _ = Describe("DrainAction", func() {
 		jobScriptProvider *scriptfakes.FakeJobScriptProvider
 		fakeScripts       map[string]*scriptfakes.FakeCancellableScript
 		jobSupervisor     *fakejobsuper.FakeJobSupervisor
+		settingsService   *fakesettings.FakeSettingsService
+		timeService       *fakeclock.FakeClock
 		action            DrainAction
 		logger            boshlog.Logger
 	)
@@ -37,7 +54,9 @@ var _ = Describe("DrainAction", func() {
 		specService = fakeas.NewFakeV1Service()
 		jobScriptProvider = &scriptfakes.FakeJobScriptProvider{}
 		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
-		action = NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, logger)
+		settingsService = &fakesettings.FakeSettingsService{}
+		timeService = fakeclock.NewFakeClock(time.Now())
+		action = NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, settingsService, timeService, logger)
 	})
 
 	BeforeEach(func() {
@@ -86,8 +105,8 @@ var _ = Describe("DrainAction", func() {
 				}
 			})
 
-			act := func() (int, error) {
-				return action.Run(DrainTypeUpdate, newSpec)
+			act := func() (interface{}, error) {
+				return action.Run(ProtocolVersion(3), DrainTypeUpdate, newSpec)
 			}
 
 			Context("when current agent has a job spec template", func() {
@@ -152,7 +171,41 @@ var _ = Describe("DrainAction", func() {
 
 							scriptName, scripts := jobScriptProvider.NewParallelScriptArgsForCall(0)
 							Expect(scriptName).To(Equal("drain"))
-							Expect(scripts).To(Equal([]boshscript.Script{fooScript, barScript}))
+							Expect(scripts).To(HaveLen(2))
+							Expect(scripts[0].Tag()).To(Equal("foo"))
+							Expect(scripts[1].Tag()).To(Equal("bar"))
+
+							Expect(scripts[0].Run()).To(Succeed())
+							Expect(fooScript.RunCallCount()).To(Equal(1))
+							Expect(barScript.RunCallCount()).To(Equal(0))
+						})
+
+						It("reports the progress of drain scripts using the JSON line protocol while they run", func() {
+							fooScript := &progressReportingScript{FakeCancellableScript: &scriptfakes.FakeCancellableScript{}}
+							barScript := &progressReportingScript{FakeCancellableScript: &scriptfakes.FakeCancellableScript{}}
+
+							jobScriptProvider.NewDrainScriptStub = func(jobName string, params boshdrain.ScriptParams) boshscript.CancellableScript {
+								if jobName == "foo" {
+									return fooScript
+								}
+								return barScript
+							}
+
+							var progress []interface{}
+							parallelScript.RunStub = func() error {
+								progress = append(progress, action.Progress())
+								fooScript.progress = boshdrain.Progress{Status: "draining 120 connections", Progress: 40}
+								progress = append(progress, action.Progress())
+								return nil
+							}
+
+							_, err := act()
+							Expect(err).ToNot(HaveOccurred())
+
+							Expect(progress).To(Equal([]interface{}{
+								nil,
+								map[string]boshdrain.Progress{"foo": {Status: "draining 120 connections", Progress: 40}},
+							}))
 						})
 
 						It("returns an error when parallel script fails", func() {
@@ -165,9 +218,176 @@ var _ = Describe("DrainAction", func() {
 						})
 					})
 
+					Context("when the director understands per-job drain results", func() {
+						var (
+							fooScript *scriptfakes.FakeCancellableScript
+							barScript *scriptfakes.FakeCancellableScript
+						)
+
+						BeforeEach(func() {
+							fooScript = &scriptfakes.FakeCancellableScript{}
+							fooScript.TagReturns("foo")
+							fooScript.ExistsReturns(true)
+
+							barScript = &scriptfakes.FakeCancellableScript{}
+							barScript.TagReturns("bar")
+							barScript.ExistsReturns(true)
+
+							jobScriptProvider.NewDrainScriptStub = func(jobName string, params boshdrain.ScriptParams) boshscript.CancellableScript {
+								if jobName == "foo" {
+									return fooScript
+								}
+								return barScript
+							}
+
+							jobScriptProvider.NewParallelScriptStub = func(name string, scripts []boshscript.Script) boshscript.CancellableScript {
+								return boshscript.NewParallelScript(name, scripts, logger)
+							}
+						})
+
+						hangUntilCancelled := func(script *scriptfakes.FakeCancellableScript) {
+							cancelled := make(chan struct{})
+							script.RunStub = func() error {
+								<-cancelled
+								return errors.New("Script was cancelled by user request")
+							}
+							script.CancelStub = func() error {
+								close(cancelled)
+								return nil
+							}
+						}
+
+						runAsync := func() chan interface{} {
+							valueCh := make(chan interface{}, 1)
+							go func() {
+								defer GinkgoRecover()
+
+								value, err := action.Run(ProtocolVersion(4), DrainTypeUpdate, newSpec)
+								Expect(err).ToNot(HaveOccurred())
+								valueCh <- value
+							}()
+							return valueCh
+						}
+
+						It("returns the outcome of every job's drain script", func() {
+							barScript.RunReturns(errors.New("fake-drain-error"))
+
+							value, err := action.Run(ProtocolVersion(4), DrainTypeUpdate, newSpec)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("Failed Jobs: bar"))
+
+							Expect(value).To(Equal(DrainActionResult{
+								Value: 0,
+								Jobs: []DrainJobResult{
+									{Job: "foo", Outcome: DrainOutcomeSucceeded},
+									{Job: "bar", Outcome: DrainOutcomeFailed, Error: "fake-drain-error"},
+								},
+							}))
+						})
+
+						It("reports jobs without a drain script as skipped", func() {
+							barScript.ExistsReturns(false)
+
+							value, err := action.Run(ProtocolVersion(4), DrainTypeUpdate, newSpec)
+							Expect(err).ToNot(HaveOccurred())
+
+							Expect(value.(DrainActionResult).Jobs).To(Equal([]DrainJobResult{
+								{Job: "foo", Outcome: DrainOutcomeSucceeded},
+								{Job: "bar", Outcome: DrainOutcomeSkipped},
+							}))
+							Expect(barScript.RunCallCount()).To(Equal(0))
+						})
+
+						It("cancels drain scripts that run past the default deadline from the settings without failing the drain", func() {
+							settingsService.Settings = boshsettings.Settings{
+								Env: boshsettings.Env{Bosh: boshsettings.BoshEnv{DrainTimeout: 60}},
+							}
+							hangUntilCancelled(fooScript)
+							barScript.ExistsReturns(false)
+
+							valueCh := runAsync()
+
+							timeService.WaitForWatcherAndIncrement(60 * time.Second)
+
+							var value interface{}
+							Eventually(valueCh).Should(Receive(&value))
+
+							Expect(fooScript.CancelCallCount()).To(Equal(1))
+							Expect(value.(DrainActionResult).Jobs).To(Equal([]DrainJobResult{
+								{Job: "foo", Outcome: DrainOutcomeTimedOut, Duration: 60, TimedOut: true},
+								{Job: "bar", Outcome: DrainOutcomeSkipped},
+							}))
+						})
+
+						It("waits for cancelled drain scripts to exit for a limited time", func() {
+							settingsService.Settings = boshsettings.Settings{
+								Env: boshsettings.Env{Bosh: boshsettings.BoshEnv{DrainTimeout: 60}},
+							}
+							exited := make(chan struct{})
+							defer close(exited)
+							fooScript.RunStub = func() error {
+								<-exited
+								return nil
+							}
+							barScript.ExistsReturns(false)
+
+							valueCh := runAsync()
+
+							timeService.WaitForWatcherAndIncrement(60 * time.Second)
+							Eventually(fooScript.CancelCallCount).Should(Equal(1))
+							Consistently(valueCh).ShouldNot(Receive())
+
+							timeService.WaitForWatcherAndIncrement(15 * time.Second)
+
+							var value interface{}
+							Eventually(valueCh).Should(Receive(&value))
+							Expect(value.(DrainActionResult).Jobs[0].Outcome).To(Equal(DrainOutcomeTimedOut))
+						})
+
+						It("returns the outcome of the drain scripts when the director cancels the drain", func() {
+							hangUntilCancelled(fooScript)
+							barScript.ExistsReturns(false)
+
+							valueCh := runAsync()
+
+							Eventually(fooScript.RunCallCount).Should(Equal(1))
+							Expect(action.Cancel()).To(Succeed())
+
+							var value interface{}
+							Eventually(valueCh).Should(Receive(&value))
+
+							Expect(fooScript.CancelCallCount()).To(Equal(1))
+							Expect(value.(DrainActionResult).Jobs).To(Equal([]DrainJobResult{
+								{Job: "foo", Outcome: DrainOutcomeFailed, Error: "Script was cancelled by user request"},
+								{Job: "bar", Outcome: DrainOutcomeSkipped},
+							}))
+						})
+
+						It("prefers the deadline of a job in the new apply spec", func() {
+							settingsService.Settings = boshsettings.Settings{
+								Env: boshsettings.Env{Bosh: boshsettings.BoshEnv{DrainTimeout: 60}},
+							}
+							addJobTemplate(&newSpec.JobSpec, "foo")
+							newSpec.PropertiesSpec.JobDrainTimeouts = map[string]int{"foo": 10}
+							hangUntilCancelled(fooScript)
+							barScript.ExistsReturns(false)
+
+							valueCh := runAsync()
+
+							timeService.WaitForWatcherAndIncrement(10 * time.Second)
+
+							var value interface{}
+							Eventually(valueCh).Should(Receive(&value))
+
+							Expect(value.(DrainActionResult).Jobs[0]).To(Equal(
+								DrainJobResult{Job: "foo", Outcome: DrainOutcomeTimedOut, Duration: 10, TimedOut: true},
+							))
+						})
+					})
+
 					Context("when apply spec is not provided", func() {
 						It("returns error", func() {
-							value, err := action.Run(DrainTypeUpdate)
+							value, err := action.Run(ProtocolVersion(3), DrainTypeUpdate)
 							Expect(err).To(HaveOccurred())
 							Expect(err.Error()).To(ContainSubstring("Drain update requires new spec"))
 							Expect(value).To(Equal(0))
@@ -201,7 +421,7 @@ var _ = Describe("DrainAction", func() {
 		})
 
 		Context("when drain shutdown is requested", func() {
-			act := func() (int, error) { return action.Run(DrainTypeShutdown) }
+			act := func() (interface{}, error) { return action.Run(ProtocolVersion(3), DrainTypeShutdown) }
 
 			Context("when current agent has a job spec template", func() {
 				var (
@@ -265,7 +485,13 @@ var _ = Describe("DrainAction", func() {
 
 							scriptName, scripts := jobScriptProvider.NewParallelScriptArgsForCall(0)
 							Expect(scriptName).To(Equal("drain"))
-							Expect(scripts).To(Equal([]boshscript.Script{fooScript, barScript}))
+							Expect(scripts).To(HaveLen(2))
+							Expect(scripts[0].Tag()).To(Equal("foo"))
+							Expect(scripts[1].Tag()).To(Equal("bar"))
+
+							Expect(scripts[0].Run()).To(Succeed())
+							Expect(fooScript.RunCallCount()).To(Equal(1))
+							Expect(barScript.RunCallCount()).To(Equal(0))
 						})
 
 						It("returns an error when parallel script fails", func() {
@@ -316,7 +542,7 @@ var _ = Describe("DrainAction", func() {
 		})
 
 		Context("when drain status is requested", func() {
-			act := func() (int, error) { return action.Run(DrainTypeStatus) }
+			act := func() (interface{}, error) { return action.Run(ProtocolVersion(3), DrainTypeStatus) }
 
 			It("returns an error", func() {
 				value, err := act()
@@ -362,7 +588,7 @@ var _ = Describe("DrainAction", func() {
 
 		Context("when action was not canceled yet", func() {
 			It("cancel action", func() {
-				_, err := action.Run(DrainTypeShutdown, newSpec)
+				_, err := action.Run(ProtocolVersion(3), DrainTypeShutdown, newSpec)
 				Expect(err).ToNot(HaveOccurred())
 
 				err = action.Cancel()
@@ -8,13 +8,13 @@ import (
 )
 
 type FakeFactory struct {
-	registeredActions    map[string]*TestAction
+	registeredActions    map[string]boshaction.Action
 	registeredActionErrs map[string]error
 }
 
 func NewFakeFactory() *FakeFactory {
 	return &FakeFactory{
-		registeredActions:    make(map[string]*TestAction),
+		registeredActions:    make(map[string]boshaction.Action),
 		registeredActionErrs: make(map[string]error),
 	}
 }
@@ -29,7 +29,7 @@ func (f *FakeFactory) Create(method string) (boshaction.Action, error) {
 	return nil, errors.New("Action not found")
 }
 
-func (f *FakeFactory) RegisterAction(method string, action *TestAction) {
+func (f *FakeFactory) RegisterAction(method string, action boshaction.Action) {
 	if a := f.registeredActions[method]; a != nil {
 		panic(fmt.Sprintf("Action is already registered: %v", a))
 	}
@@ -84,3 +84,13 @@ func (a *TestAction) Cancel() error {
 	a.Canceled = true
 	return a.CancelErr
 }
+
+type TestProgressAction struct {
+	TestAction
+
+	ProgressValue interface{}
+}
+
+func (a *TestProgressAction) Progress() interface{} {
+	return a.ProgressValue
+}
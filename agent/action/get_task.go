@@ -38,6 +38,7 @@ func (a GetTaskAction) Run(taskID string) (interface{}, error) {
 		return boshtask.StateValue{
 			AgentTaskID: task.ID,
 			State:       task.State,
+			Progress:    task.Progress(),
 		}, nil
 	}
 
@@ -0,0 +1,209 @@
+package action_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+)
+
+type jobAction interface {
+	Action
+	Run(name string) (string, error)
+}
+
+// stoppingJobSupervisor reports the process of fake-job in the given
+// state, like monit does while a stop is still pending
+type stoppingJobSupervisor struct {
+	*fakejobsuper.FakeJobSupervisor
+
+	lock    sync.Mutex
+	state   string
+	started []string
+}
+
+func (s *stoppingJobSupervisor) setState(state string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.state = state
+}
+
+func (s *stoppingJobSupervisor) Processes() ([]boshjobsuper.Process, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return []boshjobsuper.Process{
+		{Name: "fake-process", Job: "fake-job", State: s.state},
+		{Name: "other-process", Job: "other-job", State: "running"},
+	}, nil
+}
+
+func (s *stoppingJobSupervisor) StartService(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.started = append(s.started, name)
+	return nil
+}
+
+func (s *stoppingJobSupervisor) startedServices() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.started...)
+}
+
+var _ = Describe("StartJob, StopJob and RestartJob", func() {
+	var jobSupervisor *fakejobsuper.FakeJobSupervisor
+
+	BeforeEach(func() {
+		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
+	})
+
+	for _, entry := range []struct {
+		name         string
+		newAction    func(boshjobsuper.JobSupervisor) jobAction
+		asynchronous bool
+		value        string
+		stops        bool
+		starts       bool
+	}{
+		{
+			name:      "StartJob",
+			newAction: func(s boshjobsuper.JobSupervisor) jobAction { return NewStartJob(s) },
+			value:     "started",
+			starts:    true,
+		},
+		{
+			name:         "StopJob",
+			newAction:    func(s boshjobsuper.JobSupervisor) jobAction { return NewStopJob(s) },
+			asynchronous: true,
+			value:        "stopped",
+			stops:        true,
+		},
+		{
+			name: "RestartJob",
+			newAction: func(s boshjobsuper.JobSupervisor) jobAction {
+				return NewRestartJob(s, fakeclock.NewFakeClock(time.Now()))
+			},
+			asynchronous: true,
+			value:        "restarted",
+			stops:        true,
+			starts:       true,
+		},
+	} {
+		entry := entry
+
+		Describe(entry.name, func() {
+			var action jobAction
+
+			BeforeEach(func() {
+				action = entry.newAction(jobSupervisor)
+			})
+
+			if entry.asynchronous {
+				AssertActionIsAsynchronous(entry.newAction(nil))
+			} else {
+				AssertActionIsNotAsynchronous(entry.newAction(nil))
+			}
+
+			AssertActionIsNotPersistent(entry.newAction(nil))
+			AssertActionIsLoggable(entry.newAction(nil))
+
+			AssertActionIsNotResumable(entry.newAction(nil))
+			AssertActionIsNotCancelable(entry.newAction(nil))
+
+			It("acts on the named job and returns "+entry.value, func() {
+				value, err := action.Run("fake-job")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(entry.value))
+
+				if entry.stops {
+					Expect(jobSupervisor.StoppedServices).To(Equal([]string{"fake-job"}))
+				} else {
+					Expect(jobSupervisor.StoppedServices).To(BeEmpty())
+				}
+
+				if entry.starts {
+					Expect(jobSupervisor.StartedServices).To(Equal([]string{"fake-job"}))
+				} else {
+					Expect(jobSupervisor.StartedServices).To(BeEmpty())
+				}
+			})
+
+			if entry.stops {
+				It("returns an error and does not start the job when stopping fails", func() {
+					jobSupervisor.StopServiceErr = errors.New("fake-error")
+
+					_, err := action.Run("fake-job")
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("Stopping job 'fake-job': fake-error"))
+					Expect(jobSupervisor.StartedServices).To(BeEmpty())
+				})
+			}
+
+			if entry.starts {
+				It("returns an error when starting fails", func() {
+					jobSupervisor.StartServiceErr = errors.New("fake-error")
+
+					_, err := action.Run("fake-job")
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("Starting job 'fake-job': fake-error"))
+				})
+			}
+		})
+	}
+
+	Describe("RestartJob", func() {
+		var (
+			supervisor  *stoppingJobSupervisor
+			timeService *fakeclock.FakeClock
+			action      RestartJobAction
+		)
+
+		BeforeEach(func() {
+			supervisor = &stoppingJobSupervisor{FakeJobSupervisor: jobSupervisor, state: "running"}
+			timeService = fakeclock.NewFakeClock(time.Now())
+			action = NewRestartJob(supervisor, timeService)
+		})
+
+		It("starts the job only once its processes stopped", func() {
+			done := make(chan error, 1)
+			go func() {
+				_, err := action.Run("fake-job")
+				done <- err
+			}()
+
+			timeService.WaitForNWatchersAndIncrement(time.Second, 2)
+			Eventually(timeService.WatcherCount).Should(Equal(2))
+			Expect(supervisor.startedServices()).To(BeEmpty())
+
+			supervisor.setState("stopped")
+			timeService.Increment(time.Second)
+
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(jobSupervisor.StoppedServices).To(Equal([]string{"fake-job"}))
+			Expect(supervisor.startedServices()).To(Equal([]string{"fake-job"}))
+		})
+
+		It("returns an error and does not start the job when it does not stop in time", func() {
+			done := make(chan error, 1)
+			go func() {
+				_, err := action.Run("fake-job")
+				done <- err
+			}()
+
+			timeService.WaitForNWatchersAndIncrement(5*time.Minute, 2)
+
+			var err error
+			Eventually(done).Should(Receive(&err))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Stopping job 'fake-job': Timed out"))
+			Expect(supervisor.startedServices()).To(BeEmpty())
+		})
+	})
+})
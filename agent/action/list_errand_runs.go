@@ -0,0 +1,62 @@
+package action
+
+import (
+	"errors"
+
+	bosherrand "github.com/cloudfoundry/bosh-agent/agent/errand"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type ListErrandRunsAction struct {
+	history bosherrand.RunHistory
+}
+
+func NewListErrandRuns(history bosherrand.RunHistory) ListErrandRunsAction {
+	return ListErrandRunsAction{history: history}
+}
+
+func (a ListErrandRunsAction) IsAsynchronous(_ ProtocolVersion) bool {
+	return false
+}
+
+func (a ListErrandRunsAction) IsPersistent() bool {
+	return false
+}
+
+func (a ListErrandRunsAction) IsLoggable() bool {
+	return true
+}
+
+// Run returns the latest runs of all errands, or of the given errands,
+// from the oldest to the latest
+func (a ListErrandRunsAction) Run(errandNames ...string) ([]bosherrand.Run, error) {
+	runs, err := a.history.List()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing errand runs")
+	}
+
+	if len(errandNames) == 0 {
+		return runs, nil
+	}
+
+	filtered := []bosherrand.Run{}
+
+	for _, run := range runs {
+		for _, name := range errandNames {
+			if run.Errand == name {
+				filtered = append(filtered, run)
+				break
+			}
+		}
+	}
+
+	return filtered, nil
+}
+
+func (a ListErrandRunsAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a ListErrandRunsAction) Cancel() error {
+	return errors.New("not supported")
+}
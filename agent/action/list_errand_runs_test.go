@@ -0,0 +1,60 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	bosherrand "github.com/cloudfoundry/bosh-agent/agent/errand"
+	"github.com/cloudfoundry/bosh-agent/agent/errand/errandfakes"
+)
+
+var _ = Describe("ListErrandRuns", func() {
+	var (
+		history *errandfakes.FakeRunHistory
+		action  ListErrandRunsAction
+	)
+
+	BeforeEach(func() {
+		history = &errandfakes.FakeRunHistory{}
+		action = NewListErrandRuns(history)
+
+		history.ListReturns([]bosherrand.Run{
+			{Errand: "fake-errand-1", ExitCode: 0},
+			{Errand: "fake-errand-2", ExitCode: 1},
+			{Errand: "fake-errand-1", ExitCode: 2},
+		}, nil)
+	})
+
+	AssertActionIsNotAsynchronous(action)
+	AssertActionIsNotPersistent(action)
+	AssertActionIsLoggable(action)
+
+	AssertActionIsNotResumable(action)
+	AssertActionIsNotCancelable(action)
+
+	It("returns the runs of all errands", func() {
+		runs, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runs).To(HaveLen(3))
+	})
+
+	It("returns the runs of the given errands only", func() {
+		runs, err := action.Run("fake-errand-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runs).To(Equal([]bosherrand.Run{
+			{Errand: "fake-errand-1", ExitCode: 0},
+			{Errand: "fake-errand-1", ExitCode: 2},
+		}))
+	})
+
+	It("returns an error when the history cannot be read", func() {
+		history.ListReturns(nil, errors.New("fake-list-error"))
+
+		_, err := action.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-list-error"))
+	})
+})
@@ -0,0 +1,106 @@
+package action
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	restartJobStopTimeout  = 5 * time.Minute
+	restartJobPollInterval = 1 * time.Second
+)
+
+type RestartJobAction struct {
+	jobSupervisor boshjobsuper.JobSupervisor
+	timeService   clock.Clock
+}
+
+func NewRestartJob(jobSupervisor boshjobsuper.JobSupervisor, timeService clock.Clock) (restartJob RestartJobAction) {
+	restartJob = RestartJobAction{
+		jobSupervisor: jobSupervisor,
+		timeService:   timeService,
+	}
+	return
+}
+
+func (a RestartJobAction) IsAsynchronous(_ ProtocolVersion) bool {
+	return true
+}
+
+func (a RestartJobAction) IsPersistent() bool {
+	return false
+}
+
+func (a RestartJobAction) IsLoggable() bool {
+	return true
+}
+
+func (a RestartJobAction) Run(name string) (string, error) {
+	err := a.jobSupervisor.StopService(name)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Stopping job '%s'", name)
+	}
+
+	err = a.waitUntilStopped(name)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Stopping job '%s'", name)
+	}
+
+	err = a.jobSupervisor.StartService(name)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Starting job '%s'", name)
+	}
+
+	return "restarted", nil
+}
+
+// waitUntilStopped waits until no process of the job runs any more.
+// Supervisors like monit only queue the stop and ignore a start sent
+// while it is pending.
+func (a RestartJobAction) waitUntilStopped(name string) error {
+	timer := a.timeService.NewTimer(restartJobStopTimeout)
+	defer timer.Stop()
+
+	for {
+		processes, err := a.jobSupervisor.Processes()
+		if err != nil {
+			return bosherr.WrapError(err, "Getting processes")
+		}
+
+		stopped := true
+		for _, process := range processes {
+			if process.Name != name && process.Job != name {
+				continue
+			}
+
+			if process.State == "running" || process.State == "starting" || process.State == "stopping" {
+				stopped = false
+			}
+		}
+
+		if stopped {
+			return nil
+		}
+
+		select {
+		case <-timer.C():
+			return bosherr.Errorf("Timed out after %s waiting for processes to stop", restartJobStopTimeout)
+		default:
+		}
+
+		a.timeService.Sleep(restartJobPollInterval)
+	}
+}
+
+func (a RestartJobAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a RestartJobAction) Cancel() error {
+	return errors.New("not supported")
+}
@@ -0,0 +1,86 @@
+package action
+
+import (
+	"errors"
+
+	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
+	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type RollbackAction struct {
+	applier     boshappl.Applier
+	specService boshas.V1Service
+	specHistory boshas.SpecHistory
+}
+
+func NewRollback(
+	applier boshappl.Applier,
+	specService boshas.V1Service,
+	specHistory boshas.SpecHistory,
+) RollbackAction {
+	return RollbackAction{
+		applier:     applier,
+		specService: specService,
+		specHistory: specHistory,
+	}
+}
+
+func (a RollbackAction) IsAsynchronous(_ ProtocolVersion) bool {
+	return true
+}
+
+func (a RollbackAction) IsPersistent() bool {
+	return false
+}
+
+func (a RollbackAction) IsLoggable() bool {
+	return true
+}
+
+// Run applies the latest previous spec again from the jobs and packages
+// that were kept installed for it, without downloading anything. Jobs
+// have to be started afterwards like after an apply. When the rollback
+// fails the current spec stays the current one.
+func (a RollbackAction) Run() (string, error) {
+	currentSpec, err := a.specService.Get()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Getting current apply spec")
+	}
+
+	previousSpecs, err := a.specHistory.List()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Listing previous apply specs")
+	}
+
+	if len(previousSpecs) == 0 {
+		return "", bosherr.Error("No previous apply spec to roll back to")
+	}
+
+	previousSpec := previousSpecs[0]
+
+	err = a.applier.Rollback(currentSpec, previousSpec)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Rolling back")
+	}
+
+	err = a.specService.Set(previousSpec)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Persisting apply spec")
+	}
+
+	err = a.specHistory.Pop()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Forgetting previous apply spec")
+	}
+
+	return "rolled back", nil
+}
+
+func (a RollbackAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a RollbackAction) Cancel() error {
+	return errors.New("not supported")
+}
@@ -0,0 +1,107 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	fakeappl "github.com/cloudfoundry/bosh-agent/agent/applier/fakes"
+)
+
+var _ = Describe("RollbackAction", func() {
+	var (
+		applier      *fakeappl.FakeApplier
+		specService  *fakeas.FakeV1Service
+		specHistory  *fakeas.FakeSpecHistory
+		action       RollbackAction
+		currentSpec  boshas.V1ApplySpec
+		previousSpec boshas.V1ApplySpec
+		olderSpec    boshas.V1ApplySpec
+	)
+
+	BeforeEach(func() {
+		applier = fakeappl.NewFakeApplier()
+		specService = fakeas.NewFakeV1Service()
+		specHistory = fakeas.NewFakeSpecHistory()
+		action = NewRollback(applier, specService, specHistory)
+
+		currentSpec = boshas.V1ApplySpec{ConfigurationHash: "fake-current-config-hash"}
+		previousSpec = boshas.V1ApplySpec{ConfigurationHash: "fake-previous-config-hash"}
+		olderSpec = boshas.V1ApplySpec{ConfigurationHash: "fake-older-config-hash"}
+
+		specService.Spec = currentSpec
+		specHistory.Specs = []boshas.V1ApplySpec{previousSpec, olderSpec}
+	})
+
+	AssertActionIsAsynchronous(action)
+	AssertActionIsNotPersistent(action)
+	AssertActionIsLoggable(action)
+	AssertActionIsNotCancelable(action)
+	AssertActionIsNotResumable(action)
+
+	It("rolls back to the latest previous spec and makes it the current spec", func() {
+		value, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(value).To(Equal("rolled back"))
+
+		Expect(applier.RolledBack).To(BeTrue())
+		Expect(applier.RollbackCurrentApplySpec).To(Equal(currentSpec))
+		Expect(applier.RollbackPreviousApplySpec).To(Equal(previousSpec))
+		Expect(specService.Spec).To(Equal(previousSpec))
+		Expect(specHistory.Specs).To(Equal([]boshas.V1ApplySpec{olderSpec}))
+	})
+
+	It("returns error when the current spec cannot be read", func() {
+		specService.GetErr = errors.New("fake-get-error")
+
+		_, err := action.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-get-error"))
+		Expect(applier.RolledBack).To(BeFalse())
+	})
+
+	It("returns error when there is no previous spec", func() {
+		specHistory.Specs = nil
+
+		_, err := action.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("No previous apply spec"))
+		Expect(applier.RolledBack).To(BeFalse())
+	})
+
+	It("returns error when the previous specs cannot be listed", func() {
+		specHistory.ListErr = errors.New("fake-list-error")
+
+		_, err := action.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-list-error"))
+	})
+
+	Context("when the applier fails rolling back", func() {
+		BeforeEach(func() {
+			applier.RollbackError = errors.New("fake-rollback-error")
+		})
+
+		It("returns error and keeps the current and the previous specs", func() {
+			_, err := action.Run()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-rollback-error"))
+
+			Expect(specService.Spec).To(Equal(currentSpec))
+			Expect(specHistory.Specs).To(Equal([]boshas.V1ApplySpec{previousSpec, olderSpec}))
+		})
+	})
+
+	It("returns error when the previous spec cannot be persisted", func() {
+		specService.SetErr = errors.New("fake-set-error")
+
+		_, err := action.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-set-error"))
+		Expect(specHistory.Popped).To(BeFalse())
+	})
+})
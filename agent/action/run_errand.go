@@ -1,43 +1,148 @@
 package action
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"path"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"code.cloudfoundry.org/clock"
+
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	bosherrand "github.com/cloudfoundry/bosh-agent/agent/errand"
+	blobdelegator "github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator"
+	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
 	"github.com/cloudfoundry/bosh-agent/agent/script/cmd"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-const runErrandActionLogTag = "runErrandAction"
+const (
+	runErrandActionLogTag = "runErrandAction"
+
+	// Every run of an errand logs to a file named after its start time and
+	// a random suffix, so that runs started in the same second do not share it
+	errandLogTimeFormat     = "20060102T150405Z"
+	errandLogSuffixByteSize = 4
+
+	// Bytes of stdout and stderr each returned in the errand result
+	errandOutputTailSize = 64 * 1024
+
+	// Bytes of the latest output kept for directors polling get_task
+	errandLiveOutputSize = 64 * 1024
+)
 
 type RunErrandAction struct {
 	specService boshas.V1Service
 	jobsDir     string
+	logsDir     string
 	cmdRunner   boshsys.CmdRunner
+	fs          boshsys.FileSystem
+	blobstore   blobdelegator.BlobstoreDelegator
+	history     bosherrand.RunHistory
+	timeService clock.Clock
 	logger      boshlog.Logger
 
 	cancelCh chan struct{}
+	output   *errandOutput
+}
+
+// RunErrandOptions are passed by directors as the only argument of
+// run_errand. Older directors pass the name of the errand instead,
+// or nothing at all to run the errand of the instance's job.
+type RunErrandOptions struct {
+	Name string `json:"name"`
+
+	// Passed to bin/run of the errand
+	Args []string          `json:"args"`
+	Env  map[string]string `json:"env"`
+
+	// Who requested the run, recorded in the run history
+	Caller string `json:"caller"`
+
+	// Upload the errand's log through the blobstore once it finishes
+	UploadLog bool `json:"upload_log"`
+}
+
+func (o *RunErrandOptions) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*o = RunErrandOptions{Name: name}
+		return nil
+	}
+
+	type options RunErrandOptions
+
+	return json.Unmarshal(data, (*options)(o))
+}
+
+// ErrandOutputChunk is output written by a running errand. Offset is the
+// position of the chunk in the stream so that directors polling get_task
+// can skip chunks they have already seen.
+type ErrandOutputChunk struct {
+	Stream string `json:"stream"`
+	Offset int64  `json:"offset"`
+	Data   string `json:"data"`
+}
+
+type ErrandProgress struct {
+	Chunks []ErrandOutputChunk `json:"chunks"`
+}
+
+// errandOutput keeps the latest output of the running errand
+type errandOutput struct {
+	lock    sync.Mutex
+	chunks  []ErrandOutputChunk
+	size    int
+	offsets map[string]int64
+}
+
+// errandStreamWriter records the output written to a stream of the errand
+type errandStreamWriter struct {
+	output *errandOutput
+	stream string
+}
+
+// lockedWriter serializes writes of stdout and stderr to the same log file
+type lockedWriter struct {
+	lock sync.Mutex
+	w    io.Writer
 }
 
 func NewRunErrand(
 	specService boshas.V1Service,
 	jobsDir string,
+	logsDir string,
 	cmdRunner boshsys.CmdRunner,
+	fs boshsys.FileSystem,
+	blobstore blobdelegator.BlobstoreDelegator,
+	history bosherrand.RunHistory,
+	timeService clock.Clock,
 	logger boshlog.Logger,
 ) RunErrandAction {
 	return RunErrandAction{
 		specService: specService,
 		jobsDir:     jobsDir,
+		logsDir:     logsDir,
 		cmdRunner:   cmdRunner,
+		fs:          fs,
+		blobstore:   blobstore,
+		history:     history,
+		timeService: timeService,
 		logger:      logger,
 
 		// Initialize channel in a constructor to avoid race
 		// between initializing in Run()/Cancel()
 		cancelCh: make(chan struct{}, 1),
+		output:   &errandOutput{},
 	}
 }
 
@@ -53,21 +158,32 @@ func (a RunErrandAction) IsLoggable() bool {
 	return true
 }
 
+// ErrandResult contains the tail of the errand's stdout and stderr.
+// The whole output is in the log at LogPath.
 type ErrandResult struct {
 	Stdout     string `json:"stdout"`
 	Stderr     string `json:"stderr"`
 	ExitStatus int    `json:"exit_code"`
+
+	LogPath        string `json:"log_path,omitempty"`
+	LogBlobstoreID string `json:"log_blobstore_id,omitempty"`
+	LogSHA1        string `json:"log_sha1,omitempty"`
 }
 
-func (a RunErrandAction) Run(errandName ...string) (ErrandResult, error) {
+func (a RunErrandAction) Run(options ...RunErrandOptions) (ErrandResult, error) {
 	currentSpec, err := a.specService.Get()
 	if err != nil {
 		return ErrandResult{}, bosherr.WrapError(err, "Getting current spec")
 	}
 
 	var templateName string
+	var opts RunErrandOptions
+
+	if len(options) > 0 {
+		opts = options[0]
+	}
 
-	if len(errandName) == 0 {
+	if opts.Name == "" {
 		if len(currentSpec.JobSpec.Template) == 0 {
 			return ErrandResult{}, bosherr.Error("At least one job template is required to run an errand")
 		}
@@ -76,23 +192,86 @@ func (a RunErrandAction) Run(errandName ...string) (ErrandResult, error) {
 	} else {
 		foundErrand := false
 		for _, v := range currentSpec.JobSpec.JobTemplateSpecs {
-			if v.Name == errandName[0] {
+			if v.Name == opts.Name {
 				foundErrand = true
 			}
 		}
 
 		if !foundErrand {
-			return ErrandResult{}, bosherr.Errorf("Could not find errand %s", errandName[0])
+			return ErrandResult{}, bosherr.Errorf("Could not find errand %s", opts.Name)
+		}
+
+		templateName = opts.Name
+	}
+
+	suffixBytes := make([]byte, errandLogSuffixByteSize)
+
+	_, err = rand.Read(suffixBytes)
+	if err != nil {
+		return ErrandResult{}, bosherr.WrapError(err, "Generating errand log name")
+	}
+
+	startedAt := a.timeService.Now()
+	logName := fmt.Sprintf("errand-%s-%s.log", startedAt.UTC().Format(errandLogTimeFormat), hex.EncodeToString(suffixBytes))
+	logPath := filepath.Join(a.logsDir, templateName, logName)
+
+	errandResult, err := a.run(templateName, logPath, opts)
+
+	a.record(bosherrand.Run{
+		Errand:     templateName,
+		Caller:     opts.Caller,
+		StartedAt:  startedAt,
+		FinishedAt: a.timeService.Now(),
+		ExitCode:   errandResult.ExitStatus,
+		LogPath:    logPath,
+	}, err)
+
+	if err != nil {
+		return ErrandResult{}, err
+	}
+
+	if opts.UploadLog {
+		blobID, digest, err := a.blobstore.Write("", logPath, nil)
+		if err != nil {
+			return errandResult, bosherr.WrapError(err, "Uploading errand log")
 		}
 
-		templateName = errandName[0]
+		errandResult.LogBlobstoreID = blobID
+		errandResult.LogSHA1 = digest.String()
 	}
 
-	command := cmd.BuildCommand(path.Join(a.jobsDir, templateName, "bin", "run"))
+	return errandResult, nil
+}
+
+func (a RunErrandAction) run(templateName, logPath string, opts RunErrandOptions) (ErrandResult, error) {
+	failed := ErrandResult{ExitStatus: -1}
+
+	err := a.fs.MkdirAll(filepath.Dir(logPath), os.FileMode(0750))
+	if err != nil {
+		return failed, bosherr.WrapError(err, "Creating errand log directory")
+	}
+
+	logFile, err := a.fs.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, os.FileMode(0640))
+	if err != nil {
+		return failed, bosherr.WrapError(err, "Opening errand log")
+	}
+	defer func() {
+		_ = logFile.Close()
+	}()
+
+	a.output.reset()
+
+	log := &lockedWriter{w: logFile}
+	stdoutTail := boshscript.NewTailBuffer(errandOutputTailSize)
+	stderrTail := boshscript.NewTailBuffer(errandOutputTailSize)
+
+	command := cmd.BuildCommandWithEnv(path.Join(a.jobsDir, templateName, "bin", "run"), opts.Args, opts.Env)
+	command.Stdout = io.MultiWriter(log, stdoutTail, a.output.writer("stdout"))
+	command.Stderr = io.MultiWriter(log, stderrTail, a.output.writer("stderr"))
 
 	process, err := a.cmdRunner.RunComplexCommandAsync(command)
 	if err != nil {
-		return ErrandResult{}, bosherr.WrapError(err, "Running errand script")
+		return failed, bosherr.WrapError(err, "Running errand script")
 	}
 
 	var result boshsys.Result
@@ -112,16 +291,40 @@ func (a RunErrandAction) Run(errandName ...string) (ErrandResult, error) {
 	}
 
 	if result.Error != nil && result.ExitStatus == -1 {
-		return ErrandResult{}, bosherr.WrapError(result.Error, "Running errand script")
+		return failed, bosherr.WrapError(result.Error, "Running errand script")
 	}
 
 	return ErrandResult{
-		Stdout:     result.Stdout,
-		Stderr:     result.Stderr,
+		Stdout:     stdoutTail.String(),
+		Stderr:     stderrTail.String(),
 		ExitStatus: result.ExitStatus,
+		LogPath:    logPath,
 	}, nil
 }
 
+// record does not fail the errand when the run cannot be
+// recorded since the errand has already run at that point
+func (a RunErrandAction) record(run bosherrand.Run, err error) {
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	recordErr := a.history.Record(run)
+	if recordErr != nil {
+		a.logger.Error(runErrandActionLogTag, "Failed to record run of errand %s: %s", run.Errand, recordErr.Error())
+	}
+}
+
+// Progress returns the latest output of the running errand
+func (a RunErrandAction) Progress() interface{} {
+	chunks := a.output.latest()
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	return ErrandProgress{Chunks: chunks}
+}
+
 func (a RunErrandAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
@@ -145,3 +348,66 @@ func (a RunErrandAction) Cancel() error {
 	}
 	return nil
 }
+
+func (o *errandOutput) reset() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.chunks = nil
+	o.size = 0
+	o.offsets = map[string]int64{}
+}
+
+func (o *errandOutput) writer(stream string) io.Writer {
+	return errandStreamWriter{output: o, stream: stream}
+}
+
+func (o *errandOutput) latest() []ErrandOutputChunk {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return append([]ErrandOutputChunk{}, o.chunks...)
+}
+
+// append drops the oldest output once the output kept grows too large
+func (o *errandOutput) append(stream string, data []byte) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	offset := o.offsets[stream]
+	o.offsets[stream] += int64(len(data))
+
+	if len(data) > errandLiveOutputSize {
+		offset += int64(len(data) - errandLiveOutputSize)
+		data = data[len(data)-errandLiveOutputSize:]
+	}
+
+	o.chunks = append(o.chunks, ErrandOutputChunk{Stream: stream, Offset: offset, Data: string(data)})
+	o.size += len(data)
+
+	for o.size > errandLiveOutputSize {
+		excess := o.size - errandLiveOutputSize
+
+		if len(o.chunks[0].Data) <= excess {
+			o.size -= len(o.chunks[0].Data)
+			o.chunks = o.chunks[1:]
+			continue
+		}
+
+		o.chunks[0].Offset += int64(excess)
+		o.chunks[0].Data = o.chunks[0].Data[excess:]
+		o.size -= excess
+	}
+}
+
+func (w errandStreamWriter) Write(p []byte) (int, error) {
+	w.output.append(w.stream, p)
+	return len(p), nil
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.w.Write(p)
+}
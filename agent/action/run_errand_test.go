@@ -1,9 +1,15 @@
 package action_test
 
 import (
+	"encoding/json"
 	"errors"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"time"
 
+	"code.cloudfoundry.org/clock/fakeclock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -11,17 +17,45 @@ import (
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	bosherrand "github.com/cloudfoundry/bosh-agent/agent/errand"
+	"github.com/cloudfoundry/bosh-agent/agent/errand/errandfakes"
+	fakeblobdelegator "github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator/blobstore_delegatorfakes"
 	boshenv "github.com/cloudfoundry/bosh-agent/agent/script/pathenv"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
-	"runtime"
 )
 
+// streamingCmdRunner writes output to the writers of a command when
+// it starts, like the runner of the platform does while it runs
+type streamingCmdRunner struct {
+	*fakesys.FakeCmdRunner
+
+	stdout string
+	stderr string
+}
+
+func (r *streamingCmdRunner) RunComplexCommandAsync(cmd boshsys.Command) (boshsys.Process, error) {
+	if r.stdout != "" {
+		_, _ = cmd.Stdout.Write([]byte(r.stdout))
+	}
+
+	if r.stderr != "" {
+		_, _ = cmd.Stderr.Write([]byte(r.stderr))
+	}
+
+	return r.FakeCmdRunner.RunComplexCommandAsync(cmd)
+}
+
 var _ = Describe("RunErrand", func() {
 	var (
 		specService *fakeas.FakeV1Service
-		cmdRunner   *fakesys.FakeCmdRunner
+		cmdRunner   *streamingCmdRunner
+		fs          *fakesys.FakeFileSystem
+		blobstore   *fakeblobdelegator.FakeBlobstoreDelegator
+		history     *errandfakes.FakeRunHistory
+		timeService *fakeclock.FakeClock
 		action      RunErrandAction
 		errandName  string
 		fullCommand string
@@ -29,9 +63,17 @@ var _ = Describe("RunErrand", func() {
 
 	BeforeEach(func() {
 		specService = fakeas.NewFakeV1Service()
-		cmdRunner = fakesys.NewFakeCmdRunner()
+		cmdRunner = &streamingCmdRunner{
+			FakeCmdRunner: fakesys.NewFakeCmdRunner(),
+			stdout:        "fake-stdout",
+			stderr:        "fake-stderr",
+		}
+		fs = fakesys.NewFakeFileSystem()
+		blobstore = &fakeblobdelegator.FakeBlobstoreDelegator{}
+		history = &errandfakes.FakeRunHistory{}
+		timeService = fakeclock.NewFakeClock(time.Date(2026, time.October, 18, 9, 30, 15, 0, time.UTC))
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		action = NewRunErrand(specService, "/fake-jobs-dir", cmdRunner, logger)
+		action = NewRunErrand(specService, "/fake-jobs-dir", "/fake-logs-dir", cmdRunner, fs, blobstore, history, timeService, logger)
 		errandName = "fake-job-name"
 		if runtime.GOOS == "windows" {
 			fullCommand = "powershell /fake-jobs-dir/fake-job-name/bin/run"
@@ -55,8 +97,6 @@ var _ = Describe("RunErrand", func() {
 					specService.Spec = currentSpec
 					cmdRunner.AddProcess(fullCommand, &fakesys.FakeProcess{
 						WaitResult: boshsys.Result{
-							Stdout:     "fake-stdout",
-							Stderr:     "fake-stderr",
 							ExitStatus: 0,
 						},
 					})
@@ -70,6 +110,7 @@ var _ = Describe("RunErrand", func() {
 							Stdout:     "fake-stdout",
 							Stderr:     "fake-stderr",
 							ExitStatus: 0,
+							LogPath:    result.LogPath,
 						},
 					))
 				})
@@ -93,40 +134,152 @@ var _ = Describe("RunErrand", func() {
 					BeforeEach(func() {
 						cmdRunner.AddProcess(fullCommand, &fakesys.FakeProcess{
 							WaitResult: boshsys.Result{
-								Stdout:     "fake-stdout",
-								Stderr:     "fake-stderr",
 								ExitStatus: 0,
 							},
 						})
 					})
 
 					It("returns errand result without error after running an errand", func() {
-						result, err := action.Run(errandName)
+						result, err := action.Run(RunErrandOptions{Name: errandName})
 						Expect(err).ToNot(HaveOccurred())
 						Expect(result).To(Equal(
 							ErrandResult{
 								Stdout:     "fake-stdout",
 								Stderr:     "fake-stderr",
 								ExitStatus: 0,
+								LogPath:    result.LogPath,
 							},
 						))
 					})
 
+					It("streams the output to the errand log in the job's log dir", func() {
+						cmdRunner.stderr = ""
+
+						result, err := action.Run(RunErrandOptions{Name: errandName})
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(fs.ReadFileString(result.LogPath)).To(Equal("fake-stdout"))
+					})
+
+					It("logs every run to a file named after its start time", func() {
+						cmdRunner.AddProcess(fullCommand, &fakesys.FakeProcess{})
+
+						first, err := action.Run(RunErrandOptions{Name: errandName})
+						Expect(err).ToNot(HaveOccurred())
+
+						second, err := action.Run(RunErrandOptions{Name: errandName})
+						Expect(err).ToNot(HaveOccurred())
+
+						logPathPattern := "^" + regexp.QuoteMeta(filepath.Join("/fake-logs-dir", "fake-job-name", "errand-20261018T093015Z-")) + `[0-9a-f]{8}\.log$`
+						Expect(first.LogPath).To(MatchRegexp(logPathPattern))
+						Expect(second.LogPath).To(MatchRegexp(logPathPattern))
+						Expect(second.LogPath).ToNot(Equal(first.LogPath))
+					})
+
+					It("returns only the tail of long output", func() {
+						cmdRunner.stdout = strings.Repeat("a", 100*1024) + "the end"
+
+						result, err := action.Run(RunErrandOptions{Name: errandName})
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.Stdout).To(HaveLen(64 * 1024))
+						Expect(result.Stdout).To(HaveSuffix("the end"))
+					})
+
+					It("reports the latest output with its offset in the stream as progress", func() {
+						cmdRunner.stdout = strings.Repeat("a", 100*1024)
+
+						_, err := action.Run(RunErrandOptions{Name: errandName})
+						Expect(err).ToNot(HaveOccurred())
+
+						progress := action.Progress().(ErrandProgress)
+						Expect(progress.Chunks).To(HaveLen(2))
+						Expect(progress.Chunks[0].Stream).To(Equal("stdout"))
+						Expect(progress.Chunks[0].Offset).To(Equal(int64(100*1024 - 64*1024 + len("fake-stderr"))))
+						Expect(progress.Chunks[1]).To(Equal(ErrandOutputChunk{Stream: "stderr", Offset: 0, Data: "fake-stderr"}))
+					})
+
+					It("does not upload the errand log unless requested", func() {
+						_, err := action.Run(RunErrandOptions{Name: errandName})
+						Expect(err).ToNot(HaveOccurred())
+						Expect(blobstore.WriteCallCount()).To(Equal(0))
+					})
+
+					It("uploads the errand log when requested", func() {
+						blobstore.WriteReturns("fake-blob-id", boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-sha1")), nil)
+
+						result, err := action.Run(RunErrandOptions{Name: errandName, UploadLog: true})
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.LogBlobstoreID).To(Equal("fake-blob-id"))
+						Expect(result.LogSHA1).To(Equal("fake-sha1"))
+
+						signedURL, path, headers := blobstore.WriteArgsForCall(0)
+						Expect(signedURL).To(BeEmpty())
+						Expect(path).To(Equal(result.LogPath))
+						Expect(headers).To(BeNil())
+					})
+
+					It("returns an error when the errand log cannot be uploaded", func() {
+						blobstore.WriteReturns("", boshcrypto.MultipleDigest{}, errors.New("fake-write-error"))
+
+						_, err := action.Run(RunErrandOptions{Name: errandName, UploadLog: true})
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+					})
+
 					It("runs errand script with properly configured environment", func() {
-						_, err := action.Run(errandName)
+						_, err := action.Run(RunErrandOptions{Name: errandName})
 						Expect(err).ToNot(HaveOccurred())
 						cmd := cmdRunner.RunComplexCommands[0]
 						env := map[string]string{"PATH": boshenv.Path()}
 						Expect(cmd.Env).To(Equal(env))
 					})
+
+					It("passes the requested arguments and environment to the errand script", func() {
+						cmdRunner.AddProcess(fullCommand+" --fake-arg fake-value", &fakesys.FakeProcess{})
+
+						_, err := action.Run(RunErrandOptions{
+							Name: errandName,
+							Args: []string{"--fake-arg", "fake-value"},
+							Env:  map[string]string{"FAKE_VAR": "fake-var-value", "PATH": "fake-path"},
+						})
+						Expect(err).ToNot(HaveOccurred())
+
+						cmd := cmdRunner.RunComplexCommands[0]
+						Expect(cmd.Args).To(ContainElements("--fake-arg", "fake-value"))
+						Expect(cmd.Env).To(Equal(map[string]string{
+							"FAKE_VAR": "fake-var-value",
+							"PATH":     boshenv.Path(),
+						}))
+					})
+
+					It("records the run in the history", func() {
+						result, err := action.Run(RunErrandOptions{Name: errandName, Caller: "fake-caller"})
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(history.RecordCallCount()).To(Equal(1))
+						Expect(history.RecordArgsForCall(0)).To(Equal(bosherrand.Run{
+							Errand:     errandName,
+							Caller:     "fake-caller",
+							StartedAt:  timeService.Now(),
+							FinishedAt: timeService.Now(),
+							ExitCode:   0,
+							LogPath:    result.LogPath,
+						}))
+					})
+
+					It("does not fail the errand when the run cannot be recorded", func() {
+						history.RecordReturns(errors.New("fake-record-error"))
+
+						result, err := action.Run(RunErrandOptions{Name: errandName})
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.ExitStatus).To(Equal(0))
+					})
 				})
 
 				Context("when errand script fails with non-0 exit code (execution of script is ok)", func() {
 					BeforeEach(func() {
 						cmdRunner.AddProcess(fullCommand, &fakesys.FakeProcess{
 							WaitResult: boshsys.Result{
-								Stdout:     "fake-stdout",
-								Stderr:     "fake-stderr",
 								ExitStatus: 123,
 								Error:      errors.New("fake-bosh-error"), // not used
 							},
@@ -134,18 +287,30 @@ var _ = Describe("RunErrand", func() {
 					})
 
 					It("returns errand result without an error", func() {
-						result, err := action.Run(errandName)
+						result, err := action.Run(RunErrandOptions{Name: errandName})
 						Expect(err).ToNot(HaveOccurred())
 						Expect(result).To(Equal(
 							ErrandResult{
 								Stdout:     "fake-stdout",
 								Stderr:     "fake-stderr",
 								ExitStatus: 123,
+								LogPath:    result.LogPath,
 							},
 						))
 					})
 				})
 
+				Context("when the errand log cannot be opened", func() {
+					It("returns an error without running the errand", func() {
+						fs.OpenFileErr = errors.New("fake-open-file-error")
+
+						_, err := action.Run(RunErrandOptions{Name: errandName})
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("fake-open-file-error"))
+						Expect(cmdRunner.RunComplexCommands).To(BeEmpty())
+					})
+				})
+
 				Context("when errand script fails to execute", func() {
 					BeforeEach(func() {
 						cmdRunner.AddProcess(fullCommand, &fakesys.FakeProcess{
@@ -157,11 +322,21 @@ var _ = Describe("RunErrand", func() {
 					})
 
 					It("returns error because script failed to execute", func() {
-						result, err := action.Run(errandName)
+						result, err := action.Run(RunErrandOptions{Name: errandName})
 						Expect(err).To(HaveOccurred())
 						Expect(err.Error()).To(ContainSubstring("fake-bosh-error"))
 						Expect(result).To(Equal(ErrandResult{}))
 					})
+
+					It("records the failed run in the history", func() {
+						_, err := action.Run(RunErrandOptions{Name: errandName})
+						Expect(err).To(HaveOccurred())
+
+						Expect(history.RecordCallCount()).To(Equal(1))
+						run := history.RecordArgsForCall(0)
+						Expect(run.ExitCode).To(Equal(-1))
+						Expect(run.Error).To(ContainSubstring("fake-bosh-error"))
+					})
 				})
 			})
 
@@ -171,13 +346,13 @@ var _ = Describe("RunErrand", func() {
 				})
 
 				It("returns error stating the errand cannot be found", func() {
-					_, err := action.Run(errandName)
+					_, err := action.Run(RunErrandOptions{Name: errandName})
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(Equal("Could not find errand fake-job-name"))
 				})
 
 				It("does not run errand script", func() {
-					_, err := action.Run(errandName)
+					_, err := action.Run(RunErrandOptions{Name: errandName})
 					Expect(err).To(HaveOccurred())
 					Expect(len(cmdRunner.RunComplexCommands)).To(Equal(0))
 				})
@@ -190,19 +365,33 @@ var _ = Describe("RunErrand", func() {
 			})
 
 			It("returns error stating that job template is required", func() {
-				_, err := action.Run(errandName)
+				_, err := action.Run(RunErrandOptions{Name: errandName})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-get-error"))
 			})
 
 			It("does not run errand script", func() {
-				_, err := action.Run(errandName)
+				_, err := action.Run(RunErrandOptions{Name: errandName})
 				Expect(err).To(HaveOccurred())
 				Expect(len(cmdRunner.RunComplexCommands)).To(Equal(0))
 			})
 		})
 	})
 
+	Describe("RunErrandOptions", func() {
+		It("accepts the name of the errand on its own like older directors send it", func() {
+			var options RunErrandOptions
+			Expect(json.Unmarshal([]byte(`"fake-job-name"`), &options)).To(Succeed())
+			Expect(options).To(Equal(RunErrandOptions{Name: "fake-job-name"}))
+		})
+
+		It("accepts options", func() {
+			var options RunErrandOptions
+			Expect(json.Unmarshal([]byte(`{"name": "fake-job-name", "upload_log": true}`), &options)).To(Succeed())
+			Expect(options).To(Equal(RunErrandOptions{Name: "fake-job-name", UploadLog: true}))
+		})
+	})
+
 	Describe("Cancel", func() {
 		BeforeEach(func() {
 			currentSpec := boshas.V1ApplySpec{
@@ -225,8 +414,6 @@ var _ = Describe("RunErrand", func() {
 				process := &fakesys.FakeProcess{
 					TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
 						p.WaitCh <- boshsys.Result{
-							Stdout:     "fake-stdout",
-							Stderr:     "fake-stderr",
 							ExitStatus: 0,
 						}
 					},
@@ -237,7 +424,7 @@ var _ = Describe("RunErrand", func() {
 				err := action.Cancel()
 				Expect(err).ToNot(HaveOccurred())
 
-				_, err = action.Run(errandName)
+				_, err = action.Run(RunErrandOptions{Name: errandName})
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(process.TerminateNicelyKillGracePeriod).To(Equal(10 * time.Second))
@@ -248,8 +435,6 @@ var _ = Describe("RunErrand", func() {
 					cmdRunner.AddProcess(fullCommand, &fakesys.FakeProcess{
 						TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
 							p.WaitCh <- boshsys.Result{
-								Stdout:     "fake-stdout",
-								Stderr:     "fake-stderr",
 								ExitStatus: 0,
 							}
 						},
@@ -260,13 +445,14 @@ var _ = Describe("RunErrand", func() {
 					err := action.Cancel()
 					Expect(err).ToNot(HaveOccurred())
 
-					result, err := action.Run(errandName)
+					result, err := action.Run(RunErrandOptions{Name: errandName})
 					Expect(err).ToNot(HaveOccurred())
 					Expect(result).To(Equal(
 						ErrandResult{
 							Stdout:     "fake-stdout",
 							Stderr:     "fake-stderr",
 							ExitStatus: 0,
+							LogPath:    result.LogPath,
 						},
 					))
 				})
@@ -277,8 +463,6 @@ var _ = Describe("RunErrand", func() {
 					cmdRunner.AddProcess(fullCommand, &fakesys.FakeProcess{
 						TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
 							p.WaitCh <- boshsys.Result{
-								Stdout:     "fake-stdout",
-								Stderr:     "fake-stderr",
 								ExitStatus: 123,
 								Error:      errors.New("fake-bosh-error"), // not used
 							}
@@ -290,13 +474,14 @@ var _ = Describe("RunErrand", func() {
 					err := action.Cancel()
 					Expect(err).ToNot(HaveOccurred())
 
-					result, err := action.Run(errandName)
+					result, err := action.Run(RunErrandOptions{Name: errandName})
 					Expect(err).ToNot(HaveOccurred())
 					Expect(result).To(Equal(
 						ErrandResult{
 							Stdout:     "fake-stdout",
 							Stderr:     "fake-stderr",
 							ExitStatus: 123,
+							LogPath:    result.LogPath,
 						},
 					))
 				})
@@ -318,7 +503,7 @@ var _ = Describe("RunErrand", func() {
 					err := action.Cancel()
 					Expect(err).ToNot(HaveOccurred())
 
-					result, err := action.Run(errandName)
+					result, err := action.Run(RunErrandOptions{Name: errandName})
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-bosh-error"))
 					Expect(result).To(Equal(ErrandResult{}))
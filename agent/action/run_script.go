@@ -2,20 +2,47 @@ package action
 
 import (
 	"errors"
+	"time"
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 type RunScriptOptions struct {
 	Env map[string]string `json:"env"`
+
+	// Seconds each job's script may run, zero when it may run forever
+	Timeout int `json:"timeout"`
+}
+
+// RunScriptActionResult is returned to directors that understand
+// per-job script results (protocol version > 3)
+type RunScriptActionResult struct {
+	Jobs map[string]RunScriptJobResult `json:"jobs"`
+}
+
+type RunScriptJobResult struct {
+	Status   string  `json:"status"`
+	ExitCode int     `json:"exit_code"`
+	Duration float64 `json:"duration"`
+	TimedOut bool    `json:"timed_out"`
+	Stdout   string  `json:"stdout"`
+	Stderr   string  `json:"stderr"`
+	Error    string  `json:"error,omitempty"`
 }
 
+const (
+	RunScriptStatusSucceeded = "succeeded"
+	RunScriptStatusFailed    = "failed"
+)
+
 type RunScriptAction struct {
-	scriptProvider boshscript.JobScriptProvider
-	specService    boshas.V1Service
+	scriptProvider  boshscript.JobScriptProvider
+	specService     boshas.V1Service
+	settingsService boshsettings.Service
 
 	logTag string
 	logger boshlog.Logger
@@ -24,11 +51,13 @@ type RunScriptAction struct {
 func NewRunScript(
 	scriptProvider boshscript.JobScriptProvider,
 	specService boshas.V1Service,
+	settingsService boshsettings.Service,
 	logger boshlog.Logger,
 ) RunScriptAction {
 	return RunScriptAction{
-		scriptProvider: scriptProvider,
-		specService:    specService,
+		scriptProvider:  scriptProvider,
+		specService:     specService,
+		settingsService: settingsService,
 
 		logTag: "RunScript Action",
 		logger: logger,
@@ -47,7 +76,10 @@ func (a RunScriptAction) IsLoggable() bool {
 	return true
 }
 
-func (a RunScriptAction) Run(scriptName string, options RunScriptOptions) (map[string]string, error) {
+// Run returns a RunScriptActionResult to directors that understand per-job
+// script results (protocol version > 3). Those learn about failed scripts
+// from the result of each job instead of an error.
+func (a RunScriptAction) Run(protocolVersion ProtocolVersion, scriptName string, options RunScriptOptions) (interface{}, error) {
 	// May be used in future to return more information
 	emptyResults := map[string]string{}
 
@@ -56,15 +88,45 @@ func (a RunScriptAction) Run(scriptName string, options RunScriptOptions) (map[s
 		return emptyResults, bosherr.WrapError(err, "Getting current spec")
 	}
 
+	timeout := time.Duration(options.Timeout) * time.Second
+
 	var scripts []boshscript.Script
 	for _, job := range currentSpec.Jobs() {
-		script := a.scriptProvider.NewScript(job.BundleName(), scriptName, options.Env)
+		script := a.scriptProvider.NewScript(job.BundleName(), scriptName, options.Env, timeout)
 		scripts = append(scripts, script)
 	}
 
-	parallelScript := a.scriptProvider.NewParallelScript(scriptName, scripts)
+	maxConcurrency := *a.settingsService.GetSettings().Env.GetParallel()
+
+	parallelScript := a.scriptProvider.NewLimitedParallelScript(scriptName, scripts, maxConcurrency)
+
+	results, err := parallelScript.RunWithResults()
+
+	if protocolVersion <= 3 {
+		return emptyResults, err
+	}
+
+	jobs := map[string]RunScriptJobResult{}
+
+	for job, result := range results {
+		jobResult := RunScriptJobResult{
+			Status:   RunScriptStatusSucceeded,
+			ExitCode: result.ExitStatus,
+			Duration: result.Duration.Seconds(),
+			TimedOut: result.TimedOut,
+			Stdout:   result.StdoutTail,
+			Stderr:   result.StderrTail,
+		}
+
+		if result.Error != nil {
+			jobResult.Status = RunScriptStatusFailed
+			jobResult.Error = result.Error.Error()
+		}
+
+		jobs[job] = jobResult
+	}
 
-	return emptyResults, parallelScript.Run()
+	return RunScriptActionResult{Jobs: jobs}, nil
 }
 
 func (a RunScriptAction) Resume() (interface{}, error) {
@@ -2,6 +2,7 @@ package action_test
 
 import (
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -11,6 +12,8 @@ import (
 	fakeapplyspec "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
 	"github.com/cloudfoundry/bosh-agent/agent/script/scriptfakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
@@ -18,6 +21,7 @@ var _ = Describe("RunScript", func() {
 	var (
 		fakeJobScriptProvider *scriptfakes.FakeJobScriptProvider
 		specService           *fakeapplyspec.FakeV1Service
+		settingsService       *fakesettings.FakeSettingsService
 		action                RunScriptAction
 		options               RunScriptOptions
 	)
@@ -26,8 +30,9 @@ var _ = Describe("RunScript", func() {
 		fakeJobScriptProvider = &scriptfakes.FakeJobScriptProvider{}
 		specService = fakeapplyspec.NewFakeV1Service()
 		specService.Spec.RenderedTemplatesArchiveSpec = &applyspec.RenderedTemplatesArchiveSpec{}
+		settingsService = &fakesettings.FakeSettingsService{}
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		action = NewRunScript(fakeJobScriptProvider, specService, logger)
+		action = NewRunScript(fakeJobScriptProvider, specService, settingsService, logger)
 		options = RunScriptOptions{
 			Env: map[string]string{
 				"FOO": "foo",
@@ -43,14 +48,14 @@ var _ = Describe("RunScript", func() {
 	AssertActionIsNotCancelable(action)
 
 	Describe("Run", func() {
-		act := func() (map[string]string, error) { return action.Run("run-me", options) }
+		act := func() (interface{}, error) { return action.Run(ProtocolVersion(3), "run-me", options) }
 
 		Context("when current spec can be retrieved", func() {
-			var parallelScript *scriptfakes.FakeCancellableScript
+			var parallelScript *scriptfakes.FakeReportingParallelScript
 
 			BeforeEach(func() {
-				parallelScript = &scriptfakes.FakeCancellableScript{}
-				fakeJobScriptProvider.NewParallelScriptReturns(parallelScript)
+				parallelScript = &scriptfakes.FakeReportingParallelScript{}
+				fakeJobScriptProvider.NewLimitedParallelScriptReturns(parallelScript)
 			})
 
 			createFakeJob := func(jobName string) {
@@ -67,7 +72,7 @@ var _ = Describe("RunScript", func() {
 				script2 := &scriptfakes.FakeScript{}
 				script2.TagReturns("fake-job-2")
 
-				fakeJobScriptProvider.NewScriptStub = func(jobName, scriptName string, scriptEnv map[string]string) boshscript.Script {
+				fakeJobScriptProvider.NewScriptStub = func(jobName, scriptName string, scriptEnv map[string]string, timeout time.Duration) boshscript.Script {
 					Expect(scriptName).To(Equal("run-me"))
 					Expect(scriptEnv["FOO"]).To(Equal("foo"))
 
@@ -80,27 +85,105 @@ var _ = Describe("RunScript", func() {
 					}
 				}
 
-				parallelScript.RunReturns(nil)
+				parallelScript.RunWithResultsReturns(nil, nil)
 
 				results, err := act()
 				Expect(err).ToNot(HaveOccurred())
 				Expect(results).To(Equal(map[string]string{}))
 
-				Expect(parallelScript.RunCallCount()).To(Equal(1))
+				Expect(parallelScript.RunWithResultsCallCount()).To(Equal(1))
 
-				scriptName, scripts := fakeJobScriptProvider.NewParallelScriptArgsForCall(0)
+				scriptName, scripts, _ := fakeJobScriptProvider.NewLimitedParallelScriptArgsForCall(0)
 				Expect(scriptName).To(Equal("run-me"))
 				Expect(scripts).To(Equal([]boshscript.Script{script1, script2}))
 			})
 
+			It("gives every script the timeout from the options", func() {
+				createFakeJob("fake-job-1")
+				options.Timeout = 90
+
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+
+				_, _, _, timeout := fakeJobScriptProvider.NewScriptArgsForCall(0)
+				Expect(timeout).To(Equal(90 * time.Second))
+			})
+
+			It("limits the number of scripts running at the same time to the parallel setting", func() {
+				parallel := 2
+				settingsService.Settings = boshsettings.Settings{
+					Env: boshsettings.Env{Bosh: boshsettings.BoshEnv{Parallel: &parallel}},
+				}
+
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+
+				_, _, maxConcurrency := fakeJobScriptProvider.NewLimitedParallelScriptArgsForCall(0)
+				Expect(maxConcurrency).To(Equal(2))
+			})
+
 			It("returns an error when parallel script fails", func() {
-				parallelScript.RunReturns(errors.New("fake-error"))
+				parallelScript.RunWithResultsReturns(nil, errors.New("fake-error"))
 
 				results, err := act()
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-error"))
 				Expect(results).To(Equal(map[string]string{}))
 			})
+
+			Context("when the director understands per-job script results", func() {
+				act := func() (interface{}, error) { return action.Run(ProtocolVersion(4), "run-me", options) }
+
+				It("returns the result of every job's script instead of an error", func() {
+					parallelScript.RunWithResultsReturns(map[string]boshscript.ScriptResult{
+						"fake-job-1": {
+							RunResult: boshscript.RunResult{
+								ExitStatus: 0,
+								Duration:   1500 * time.Millisecond,
+								StdoutTail: "fake-stdout",
+							},
+						},
+						"fake-job-2": {
+							RunResult: boshscript.RunResult{
+								ExitStatus: 143,
+								Duration:   90 * time.Second,
+								TimedOut:   true,
+								StderrTail: "fake-stderr",
+							},
+							Error: errors.New("Script timed out after 1m30s"),
+						},
+					}, errors.New("1 of 2 run-me scripts failed"))
+
+					results, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(results).To(Equal(RunScriptActionResult{
+						Jobs: map[string]RunScriptJobResult{
+							"fake-job-1": {
+								Status:   "succeeded",
+								ExitCode: 0,
+								Duration: 1.5,
+								Stdout:   "fake-stdout",
+							},
+							"fake-job-2": {
+								Status:   "failed",
+								ExitCode: 143,
+								Duration: 90,
+								TimedOut: true,
+								Stderr:   "fake-stderr",
+								Error:    "Script timed out after 1m30s",
+							},
+						},
+					}))
+				})
+
+				It("returns an error when current spec cannot be retrieved", func() {
+					specService.GetErr = errors.New("fake-spec-get-error")
+
+					_, err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-spec-get-error"))
+				})
+			})
 		})
 
 		Context("when current spec cannot be retrieved", func() {
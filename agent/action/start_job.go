@@ -0,0 +1,48 @@
+package action
+
+import (
+	"errors"
+
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type StartJobAction struct {
+	jobSupervisor boshjobsuper.JobSupervisor
+}
+
+func NewStartJob(jobSupervisor boshjobsuper.JobSupervisor) (startJob StartJobAction) {
+	startJob = StartJobAction{
+		jobSupervisor: jobSupervisor,
+	}
+	return
+}
+
+func (a StartJobAction) IsAsynchronous(_ ProtocolVersion) bool {
+	return false
+}
+
+func (a StartJobAction) IsPersistent() bool {
+	return false
+}
+
+func (a StartJobAction) IsLoggable() bool {
+	return true
+}
+
+func (a StartJobAction) Run(name string) (string, error) {
+	err := a.jobSupervisor.StartService(name)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Starting job '%s'", name)
+	}
+
+	return "started", nil
+}
+
+func (a StartJobAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a StartJobAction) Cancel() error {
+	return errors.New("not supported")
+}
@@ -30,19 +30,43 @@ func (a StopAction) IsLoggable() bool {
 	return true
 }
 
-func (a StopAction) Run(protocolVersion ProtocolVersion) (value string, err error) {
-	if protocolVersion > 2 {
-		err = a.jobSupervisor.StopAndWait()
-	} else {
+type StopActionResult struct {
+	State       string                            `json:"state"`
+	ForceKilled []boshjobsuper.ForceKilledProcess `json:"force_killed"`
+}
+
+// Run returns a StopActionResult listing force killed processes to
+// directors that understand it (protocol version > 3). Directors that
+// wait for services to stop (protocol version > 2) get "stopped".
+func (a StopAction) Run(protocolVersion ProtocolVersion) (value interface{}, err error) {
+	if protocolVersion <= 2 {
 		err = a.jobSupervisor.Stop()
+		if err != nil {
+			err = bosherr.WrapError(err, "Stopping Monitored Services")
+			return
+		}
+
+		value = "stopped"
+		return
 	}
 
+	result, err := a.jobSupervisor.StopAndWait()
 	if err != nil {
 		err = bosherr.WrapError(err, "Stopping Monitored Services")
 		return
 	}
 
-	value = "stopped"
+	if protocolVersion <= 3 {
+		value = "stopped"
+		return
+	}
+
+	forceKilled := result.ForceKilled
+	if forceKilled == nil {
+		forceKilled = []boshjobsuper.ForceKilledProcess{}
+	}
+
+	value = StopActionResult{State: "stopped", ForceKilled: forceKilled}
 	return
 }
 
@@ -0,0 +1,48 @@
+package action
+
+import (
+	"errors"
+
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type StopJobAction struct {
+	jobSupervisor boshjobsuper.JobSupervisor
+}
+
+func NewStopJob(jobSupervisor boshjobsuper.JobSupervisor) (stopJob StopJobAction) {
+	stopJob = StopJobAction{
+		jobSupervisor: jobSupervisor,
+	}
+	return
+}
+
+func (a StopJobAction) IsAsynchronous(_ ProtocolVersion) bool {
+	return true
+}
+
+func (a StopJobAction) IsPersistent() bool {
+	return false
+}
+
+func (a StopJobAction) IsLoggable() bool {
+	return true
+}
+
+func (a StopJobAction) Run(name string) (string, error) {
+	err := a.jobSupervisor.StopService(name)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Stopping job '%s'", name)
+	}
+
+	return "stopped", nil
+}
+
+func (a StopJobAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a StopJobAction) Cancel() error {
+	return errors.New("not supported")
+}
@@ -1,10 +1,13 @@
 package action_test
 
 import (
+	"errors"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 )
 
@@ -32,6 +35,50 @@ var _ = Describe("Stop", func() {
 		Expect(stopped).To(Equal("stopped"))
 	})
 
+	It("returns stopped when protocol version is 3", func() {
+		jobSupervisor.StopAndWaitResult = boshjobsuper.StopResult{
+			ForceKilled: []boshjobsuper.ForceKilledProcess{
+				{Job: "fake-job", Process: "fake-process", PID: 123, Signal: "SIGKILL"},
+			},
+		}
+
+		stopped, err := action.Run(ProtocolVersion(3))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stopped).To(Equal("stopped"))
+		Expect(jobSupervisor.StoppedAndWaited).To(BeTrue())
+	})
+
+	It("returns the force killed processes when protocol version is greater than 3", func() {
+		jobSupervisor.StopAndWaitResult = boshjobsuper.StopResult{
+			ForceKilled: []boshjobsuper.ForceKilledProcess{
+				{Job: "fake-job", Process: "fake-process", PID: 123, Signal: "SIGKILL"},
+			},
+		}
+
+		result, err := action.Run(ProtocolVersion(4))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(StopActionResult{
+			State: "stopped",
+			ForceKilled: []boshjobsuper.ForceKilledProcess{
+				{Job: "fake-job", Process: "fake-process", PID: 123, Signal: "SIGKILL"},
+			},
+		}))
+	})
+
+	It("returns an empty list when no process was force killed", func() {
+		result, err := action.Run(ProtocolVersion(4))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(StopActionResult{State: "stopped", ForceKilled: []boshjobsuper.ForceKilledProcess{}}))
+	})
+
+	It("returns an error when stopping fails", func() {
+		jobSupervisor.StopErr = errors.New("fake-stop-error")
+
+		_, err := action.Run(ProtocolVersion(3))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Stopping Monitored Services: fake-stop-error"))
+	})
+
 	It("stops job supervisor services", func() {
 		_, err := action.Run(ProtocolVersion(2))
 		Expect(err).ToNot(HaveOccurred())
@@ -4,6 +4,7 @@ import (
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	"github.com/cloudfoundry/bosh-agent/webhook"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
@@ -21,6 +22,7 @@ type concreteActionDispatcher struct {
 	taskManager   boshtask.Manager
 	actionFactory boshaction.Factory
 	actionRunner  boshaction.Runner
+	publisher     webhook.Publisher
 }
 
 func NewActionDispatcher(
@@ -29,6 +31,7 @@ func NewActionDispatcher(
 	taskManager boshtask.Manager,
 	actionFactory boshaction.Factory,
 	actionRunner boshaction.Runner,
+	publisher webhook.Publisher,
 ) (dispatcher ActionDispatcher) {
 	return concreteActionDispatcher{
 		logger:        logger,
@@ -36,6 +39,7 @@ func NewActionDispatcher(
 		taskManager:   taskManager,
 		actionFactory: actionFactory,
 		actionRunner:  actionRunner,
+		publisher:     publisher,
 	}
 }
 
@@ -103,7 +107,12 @@ func (dispatcher concreteActionDispatcher) dispatchAsynchronousAction(
 	var err error
 
 	runTask := func() (interface{}, error) {
-		return dispatcher.actionRunner.Run(action, req.GetPayload(), boshaction.ProtocolVersion(req.ProtocolVersion))
+		value, err := dispatcher.actionRunner.Run(action, req.GetPayload(), boshaction.ProtocolVersion(req.ProtocolVersion))
+		if err == nil {
+			dispatcher.publishActionEvent(req, value)
+		}
+
+		return value, err
 	}
 
 	cancelTask := func(_ boshtask.Task) error { return action.Cancel() }
@@ -141,6 +150,10 @@ func (dispatcher concreteActionDispatcher) dispatchAsynchronousAction(
 		}
 	}
 
+	if reporter, ok := action.(boshaction.ProgressReporter); ok {
+		task.ProgressFunc = reporter.Progress
+	}
+
 	dispatcher.taskService.StartTask(task)
 
 	return boshhandler.NewValueResponse(boshtask.StateValue{
@@ -162,9 +175,18 @@ func (dispatcher concreteActionDispatcher) dispatchSynchronousAction(
 		return boshhandler.NewExceptionResponse(err)
 	}
 
+	dispatcher.publishActionEvent(req, value)
+
 	return boshhandler.NewValueResponse(value)
 }
 
+func (dispatcher concreteActionDispatcher) publishActionEvent(req boshhandler.Request, value interface{}) {
+	eventType, event, found := webhook.NewActionEvent(req.Method, req.GetPayload(), value)
+	if found {
+		dispatcher.publisher.Publish(eventType, event)
+	}
+}
+
 func (dispatcher concreteActionDispatcher) removeInfo(task boshtask.Task) {
 	err := dispatcher.taskManager.RemoveInfo(task.ID)
 	if err != nil {
@@ -14,6 +14,8 @@ import (
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	"github.com/cloudfoundry/bosh-agent/webhook"
+	"github.com/cloudfoundry/bosh-agent/webhook/webhookfakes"
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
 	fakes "github.com/cloudfoundry/bosh-utils/logger/loggerfakes"
 )
@@ -26,6 +28,7 @@ func init() {
 			taskManager   *faketask.FakeManager
 			actionFactory *fakeaction.FakeFactory
 			actionRunner  *fakeaction.FakeRunner
+			publisher     *webhookfakes.FakePublisher
 			dispatcher    ActionDispatcher
 		)
 
@@ -35,7 +38,8 @@ func init() {
 			taskManager = faketask.NewFakeManager()
 			actionFactory = fakeaction.NewFakeFactory()
 			actionRunner = &fakeaction.FakeRunner{}
-			dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner)
+			publisher = &webhookfakes.FakePublisher{}
+			dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner, publisher)
 		})
 
 		It("responds with exception when the method is unknown", func() {
@@ -179,6 +183,40 @@ func init() {
 				expectedJSON := fmt.Sprintf("{\"exception\":{\"message\":\"Action Failed %s: fake-run-error\"}}", req.Method)
 				boshassert.MatchesJSONString(GinkgoT(), resp, expectedJSON)
 			})
+
+			It("does not publish an event for actions without one", func() {
+				dispatcher.Dispatch(req)
+				Expect(publisher.PublishCallCount()).To(Equal(0))
+			})
+
+			Context("when the action has an event", func() {
+				BeforeEach(func() {
+					req = boshhandler.NewRequest("fake-reply", "update_settings", []byte(`{"arguments":[{"trusted_certs":"fake-certs"}]}`), 0)
+					actionFactory.RegisterAction("update_settings", &fakeaction.TestAction{Asynchronous: false})
+				})
+
+				It("publishes the event without the settings after the action succeeds", func() {
+					actionRunner.RunValue = "updated"
+
+					dispatcher.Dispatch(req)
+
+					Expect(publisher.PublishCallCount()).To(Equal(1))
+					eventType, payload := publisher.PublishArgsForCall(0)
+					Expect(eventType).To(Equal(webhook.SettingsUpdatedEvent))
+					Expect(payload).To(Equal(webhook.ActionEvent{
+						Action: "update_settings",
+						Value:  "updated",
+					}))
+				})
+
+				It("does not publish the event when the action fails", func() {
+					actionRunner.RunErr = errors.New("fake-run-error")
+
+					dispatcher.Dispatch(req)
+
+					Expect(publisher.PublishCallCount()).To(Equal(0))
+				})
+			})
 		})
 
 		Context("when action is asynchronous", func() {
@@ -250,6 +288,27 @@ func init() {
 					Expect(string(actionRunner.RunPayload)).To(Equal("fake-payload"))
 				})
 
+				It("publishes the event of the action once the task succeeds", func() {
+					req = boshhandler.NewRequest("fake-reply", "mount_disk", []byte(`{"arguments":["fake-disk-cid"]}`), 0)
+					actionFactory.RegisterAction("mount_disk", action)
+					actionRunner.RunValue = map[string]string{}
+
+					dispatcher.Dispatch(req)
+					Expect(publisher.PublishCallCount()).To(Equal(0))
+
+					_, err := taskService.StartedTasks["fake-generated-task-id"].Func()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(publisher.PublishCallCount()).To(Equal(1))
+					eventType, payload := publisher.PublishArgsForCall(0)
+					Expect(eventType).To(Equal(webhook.DiskMountedEvent))
+					Expect(payload).To(Equal(webhook.ActionEvent{
+						Action:    "mount_disk",
+						Arguments: json.RawMessage(`["fake-disk-cid"]`),
+						Value:     map[string]string{},
+					}))
+				})
+
 				It("returns run error to the task", func() {
 					actionRunner.RunErr = errors.New("fake-run-error")
 					dispatcher.Dispatch(req)
@@ -275,6 +334,20 @@ func init() {
 					dispatcher.Dispatch(req)
 					Expect(taskService.StartedTasks["fake-generated-task-id"].EndFunc).To(BeNil())
 				})
+
+				It("does not report progress of actions that do not report any", func() {
+					dispatcher.Dispatch(req)
+					Expect(taskService.StartedTasks["fake-generated-task-id"].Progress()).To(BeNil())
+				})
+
+				It("reports the progress of the action while the task runs", func() {
+					progressAction := &fakeaction.TestProgressAction{TestAction: fakeaction.TestAction{Asynchronous: true}}
+					actionFactory.RegisterAction("fake-progress-action", progressAction)
+					dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "fake-progress-action", []byte("fake-payload"), 0))
+
+					progressAction.ProgressValue = "fake-progress"
+					Expect(taskService.StartedTasks["fake-generated-task-id"].Progress()).To(Equal("fake-progress"))
+				})
 			})
 
 			Context("when action is persistent", func() {
@@ -1,12 +1,15 @@
 package agent
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/clock"
 
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	"github.com/cloudfoundry/bosh-agent/agent/diagnostics"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
@@ -23,7 +26,8 @@ const (
 )
 
 var (
-	HeartbeatRetryInterval = 1 * time.Second
+	HeartbeatRetryInterval  = 1 * time.Second
+	AlertCollectionInterval = 30 * time.Second
 )
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . StartManager
@@ -45,6 +49,9 @@ type Agent struct {
 	uuidGenerator     boshuuid.Generator
 	timeService       clock.Clock
 	startManager      StartManager
+	alertCollector    boshalert.Collector
+
+	diagnosticsCapturer diagnostics.Capturer
 }
 
 func New(
@@ -59,6 +66,8 @@ func New(
 	uuidGenerator boshuuid.Generator,
 	timeService clock.Clock,
 	startManager StartManager,
+	alertCollector boshalert.Collector,
+	diagnosticsCapturer diagnostics.Capturer,
 ) Agent {
 	return Agent{
 		logger:            logger,
@@ -72,6 +81,9 @@ func New(
 		uuidGenerator:     uuidGenerator,
 		timeService:       timeService,
 		startManager:      startManager,
+		alertCollector:    alertCollector,
+
+		diagnosticsCapturer: diagnosticsCapturer,
 	}
 }
 
@@ -91,6 +103,8 @@ func (a Agent) Run() error {
 
 	go a.generateHeartbeats(errCh)
 
+	go a.collectAlerts(errCh)
+
 	go func() {
 		err := a.jobSupervisor.MonitorJobFailures(a.handleJobFailure(errCh))
 		if err != nil {
@@ -129,6 +143,26 @@ func (a Agent) generateHeartbeats(errCh chan error) {
 	}
 }
 
+func (a Agent) collectAlerts(errCh chan error) {
+	defer a.logger.HandlePanic("Agent Collect Alerts")
+
+	tickChan := time.Tick(AlertCollectionInterval)
+
+	for range tickChan {
+		alerts, err := a.alertCollector.Collect()
+		if err != nil {
+			a.logger.Error(agentLogTag, "Failed collecting alerts: %s", err.Error())
+		}
+
+		for _, alert := range alerts {
+			err = a.sendAlert(alert)
+			if err != nil {
+				errCh <- bosherr.WrapError(err, "Sending collected alert")
+			}
+		}
+	}
+}
+
 func (a Agent) sendAndRecordHeartbeat(errCh chan error, retry bool) {
 	status := a.jobSupervisor.Status()
 	heartbeat, err := a.getHeartbeat(status)
@@ -211,7 +245,11 @@ func (a Agent) handleJobFailure(errCh chan error) boshjobsuper.JobFailureHandler
 			errCh <- bosherr.WrapError(err, "Adapting monit alert")
 		}
 
-		err = a.mbusHandler.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+		if snapshotPath, captured := a.captureDiagnostics(monitAlert); captured {
+			alert.Summary = fmt.Sprintf("%s (diagnostics: %s)", alert.Summary, snapshotPath)
+		}
+
+		err = a.sendAlert(alert)
 		if err != nil {
 			errCh <- bosherr.WrapError(err, "Sending monit alert")
 		}
@@ -219,3 +257,37 @@ func (a Agent) handleJobFailure(errCh chan error) boshjobsuper.JobFailureHandler
 		return nil
 	}
 }
+
+func (a Agent) sendAlert(alert boshalert.Alert) error {
+	if alert.ID == "" {
+		id, err := a.uuidGenerator.Generate()
+		if err != nil {
+			return bosherr.WrapError(err, "Generating alert id")
+		}
+		alert.ID = id
+	}
+
+	return a.mbusHandler.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+}
+
+// captureDiagnostics snapshots the state of a service whose process is no
+// longer running so that the evidence survives log rotation. Failing to
+// capture never prevents the alert from being sent.
+func (a Agent) captureDiagnostics(monitAlert boshalert.MonitAlert) (string, bool) {
+	options := a.settingsService.GetSettings().Env.Bosh.Diagnostics
+	if !options.Enabled || a.diagnosticsCapturer == nil {
+		return "", false
+	}
+
+	if !strings.EqualFold(monitAlert.Event, "does not exist") {
+		return "", false
+	}
+
+	snapshotPath, err := a.diagnosticsCapturer.Capture(monitAlert.Service, options)
+	if err != nil {
+		a.logger.Error(agentLogTag, "Failed to capture diagnostics for %s: %s", monitAlert.Service, err.Error())
+		return "", false
+	}
+
+	return snapshotPath, true
+}
@@ -12,8 +12,10 @@ import (
 	"code.cloudfoundry.org/clock/fakeclock"
 	"github.com/cloudfoundry/bosh-agent/agent/agentfakes"
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/agent/alert/alertfakes"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	"github.com/cloudfoundry/bosh-agent/agent/diagnostics/diagnosticsfakes"
 	fakeagent "github.com/cloudfoundry/bosh-agent/agent/fakes"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
@@ -21,6 +23,7 @@ import (
 	"github.com/cloudfoundry/bosh-agent/platform/platformfakes"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	"github.com/cloudfoundry/bosh-agent/platform/vitals/vitalsfakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
@@ -40,12 +43,16 @@ func init() {
 			timeService      *fakeclock.FakeClock
 			vitalService     *vitalsfakes.FakeService
 			startManager     *agentfakes.FakeStartManager
+			alertCollector   *alertfakes.FakeCollector
+
+			diagnosticsCapturer *diagnosticsfakes.FakeCapturer
 
 			agent Agent
 		)
 
 		BeforeSuite(func() {
 			HeartbeatRetryInterval = 1 * time.Millisecond
+			AlertCollectionInterval = 1 * time.Millisecond
 		})
 
 		BeforeEach(func() {
@@ -61,6 +68,8 @@ func init() {
 			vitalService = &vitalsfakes.FakeService{}
 			startManager = &agentfakes.FakeStartManager{}
 			startManager.CanStartReturns(true)
+			alertCollector = &alertfakes.FakeCollector{}
+			diagnosticsCapturer = &diagnosticsfakes.FakeCapturer{}
 
 			platform.GetVitalsServiceReturns(vitalService)
 
@@ -76,6 +85,8 @@ func init() {
 				uuidGenerator,
 				timeService,
 				startManager,
+				alertCollector,
+				diagnosticsCapturer,
 			)
 
 		})
@@ -163,6 +174,8 @@ func init() {
 						uuidGenerator,
 						timeService,
 						startManager,
+						alertCollector,
+						diagnosticsCapturer,
 					)
 
 					// Immediately exit after sending initial heartbeat
@@ -290,6 +303,108 @@ func init() {
 					Message: expectedAlert,
 				}))
 			})
+
+			Context("when diagnostics are enabled", func() {
+				var monitAlert boshalert.MonitAlert
+
+				BeforeEach(func() {
+					handler.KeepOnRunning()
+
+					settingsService.Settings.Env.Bosh.Diagnostics = boshsettings.Diagnostics{Enabled: true, LogLines: 50}
+
+					monitAlert = boshalert.MonitAlert{
+						ID:          "fake-monit-alert",
+						Service:     "fake-service",
+						Event:       "does not exist",
+						Action:      "restart",
+						Date:        "Sun, 22 May 2011 20:07:41 +0500",
+						Description: "process is not running",
+					}
+					jobSupervisor.JobFailureAlert = &monitAlert
+
+					handler.SendCallback = func(input fakembus.SendInput) {
+						if input.Topic == boshhandler.Alert {
+							handler.SendErr = errors.New("stop")
+						}
+					}
+				})
+
+				alertSummaries := func() []string {
+					var summaries []string
+					for _, input := range handler.SendInputs() {
+						if input.Topic == boshhandler.Alert {
+							summaries = append(summaries, input.Message.(boshalert.Alert).Summary)
+						}
+					}
+					return summaries
+				}
+
+				It("captures a snapshot of the failed service and references it in the alert", func() {
+					diagnosticsCapturer.CaptureReturns("/fake-snapshot.json", nil)
+
+					err := agent.Run()
+					Expect(err).To(HaveOccurred())
+
+					Expect(diagnosticsCapturer.CaptureCallCount()).To(Equal(1))
+					service, options := diagnosticsCapturer.CaptureArgsForCall(0)
+					Expect(service).To(Equal("fake-service"))
+					Expect(options).To(Equal(boshsettings.Diagnostics{Enabled: true, LogLines: 50}))
+
+					Expect(alertSummaries()).To(Equal([]string{"process is not running (diagnostics: /fake-snapshot.json)"}))
+				})
+
+				It("still sends the alert when the capture fails", func() {
+					diagnosticsCapturer.CaptureReturns("", errors.New("fake-capture-err"))
+
+					err := agent.Run()
+					Expect(err).To(HaveOccurred())
+
+					Expect(alertSummaries()).To(Equal([]string{"process is not running"}))
+				})
+
+				It("does not capture a snapshot for other events", func() {
+					monitAlert.Event = "execution failed"
+
+					err := agent.Run()
+					Expect(err).To(HaveOccurred())
+
+					Expect(diagnosticsCapturer.CaptureCallCount()).To(Equal(0))
+				})
+			})
+
+			It("sends collected alerts to health manager", func() {
+				handler.KeepOnRunning()
+
+				uuidGenerator.GeneratedUUID = "fake-uuid"
+				alertCollector.CollectReturns([]boshalert.Alert{{
+					Severity:  boshalert.SeverityCritical,
+					Title:     "system - out of memory - process killed",
+					Summary:   "Kernel OOM killer terminated 1 process(es)",
+					CreatedAt: int64(1306076861),
+				}}, nil)
+
+				handler.SendCallback = func(input fakembus.SendInput) {
+					if input.Topic == boshhandler.Alert {
+						handler.SendErr = errors.New("stop")
+					}
+				}
+
+				err := agent.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("stop"))
+
+				Expect(handler.SendInputs()).To(ContainElement(fakembus.SendInput{
+					Target: boshhandler.HealthMonitor,
+					Topic:  boshhandler.Alert,
+					Message: boshalert.Alert{
+						ID:        "fake-uuid",
+						Severity:  boshalert.SeverityCritical,
+						Title:     "system - out of memory - process killed",
+						Summary:   "Kernel OOM killer terminated 1 process(es)",
+						CreatedAt: int64(1306076861),
+					},
+				}))
+			})
 		})
 	})
 }
@@ -1,5 +1,13 @@
 package alert
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+)
+
 type SeverityLevel int
 
 const (
@@ -11,6 +19,11 @@ const (
 	SeverityDefault  SeverityLevel = SeverityCritical
 )
 
+// Valid reports whether the severity is one that can be sent to the director
+func (s SeverityLevel) Valid() bool {
+	return s >= SeverityAlert && s <= SeverityWarning
+}
+
 type Alert struct {
 	ID        string        `json:"id"`
 	Severity  SeverityLevel `json:"severity"`
@@ -23,3 +36,18 @@ type Adapter interface {
 	Alert() (Alert, error)
 	IsIgnorable() bool
 }
+
+// formatTitle builds the 'service (ips) - event - action' title that the
+// health monitor expects for every alert regardless of its source.
+func formatTitle(settingsService boshsettings.Service, service, event, action string) string {
+	settings := settingsService.GetSettings()
+
+	ips := settings.Networks.IPs()
+	sort.Strings(ips)
+
+	if len(ips) > 0 {
+		service = fmt.Sprintf("%s (%s)", service, strings.Join(ips, ", "))
+	}
+
+	return fmt.Sprintf("%s - %s - %s", service, event, action)
+}
@@ -0,0 +1,107 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package alertfakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/bosh-agent/agent/alert"
+)
+
+type FakeCollector struct {
+	CollectStub        func() ([]alert.Alert, error)
+	collectMutex       sync.RWMutex
+	collectArgsForCall []struct {
+	}
+	collectReturns struct {
+		result1 []alert.Alert
+		result2 error
+	}
+	collectReturnsOnCall map[int]struct {
+		result1 []alert.Alert
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeCollector) Collect() ([]alert.Alert, error) {
+	fake.collectMutex.Lock()
+	ret, specificReturn := fake.collectReturnsOnCall[len(fake.collectArgsForCall)]
+	fake.collectArgsForCall = append(fake.collectArgsForCall, struct {
+	}{})
+	stub := fake.CollectStub
+	fakeReturns := fake.collectReturns
+	fake.recordInvocation("Collect", []interface{}{})
+	fake.collectMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeCollector) CollectCallCount() int {
+	fake.collectMutex.RLock()
+	defer fake.collectMutex.RUnlock()
+	return len(fake.collectArgsForCall)
+}
+
+func (fake *FakeCollector) CollectCalls(stub func() ([]alert.Alert, error)) {
+	fake.collectMutex.Lock()
+	defer fake.collectMutex.Unlock()
+	fake.CollectStub = stub
+}
+
+func (fake *FakeCollector) CollectReturns(result1 []alert.Alert, result2 error) {
+	fake.collectMutex.Lock()
+	defer fake.collectMutex.Unlock()
+	fake.CollectStub = nil
+	fake.collectReturns = struct {
+		result1 []alert.Alert
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCollector) CollectReturnsOnCall(i int, result1 []alert.Alert, result2 error) {
+	fake.collectMutex.Lock()
+	defer fake.collectMutex.Unlock()
+	fake.CollectStub = nil
+	if fake.collectReturnsOnCall == nil {
+		fake.collectReturnsOnCall = make(map[int]struct {
+			result1 []alert.Alert
+			result2 error
+		})
+	}
+	fake.collectReturnsOnCall[i] = struct {
+		result1 []alert.Alert
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCollector) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.collectMutex.RLock()
+	defer fake.collectMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeCollector) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ alert.Collector = new(FakeCollector)
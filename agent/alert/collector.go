@@ -0,0 +1,43 @@
+package alert
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . Collector
+
+// Collector produces alerts for conditions that the job supervisor does not
+// report, e.g. kernel or filesystem problems. Collect is called periodically
+// and should only return alerts for conditions that appeared since the
+// previous call. Alert IDs are assigned by the caller.
+type Collector interface {
+	Collect() ([]Alert, error)
+}
+
+type multiCollector struct {
+	collectors []Collector
+}
+
+func NewMultiCollector(collectors ...Collector) Collector {
+	return multiCollector{collectors: collectors}
+}
+
+func (c multiCollector) Collect() ([]Alert, error) {
+	var alerts []Alert
+	var errs []error
+
+	for _, collector := range c.collectors {
+		collected, err := collector.Collect()
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		alerts = append(alerts, collected...)
+	}
+
+	if len(errs) > 0 {
+		return alerts, bosherr.WrapError(bosherr.NewMultiError(errs...), "Collecting alerts")
+	}
+
+	return alerts, nil
+}
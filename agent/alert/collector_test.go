@@ -0,0 +1,44 @@
+package alert_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/agent/alert/alertfakes"
+)
+
+var _ = Describe("multiCollector", func() {
+	var (
+		collector1 *alertfakes.FakeCollector
+		collector2 *alertfakes.FakeCollector
+		collector  Collector
+	)
+
+	BeforeEach(func() {
+		collector1 = &alertfakes.FakeCollector{}
+		collector2 = &alertfakes.FakeCollector{}
+		collector = NewMultiCollector(collector1, collector2)
+	})
+
+	It("returns alerts from every collector", func() {
+		collector1.CollectReturns([]Alert{{Title: "fake-alert-1"}}, nil)
+		collector2.CollectReturns([]Alert{{Title: "fake-alert-2"}}, nil)
+
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(Equal([]Alert{{Title: "fake-alert-1"}, {Title: "fake-alert-2"}}))
+	})
+
+	It("keeps collecting when a collector fails and returns its error", func() {
+		collector1.CollectReturns(nil, errors.New("fake-collect-err"))
+		collector2.CollectReturns([]Alert{{Title: "fake-alert-2"}}, nil)
+
+		alerts, err := collector.Collect()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-collect-err"))
+		Expect(alerts).To(Equal([]Alert{{Title: "fake-alert-2"}}))
+	})
+})
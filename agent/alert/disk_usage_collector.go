@@ -0,0 +1,112 @@
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"code.cloudfoundry.org/clock"
+
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type DiskThresholds struct {
+	// Alert when disk space usage reaches this percentage
+	Percent int
+
+	// Alert when inode usage reaches this percentage
+	InodePercent int
+}
+
+var DefaultDiskThresholds = DiskThresholds{Percent: 95, InodePercent: 95}
+
+type diskUsageCollector struct {
+	vitalsService   boshvitals.Service
+	thresholds      DiskThresholds
+	settingsService boshsettings.Service
+	timeService     clock.Clock
+
+	exceeded map[string]bool
+}
+
+// NewDiskUsageCollector reports disks from Vitals.Disk whose space or inode
+// usage crossed the given thresholds. An alert is raised once per crossing;
+// usage has to drop below the threshold before it is reported again.
+func NewDiskUsageCollector(
+	vitalsService boshvitals.Service,
+	thresholds DiskThresholds,
+	settingsService boshsettings.Service,
+	timeService clock.Clock,
+) Collector {
+	return &diskUsageCollector{
+		vitalsService:   vitalsService,
+		thresholds:      thresholds,
+		settingsService: settingsService,
+		timeService:     timeService,
+		exceeded:        map[string]bool{},
+	}
+}
+
+func (c *diskUsageCollector) Collect() ([]Alert, error) {
+	vitals, err := c.vitalsService.Get()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting vitals")
+	}
+
+	names := make([]string, 0, len(vitals.Disk))
+	for name := range vitals.Disk {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var alerts []Alert
+
+	for _, name := range names {
+		disk := vitals.Disk[name]
+
+		alert, found := c.check(name, "disk usage", disk.Percent, c.thresholds.Percent)
+		if found {
+			alerts = append(alerts, alert)
+		}
+
+		alert, found = c.check(name, "inode usage", disk.InodePercent, c.thresholds.InodePercent)
+		if found {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return alerts, nil
+}
+
+func (c *diskUsageCollector) check(name, kind, value string, threshold int) (Alert, bool) {
+	key := name + " " + kind
+
+	if threshold <= 0 || value == "" {
+		return Alert{}, false
+	}
+
+	percent, err := strconv.Atoi(value)
+	if err != nil {
+		return Alert{}, false
+	}
+
+	if percent < threshold {
+		delete(c.exceeded, key)
+		return Alert{}, false
+	}
+
+	if c.exceeded[key] {
+		return Alert{}, false
+	}
+
+	c.exceeded[key] = true
+
+	return Alert{
+		Severity:  SeverityCritical,
+		Title:     formatTitle(c.settingsService, fmt.Sprintf("%s disk", name), fmt.Sprintf("%s above %d%%", kind, threshold), "alert"),
+		Summary:   fmt.Sprintf("%s disk %s is at %d%%", name, kind, percent),
+		CreatedAt: c.timeService.Now().Unix(),
+	}, true
+}
@@ -0,0 +1,89 @@
+package alert_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/cloudfoundry/bosh-agent/agent/alert"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	"github.com/cloudfoundry/bosh-agent/platform/vitals/vitalsfakes"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+)
+
+var _ = Describe("diskUsageCollector", func() {
+	var (
+		vitalsService   *vitalsfakes.FakeService
+		settingsService *fakesettings.FakeSettingsService
+		timeService     *fakeclock.FakeClock
+		collector       Collector
+	)
+
+	returnDiskVitals := func(disks boshvitals.DiskVitals) {
+		vitalsService.GetReturns(boshvitals.Vitals{Disk: disks}, nil)
+	}
+
+	BeforeEach(func() {
+		vitalsService = &vitalsfakes.FakeService{}
+		settingsService = &fakesettings.FakeSettingsService{}
+		timeService = fakeclock.NewFakeClock(time.Now())
+		collector = NewDiskUsageCollector(vitalsService, DiskThresholds{Percent: 95, InodePercent: 90}, settingsService, timeService)
+	})
+
+	It("reports disks whose usage reaches the thresholds", func() {
+		returnDiskVitals(boshvitals.DiskVitals{
+			"system":     {Percent: "40", InodePercent: "10"},
+			"ephemeral":  {Percent: "96", InodePercent: "10"},
+			"persistent": {Percent: "50", InodePercent: "90"},
+		})
+
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(Equal([]Alert{
+			{
+				Severity:  SeverityCritical,
+				Title:     "ephemeral disk - disk usage above 95% - alert",
+				Summary:   "ephemeral disk disk usage is at 96%",
+				CreatedAt: timeService.Now().Unix(),
+			},
+			{
+				Severity:  SeverityCritical,
+				Title:     "persistent disk - inode usage above 90% - alert",
+				Summary:   "persistent disk inode usage is at 90%",
+				CreatedAt: timeService.Now().Unix(),
+			},
+		}))
+	})
+
+	It("reports a disk again only after usage dropped below the threshold", func() {
+		returnDiskVitals(boshvitals.DiskVitals{"ephemeral": {Percent: "96"}})
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(HaveLen(1))
+
+		alerts, err = collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+
+		returnDiskVitals(boshvitals.DiskVitals{"ephemeral": {Percent: "80"}})
+		alerts, err = collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+
+		returnDiskVitals(boshvitals.DiskVitals{"ephemeral": {Percent: "97"}})
+		alerts, err = collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(HaveLen(1))
+	})
+
+	It("returns an error when getting vitals fails", func() {
+		vitalsService.GetReturns(boshvitals.Vitals{}, errors.New("fake-vitals-err"))
+
+		_, err := collector.Collect()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-vitals-err"))
+	})
+})
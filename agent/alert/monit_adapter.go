@@ -1,8 +1,6 @@
 package alert
 
 import (
-	"fmt"
-	"sort"
 	"strings"
 	"time"
 
@@ -47,18 +45,11 @@ func (m *monitAdapter) Alert() (Alert, error) {
 }
 
 func (m *monitAdapter) title() string {
-	settings := m.settingsService.GetSettings()
-
-	ips := settings.Networks.IPs()
-	sort.Strings(ips)
-
-	service := m.monitAlert.Service
-
-	if len(ips) > 0 {
-		service = fmt.Sprintf("%s (%s)", service, strings.Join(ips, ", "))
+	if m.monitAlert.Title != "" {
+		return m.monitAlert.Title
 	}
 
-	return fmt.Sprintf("%s - %s - %s", service, m.monitAlert.Event, m.monitAlert.Action)
+	return formatTitle(m.settingsService, m.monitAlert.Service, m.monitAlert.Event, m.monitAlert.Action)
 }
 
 func (m *monitAdapter) createdAt() int64 {
@@ -71,6 +62,10 @@ func (m *monitAdapter) createdAt() int64 {
 }
 
 func (m *monitAdapter) Severity() (severity SeverityLevel, found bool) {
+	if m.monitAlert.Severity.Valid() {
+		return m.monitAlert.Severity, true
+	}
+
 	severity, found = eventToSeverity[strings.ToLower(m.monitAlert.Event)]
 	if !found {
 		severity = SeverityDefault
@@ -108,6 +103,8 @@ var eventToSeverity = map[string]SeverityLevel{
 	"gid succeeded":                SeverityIgnored,
 	"gid changed":                  SeverityWarning,
 	"gid not changed":              SeverityIgnored,
+	"health check failed":          SeverityError,
+	"health check succeeded":       SeverityIgnored,
 	"heartbeat failed":             SeverityError,
 	"heartbeat succeeded":          SeverityIgnored,
 	"heartbeat changed":            SeverityWarning,
@@ -124,6 +124,42 @@ var _ = Describe("monitAdapter", func() {
 			Expect(builtAlert.CreatedAt).To(Equal(timeService.Now().Unix()))
 		})
 
+		It("uses the severity and title of alerts submitted in the generic shape", func() {
+			monitAlert := MonitAlert{
+				ID:          "fake-id",
+				Date:        "Sun, 22 May 2011 20:07:41 +0500",
+				Description: "fake-summary",
+				Severity:    SeverityWarning,
+				Title:       "fake-title",
+			}
+			settingsService.Settings.Networks = boshsettings.Networks{
+				"fake-net1": boshsettings.Network{IP: "192.168.0.1"},
+			}
+
+			monitAdapter := NewMonitAdapter(monitAlert, settingsService, timeService)
+			Expect(monitAdapter.IsIgnorable()).To(BeFalse())
+
+			builtAlert, err := monitAdapter.Alert()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(builtAlert).To(Equal(Alert{
+				ID:        "fake-id",
+				Severity:  SeverityWarning,
+				Title:     "fake-title",
+				Summary:   "fake-summary",
+				CreatedAt: int64(1306076861),
+			}))
+		})
+
+		It("derives the severity from the event when the submitted severity is out of range", func() {
+			monitAlert := buildMonitAlert()
+			monitAlert.Severity = SeverityLevel(7)
+
+			monitAdapter := NewMonitAdapter(monitAlert, settingsService, timeService)
+			builtAlert, err := monitAdapter.Alert()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(builtAlert.Severity).To(Equal(SeverityAlert))
+		})
+
 		It("sets the title with ips", func() {
 			monitAlert := buildMonitAlert()
 			settingsService.Settings.Networks = boshsettings.Networks{
@@ -7,4 +7,10 @@ type MonitAlert struct {
 	Action      string
 	Date        string // RFC1123Z formatted date string
 	Description string
+
+	// Severity and Title are only set for alerts that were submitted in the
+	// generic Alert shape (e.g. through the HTTP alert intake). When present
+	// they take precedence over the event based severity and title.
+	Severity SeverityLevel
+	Title    string
 }
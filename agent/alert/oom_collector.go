@@ -0,0 +1,94 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/clock"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const vmstatPath = "/proc/vmstat"
+
+type oomCollector struct {
+	fs              boshsys.FileSystem
+	settingsService boshsettings.Service
+	timeService     clock.Clock
+
+	initialized bool
+	lastCount   uint64
+}
+
+// NewOOMCollector reports kernel OOM kills by watching the oom_kill counter
+// in /proc/vmstat. The first collection only records the current count so
+// kills that happened before the agent started are not reported.
+func NewOOMCollector(fs boshsys.FileSystem, settingsService boshsettings.Service, timeService clock.Clock) Collector {
+	return &oomCollector{
+		fs:              fs,
+		settingsService: settingsService,
+		timeService:     timeService,
+	}
+}
+
+func (c *oomCollector) Collect() ([]Alert, error) {
+	if !c.fs.FileExists(vmstatPath) {
+		return nil, nil
+	}
+
+	contents, err := c.fs.ReadFileString(vmstatPath)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading %s", vmstatPath)
+	}
+
+	count, found, err := parseOOMKillCount(contents)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing %s", vmstatPath)
+	}
+
+	// Kernels older than 4.13 do not expose oom_kill
+	if !found {
+		return nil, nil
+	}
+
+	if !c.initialized || count < c.lastCount {
+		c.initialized = true
+		c.lastCount = count
+		return nil, nil
+	}
+
+	kills := count - c.lastCount
+	c.lastCount = count
+
+	if kills == 0 {
+		return nil, nil
+	}
+
+	return []Alert{{
+		Severity:  SeverityCritical,
+		Title:     formatTitle(c.settingsService, "system", "out of memory", "process killed"),
+		Summary:   fmt.Sprintf("Kernel OOM killer terminated %d process(es)", kills),
+		CreatedAt: c.timeService.Now().Unix(),
+	}}, nil
+}
+
+func parseOOMKillCount(vmstat string) (uint64, bool, error) {
+	for _, line := range strings.Split(vmstat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "oom_kill" {
+			continue
+		}
+
+		count, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, false, err
+		}
+
+		return count, true, nil
+	}
+
+	return 0, false, nil
+}
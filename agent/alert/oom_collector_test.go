@@ -0,0 +1,85 @@
+package alert_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/cloudfoundry/bosh-agent/agent/alert"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("oomCollector", func() {
+	var (
+		fs              *fakesys.FakeFileSystem
+		settingsService *fakesettings.FakeSettingsService
+		timeService     *fakeclock.FakeClock
+		collector       Collector
+	)
+
+	writeVmstat := func(oomKills string) {
+		err := fs.WriteFileString("/proc/vmstat", "nr_free_pages 12345\noom_kill "+oomKills+"\npgfault 9\n")
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		settingsService = &fakesettings.FakeSettingsService{}
+		timeService = fakeclock.NewFakeClock(time.Now())
+		collector = NewOOMCollector(fs, settingsService, timeService)
+	})
+
+	It("does not report kills that happened before the first collection", func() {
+		writeVmstat("3")
+
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("reports new kills since the previous collection", func() {
+		writeVmstat("3")
+		_, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+
+		writeVmstat("5")
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(Equal([]Alert{{
+			Severity:  SeverityCritical,
+			Title:     "system - out of memory - process killed",
+			Summary:   "Kernel OOM killer terminated 2 process(es)",
+			CreatedAt: timeService.Now().Unix(),
+		}}))
+
+		alerts, err = collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("does nothing when the kernel does not expose oom_kill", func() {
+		err := fs.WriteFileString("/proc/vmstat", "nr_free_pages 12345\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("does nothing when /proc/vmstat does not exist", func() {
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("returns an error when the counter cannot be parsed", func() {
+		writeVmstat("not-a-number")
+
+		_, err := collector.Collect()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Parsing /proc/vmstat"))
+	})
+})
@@ -0,0 +1,80 @@
+package alert
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/clock"
+
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const procMountsPath = "/proc/mounts"
+
+type readOnlyMountCollector struct {
+	mountsSearcher  boshdisk.MountsSearcher
+	fs              boshsys.FileSystem
+	settingsService boshsettings.Service
+	timeService     clock.Clock
+
+	initialized bool
+	readOnly    map[string]bool
+}
+
+// NewReadOnlyMountCollector reports block device filesystems that were
+// writable on a previous collection and are now mounted read-only, which is
+// what the kernel does with errors=remount-ro after an I/O error.
+func NewReadOnlyMountCollector(
+	mountsSearcher boshdisk.MountsSearcher,
+	fs boshsys.FileSystem,
+	settingsService boshsettings.Service,
+	timeService clock.Clock,
+) Collector {
+	return &readOnlyMountCollector{
+		mountsSearcher:  mountsSearcher,
+		fs:              fs,
+		settingsService: settingsService,
+		timeService:     timeService,
+		readOnly:        map[string]bool{},
+	}
+}
+
+func (c *readOnlyMountCollector) Collect() ([]Alert, error) {
+	if !c.fs.FileExists(procMountsPath) {
+		return nil, nil
+	}
+
+	mounts, err := c.mountsSearcher.SearchMounts()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Searching mounts")
+	}
+
+	var alerts []Alert
+
+	current := map[string]bool{}
+	for _, mount := range mounts {
+		if !strings.HasPrefix(mount.PartitionPath, "/dev/") {
+			continue
+		}
+
+		current[mount.MountPoint] = mount.ReadOnly
+
+		wasReadOnly, seen := c.readOnly[mount.MountPoint]
+		if c.initialized && seen && !wasReadOnly && mount.ReadOnly {
+			alerts = append(alerts, Alert{
+				Severity:  SeverityCritical,
+				Title:     formatTitle(c.settingsService, mount.MountPoint, "filesystem read-only", "alert"),
+				Summary:   fmt.Sprintf("Filesystem %s mounted at %s was remounted read-only", mount.PartitionPath, mount.MountPoint),
+				CreatedAt: c.timeService.Now().Unix(),
+			})
+		}
+	}
+
+	c.initialized = true
+	c.readOnly = current
+
+	return alerts, nil
+}
@@ -0,0 +1,94 @@
+package alert_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/cloudfoundry/bosh-agent/agent/alert"
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
+	fakedisk "github.com/cloudfoundry/bosh-agent/platform/disk/fakes"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("readOnlyMountCollector", func() {
+	var (
+		mountsSearcher  *fakedisk.FakeMountsSearcher
+		fs              *fakesys.FakeFileSystem
+		settingsService *fakesettings.FakeSettingsService
+		timeService     *fakeclock.FakeClock
+		collector       Collector
+	)
+
+	BeforeEach(func() {
+		mountsSearcher = &fakedisk.FakeMountsSearcher{}
+		fs = fakesys.NewFakeFileSystem()
+		err := fs.WriteFileString("/proc/mounts", "")
+		Expect(err).ToNot(HaveOccurred())
+		settingsService = &fakesettings.FakeSettingsService{}
+		timeService = fakeclock.NewFakeClock(time.Now())
+		collector = NewReadOnlyMountCollector(mountsSearcher, fs, settingsService, timeService)
+	})
+
+	It("reports device mounts that became read-only", func() {
+		mountsSearcher.SearchMountsMounts = []boshdisk.Mount{
+			{PartitionPath: "/dev/sda1", MountPoint: "/"},
+			{PartitionPath: "/dev/sdb2", MountPoint: "/var/vcap/store"},
+		}
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+
+		mountsSearcher.SearchMountsMounts = []boshdisk.Mount{
+			{PartitionPath: "/dev/sda1", MountPoint: "/"},
+			{PartitionPath: "/dev/sdb2", MountPoint: "/var/vcap/store", ReadOnly: true},
+		}
+		alerts, err = collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(Equal([]Alert{{
+			Severity:  SeverityCritical,
+			Title:     "/var/vcap/store - filesystem read-only - alert",
+			Summary:   "Filesystem /dev/sdb2 mounted at /var/vcap/store was remounted read-only",
+			CreatedAt: timeService.Now().Unix(),
+		}}))
+
+		alerts, err = collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("ignores mounts that were read-only when first seen", func() {
+		mountsSearcher.SearchMountsMounts = []boshdisk.Mount{
+			{PartitionPath: "/dev/loop0", MountPoint: "/snap/core", ReadOnly: true},
+		}
+		_, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("ignores mounts that are not backed by a device", func() {
+		mountsSearcher.SearchMountsMounts = []boshdisk.Mount{{PartitionPath: "tmpfs", MountPoint: "/run"}}
+		_, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+
+		mountsSearcher.SearchMountsMounts = []boshdisk.Mount{{PartitionPath: "tmpfs", MountPoint: "/run", ReadOnly: true}}
+		alerts, err := collector.Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("returns an error when searching mounts fails", func() {
+		mountsSearcher.SearchMountsErr = errors.New("fake-search-err")
+
+		_, err := collector.Collect()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-search-err"))
+	})
+})
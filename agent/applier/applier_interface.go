@@ -8,4 +8,5 @@ type Applier interface {
 	Prepare(desiredApplySpec boshas.ApplySpec) error
 	ConfigureJobs(desiredApplySpec boshas.ApplySpec) error
 	Apply(desiredApplySpec boshas.ApplySpec) error
+	Rollback(currentApplySpec, previousApplySpec boshas.ApplySpec) error
 }
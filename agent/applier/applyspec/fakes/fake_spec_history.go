@@ -0,0 +1,46 @@
+package fakes
+
+import (
+	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+)
+
+type FakeSpecHistory struct {
+	Specs []boshas.V1ApplySpec
+
+	PushErr error
+	ListErr error
+	PopErr  error
+
+	PushedSpecs []boshas.V1ApplySpec
+	Popped      bool
+}
+
+func NewFakeSpecHistory() *FakeSpecHistory {
+	return &FakeSpecHistory{}
+}
+
+func (h *FakeSpecHistory) Push(spec boshas.V1ApplySpec) error {
+	if h.PushErr != nil {
+		return h.PushErr
+	}
+
+	h.PushedSpecs = append(h.PushedSpecs, spec)
+	h.Specs = append([]boshas.V1ApplySpec{spec}, h.Specs...)
+	return nil
+}
+
+func (h *FakeSpecHistory) List() ([]boshas.V1ApplySpec, error) {
+	return h.Specs, h.ListErr
+}
+
+func (h *FakeSpecHistory) Pop() error {
+	if h.PopErr != nil {
+		return h.PopErr
+	}
+
+	h.Popped = true
+	if len(h.Specs) > 0 {
+		h.Specs = h.Specs[1:]
+	}
+	return nil
+}
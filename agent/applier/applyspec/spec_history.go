@@ -0,0 +1,129 @@
+package applyspec
+
+import (
+	"encoding/json"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// SpecHistory keeps the previously applied specs so that an instance
+// can be rolled back without the director sending them again
+type SpecHistory interface {
+	// Push records the spec that is about to be replaced
+	Push(spec V1ApplySpec) error
+
+	// List returns the previous specs from the latest to the oldest
+	List() ([]V1ApplySpec, error)
+
+	// Pop forgets the latest previous spec once it was rolled back to
+	Pop() error
+}
+
+type fileSpecHistory struct {
+	fs       boshsys.FileSystem
+	path     string
+	maxSpecs int
+
+	lock *sync.Mutex
+}
+
+// NewFileSpecHistory keeps the latest maxSpecs specs in a JSON file at path
+func NewFileSpecHistory(fs boshsys.FileSystem, path string, maxSpecs int) SpecHistory {
+	return fileSpecHistory{
+		fs:       fs,
+		path:     path,
+		maxSpecs: maxSpecs,
+
+		lock: &sync.Mutex{},
+	}
+}
+
+// Push does not record a spec twice in a row so that
+// retrying a failed apply does not fill the history
+func (h fileSpecHistory) Push(spec V1ApplySpec) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	specs, err := h.read()
+	if err != nil {
+		return err
+	}
+
+	if len(specs) > 0 {
+		same, err := SameSpecs(specs[0], spec)
+		if err != nil {
+			return err
+		}
+
+		if same {
+			return nil
+		}
+	}
+
+	specs = append([]V1ApplySpec{spec}, specs...)
+
+	if len(specs) > h.maxSpecs {
+		specs = specs[:h.maxSpecs]
+	}
+
+	return h.write(specs)
+}
+
+func (h fileSpecHistory) List() ([]V1ApplySpec, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.read()
+}
+
+func (h fileSpecHistory) Pop() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	specs, err := h.read()
+	if err != nil {
+		return err
+	}
+
+	if len(specs) == 0 {
+		return nil
+	}
+
+	return h.write(specs[1:])
+}
+
+func (h fileSpecHistory) read() ([]V1ApplySpec, error) {
+	specs := []V1ApplySpec{}
+
+	if !h.fs.FileExists(h.path) {
+		return specs, nil
+	}
+
+	contents, err := h.fs.ReadFile(h.path)
+	if err != nil {
+		return specs, bosherr.WrapError(err, "Reading spec history")
+	}
+
+	err = json.Unmarshal(contents, &specs)
+	if err != nil {
+		return []V1ApplySpec{}, bosherr.WrapError(err, "Unmarshalling spec history")
+	}
+
+	return specs, nil
+}
+
+func (h fileSpecHistory) write(specs []V1ApplySpec) error {
+	specsBytes, err := json.Marshal(specs)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling spec history")
+	}
+
+	err = h.fs.WriteFile(h.path, specsBytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing spec history")
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package applyspec_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("fileSpecHistory", func() {
+	var (
+		fs      *fakesys.FakeFileSystem
+		history SpecHistory
+	)
+
+	spec := func(hash string) V1ApplySpec {
+		return V1ApplySpec{Deployment: "fake-deployment", ConfigurationHash: hash}
+	}
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		history = NewFileSpecHistory(fs, "/fake-bosh-dir/spec_history.json", 2)
+	})
+
+	It("returns no specs before any spec was pushed", func() {
+		Expect(history.List()).To(BeEmpty())
+	})
+
+	It("returns the latest specs first and keeps only the latest specs", func() {
+		Expect(history.Push(spec("fake-hash-1"))).To(Succeed())
+		Expect(history.Push(spec("fake-hash-2"))).To(Succeed())
+		Expect(history.Push(spec("fake-hash-3"))).To(Succeed())
+
+		Expect(history.List()).To(Equal([]V1ApplySpec{spec("fake-hash-3"), spec("fake-hash-2")}))
+	})
+
+	It("does not push the latest spec again", func() {
+		Expect(history.Push(spec("fake-hash-1"))).To(Succeed())
+		Expect(history.Push(spec("fake-hash-1"))).To(Succeed())
+
+		Expect(history.List()).To(Equal([]V1ApplySpec{spec("fake-hash-1")}))
+	})
+
+	It("forgets the latest spec when it is popped", func() {
+		Expect(history.Push(spec("fake-hash-1"))).To(Succeed())
+		Expect(history.Push(spec("fake-hash-2"))).To(Succeed())
+
+		Expect(history.Pop()).To(Succeed())
+		Expect(history.List()).To(Equal([]V1ApplySpec{spec("fake-hash-1")}))
+
+		Expect(history.Pop()).To(Succeed())
+		Expect(history.Pop()).To(Succeed())
+		Expect(history.List()).To(BeEmpty())
+	})
+
+	It("returns an error when the history cannot be written", func() {
+		fs.WriteFileError = errors.New("fake-write-error")
+
+		err := history.Push(spec("fake-hash-1"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+	})
+
+	It("returns an error when the history cannot be read", func() {
+		Expect(fs.WriteFileString("/fake-bosh-dir/spec_history.json", "not-json")).To(Succeed())
+
+		_, err := history.List()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unmarshalling spec history"))
+	})
+})
@@ -1,9 +1,13 @@
 package applyspec
 
 import (
+	"bytes"
 	"encoding/json"
+	"time"
 
 	"github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type V1ApplySpec struct {
@@ -30,6 +34,12 @@ type V1ApplySpec struct {
 
 type PropertiesSpec struct {
 	LoggingSpec LoggingSpec `json:"logging"`
+
+	// Resource limits by job name
+	JobResources map[string]cgroup.Limits `json:"job_resources,omitempty"`
+
+	// Drain deadlines in seconds by job name
+	JobDrainTimeouts map[string]int `json:"job_drain_timeouts,omitempty"`
 }
 
 type LoggingSpec struct {
@@ -60,6 +70,8 @@ func (s V1ApplySpec) Jobs() []models.Job {
 		for _, j := range s.JobSpec.JobTemplateSpecsAsJobs() {
 			j.Source = s.RenderedTemplatesArchiveSpec.AsSource(j)
 			j.Packages = s.Packages()
+			j.ResourceLimits = s.PropertiesSpec.JobResources[j.Name]
+			j.DrainTimeout = time.Duration(s.PropertiesSpec.JobDrainTimeouts[j.Name]) * time.Second
 			jobsWithSource = append(jobsWithSource, j)
 		}
 	}
@@ -100,3 +112,19 @@ func (s *NetworkSpec) UnmarshalJSON(data []byte) error {
 func (s NetworkSpec) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.Fields)
 }
+
+// SameSpecs compares the JSON representations of two specs since their
+// packages are kept in maps and specs are read back from JSON
+func SameSpecs(spec, otherSpec V1ApplySpec) (bool, error) {
+	specBytes, err := json.Marshal(spec)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Marshalling apply spec")
+	}
+
+	otherSpecBytes, err := json.Marshal(otherSpec)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Marshalling apply spec")
+	}
+
+	return bytes.Equal(specBytes, otherSpecBytes), nil
+}
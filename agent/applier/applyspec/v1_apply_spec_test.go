@@ -2,12 +2,14 @@ package applyspec_test
 
 import (
 	"encoding/json"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 	"github.com/cloudfoundry/bosh-utils/crypto"
 )
 
@@ -267,6 +269,46 @@ var _ = Describe("V1ApplySpec", func() {
 			}))
 		})
 
+		It("attaches resource limits from the properties by job name", func() {
+			spec := V1ApplySpec{
+				JobSpec: JobSpec{
+					JobTemplateSpecs: []JobTemplateSpec{
+						{Name: "fake-job1-name", Version: "fake-job1-version"},
+						{Name: "fake-job2-name", Version: "fake-job2-version"},
+					},
+				},
+				PropertiesSpec: PropertiesSpec{
+					JobResources: map[string]cgroup.Limits{
+						"fake-job1-name": {MemoryMax: "1G", PidsMax: 100},
+					},
+				},
+				RenderedTemplatesArchiveSpec: &RenderedTemplatesArchiveSpec{},
+			}
+
+			jobs := spec.Jobs()
+			Expect(jobs[0].ResourceLimits).To(Equal(cgroup.Limits{MemoryMax: "1G", PidsMax: 100}))
+			Expect(jobs[1].ResourceLimits).To(Equal(cgroup.Limits{}))
+		})
+
+		It("attaches drain timeouts from the properties by job name", func() {
+			spec := V1ApplySpec{
+				JobSpec: JobSpec{
+					JobTemplateSpecs: []JobTemplateSpec{
+						{Name: "fake-job1-name", Version: "fake-job1-version"},
+						{Name: "fake-job2-name", Version: "fake-job2-version"},
+					},
+				},
+				PropertiesSpec: PropertiesSpec{
+					JobDrainTimeouts: map[string]int{"fake-job2-name": 300},
+				},
+				RenderedTemplatesArchiveSpec: &RenderedTemplatesArchiveSpec{},
+			}
+
+			jobs := spec.Jobs()
+			Expect(jobs[0].DrainTimeout).To(BeZero())
+			Expect(jobs[1].DrainTimeout).To(Equal(5 * time.Minute))
+		})
+
 		It("returns no jobs when no jobs specified", func() {
 			spec := V1ApplySpec{}
 			Expect(spec.Jobs()).To(Equal([]models.Job{}))
@@ -1,5 +1,11 @@
 package bundlecollection
 
+import (
+	"io"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
 // BundleDefinition uniquely identifies an asset within a BundleCollection (e.g. Job, Package)
 type BundleDefinition interface {
 	BundleName() string
@@ -12,7 +18,9 @@ type BundleCollection interface {
 }
 
 type Bundle interface {
-	Install(sourcePath, pathInBundle string) (path string, err error)
+	// Install extracts the gzipped tarball read from source and verifies it
+	// against digest before moving it into the install path.
+	Install(source io.Reader, digest boshcrypto.Digest, pathInBundle string) (path string, err error)
 	InstallWithoutContents() (path string, err error)
 	Uninstall() (err error)
 
@@ -1,11 +1,18 @@
 package fakes
 
+import (
+	"io"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
 type FakeBundleInstallCallBack func()
 
 type FakeBundle struct {
 	ActionsCalled []string
 
-	InstallSourcePath   string
+	InstallSource       io.Reader
+	InstallDigest       boshcrypto.Digest
 	InstallPathInBundle string
 	InstallCallBack     FakeBundleInstallCallBack
 	InstallPath         string
@@ -33,8 +40,9 @@ func NewFakeBundle() (bundle *FakeBundle) {
 	return
 }
 
-func (s *FakeBundle) Install(sourcePath, pathInBundle string) (string, error) {
-	s.InstallSourcePath = sourcePath
+func (s *FakeBundle) Install(source io.Reader, digest boshcrypto.Digest, pathInBundle string) (string, error) {
+	s.InstallSource = source
+	s.InstallDigest = digest
 	s.InstallPathInBundle = pathInBundle
 	s.Installed = true
 	s.ActionsCalled = append(s.ActionsCalled, "Install")
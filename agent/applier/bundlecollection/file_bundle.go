@@ -1,20 +1,25 @@
 package bundlecollection
 
 import (
+	"io"
 	"os"
 	"path"
 	"path/filepath"
 
 	"code.cloudfoundry.org/clock"
-	"github.com/cloudfoundry/bosh-agent/agent/tarpath"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
-	"github.com/cloudfoundry/bosh-utils/fileutil"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
 const (
 	fileBundleLogTag = "FileBundle"
+
+	// unpackSuffix marks directories that bundles are extracted into
+	// before their contents have been verified.
+	unpackSuffix = "-bosh-agent-unpack"
 )
 
 type FileBundle struct {
@@ -23,8 +28,7 @@ type FileBundle struct {
 	fileMode     os.FileMode
 	fs           boshsys.FileSystem
 	timeProvider clock.Clock
-	compressor   fileutil.Compressor
-	detector     tarpath.Detector
+	extractor    tarstream.Extractor
 	logger       boshlog.Logger
 }
 
@@ -33,8 +37,7 @@ func NewFileBundle(
 	fileMode os.FileMode,
 	fs boshsys.FileSystem,
 	timeProvider clock.Clock,
-	compressor fileutil.Compressor,
-	detector tarpath.Detector,
+	extractor tarstream.Extractor,
 	logger boshlog.Logger,
 ) FileBundle {
 	return FileBundle{
@@ -43,8 +46,7 @@ func NewFileBundle(
 		fileMode:     fileMode,
 		fs:           fs,
 		timeProvider: timeProvider,
-		compressor:   compressor,
-		detector:     detector,
+		extractor:    extractor,
 		logger:       logger,
 	}
 }
@@ -67,48 +69,46 @@ func (b FileBundle) InstallWithoutContents() (string, error) {
 	return b.installPath, nil
 }
 
-func (b FileBundle) Install(sourcePath, pathInBundle string) (string, error) {
+func (b FileBundle) Install(source io.Reader, digest boshcrypto.Digest, pathInBundle string) (string, error) {
 	b.logger.Debug(fileBundleLogTag, "Installing %v", b)
 
-	if _, err := b.InstallWithoutContents(); err != nil {
-		return "", err
-	}
-
-	stripComponents := 0
-	if pathInBundle != "" {
-		// Job bundles contain more than one job. We receive the individual job's
-		// path as pathInBundle but we don't want to have that be duplicated in the
-		// installPath so we have tar strip that component out.
-		stripComponents = 1
-
-		// The structure of the tarball is different depending on whether or not it
-		// was delivered over NATS or via the blobstore due to different archiving
-		// code paths in the director. The NATS blobs do not contain a leading ./
-		// path. We need to detect this case and extract the correct style of path.
-		var err error
-		hasSlash, err := b.detector.Detect(sourcePath, pathInBundle)
-		if err != nil {
-			_ = b.Uninstall()
-			return "", bosherr.WrapError(err, "Detecting prefix of package files")
-		}
+	tmpInstallPath := b.installPath + unpackSuffix
 
-		if hasSlash {
-			pathInBundle = "./" + pathInBundle
-			stripComponents = 2
-		}
+	if err := b.fs.RemoveAll(tmpInstallPath); err != nil {
+		return "", bosherr.WrapError(err, "Removing temporary installation directory")
+	}
+	if err := b.fs.MkdirAll(tmpInstallPath, b.fileMode); err != nil {
+		return "", bosherr.WrapError(err, "Creating temporary installation directory")
 	}
 
-	err := b.compressor.DecompressFileToDir(
-		sourcePath,
-		b.installPath,
-		fileutil.CompressorOptions{PathInArchive: pathInBundle, StripComponents: stripComponents},
-	)
+	// Job bundles contain more than one job. We receive the individual job's
+	// path as pathInBundle but we don't want to have that be duplicated in the
+	// installPath so the extractor strips that component out.
+	err := b.extractor.Extract(source, digest, tmpInstallPath, tarstream.Options{PathInArchive: pathInBundle})
 	if err != nil {
-		_ = b.Uninstall()
+		_ = b.fs.RemoveAll(tmpInstallPath)
 		return "", bosherr.WrapError(err, "Decompressing package files")
 	}
 
-	b.logger.Debug(fileBundleLogTag, "Installing %v", b)
+	// Contents are only moved into place once the digest has been verified
+	if err := b.Uninstall(); err != nil {
+		_ = b.fs.RemoveAll(tmpInstallPath)
+		return "", bosherr.WrapError(err, "Removing previous installation")
+	}
+	if err := b.fs.Rename(tmpInstallPath, b.installPath); err != nil {
+		_ = b.fs.RemoveAll(tmpInstallPath)
+		return "", bosherr.WrapError(err, "Moving package files into installation directory")
+	}
+	if err := b.fs.Chmod(b.installPath, b.fileMode); err != nil {
+		_ = b.Uninstall()
+		return "", bosherr.WrapError(err, "Setting permissions on installation directory")
+	}
+
+	if _, err := b.InstallWithoutContents(); err != nil {
+		return "", err
+	}
+
+	b.logger.Debug(fileBundleLogTag, "Installed %v", b)
 	return b.installPath, nil
 }
 
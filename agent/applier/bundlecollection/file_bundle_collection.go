@@ -8,10 +8,9 @@ import (
 	"os"
 
 	"code.cloudfoundry.org/clock"
-	"github.com/cloudfoundry/bosh-agent/agent/tarpath"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
-	"github.com/cloudfoundry/bosh-utils/fileutil"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
@@ -52,7 +51,7 @@ type FileBundleCollection struct {
 	fileMode     os.FileMode
 	fs           boshsys.FileSystem
 	timeProvider clock.Clock
-	compressor   fileutil.Compressor
+	extractor    tarstream.Extractor
 	logger       boshlog.Logger
 }
 
@@ -61,7 +60,7 @@ func NewFileBundleCollection(
 	fileMode os.FileMode,
 	fs boshsys.FileSystem,
 	timeProvider clock.Clock,
-	compressor fileutil.Compressor,
+	extractor tarstream.Extractor,
 	logger boshlog.Logger,
 ) FileBundleCollection {
 	return FileBundleCollection{
@@ -71,7 +70,7 @@ func NewFileBundleCollection(
 		fileMode:     fileMode,
 		fs:           fs,
 		timeProvider: timeProvider,
-		compressor:   compressor,
+		extractor:    extractor,
 		logger:       logger,
 	}
 }
@@ -93,7 +92,7 @@ func (bc FileBundleCollection) Get(definition BundleDefinition) (Bundle, error)
 	installPath := path.Join(bc.installPath, bc.name, definition.BundleName(), bundleVersionDigest.String())
 	enablePath := path.Join(bc.enablePath, bc.name, definition.BundleName())
 
-	return NewFileBundle(installPath, enablePath, bc.fileMode, bc.fs, bc.timeProvider, bc.compressor, tarpath.NewPrefixDetector(), bc.logger), nil
+	return NewFileBundle(installPath, enablePath, bc.fileMode, bc.fs, bc.timeProvider, bc.extractor, bc.logger), nil
 }
 
 func (bc FileBundleCollection) getDigested(definition BundleDefinition) (Bundle, error) {
@@ -107,7 +106,7 @@ func (bc FileBundleCollection) getDigested(definition BundleDefinition) (Bundle,
 
 	installPath := path.Join(bc.installPath, bc.name, definition.BundleName(), definition.BundleVersion())
 	enablePath := path.Join(bc.enablePath, bc.name, definition.BundleName())
-	return NewFileBundle(installPath, enablePath, bc.fileMode, bc.fs, bc.timeProvider, bc.compressor, tarpath.NewPrefixDetector(), bc.logger), nil
+	return NewFileBundle(installPath, enablePath, bc.fileMode, bc.fs, bc.timeProvider, bc.extractor, bc.logger), nil
 }
 
 func (bc FileBundleCollection) List() ([]Bundle, error) {
@@ -119,6 +118,10 @@ func (bc FileBundleCollection) List() ([]Bundle, error) {
 	}
 
 	for _, path := range bundleInstallPaths {
+		if strings.HasSuffix(path, unpackSuffix) {
+			continue
+		}
+
 		bundle, err := bc.getDigested(newFileBundleDefinition(path))
 		if err != nil {
 			return bundles, bosherr.WrapError(err, "Getting bundle")
@@ -4,14 +4,13 @@ import (
 	"errors"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
-	"github.com/cloudfoundry/bosh-agent/agent/tarpath"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"os"
 
 	"github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection/fakes"
-	fakefileutil "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream/tarstreamfakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
@@ -28,7 +27,7 @@ var _ = Describe("FileBundleCollection", func() {
 	var (
 		fs                   *fakesys.FakeFileSystem
 		fakeClock            *fakes.FakeClock
-		fakeCompressor       *fakefileutil.FakeCompressor
+		fakeExtractor        *tarstreamfakes.FakeExtractor
 		logger               boshlog.Logger
 		fileBundleCollection FileBundleCollection
 	)
@@ -36,6 +35,7 @@ var _ = Describe("FileBundleCollection", func() {
 	BeforeEach(func() {
 		fs = fakesys.NewFakeFileSystem()
 		fakeClock = new(fakes.FakeClock)
+		fakeExtractor = new(tarstreamfakes.FakeExtractor)
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 		fileBundleCollection = NewFileBundleCollection(
 			"/fake-collection-path/data",
@@ -44,7 +44,7 @@ var _ = Describe("FileBundleCollection", func() {
 			os.FileMode(0750),
 			fs,
 			fakeClock,
-			fakeCompressor,
+			fakeExtractor,
 			logger,
 		)
 	})
@@ -65,8 +65,7 @@ var _ = Describe("FileBundleCollection", func() {
 				os.FileMode(0750),
 				fs,
 				fakeClock,
-				fakeCompressor,
-				tarpath.NewPrefixDetector(),
+				fakeExtractor,
 				logger,
 			)
 
@@ -111,8 +110,7 @@ var _ = Describe("FileBundleCollection", func() {
 					os.FileMode(0750),
 					fs,
 					fakeClock,
-					fakeCompressor,
-					tarpath.NewPrefixDetector(),
+					fakeExtractor,
 					logger,
 				),
 				NewFileBundle(
@@ -121,8 +119,7 @@ var _ = Describe("FileBundleCollection", func() {
 					os.FileMode(0750),
 					fs,
 					fakeClock,
-					fakeCompressor,
-					tarpath.NewPrefixDetector(),
+					fakeExtractor,
 					logger,
 				),
 				NewFileBundle(
@@ -131,8 +128,7 @@ var _ = Describe("FileBundleCollection", func() {
 					os.FileMode(0750),
 					fs,
 					fakeClock,
-					fakeCompressor,
-					tarpath.NewPrefixDetector(),
+					fakeExtractor,
 					logger,
 				),
 			}
@@ -140,6 +136,27 @@ var _ = Describe("FileBundleCollection", func() {
 			Expect(bundles).To(Equal(expectedBundles))
 		})
 
+		It("skips bundles that are still being unpacked", func() {
+			fs.SetGlob(installPath+"/*/*", []string{
+				installPath + "/fake-bundle-1-name/fake-bundle-1-version-1",
+				installPath + "/fake-bundle-1-name/fake-bundle-1-version-2-bosh-agent-unpack",
+			})
+
+			bundles, err := fileBundleCollection.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bundles).To(Equal([]Bundle{
+				NewFileBundle(
+					installPath+"/fake-bundle-1-name/fake-bundle-1-version-1",
+					enablePath+"/fake-bundle-1-name",
+					os.FileMode(0750),
+					fs,
+					fakeClock,
+					fakeExtractor,
+					logger,
+				),
+			}))
+		})
+
 		It("returns error when glob fails to execute", func() {
 			fs.GlobErr = errors.New("fake-glob-error")
 
@@ -10,8 +10,7 @@ import (
 
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection/fakes"
-	"github.com/cloudfoundry/bosh-agent/agent/tarpath"
-	fakefileutil "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream/tarstreamfakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
@@ -20,7 +19,7 @@ var _ = Describe("FileBundleCollection", func() {
 	var (
 		fs                   *fakesys.FakeFileSystem
 		fakeClock            *fakes.FakeClock
-		fakeCompressor       *fakefileutil.FakeCompressor
+		fakeExtractor        *tarstreamfakes.FakeExtractor
 		logger               boshlog.Logger
 		fileBundleCollection FileBundleCollection
 	)
@@ -28,6 +27,7 @@ var _ = Describe("FileBundleCollection", func() {
 	BeforeEach(func() {
 		fs = fakesys.NewFakeFileSystem()
 		fakeClock = new(fakes.FakeClock)
+		fakeExtractor = new(tarstreamfakes.FakeExtractor)
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 		fileBundleCollection = NewFileBundleCollection(
 			`C:\fake-collection-path\data`,
@@ -36,7 +36,7 @@ var _ = Describe("FileBundleCollection", func() {
 			os.FileMode(0750),
 			fs,
 			fakeClock,
-			fakeCompressor,
+			fakeExtractor,
 			logger,
 		)
 	})
@@ -57,8 +57,7 @@ var _ = Describe("FileBundleCollection", func() {
 				os.FileMode(0750),
 				fs,
 				fakeClock,
-				fakeCompressor,
-				tarpath.NewPrefixDetector(),
+				fakeExtractor,
 				logger,
 			)
 
@@ -103,8 +102,7 @@ var _ = Describe("FileBundleCollection", func() {
 					os.FileMode(0750),
 					fs,
 					fakeClock,
-					fakeCompressor,
-					tarpath.NewPrefixDetector(),
+					fakeExtractor,
 					logger,
 				),
 				NewFileBundle(
@@ -113,8 +111,7 @@ var _ = Describe("FileBundleCollection", func() {
 					os.FileMode(0750),
 					fs,
 					fakeClock,
-					fakeCompressor,
-					tarpath.NewPrefixDetector(),
+					fakeExtractor,
 					logger,
 				),
 				NewFileBundle(
@@ -123,8 +120,7 @@ var _ = Describe("FileBundleCollection", func() {
 					os.FileMode(0750),
 					fs,
 					fakeClock,
-					fakeCompressor,
-					tarpath.NewPrefixDetector(),
+					fakeExtractor,
 					logger,
 				),
 			}
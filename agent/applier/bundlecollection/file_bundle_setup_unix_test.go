@@ -4,15 +4,18 @@
 package bundlecollection_test
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudfoundry/bosh-agent/agent/action/fakes"
-	"github.com/cloudfoundry/bosh-agent/agent/tarpath/tarpathfakes"
-	fakefileutil "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream/tarstreamfakes"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 
@@ -21,22 +24,19 @@ import (
 
 var _ = Describe("FileBundle", func() {
 	var (
-		fs             *fakesys.FakeFileSystem
-		fakeClock      *fakes.FakeClock
-		fakeCompressor *fakefileutil.FakeCompressor
-		fakeDetector   *tarpathfakes.FakeDetector
-		logger         boshlog.Logger
-		sourcePath     string
-		installPath    string
-		enablePath     string
-		fileBundle     FileBundle
+		fs            *fakesys.FakeFileSystem
+		fakeClock     *fakes.FakeClock
+		fakeExtractor *tarstreamfakes.FakeExtractor
+		logger        boshlog.Logger
+		installPath   string
+		enablePath    string
+		fileBundle    FileBundle
 	)
 
 	BeforeEach(func() {
 		fs = fakesys.NewFakeFileSystem()
 		fakeClock = new(fakes.FakeClock)
-		fakeCompressor = new(fakefileutil.FakeCompressor)
-		fakeDetector = new(tarpathfakes.FakeDetector)
+		fakeExtractor = new(tarstreamfakes.FakeExtractor)
 		installPath = "/install-path"
 		enablePath = "/enable-path"
 		logger = boshlog.NewLogger(boshlog.LevelNone)
@@ -46,36 +46,17 @@ var _ = Describe("FileBundle", func() {
 			os.FileMode(0750),
 			fs,
 			fakeClock,
-			fakeCompressor,
-			fakeDetector,
+			fakeExtractor,
 			logger,
 		)
 	})
 
-	createSourcePath := func() string {
-		path := "/source-path"
-		err := fs.MkdirAll(path, os.ModePerm)
-		Expect(err).ToNot(HaveOccurred())
-
-		err = fs.WriteFileString("/source-path/config.go", "package go")
-		Expect(err).ToNot(HaveOccurred())
-
-		return path
-	}
-
-	BeforeEach(func() {
-		sourcePath = createSourcePath()
-	})
-
 	It("uninstalls the bundle", func() {
-		fakeCompressor.DecompressFileToDirCallBack = func() {
-			decompressPath := fakeCompressor.DecompressFileToDirDirs[len(fakeCompressor.DecompressFileToDirDirs)-1]
-			contents, err := fs.ReadFileString(filepath.Join(sourcePath, "config.go"))
-			Expect(err).NotTo(HaveOccurred())
-			fs.WriteFileString(filepath.Join(decompressPath, "config.go"), contents)
+		fakeExtractor.ExtractStub = func(_ io.Reader, _ boshcrypto.Digest, targetDir string, _ tarstream.Options) error {
+			return fs.WriteFileString(filepath.Join(targetDir, "config.go"), "package go")
 		}
 
-		path, err := fileBundle.Install(sourcePath, "")
+		path, err := fileBundle.Install(strings.NewReader("fake-tarball"), boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-sha1"), "")
 		Expect(err).NotTo(HaveOccurred())
 		Expect(path).To(Equal(installPath))
 
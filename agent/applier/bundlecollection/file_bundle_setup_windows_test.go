@@ -2,7 +2,7 @@ package bundlecollection_test
 
 import (
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
-	"github.com/cloudfoundry/bosh-agent/agent/tarpath/tarpathfakes"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream/tarstreamfakes"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -13,29 +13,25 @@ import (
 	"time"
 
 	"github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection/fakes"
-	fakefileutil "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 var _ = Describe("FileBundle uninstallation", func() {
 	var (
-		fs             *fakesys.FakeFileSystem
-		fakeClock      *fakes.FakeClock
-		fakeCompressor *fakefileutil.FakeCompressor
-		fakeDetector   *tarpathfakes.FakeDetector
-		logger         boshlog.Logger
-		sourcePath     string
-		installPath    string
-		enablePath     string
-		fileBundle     FileBundle
+		fs            *fakesys.FakeFileSystem
+		fakeClock     *fakes.FakeClock
+		fakeExtractor *tarstreamfakes.FakeExtractor
+		logger        boshlog.Logger
+		installPath   string
+		enablePath    string
+		fileBundle    FileBundle
 	)
 
 	BeforeEach(func() {
 		fs = fakesys.NewFakeFileSystem()
 		fakeClock = new(fakes.FakeClock)
-		fakeCompressor = new(fakefileutil.FakeCompressor)
-		fakeDetector = &tarpathfakes.FakeDetector{}
+		fakeExtractor = new(tarstreamfakes.FakeExtractor)
 		installPath = "/install-path"
 		enablePath = "/enable-path"
 		logger = boshlog.NewLogger(boshlog.LevelNone)
@@ -46,23 +42,11 @@ var _ = Describe("FileBundle uninstallation", func() {
 			os.FileMode(0750),
 			fs,
 			fakeClock,
-			fakeCompressor,
-			fakeDetector,
+			fakeExtractor,
 			logger,
 		)
 	})
 
-	createSourcePath := func() string {
-		path := "/source-path"
-		err := fs.MkdirAll(path, os.ModePerm)
-		Expect(err).ToNot(HaveOccurred())
-		return path
-	}
-
-	BeforeEach(func() {
-		sourcePath = createSourcePath()
-	})
-
 	Describe("Uninstall", func() {
 		It("succeeds when the first five calls to RemoveAll fails", func() {
 			callCounter := 0
@@ -78,7 +62,7 @@ var _ = Describe("FileBundle uninstallation", func() {
 				return nil
 			}
 
-			_, err := fileBundle.Install(sourcePath, "")
+			err := fs.MkdirAll(installPath, os.ModePerm)
 			Expect(err).NotTo(HaveOccurred())
 
 			err = fileBundle.Uninstall()
@@ -105,7 +89,7 @@ var _ = Describe("FileBundle uninstallation", func() {
 			fakeClock.SinceReturns(1 * time.Second)
 			fakeClock.SinceReturnsOnCall(failingRemoveAlls, BundleSetupTimeout+(1*time.Second))
 
-			_, err := fileBundle.Install(sourcePath, "")
+			err := fs.MkdirAll(installPath, os.ModePerm)
 			Expect(err).NotTo(HaveOccurred())
 
 			err = fileBundle.Uninstall()
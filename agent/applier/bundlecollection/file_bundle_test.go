@@ -2,16 +2,19 @@ package bundlecollection_test
 
 import (
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection/fakes"
-	"github.com/cloudfoundry/bosh-agent/agent/tarpath/tarpathfakes"
-	fakefileutil "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream/tarstreamfakes"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
@@ -20,22 +23,21 @@ import (
 
 var _ = Describe("FileBundle", func() {
 	var (
-		fs             *fakesys.FakeFileSystem
-		fakeClock      *fakes.FakeClock
-		fakeCompressor *fakefileutil.FakeCompressor
-		fakeDetector   *tarpathfakes.FakeDetector
-		logger         boshlog.Logger
-		sourcePath     string
-		installPath    string
-		enablePath     string
-		fileBundle     FileBundle
+		fs            *fakesys.FakeFileSystem
+		fakeClock     *fakes.FakeClock
+		fakeExtractor *tarstreamfakes.FakeExtractor
+		logger        boshlog.Logger
+		source        io.Reader
+		digest        boshcrypto.Digest
+		installPath   string
+		enablePath    string
+		fileBundle    FileBundle
 	)
 
 	BeforeEach(func() {
 		fs = fakesys.NewFakeFileSystem()
 		fakeClock = new(fakes.FakeClock)
-		fakeCompressor = new(fakefileutil.FakeCompressor)
-		fakeDetector = &tarpathfakes.FakeDetector{}
+		fakeExtractor = new(tarstreamfakes.FakeExtractor)
 		installPath = "/install-path"
 		enablePath = "/enable-path"
 		logger = boshlog.NewLogger(boshlog.LevelNone)
@@ -45,25 +47,14 @@ var _ = Describe("FileBundle", func() {
 			os.FileMode(0750),
 			fs,
 			fakeClock,
-			fakeCompressor,
-			fakeDetector,
+			fakeExtractor,
 			logger,
 		)
 	})
 
-	createSourcePath := func() string {
-		path := "/source-path"
-		err := fs.MkdirAll(path, 0750)
-		Expect(err).ToNot(HaveOccurred())
-
-		err = fs.WriteFileString("/source-path/config.go", "package go")
-		Expect(err).ToNot(HaveOccurred())
-
-		return path
-	}
-
 	BeforeEach(func() {
-		sourcePath = createSourcePath()
+		source = strings.NewReader("fake-tarball")
+		digest = boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-sha1")
 	})
 
 	Describe("InstallWithoutContents", func() {
@@ -109,9 +100,7 @@ var _ = Describe("FileBundle", func() {
 		})
 
 		It("sets correct permissions on install path", func() {
-			fs.Chmod(sourcePath, os.FileMode(0700))
-
-			_, err := fileBundle.Install(sourcePath, "")
+			_, err := fileBundle.Install(source, digest, "")
 			Expect(err).NotTo(HaveOccurred())
 
 			fileStats := fs.GetFileTestStat(installPath)
@@ -134,20 +123,21 @@ var _ = Describe("FileBundle", func() {
 
 	Describe("Install", func() {
 		It("installs the bundle from source at the given path", func() {
-			fakeCompressor.DecompressFileToDirCallBack = func() {
-				decompressPath := fakeCompressor.DecompressFileToDirDirs[len(fakeCompressor.DecompressFileToDirDirs)-1]
-				contents, err := fs.ReadFileString(filepath.Join(sourcePath, "config.go"))
-				Expect(err).NotTo(HaveOccurred())
-				fs.WriteFileString(filepath.Join(decompressPath, "config.go"), contents)
+			fakeExtractor.ExtractStub = func(_ io.Reader, _ boshcrypto.Digest, targetDir string, _ tarstream.Options) error {
+				Expect(fs.FileExists(installPath)).To(BeFalse())
+				return fs.WriteFileString(filepath.Join(targetDir, "config.go"), "package go")
 			}
 
-			path, err := fileBundle.Install(sourcePath, "")
+			path, err := fileBundle.Install(source, digest, "")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(path).To(Equal(installPath))
 
-			opts := fakeCompressor.DecompressFileToDirOptions[0]
+			Expect(fakeExtractor.ExtractCallCount()).To(Equal(1))
+			sourceArg, digestArg, targetDirArg, opts := fakeExtractor.ExtractArgsForCall(0)
+			Expect(sourceArg).To(Equal(source))
+			Expect(digestArg).To(Equal(digest))
+			Expect(targetDirArg).To(Equal("/install-path-bosh-agent-unpack"))
 			Expect(opts.PathInArchive).To(Equal(""))
-			Expect(opts.StripComponents).To(Equal(0))
 
 			installed, err := fileBundle.IsInstalled()
 			Expect(err).NotTo(HaveOccurred())
@@ -156,35 +146,47 @@ var _ = Describe("FileBundle", func() {
 			contents, err := fs.ReadFileString(filepath.Join(path, "config.go"))
 			Expect(err).NotTo(HaveOccurred())
 			Expect(contents).To(Equal("package go"))
+			Expect(fs.FileExists("/install-path-bosh-agent-unpack")).To(BeFalse())
 		})
 
-		It("returns error when decompression fails", func() {
-			fakeCompressor.DecompressFileToDirErr = errors.New("disaster")
+		It("replaces a previous installation", func() {
+			Expect(fs.WriteFileString(filepath.Join(installPath, "stale"), "stale")).To(Succeed())
 
-			_, err := fileBundle.Install(sourcePath, "")
+			_, err := fileBundle.Install(source, digest, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fs.FileExists(filepath.Join(installPath, "stale"))).To(BeFalse())
+		})
+
+		It("returns error when extraction or verification fails", func() {
+			fakeExtractor.ExtractReturns(errors.New("disaster"))
+
+			_, err := fileBundle.Install(source, digest, "")
 			Expect(err).To(MatchError(ContainSubstring("disaster")))
 
 			installed, err := fileBundle.IsInstalled()
 			Expect(err).NotTo(HaveOccurred())
 			Expect(installed).To(BeFalse())
+			Expect(fs.FileExists("/install-path-bosh-agent-unpack")).To(BeFalse())
 		})
 
-		It("returns an error if creation of install directory fails", func() {
-			fs.MkdirAllError = errors.New("mkdir failed")
+		It("leaves a previous installation in place when extraction or verification fails", func() {
+			Expect(fs.WriteFileString(filepath.Join(installPath, "config.go"), "package go")).To(Succeed())
+			fakeExtractor.ExtractReturns(errors.New("disaster"))
 
-			_, err := fileBundle.Install(sourcePath, "")
-			Expect(err).To(MatchError(ContainSubstring("mkdir failed")))
+			_, err := fileBundle.Install(source, digest, "")
+			Expect(err).To(HaveOccurred())
 
-			installed, err := fileBundle.IsInstalled()
+			contents, err := fs.ReadFileString(filepath.Join(installPath, "config.go"))
 			Expect(err).NotTo(HaveOccurred())
-			Expect(installed).To(BeFalse())
+			Expect(contents).To(Equal("package go"))
 		})
 
-		It("returns an error if the detection fails", func() {
-			fakeDetector.DetectReturns(false, errors.New("detect failed"))
+		It("returns an error if creation of install directory fails", func() {
+			fs.MkdirAllError = errors.New("mkdir failed")
 
-			_, err := fileBundle.Install(sourcePath, "subdir")
-			Expect(err).To(MatchError(ContainSubstring("detect failed")))
+			_, err := fileBundle.Install(source, digest, "")
+			Expect(err).To(MatchError(ContainSubstring("mkdir failed")))
 
 			installed, err := fileBundle.IsInstalled()
 			Expect(err).NotTo(HaveOccurred())
@@ -194,7 +196,7 @@ var _ = Describe("FileBundle", func() {
 		It("returns an error if chown install directory fails", func() {
 			fs.ChownErr = errors.New("chown failed")
 
-			_, err := fileBundle.Install(sourcePath, "")
+			_, err := fileBundle.Install(source, digest, "")
 			Expect(err).To(MatchError(ContainSubstring("chown failed")))
 
 			installed, err := fileBundle.IsInstalled()
@@ -203,9 +205,7 @@ var _ = Describe("FileBundle", func() {
 		})
 
 		It("sets correct permissions on install path", func() {
-			fs.Chmod(sourcePath, os.FileMode(0700))
-
-			_, err := fileBundle.Install(sourcePath, "")
+			_, err := fileBundle.Install(source, digest, "")
 			Expect(err).NotTo(HaveOccurred())
 
 			fileStats := fs.GetFileTestStat(installPath)
@@ -218,16 +218,13 @@ var _ = Describe("FileBundle", func() {
 
 		// Job bundles contain many jobs but we only want to extract a single one.
 		Describe("extracting only part of the bundle", func() {
-			It("detects the prefix to use", func() {
-				fakeDetector.DetectReturns(true, nil)
-
-				path, err := fileBundle.Install(sourcePath, "subdir")
+			It("passes the path in the bundle to the extractor", func() {
+				path, err := fileBundle.Install(source, digest, "subdir")
 				Expect(err).NotTo(HaveOccurred())
 				Expect(path).To(Equal(installPath))
 
-				opts := fakeCompressor.DecompressFileToDirOptions[0]
-				Expect(opts.PathInArchive).To(Equal("./subdir"))
-				Expect(opts.StripComponents).To(Equal(2))
+				_, _, _, opts := fakeExtractor.ExtractArgsForCall(0)
+				Expect(opts.PathInArchive).To(Equal("subdir"))
 			})
 		})
 	})
@@ -250,7 +247,7 @@ var _ = Describe("FileBundle", func() {
 
 	Describe("IsInstalled", func() {
 		It("returns true when it is installed", func() {
-			_, err := fileBundle.Install(sourcePath, "")
+			_, err := fileBundle.Install(source, digest, "")
 			Expect(err).NotTo(HaveOccurred())
 
 			installed, err := fileBundle.IsInstalled()
@@ -268,7 +265,7 @@ var _ = Describe("FileBundle", func() {
 	Describe("Enable", func() {
 		Context("when bundle is installed", func() {
 			BeforeEach(func() {
-				_, err := fileBundle.Install(sourcePath, "")
+				_, err := fileBundle.Install(source, digest, "")
 				Expect(err).NotTo(HaveOccurred())
 			})
 
@@ -317,7 +314,7 @@ var _ = Describe("FileBundle", func() {
 
 		Context("when enable dir cannot be created", func() {
 			It("returns error", func() {
-				_, err := fileBundle.Install(sourcePath, "")
+				_, err := fileBundle.Install(source, digest, "")
 				Expect(err).NotTo(HaveOccurred())
 				fs.MkdirAllError = errors.New("fake-mkdirall-error")
 
@@ -329,7 +326,7 @@ var _ = Describe("FileBundle", func() {
 
 		Context("when bundle cannot be enabled", func() {
 			It("returns error", func() {
-				_, err := fileBundle.Install(sourcePath, "")
+				_, err := fileBundle.Install(source, digest, "")
 				Expect(err).NotTo(HaveOccurred())
 				fs.SymlinkError = errors.New("fake-symlink-error")
 
@@ -353,7 +350,7 @@ var _ = Describe("FileBundle", func() {
 
 		Context("where the enabled path target is the same installed version", func() {
 			BeforeEach(func() {
-				_, err := fileBundle.Install(sourcePath, "")
+				_, err := fileBundle.Install(source, digest, "")
 				Expect(err).NotTo(HaveOccurred())
 
 				_, err = fileBundle.Enable()
@@ -381,7 +378,7 @@ var _ = Describe("FileBundle", func() {
 			newerInstallPath := "/newer-install-path"
 
 			BeforeEach(func() {
-				_, err := fileBundle.Install(sourcePath, "")
+				_, err := fileBundle.Install(source, digest, "")
 				Expect(err).NotTo(HaveOccurred())
 
 				_, err = fileBundle.Enable()
@@ -393,13 +390,11 @@ var _ = Describe("FileBundle", func() {
 					os.FileMode(0750),
 					fs,
 					fakeClock,
-					fakeCompressor,
-					fakeDetector,
+					fakeExtractor,
 					logger,
 				)
 
-				otherSourcePath := createSourcePath()
-				_, err = newerFileBundle.Install(otherSourcePath, "")
+				_, err = newerFileBundle.Install(strings.NewReader("other-fake-tarball"), digest, "")
 				Expect(err).NotTo(HaveOccurred())
 
 				_, err = newerFileBundle.Enable()
@@ -430,7 +425,7 @@ var _ = Describe("FileBundle", func() {
 
 	Describe("Uninstall", func() {
 		It("removes the files from disk", func() {
-			_, err := fileBundle.Install(sourcePath, "")
+			_, err := fileBundle.Install(source, digest, "")
 			Expect(err).NotTo(HaveOccurred())
 
 			err = fileBundle.Uninstall()
@@ -4,14 +4,15 @@
 package bundlecollection_test
 
 import (
-	"github.com/cloudfoundry/bosh-agent/agent/tarpath/tarpathfakes"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream/tarstreamfakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"os"
+	"strings"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection/fakes"
-	fakefileutil "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
@@ -20,15 +21,13 @@ import (
 
 var _ = Describe("FileBundle", func() {
 	var (
-		fs             *fakesys.FakeFileSystem
-		fakeClock      *fakes.FakeClock
-		fakeCompressor *fakefileutil.FakeCompressor
-		fakeDetector   *tarpathfakes.FakeDetector
-		logger         boshlog.Logger
-		sourcePath     string
-		installPath    string
-		enablePath     string
-		fileBundle     FileBundle
+		fs            *fakesys.FakeFileSystem
+		fakeClock     *fakes.FakeClock
+		fakeExtractor *tarstreamfakes.FakeExtractor
+		logger        boshlog.Logger
+		installPath   string
+		enablePath    string
+		fileBundle    FileBundle
 	)
 
 	BeforeEach(func() {
@@ -54,28 +53,15 @@ var _ = Describe("FileBundle", func() {
 		enablePath = "/C/var/vcap/jobs/job_name"
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 
-		fakeCompressor = new(fakefileutil.FakeCompressor)
-		fakeDetector = &tarpathfakes.FakeDetector{}
+		fakeExtractor = new(tarstreamfakes.FakeExtractor)
 
-		fileBundle = NewFileBundle(installPath, enablePath, os.FileMode(0750), fs, fakeClock, fakeCompressor, fakeDetector, logger)
-	})
-
-	createSourcePath := func() string {
-		path := "/D/source-path"
-		err := fs.MkdirAll(path, os.ModePerm)
-		Expect(err).ToNot(HaveOccurred())
-
-		return path
-	}
-
-	BeforeEach(func() {
-		sourcePath = createSourcePath()
+		fileBundle = NewFileBundle(installPath, enablePath, os.FileMode(0750), fs, fakeClock, fakeExtractor, logger)
 	})
 
 	Describe("Disable", func() {
 		Context("where the enabled path target is the same installed version", func() {
 			BeforeEach(func() {
-				_, err := fileBundle.Install(sourcePath, "")
+				_, err := fileBundle.Install(strings.NewReader("fake-tarball"), boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-sha1"), "")
 				Expect(err).NotTo(HaveOccurred())
 
 				_, err = fileBundle.Enable()
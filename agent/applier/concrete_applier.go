@@ -1,8 +1,11 @@
 package applier
 
 import (
+	"sync/atomic"
+
 	as "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/jobs"
+	"github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/packages"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
@@ -18,6 +21,7 @@ type concreteApplier struct {
 	jobSupervisor     boshjobsuper.JobSupervisor
 	dirProvider       boshdirs.Provider
 	settings          boshsettings.Settings
+	specHistory       as.SpecHistory
 }
 
 func NewConcreteApplier(
@@ -27,6 +31,7 @@ func NewConcreteApplier(
 	jobSupervisor boshjobsuper.JobSupervisor,
 	dirProvider boshdirs.Provider,
 	settings boshsettings.Settings,
+	specHistory as.SpecHistory,
 ) Applier {
 	return &concreteApplier{
 		jobApplier:        jobApplier,
@@ -35,15 +40,31 @@ func NewConcreteApplier(
 		jobSupervisor:     jobSupervisor,
 		dirProvider:       dirProvider,
 		settings:          settings,
+		specHistory:       specHistory,
 	}
 }
 
 func (a *concreteApplier) Prepare(desiredApplySpec as.ApplySpec) error {
-	var tasks []func() error
-	pool := work.Pool{
-		Count: *a.settings.Env.GetParallel(),
+	err := a.prepare(desiredApplySpec)
+	if err != nil {
+		return err
 	}
 
+	err = a.jobApplier.DeleteSourceBlobs(desiredApplySpec.Jobs())
+	if err != nil {
+		return bosherr.WrapError(err, "Failed removing job source blobs")
+	}
+
+	return nil
+}
+
+// prepare downloads and installs the jobs and packages that are not
+// installed yet, up to Env.Bosh.Parallel at a time. No further downloads
+// are started once one failed and errors are reported in the order of
+// the jobs and packages in the spec.
+func (a *concreteApplier) prepare(desiredApplySpec as.ApplySpec) error {
+	var tasks []func() error
+
 	for _, job := range desiredApplySpec.Jobs() {
 		job := job
 		tasks = append(tasks, func() error {
@@ -66,21 +87,74 @@ func (a *concreteApplier) Prepare(desiredApplySpec as.ApplySpec) error {
 		})
 	}
 
-	err := pool.ParallelDo(tasks...)
-	if err != nil {
-		return err
+	pool := work.Pool{
+		Count: *a.settings.Env.GetParallel(),
 	}
 
-	err = a.jobApplier.DeleteSourceBlobs(desiredApplySpec.Jobs())
-	if err != nil {
-		return bosherr.WrapError(err, "Failed removing job source blobs")
+	// A pool without workers would not run any task
+	if pool.Count < 1 {
+		pool.Count = 1
+	}
+
+	// The pool reports errors in the order the tasks failed in
+	errs := make([]error, len(tasks))
+	var failed int32
+
+	indexedTasks := make([]func() error, len(tasks))
+	for i, task := range tasks {
+		i, task := i, task
+		indexedTasks[i] = func() error {
+			if atomic.LoadInt32(&failed) == 1 {
+				return nil
+			}
+
+			errs[i] = task()
+			if errs[i] != nil {
+				atomic.StoreInt32(&failed, 1)
+			}
+			return errs[i]
+		}
+	}
+
+	_ = pool.ParallelDo(indexedTasks...)
+
+	var orderedErrs []error
+	for _, err := range errs {
+		if err != nil {
+			orderedErrs = append(orderedErrs, err)
+		}
+	}
+
+	if len(orderedErrs) > 0 {
+		return bosherr.NewMultiError(orderedErrs...)
 	}
 
 	return nil
 }
 
+// Apply enables the jobs and packages of the desired spec. They are
+// downloaded before the current jobs are removed. The jobs and packages
+// of the previous specs stay installed so that they can be rolled back to.
 func (a *concreteApplier) Apply(desiredApplySpec as.ApplySpec) error {
-	err := a.jobSupervisor.RemoveAllJobs()
+	return a.apply(desiredApplySpec, true)
+}
+
+// apply only deletes the job sources from the blobstore when they were just
+// downloaded, a rollback uses jobs that were installed from blobs the
+// director may still reference. The jobs and packages of the kept specs
+// stay installed like those of the previous specs.
+func (a *concreteApplier) apply(desiredApplySpec as.ApplySpec, deleteSourceBlobs bool, keptApplySpecs ...as.ApplySpec) error {
+	retainedJobs, retainedPackages, err := a.retained(keptApplySpecs...)
+	if err != nil {
+		return err
+	}
+
+	err = a.prepare(desiredApplySpec)
+	if err != nil {
+		return err
+	}
+
+	err = a.jobSupervisor.RemoveAllJobs()
 	if err != nil {
 		return bosherr.WrapError(err, "Removing all jobs")
 	}
@@ -93,12 +167,14 @@ func (a *concreteApplier) Apply(desiredApplySpec as.ApplySpec) error {
 		}
 	}
 
-	err = a.jobApplier.DeleteSourceBlobs(desiredApplySpec.Jobs())
-	if err != nil {
-		return bosherr.WrapError(err, "Failed removing job source blobs")
+	if deleteSourceBlobs {
+		err = a.jobApplier.DeleteSourceBlobs(desiredApplySpec.Jobs())
+		if err != nil {
+			return bosherr.WrapError(err, "Failed removing job source blobs")
+		}
 	}
 
-	err = a.jobApplier.KeepOnly(desiredApplySpec.Jobs())
+	err = a.jobApplier.KeepOnlyRetaining(desiredApplySpec.Jobs(), retainedJobs)
 	if err != nil {
 		return bosherr.WrapError(err, "Keeping only needed jobs")
 	}
@@ -110,7 +186,7 @@ func (a *concreteApplier) Apply(desiredApplySpec as.ApplySpec) error {
 		}
 	}
 
-	err = a.packageApplier.KeepOnly(desiredApplySpec.Packages())
+	err = a.packageApplier.KeepOnlyRetaining(desiredApplySpec.Packages(), retainedPackages)
 	if err != nil {
 		return bosherr.WrapError(err, "Keeping only needed packages")
 	}
@@ -123,6 +199,61 @@ func (a *concreteApplier) Apply(desiredApplySpec as.ApplySpec) error {
 	return a.setUpLogrotate(desiredApplySpec)
 }
 
+// Rollback enables the jobs and packages of a previously applied spec and
+// configures its jobs. It only uses installed jobs and packages and fails
+// before changing anything when one of them is no longer installed. The
+// jobs and packages of the current spec stay installed until the next
+// apply, and the current spec is enabled again when the rollback fails
+// partway.
+func (a *concreteApplier) Rollback(currentApplySpec, previousApplySpec as.ApplySpec) error {
+	for _, job := range previousApplySpec.Jobs() {
+		installed, err := a.jobApplier.IsInstalled(job)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Checking if job %s is installed", job.Name)
+		}
+
+		if !installed {
+			return bosherr.Errorf("Job %s %s is no longer installed", job.Name, job.Version)
+		}
+
+		for _, pkg := range job.Packages {
+			err = a.checkPackageInstalled(pkg)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, pkg := range previousApplySpec.Packages() {
+		err := a.checkPackageInstalled(pkg)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := a.enable(previousApplySpec, currentApplySpec)
+	if err == nil {
+		return nil
+	}
+
+	restoreErr := a.enable(currentApplySpec, previousApplySpec)
+	if restoreErr != nil {
+		return bosherr.NewMultiError(err, bosherr.WrapError(restoreErr, "Restoring current spec"))
+	}
+
+	return bosherr.WrapError(err, "Restored current spec")
+}
+
+// enable applies and configures a spec whose jobs and packages are installed
+func (a *concreteApplier) enable(applySpec as.ApplySpec, keptApplySpec as.ApplySpec) error {
+	err := a.apply(applySpec, false, keptApplySpec)
+	if err != nil {
+		return err
+	}
+
+	return a.ConfigureJobs(applySpec)
+}
+
 func (a *concreteApplier) ConfigureJobs(desiredApplySpec as.ApplySpec) error {
 	jobs := desiredApplySpec.Jobs()
 	for i := 0; i < len(jobs); i++ {
@@ -154,3 +285,39 @@ func (a *concreteApplier) setUpLogrotate(applySpec as.ApplySpec) error {
 
 	return nil
 }
+
+func (a *concreteApplier) checkPackageInstalled(pkg models.Package) error {
+	installed, err := a.packageApplier.IsInstalled(pkg)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Checking if package %s is installed", pkg.Name)
+	}
+
+	if !installed {
+		return bosherr.Errorf("Package %s %s is no longer installed", pkg.Name, pkg.Version)
+	}
+
+	return nil
+}
+
+// retained returns the jobs and packages of the previous and the kept specs
+func (a *concreteApplier) retained(keptApplySpecs ...as.ApplySpec) ([]models.Job, []models.Package, error) {
+	var jobs []models.Job
+	var packages []models.Package
+
+	for _, spec := range keptApplySpecs {
+		jobs = append(jobs, spec.Jobs()...)
+		packages = append(packages, spec.Packages()...)
+	}
+
+	specs, err := a.specHistory.List()
+	if err != nil {
+		return jobs, packages, bosherr.WrapError(err, "Listing previous apply specs")
+	}
+
+	for _, spec := range specs {
+		jobs = append(jobs, spec.Jobs()...)
+		packages = append(packages, spec.Packages()...)
+	}
+
+	return jobs, packages, nil
+}
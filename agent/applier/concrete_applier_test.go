@@ -3,6 +3,7 @@ package applier_test
 import (
 	"errors"
 	"path/filepath"
+	"sync"
 
 	"github.com/stretchr/testify/assert"
 
@@ -10,6 +11,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/applier"
+	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	fakejobs "github.com/cloudfoundry/bosh-agent/agent/applier/jobs/jobsfakes"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
@@ -57,6 +59,7 @@ var _ = Describe("concreteApplier", func() {
 		packageApplier    *fakepackages.FakeApplier
 		logRotateDelegate *FakeLogRotateDelegate
 		jobSupervisor     *fakejobsuper.FakeJobSupervisor
+		specHistory       *fakeas.FakeSpecHistory
 		applier           Applier
 		settingsService   boshsettings.Service
 	)
@@ -67,6 +70,7 @@ var _ = Describe("concreteApplier", func() {
 		logRotateDelegate = &FakeLogRotateDelegate{}
 		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
 		settingsService = &fakesettings.FakeSettingsService{}
+		specHistory = fakeas.NewFakeSpecHistory()
 		applier = NewConcreteApplier(
 			jobApplier,
 			packageApplier,
@@ -74,6 +78,7 @@ var _ = Describe("concreteApplier", func() {
 			jobSupervisor,
 			boshdirs.NewProvider("/fake-base-dir"),
 			settingsService.GetSettings(),
+			specHistory,
 		)
 	})
 
@@ -124,6 +129,69 @@ var _ = Describe("concreteApplier", func() {
 			Expect(err.Error()).To(ContainSubstring("fake-prepare-package-error"))
 		})
 
+		It("prepares up to the configured number of jobs and packages at a time", func() {
+			parallel := 2
+			settings := boshsettings.Settings{Env: boshsettings.Env{Bosh: boshsettings.BoshEnv{Parallel: &parallel}}}
+			applier = NewConcreteApplier(jobApplier, packageApplier, logRotateDelegate, jobSupervisor, boshdirs.NewProvider("/fake-base-dir"), settings, specHistory)
+
+			lock := &sync.Mutex{}
+			inFlight := 0
+			release := make(chan struct{})
+
+			packageApplier.PrepareStub = func(models.Package) error {
+				lock.Lock()
+				inFlight++
+				lock.Unlock()
+
+				<-release
+
+				lock.Lock()
+				inFlight--
+				lock.Unlock()
+				return nil
+			}
+
+			getInFlight := func() int {
+				lock.Lock()
+				defer lock.Unlock()
+				return inFlight
+			}
+
+			errCh := make(chan error)
+			go func() {
+				errCh <- applier.Prepare(&fakeas.FakeApplySpec{
+					PackageResults: []models.Package{buildPackage(), buildPackage(), buildPackage(), buildPackage()},
+				})
+			}()
+
+			Eventually(getInFlight).Should(Equal(2))
+			Consistently(getInFlight, "50ms").Should(Equal(2))
+
+			close(release)
+			Expect(<-errCh).To(Succeed())
+			Expect(packageApplier.PreparedPackages).To(HaveLen(4))
+		})
+
+		It("reports the errors in the order of the packages in the spec", func() {
+			pkg1 := buildPackage()
+			pkg2 := buildPackage()
+			pkg2Failed := make(chan struct{})
+
+			packageApplier.PrepareStub = func(pkg models.Package) error {
+				if pkg.Name == pkg2.Name {
+					close(pkg2Failed)
+					return errors.New("fake-prepare-package-2-error")
+				}
+
+				<-pkg2Failed
+				return errors.New("fake-prepare-package-1-error")
+			}
+
+			err := applier.Prepare(&fakeas.FakeApplySpec{PackageResults: []models.Package{pkg1, pkg2}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(MatchRegexp(`(?s)fake-prepare-package-1-error.*fake-prepare-package-2-error`))
+		})
+
 		It("deletes the job source from the blobstore after preparing", func() {
 			job := buildJob()
 
@@ -195,6 +263,31 @@ var _ = Describe("concreteApplier", func() {
 
 		})
 
+		It("prepares jobs and packages before removing all jobs from job supervisor", func() {
+			job := buildJob()
+			pkg := buildPackage()
+
+			jobApplier.PrepareStub = func(models.Job) error {
+				Expect(jobSupervisor.RemovedAllJobs).To(BeFalse())
+				return nil
+			}
+
+			err := applier.Apply(&fakeas.FakeApplySpec{JobResults: []models.Job{job}, PackageResults: []models.Package{pkg}})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(jobApplier.PrepareCallCount()).To(Equal(1))
+			Expect(packageApplier.PreparedPackages).To(Equal([]models.Package{pkg}))
+		})
+
+		It("does not remove jobs from job supervisor when preparing fails", func() {
+			jobApplier.PrepareReturns(errors.New("fake-prepare-job-error"))
+
+			err := applier.Apply(&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-prepare-job-error"))
+			Expect(jobSupervisor.RemovedAllJobs).To(BeFalse())
+		})
+
 		It("returns error if removing all jobs from job supervisor fails", func() {
 			jobSupervisor.RemovedAllJobsErr = errors.New("fake-remove-all-jobs-error")
 
@@ -231,12 +324,43 @@ var _ = Describe("concreteApplier", func() {
 
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(jobApplier.KeepOnlyCallCount()).To(Equal(1))
-			Expect(jobApplier.KeepOnlyArgsForCall(0)).To(Equal([]models.Job{desiredJob}))
+			Expect(jobApplier.KeepOnlyRetainingCallCount()).To(Equal(1))
+			jobs, retainedJobs := jobApplier.KeepOnlyRetainingArgsForCall(0)
+			Expect(jobs).To(Equal([]models.Job{desiredJob}))
+			Expect(retainedJobs).To(BeEmpty())
+		})
+
+		It("asked jobApplier and packageApplier to retain the jobs and packages of the previous specs", func() {
+			previousSpec := boshas.V1ApplySpec{
+				JobSpec: boshas.JobSpec{
+					JobTemplateSpecs: []boshas.JobTemplateSpec{{Name: "fake-previous-job", Version: "fake-previous-version"}},
+				},
+				PackageSpecs: map[string]boshas.PackageSpec{
+					"fake-previous-package": {Name: "fake-previous-package", Version: "fake-previous-version"},
+				},
+				RenderedTemplatesArchiveSpec: &boshas.RenderedTemplatesArchiveSpec{},
+			}
+			specHistory.Specs = []boshas.V1ApplySpec{previousSpec}
+
+			err := applier.Apply(&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, retainedJobs := jobApplier.KeepOnlyRetainingArgsForCall(0)
+			Expect(retainedJobs).To(Equal(previousSpec.Jobs()))
+			Expect(packageApplier.RetainedPackages).To(Equal(previousSpec.Packages()))
+		})
+
+		It("returns error when the previous specs cannot be listed", func() {
+			specHistory.ListErr = errors.New("fake-list-error")
+
+			err := applier.Apply(&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-list-error"))
+			Expect(jobSupervisor.RemovedAllJobs).To(BeFalse())
 		})
 
 		It("returns error when jobApplier fails to keep only the jobs in the desired specs", func() {
-			jobApplier.KeepOnlyReturns(errors.New("fake-keep-only-error"))
+			jobApplier.KeepOnlyRetainingReturns(errors.New("fake-keep-only-error"))
 
 			desiredJob := buildJob()
 
@@ -344,4 +468,112 @@ var _ = Describe("concreteApplier", func() {
 			Expect(jobApplier.DeleteSourceBlobsArgsForCall(0)).To(Equal([]models.Job{job}))
 		})
 	})
+
+	Describe("Rollback", func() {
+		var (
+			job        models.Job
+			pkg        models.Package
+			currentJob models.Job
+			currentPkg models.Package
+			previous   *fakeas.FakeApplySpec
+			current    *fakeas.FakeApplySpec
+		)
+
+		BeforeEach(func() {
+			pkg = buildPackage()
+			job = buildJob()
+			job.Packages = []models.Package{pkg}
+			previous = &fakeas.FakeApplySpec{JobResults: []models.Job{job}, PackageResults: []models.Package{pkg}}
+
+			currentPkg = buildPackage()
+			currentJob = buildJob()
+			currentJob.Packages = []models.Package{currentPkg}
+			current = &fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}, PackageResults: []models.Package{currentPkg}}
+
+			jobApplier.IsInstalledReturns(true, nil)
+		})
+
+		It("applies and configures the previous spec", func() {
+			err := applier.Rollback(current, previous)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(jobSupervisor.RemovedAllJobs).To(BeTrue())
+			Expect(jobApplier.ApplyCallCount()).To(Equal(1))
+			Expect(jobApplier.ApplyArgsForCall(0)).To(Equal(job))
+			Expect(packageApplier.AppliedPackages).To(Equal([]models.Package{pkg}))
+			Expect(jobApplier.ConfigureCallCount()).To(Equal(1))
+			Expect(jobSupervisor.Reloaded).To(BeTrue())
+		})
+
+		It("keeps the jobs and packages of the current spec installed", func() {
+			err := applier.Rollback(current, previous)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, retainedJobs := jobApplier.KeepOnlyRetainingArgsForCall(0)
+			Expect(retainedJobs).To(ContainElement(currentJob))
+			Expect(packageApplier.RetainedPackages).To(ContainElement(currentPkg))
+		})
+
+		It("applies and configures the current spec again when enabling the previous spec fails", func() {
+			jobApplier.ApplyReturnsOnCall(0, errors.New("fake-apply-job-error"))
+
+			err := applier.Rollback(current, previous)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Restored current spec"))
+			Expect(err.Error()).To(ContainSubstring("fake-apply-job-error"))
+
+			Expect(jobApplier.ApplyCallCount()).To(Equal(2))
+			Expect(jobApplier.ApplyArgsForCall(1)).To(Equal(currentJob))
+			Expect(packageApplier.AppliedPackages).To(Equal([]models.Package{currentPkg}))
+			Expect(jobApplier.ConfigureCallCount()).To(Equal(1))
+			configuredJob, _ := jobApplier.ConfigureArgsForCall(0)
+			Expect(configuredJob).To(Equal(currentJob))
+		})
+
+		It("returns both errors when the current spec cannot be enabled again", func() {
+			jobApplier.ApplyReturns(errors.New("fake-apply-job-error"))
+
+			err := applier.Rollback(current, previous)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Applying job " + job.Name))
+			Expect(err.Error()).To(ContainSubstring("Restoring current spec: Applying job " + currentJob.Name))
+		})
+
+		It("does not delete the job sources from the blobstore", func() {
+			err := applier.Rollback(current, previous)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(jobApplier.DeleteSourceBlobsCallCount()).To(Equal(0))
+		})
+
+		It("does not change anything when a job is no longer installed", func() {
+			jobApplier.IsInstalledReturns(false, nil)
+
+			err := applier.Rollback(current, previous)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is no longer installed"))
+
+			Expect(jobSupervisor.RemovedAllJobs).To(BeFalse())
+			Expect(jobApplier.ApplyCallCount()).To(Equal(0))
+		})
+
+		It("does not change anything when a package is no longer installed", func() {
+			packageApplier.NotInstalledPackages = []models.Package{pkg}
+
+			err := applier.Rollback(current, previous)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Package " + pkg.Name))
+
+			Expect(jobSupervisor.RemovedAllJobs).To(BeFalse())
+			Expect(packageApplier.AppliedPackages).To(BeEmpty())
+		})
+
+		It("returns error when checking if a job is installed fails", func() {
+			jobApplier.IsInstalledReturns(false, errors.New("fake-is-installed-error"))
+
+			err := applier.Rollback(current, previous)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-is-installed-error"))
+		})
+	})
 })
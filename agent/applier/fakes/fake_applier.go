@@ -14,6 +14,11 @@ type FakeApplier struct {
 	ApplyDesiredApplySpec boshas.ApplySpec
 	ApplyError            error
 
+	RolledBack                bool
+	RollbackCurrentApplySpec  boshas.ApplySpec
+	RollbackPreviousApplySpec boshas.ApplySpec
+	RollbackError             error
+
 	Configured                 bool
 	ConfiguredDesiredApplySpec boshas.ApplySpec
 	ConfiguredJobs             []models.Job
@@ -41,3 +46,10 @@ func (s *FakeApplier) Apply(desiredApplySpec boshas.ApplySpec) error {
 	s.ApplyDesiredApplySpec = desiredApplySpec
 	return s.ApplyError
 }
+
+func (s *FakeApplier) Rollback(currentApplySpec, previousApplySpec boshas.ApplySpec) error {
+	s.RolledBack = true
+	s.RollbackCurrentApplySpec = currentApplySpec
+	s.RollbackPreviousApplySpec = previousApplySpec
+	return s.RollbackError
+}
@@ -8,8 +8,10 @@ import (
 
 type Applier interface {
 	Prepare(job models.Job) error
+	IsInstalled(job models.Job) (bool, error)
 	Apply(job models.Job) error
 	Configure(job models.Job, jobIndex int) error
 	KeepOnly(jobs []models.Job) error
+	KeepOnlyRetaining(jobs []models.Job, retainedJobs []models.Job) error
 	DeleteSourceBlobs(jobs []models.Job) error
 }
@@ -43,6 +43,19 @@ type FakeApplier struct {
 	deleteSourceBlobsReturnsOnCall map[int]struct {
 		result1 error
 	}
+	IsInstalledStub        func(models.Job) (bool, error)
+	isInstalledMutex       sync.RWMutex
+	isInstalledArgsForCall []struct {
+		arg1 models.Job
+	}
+	isInstalledReturns struct {
+		result1 bool
+		result2 error
+	}
+	isInstalledReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
 	KeepOnlyStub        func([]models.Job) error
 	keepOnlyMutex       sync.RWMutex
 	keepOnlyArgsForCall []struct {
@@ -54,6 +67,18 @@ type FakeApplier struct {
 	keepOnlyReturnsOnCall map[int]struct {
 		result1 error
 	}
+	KeepOnlyRetainingStub        func([]models.Job, []models.Job) error
+	keepOnlyRetainingMutex       sync.RWMutex
+	keepOnlyRetainingArgsForCall []struct {
+		arg1 []models.Job
+		arg2 []models.Job
+	}
+	keepOnlyRetainingReturns struct {
+		result1 error
+	}
+	keepOnlyRetainingReturnsOnCall map[int]struct {
+		result1 error
+	}
 	PrepareStub        func(models.Job) error
 	prepareMutex       sync.RWMutex
 	prepareArgsForCall []struct {
@@ -258,6 +283,70 @@ func (fake *FakeApplier) DeleteSourceBlobsReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeApplier) IsInstalled(arg1 models.Job) (bool, error) {
+	fake.isInstalledMutex.Lock()
+	ret, specificReturn := fake.isInstalledReturnsOnCall[len(fake.isInstalledArgsForCall)]
+	fake.isInstalledArgsForCall = append(fake.isInstalledArgsForCall, struct {
+		arg1 models.Job
+	}{arg1})
+	stub := fake.IsInstalledStub
+	fakeReturns := fake.isInstalledReturns
+	fake.recordInvocation("IsInstalled", []interface{}{arg1})
+	fake.isInstalledMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeApplier) IsInstalledCallCount() int {
+	fake.isInstalledMutex.RLock()
+	defer fake.isInstalledMutex.RUnlock()
+	return len(fake.isInstalledArgsForCall)
+}
+
+func (fake *FakeApplier) IsInstalledCalls(stub func(models.Job) (bool, error)) {
+	fake.isInstalledMutex.Lock()
+	defer fake.isInstalledMutex.Unlock()
+	fake.IsInstalledStub = stub
+}
+
+func (fake *FakeApplier) IsInstalledArgsForCall(i int) models.Job {
+	fake.isInstalledMutex.RLock()
+	defer fake.isInstalledMutex.RUnlock()
+	argsForCall := fake.isInstalledArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeApplier) IsInstalledReturns(result1 bool, result2 error) {
+	fake.isInstalledMutex.Lock()
+	defer fake.isInstalledMutex.Unlock()
+	fake.IsInstalledStub = nil
+	fake.isInstalledReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeApplier) IsInstalledReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.isInstalledMutex.Lock()
+	defer fake.isInstalledMutex.Unlock()
+	fake.IsInstalledStub = nil
+	if fake.isInstalledReturnsOnCall == nil {
+		fake.isInstalledReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.isInstalledReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeApplier) KeepOnly(arg1 []models.Job) error {
 	var arg1Copy []models.Job
 	if arg1 != nil {
@@ -324,6 +413,78 @@ func (fake *FakeApplier) KeepOnlyReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeApplier) KeepOnlyRetaining(arg1 []models.Job, arg2 []models.Job) error {
+	var arg1Copy []models.Job
+	if arg1 != nil {
+		arg1Copy = make([]models.Job, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	var arg2Copy []models.Job
+	if arg2 != nil {
+		arg2Copy = make([]models.Job, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.keepOnlyRetainingMutex.Lock()
+	ret, specificReturn := fake.keepOnlyRetainingReturnsOnCall[len(fake.keepOnlyRetainingArgsForCall)]
+	fake.keepOnlyRetainingArgsForCall = append(fake.keepOnlyRetainingArgsForCall, struct {
+		arg1 []models.Job
+		arg2 []models.Job
+	}{arg1Copy, arg2Copy})
+	stub := fake.KeepOnlyRetainingStub
+	fakeReturns := fake.keepOnlyRetainingReturns
+	fake.recordInvocation("KeepOnlyRetaining", []interface{}{arg1Copy, arg2Copy})
+	fake.keepOnlyRetainingMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeApplier) KeepOnlyRetainingCallCount() int {
+	fake.keepOnlyRetainingMutex.RLock()
+	defer fake.keepOnlyRetainingMutex.RUnlock()
+	return len(fake.keepOnlyRetainingArgsForCall)
+}
+
+func (fake *FakeApplier) KeepOnlyRetainingCalls(stub func([]models.Job, []models.Job) error) {
+	fake.keepOnlyRetainingMutex.Lock()
+	defer fake.keepOnlyRetainingMutex.Unlock()
+	fake.KeepOnlyRetainingStub = stub
+}
+
+func (fake *FakeApplier) KeepOnlyRetainingArgsForCall(i int) ([]models.Job, []models.Job) {
+	fake.keepOnlyRetainingMutex.RLock()
+	defer fake.keepOnlyRetainingMutex.RUnlock()
+	argsForCall := fake.keepOnlyRetainingArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeApplier) KeepOnlyRetainingReturns(result1 error) {
+	fake.keepOnlyRetainingMutex.Lock()
+	defer fake.keepOnlyRetainingMutex.Unlock()
+	fake.KeepOnlyRetainingStub = nil
+	fake.keepOnlyRetainingReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeApplier) KeepOnlyRetainingReturnsOnCall(i int, result1 error) {
+	fake.keepOnlyRetainingMutex.Lock()
+	defer fake.keepOnlyRetainingMutex.Unlock()
+	fake.KeepOnlyRetainingStub = nil
+	if fake.keepOnlyRetainingReturnsOnCall == nil {
+		fake.keepOnlyRetainingReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.keepOnlyRetainingReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeApplier) Prepare(arg1 models.Job) error {
 	fake.prepareMutex.Lock()
 	ret, specificReturn := fake.prepareReturnsOnCall[len(fake.prepareArgsForCall)]
@@ -394,8 +555,12 @@ func (fake *FakeApplier) Invocations() map[string][][]interface{} {
 	defer fake.configureMutex.RUnlock()
 	fake.deleteSourceBlobsMutex.RLock()
 	defer fake.deleteSourceBlobsMutex.RUnlock()
+	fake.isInstalledMutex.RLock()
+	defer fake.isInstalledMutex.RUnlock()
 	fake.keepOnlyMutex.RLock()
 	defer fake.keepOnlyMutex.RUnlock()
+	fake.keepOnlyRetainingMutex.RLock()
+	defer fake.keepOnlyRetainingMutex.RUnlock()
 	fake.prepareMutex.RLock()
 	defer fake.prepareMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
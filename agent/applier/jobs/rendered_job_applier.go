@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"encoding/json"
 	"fmt"
 	"path"
 	"strings"
@@ -12,6 +13,7 @@ import (
 
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -78,6 +80,15 @@ func (s renderedJobApplier) Prepare(job models.Job) error {
 	return nil
 }
 
+func (s renderedJobApplier) IsInstalled(job models.Job) (bool, error) {
+	jobBundle, err := s.jobsBc.Get(job)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Getting job bundle")
+	}
+
+	return jobBundle.IsInstalled()
+}
+
 func (s *renderedJobApplier) Apply(job models.Job) error {
 	s.logger.Debug(logTag, "Applying job %v", job)
 
@@ -104,18 +115,20 @@ func (s *renderedJobApplier) Apply(job models.Job) error {
 }
 
 func (s *renderedJobApplier) downloadAndInstall(job models.Job, jobBundle boshbc.Bundle) error {
-	file, err := s.blobstore.Get(boshcrypto.MustNewMultipleDigest(job.Source.Sha1), job.Source.SignedURL, job.Source.BlobstoreID, job.Source.BlobstoreHeaders)
+	digest := boshcrypto.MustNewMultipleDigest(job.Source.Sha1)
+
+	blob, err := s.blobstore.Open(digest, job.Source.SignedURL, job.Source.BlobstoreID, job.Source.BlobstoreHeaders)
 	if err != nil {
 		return bosherr.WrapError(err, "Getting job source from blobstore")
 	}
 
 	defer func() {
-		if err = s.blobstore.CleanUp("", file); err != nil {
-			s.logger.Warn(logTag, "Failed to clean up blobstore blob: %s", err.Error())
+		if err = blob.Close(); err != nil {
+			s.logger.Warn(logTag, "Failed to close job source blob: %s", err.Error())
 		}
 	}()
 
-	_, err = jobBundle.Install(file, job.Source.PathInArchive)
+	_, err = jobBundle.Install(blob, digest, job.Source.PathInArchive)
 	if err != nil {
 		return bosherr.WrapError(err, "Installing job bundle")
 	}
@@ -168,9 +181,31 @@ func (s *renderedJobApplier) Configure(job models.Job, jobIndex int) (err error)
 		return
 	}
 
+	limits, err := s.resourceLimits(job, jobDir)
+	if err != nil {
+		return
+	}
+
+	err = s.jobSupervisor.SetResourceLimits(job.Name, limits)
+	if err != nil {
+		err = bosherr.WrapError(err, "Setting resource limits")
+		return
+	}
+
+	dependencies, err := s.startDependencies(jobDir)
+	if err != nil {
+		return
+	}
+
+	err = s.jobSupervisor.SetStartDependencies(job.Name, dependencies.StartAfter)
+	if err != nil {
+		err = bosherr.WrapError(err, "Setting start dependencies")
+		return
+	}
+
 	monitFilePath := path.Join(jobDir, "monit")
 	if s.fs.FileExists(monitFilePath) {
-		err = s.jobSupervisor.AddJob(job.Name, jobIndex, monitFilePath)
+		err = s.jobSupervisor.AddJob(job.Name, job.Name, jobIndex, monitFilePath)
 		if err != nil {
 			err = bosherr.WrapError(err, "Adding monit configuration")
 			return
@@ -187,7 +222,7 @@ func (s *renderedJobApplier) Configure(job models.Job, jobIndex int) (err error)
 		label := strings.Replace(path.Base(monitFilePath), ".monit", "", 1)
 		subJobName := fmt.Sprintf("%s_%s", job.Name, label)
 
-		err = s.jobSupervisor.AddJob(subJobName, jobIndex, monitFilePath)
+		err = s.jobSupervisor.AddJob(job.Name, subJobName, jobIndex, monitFilePath)
 		if err != nil {
 			err = bosherr.WrapErrorf(err, "Adding additional monit configuration %s", label)
 			return
@@ -197,8 +232,36 @@ func (s *renderedJobApplier) Configure(job models.Job, jobIndex int) (err error)
 	return nil
 }
 
+// resourceLimits returns the limits shipped with the job
+// overridden by the limits from the apply spec
+func (s *renderedJobApplier) resourceLimits(job models.Job, jobDir string) (cgroup.Limits, error) {
+	var limits cgroup.Limits
+
+	resourcesFilePath := path.Join(jobDir, "resources.json")
+	if s.fs.FileExists(resourcesFilePath) {
+		contents, err := s.fs.ReadFile(resourcesFilePath)
+		if err != nil {
+			return limits, bosherr.WrapError(err, "Reading resource limits")
+		}
+
+		err = json.Unmarshal(contents, &limits)
+		if err != nil {
+			return limits, bosherr.WrapError(err, "Unmarshalling resource limits")
+		}
+	}
+
+	return limits.Merge(job.ResourceLimits), nil
+}
+
 func (s *renderedJobApplier) KeepOnly(jobs []models.Job) error {
-	s.logger.Debug(logTag, "Keeping only jobs %v", jobs)
+	return s.KeepOnlyRetaining(jobs, nil)
+}
+
+// KeepOnlyRetaining disables all jobs but the given jobs and uninstalls all
+// jobs but the given and the retained jobs, so that retained jobs can be
+// enabled again without downloading them.
+func (s *renderedJobApplier) KeepOnlyRetaining(jobs []models.Job, retainedJobs []models.Job) error {
+	s.logger.Debug(logTag, "Keeping only jobs %v retaining %v", jobs, retainedJobs)
 
 	installedBundles, err := s.jobsBc.List()
 	if err != nil {
@@ -206,39 +269,56 @@ func (s *renderedJobApplier) KeepOnly(jobs []models.Job) error {
 	}
 
 	for _, installedBundle := range installedBundles {
-		var shouldKeep bool
-
-		for _, job := range jobs {
-			jobBundle, err := s.jobsBc.Get(job)
-			if err != nil {
-				return bosherr.WrapError(err, "Getting job bundle")
-			}
-
-			if jobBundle == installedBundle {
-				shouldKeep = true
-				break
-			}
+		shouldKeep, err := s.containsBundle(jobs, installedBundle)
+		if err != nil {
+			return err
 		}
 
-		if !shouldKeep {
-			err = installedBundle.Disable()
-			if err != nil {
-				return bosherr.WrapError(err, "Disabling job bundle")
-			}
-
-			// If we uninstall the bundle first, and the disable failed (leaving the symlink),
-			// then the next time bundle collection will not include bundle in its list
-			// which means that symlink will never be deleted.
-			err = installedBundle.Uninstall()
-			if err != nil {
-				return bosherr.WrapError(err, "Uninstalling job bundle")
-			}
+		if shouldKeep {
+			continue
+		}
+
+		err = installedBundle.Disable()
+		if err != nil {
+			return bosherr.WrapError(err, "Disabling job bundle")
+		}
+
+		shouldRetain, err := s.containsBundle(retainedJobs, installedBundle)
+		if err != nil {
+			return err
+		}
+
+		if shouldRetain {
+			continue
+		}
+
+		// If we uninstall the bundle first, and the disable failed (leaving the symlink),
+		// then the next time bundle collection will not include bundle in its list
+		// which means that symlink will never be deleted.
+		err = installedBundle.Uninstall()
+		if err != nil {
+			return bosherr.WrapError(err, "Uninstalling job bundle")
 		}
 	}
 
 	return nil
 }
 
+func (s *renderedJobApplier) containsBundle(jobs []models.Job, bundle boshbc.Bundle) (bool, error) {
+	for _, job := range jobs {
+		jobBundle, err := s.jobsBc.Get(job)
+		if err != nil {
+			return false, bosherr.WrapError(err, "Getting job bundle")
+		}
+
+		if jobBundle == bundle {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 func (s *renderedJobApplier) DeleteSourceBlobs(jobs []models.Job) error {
 	deletedBlobs := map[string]bool{}
 
@@ -257,3 +337,30 @@ func (s *renderedJobApplier) DeleteSourceBlobs(jobs []models.Job) error {
 
 	return nil
 }
+
+// jobDependencies is shipped by jobs as dependencies.json
+type jobDependencies struct {
+	// Colocated jobs that have to be running before the job is started
+	StartAfter []string `json:"start_after"`
+}
+
+func (s *renderedJobApplier) startDependencies(jobDir string) (jobDependencies, error) {
+	var dependencies jobDependencies
+
+	dependenciesFilePath := path.Join(jobDir, "dependencies.json")
+	if !s.fs.FileExists(dependenciesFilePath) {
+		return dependencies, nil
+	}
+
+	contents, err := s.fs.ReadFile(dependenciesFilePath)
+	if err != nil {
+		return dependencies, bosherr.WrapError(err, "Reading start dependencies")
+	}
+
+	err = json.Unmarshal(contents, &dependencies)
+	if err != nil {
+		return dependencies, bosherr.WrapError(err, "Unmarshalling start dependencies")
+	}
+
+	return dependencies, nil
+}
@@ -4,12 +4,14 @@ import (
 	"errors"
 	"io"
 	"os"
+	"strings"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/jobs"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 	"github.com/cloudfoundry/bosh-agent/settings/directories"
 
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
@@ -34,6 +36,7 @@ var _ = Describe("renderedJobApplier", func() {
 		fs                     *fakesys.FakeFileSystem
 		applier                Applier
 		fixPermissions         *fakeFixer
+		blob                   *fakeBlob
 	)
 
 	BeforeEach(func() {
@@ -41,6 +44,8 @@ var _ = Describe("renderedJobApplier", func() {
 		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
 		packageApplierProvider = fakepackages.NewFakeApplierProvider()
 		blobstore = &fakeblobdelegator.FakeBlobstoreDelegator{}
+		blob = &fakeBlob{Reader: strings.NewReader("fake-blob")}
+		blobstore.OpenReturns(blob, nil)
 		fs = fakesys.NewFakeFileSystem()
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 		dirProvider := directories.NewProvider("/fakebasedir")
@@ -82,24 +87,21 @@ var _ = Describe("renderedJobApplier", func() {
 				Expect(err.Error()).To(ContainSubstring("fake-install-error"))
 			})
 
-			It("downloads and later cleans up downloaded job template blob", func() {
-				blobstore.GetReturns("/fake-blobstore-file-name", nil)
-
+			It("downloads and later closes the job template blob", func() {
 				err := act()
 				Expect(err).ToNot(HaveOccurred())
-				fingerPrint, signedURL, blobID, headers := blobstore.GetArgsForCall(0)
+				fingerPrint, signedURL, blobID, headers := blobstore.OpenArgsForCall(0)
 				Expect(blobID).To(Equal("fake-blobstore-id"))
 				Expect(signedURL).To(Equal("/fake/signed/url"))
 				Expect(headers).To(Equal(map[string]string{"key": "value"}))
 				Expect(fingerPrint).To(Equal(boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-blob-sha1"))))
 
-				// downloaded file is cleaned up
-				_, cleanupArg := blobstore.CleanUpArgsForCall(0)
-				Expect(cleanupArg).To(Equal("/fake-blobstore-file-name"))
+				// downloaded blob is closed
+				Expect(blob.closed).To(BeTrue())
 			})
 
 			It("returns error when downloading job template blob fails", func() {
-				blobstore.GetReturns("", errors.New("fake-get-error"))
+				blobstore.OpenReturns(nil, errors.New("fake-get-error"))
 
 				err := act()
 				Expect(err).To(HaveOccurred())
@@ -107,12 +109,11 @@ var _ = Describe("renderedJobApplier", func() {
 			})
 
 			It("can process sha1 checksums in the new format", func() {
-				blobstore.GetReturns("/fake-blobstore-file-name", nil)
 				job.Source.Sha1 = boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "sha1:fake-blob-sha1")
 
 				err := act()
 				Expect(err).ToNot(HaveOccurred())
-				fingerPrint, signedURL, blobID, headers := blobstore.GetArgsForCall(0)
+				fingerPrint, signedURL, blobID, headers := blobstore.OpenArgsForCall(0)
 				Expect(blobID).To(Equal("fake-blobstore-id"))
 				Expect(signedURL).To(Equal("/fake/signed/url"))
 				Expect(headers).To(Equal(map[string]string{"key": "value"}))
@@ -120,26 +121,23 @@ var _ = Describe("renderedJobApplier", func() {
 			})
 
 			It("can process sha2 checksums", func() {
-				blobstore.GetReturns("/fake-blobstore-file-name", nil)
 				job.Source.Sha1 = boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA256, "sha256:fake-blob-sha256")
 
 				err := act()
 				Expect(err).ToNot(HaveOccurred())
-				fingerPrint, signedURL, blobID, headers := blobstore.GetArgsForCall(0)
+				fingerPrint, signedURL, blobID, headers := blobstore.OpenArgsForCall(0)
 				Expect(blobID).To(Equal("fake-blobstore-id"))
 				Expect(signedURL).To(Equal("/fake/signed/url"))
 				Expect(headers).To(Equal(map[string]string{"key": "value"}))
 				Expect(fingerPrint).To(Equal(boshcrypto.MustNewMultipleDigest(job.Source.Sha1)))
 			})
 
-			It("installs bundle from decompressed tmp path of a job template", func() {
-				blobstore.GetReturns("/fake-blobstore-file-name", nil)
-
+			It("installs bundle from the job template blob", func() {
 				err := act()
 				Expect(err).ToNot(HaveOccurred())
 
-				// make sure that bundle install happened after decompression
-				Expect(bundle.InstallSourcePath).To(Equal("/fake-blobstore-file-name"))
+				Expect(bundle.InstallSource).To(Equal(blob))
+				Expect(bundle.InstallDigest).To(Equal(boshcrypto.MustNewMultipleDigest(job.Source.Sha1)))
 				Expect(bundle.InstallPathInBundle).To(Equal("fake-path-in-archive"))
 			})
 
@@ -277,7 +275,7 @@ var _ = Describe("renderedJobApplier", func() {
 				It("does not download the job template", func() {
 					err := act()
 					Expect(err).ToNot(HaveOccurred())
-					Expect(blobstore.GetCallCount()).To(Equal(0))
+					Expect(blobstore.OpenCallCount()).To(Equal(0))
 				})
 			})
 
@@ -339,7 +337,7 @@ var _ = Describe("renderedJobApplier", func() {
 				It("does not download the job template", func() {
 					err := act()
 					Expect(err).ToNot(HaveOccurred())
-					Expect(blobstore.GetCallCount()).To(Equal(0))
+					Expect(blobstore.OpenCallCount()).To(Equal(0))
 				})
 
 				ItUpdatesPackages(act)
@@ -390,17 +388,92 @@ var _ = Describe("renderedJobApplier", func() {
 
 			Expect(jobSupervisor.AddJobArgs[0]).To(Equal(fakejobsuper.AddJobArgs{
 				Name:       job.Name,
+				ConfigName: job.Name,
 				Index:      0,
 				ConfigPath: "/path/to/job/monit",
 			}))
 
 			Expect(jobSupervisor.AddJobArgs[1]).To(Equal(fakejobsuper.AddJobArgs{
-				Name:       job.Name + "_subjob",
+				Name:       job.Name,
+				ConfigName: job.Name + "_subjob",
 				Index:      0,
 				ConfigPath: "/path/to/job/subjob.monit",
 			}))
 		})
 
+		It("sets the job's resource limits before adding the job", func() {
+			job, bundle := buildJob(jobsBc)
+			job.ResourceLimits = cgroup.Limits{MemoryMax: "1G"}
+
+			fs.WriteFileString("/path/to/job/resources.json", `{"cpu_weight": 50, "memory_max": "512M"}`)
+			bundle.GetDirPath = "/path/to/job"
+
+			err := applier.Configure(job, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(jobSupervisor.ResourceLimits).To(Equal(map[string]cgroup.Limits{
+				job.Name: {CPUWeight: 50, MemoryMax: "1G"},
+			}))
+		})
+
+		It("returns an error when the shipped resource limits are invalid", func() {
+			job, bundle := buildJob(jobsBc)
+
+			fs.WriteFileString("/path/to/job/resources.json", `{"cpu_weight": "lots"}`)
+			bundle.GetDirPath = "/path/to/job"
+
+			err := applier.Configure(job, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling resource limits"))
+		})
+
+		It("returns an error when the job supervisor fails to set resource limits", func() {
+			job, _ := buildJob(jobsBc)
+			jobSupervisor.SetResourceLimitsErr = errors.New("fake-limits-error")
+
+			err := applier.Configure(job, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Setting resource limits: fake-limits-error"))
+			Expect(jobSupervisor.AddJobArgs).To(BeEmpty())
+		})
+
+		It("sets the job's start dependencies before adding the job", func() {
+			job, bundle := buildJob(jobsBc)
+
+			fs.WriteFileString("/path/to/job/monit", "some conf")
+			fs.WriteFileString("/path/to/job/dependencies.json", `{"start_after": ["postgres", "proxy"]}`)
+			bundle.GetDirPath = "/path/to/job"
+
+			err := applier.Configure(job, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(jobSupervisor.StartDependencies).To(Equal(map[string][]string{
+				job.Name: {"postgres", "proxy"},
+			}))
+			Expect(jobSupervisor.AddJobArgs).To(HaveLen(1))
+		})
+
+		It("returns an error when the shipped start dependencies are invalid", func() {
+			job, bundle := buildJob(jobsBc)
+
+			fs.WriteFileString("/path/to/job/dependencies.json", `{"start_after": "postgres"}`)
+			bundle.GetDirPath = "/path/to/job"
+
+			err := applier.Configure(job, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling start dependencies"))
+		})
+
+		It("returns an error when the job supervisor fails to set start dependencies", func() {
+			job, _ := buildJob(jobsBc)
+			jobSupervisor.SetStartDependenciesErr = errors.New("fake-dependencies-error")
+
+			err := applier.Configure(job, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Setting start dependencies: fake-dependencies-error"))
+			Expect(jobSupervisor.AddJobArgs).To(BeEmpty())
+		})
+
 		It("does not require monit script", func() {
 			job, _ := buildJob(jobsBc)
 
@@ -410,6 +483,26 @@ var _ = Describe("renderedJobApplier", func() {
 		})
 	})
 
+	Describe("IsInstalled", func() {
+		It("returns whether the bundle of the job is installed", func() {
+			job, bundle := buildJob(jobsBc)
+
+			Expect(applier.IsInstalled(job)).To(BeFalse())
+
+			bundle.Installed = true
+			Expect(applier.IsInstalled(job)).To(BeTrue())
+		})
+
+		It("returns error when bundle collection cannot retrieve bundle for job", func() {
+			job, _ := buildJob(jobsBc)
+			jobsBc.GetErr = errors.New("fake-bc-get-error")
+
+			_, err := applier.IsInstalled(job)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-bc-get-error"))
+		})
+	})
+
 	Describe("KeepOnly", func() {
 		It("first disables and then uninstalls jobs that are not in keeponly list", func() {
 			_, bundle1 := buildJob(jobsBc)
@@ -428,6 +521,21 @@ var _ = Describe("renderedJobApplier", func() {
 			Expect(bundle4.ActionsCalled).To(Equal([]string{}))
 		})
 
+		It("disables but does not uninstall retained jobs", func() {
+			job1, bundle1 := buildJob(jobsBc)
+			job2, bundle2 := buildJob(jobsBc)
+			_, bundle3 := buildJob(jobsBc)
+
+			jobsBc.ListBundles = []boshbc.Bundle{bundle1, bundle2, bundle3}
+
+			err := applier.KeepOnlyRetaining([]models.Job{job2}, []models.Job{job1, job2})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(bundle1.ActionsCalled).To(Equal([]string{"Disable"}))
+			Expect(bundle2.ActionsCalled).To(Equal([]string{}))
+			Expect(bundle3.ActionsCalled).To(Equal([]string{"Disable", "Uninstall"}))
+		})
+
 		It("returns error when bundle collection fails to return list of installed bundles", func() {
 			jobsBc.ListErr = errors.New("fake-bc-list-error")
 
@@ -519,6 +627,16 @@ var _ = Describe("renderedJobApplier", func() {
 	})
 })
 
+type fakeBlob struct {
+	io.Reader
+	closed bool
+}
+
+func (b *fakeBlob) Close() error {
+	b.closed = true
+	return nil
+}
+
 type fakeFixer struct {
 	fakeFixError error
 
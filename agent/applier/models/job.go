@@ -1,8 +1,11 @@
 package models
 
 import (
-	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	"os"
+	"time"
+
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type Job struct {
@@ -13,6 +16,14 @@ type Job struct {
 	// Packages that this job depends on; however,
 	// currently it will contain packages from all jobs
 	Packages []Package
+
+	// Resource limits from the apply spec, which override
+	// the limits shipped in the job's resources.json
+	ResourceLimits cgroup.Limits
+
+	// Time the job's drain script may run before it is cancelled,
+	// zero when the apply spec does not set a deadline
+	DrainTimeout time.Duration
 }
 
 func (s Job) BundleName() string {
@@ -6,6 +6,8 @@ import (
 
 type Applier interface {
 	Prepare(pkg models.Package) error
+	IsInstalled(pkg models.Package) (bool, error)
 	Apply(pkg models.Package) error
 	KeepOnly(pkgs []models.Package) error
+	KeepOnlyRetaining(pkgs []models.Package, retainedPkgs []models.Package) error
 }
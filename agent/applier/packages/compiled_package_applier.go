@@ -61,6 +61,15 @@ func (s compiledPackageApplier) Prepare(pkg models.Package) error {
 	return nil
 }
 
+func (s compiledPackageApplier) IsInstalled(pkg models.Package) (bool, error) {
+	pkgBundle, err := s.packagesBc.Get(pkg)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Getting package bundle")
+	}
+
+	return pkgBundle.IsInstalled()
+}
+
 func (s compiledPackageApplier) Apply(pkg models.Package) error {
 	s.logger.Debug(logTag, "Applying package %v", pkg)
 
@@ -83,18 +92,18 @@ func (s compiledPackageApplier) Apply(pkg models.Package) error {
 }
 
 func (s *compiledPackageApplier) downloadAndInstall(pkg models.Package, pkgBundle bc.Bundle) error {
-	file, err := s.blobstore.Get(pkg.Source.Sha1, pkg.Source.SignedURL, pkg.Source.BlobstoreID, pkg.Source.BlobstoreHeaders)
+	blob, err := s.blobstore.Open(pkg.Source.Sha1, pkg.Source.SignedURL, pkg.Source.BlobstoreID, pkg.Source.BlobstoreHeaders)
 	if err != nil {
 		return bosherr.WrapError(err, "Fetching package blob")
 	}
 
 	defer func() {
-		if err = s.blobstore.CleanUp("", file); err != nil {
-			s.logger.Warn(logTag, "Failed to clean up blobstore blob: %s", err.Error())
+		if err = blob.Close(); err != nil {
+			s.logger.Warn(logTag, "Failed to close package blob: %s", err.Error())
 		}
 	}()
 
-	_, err = pkgBundle.Install(file, "")
+	_, err = pkgBundle.Install(blob, pkg.Source.Sha1, "")
 	if err != nil {
 		return bosherr.WrapError(err, "Installing package directory")
 	}
@@ -103,7 +112,15 @@ func (s *compiledPackageApplier) downloadAndInstall(pkg models.Package, pkgBundl
 }
 
 func (s *compiledPackageApplier) KeepOnly(pkgs []models.Package) error {
-	s.logger.Debug(logTag, "Keeping only packages %v", pkgs)
+	return s.KeepOnlyRetaining(pkgs, nil)
+}
+
+// KeepOnlyRetaining disables all packages but the given packages. When
+// operating as owner it uninstalls all packages but the given and the
+// retained packages, so that retained packages can be enabled again
+// without downloading them.
+func (s *compiledPackageApplier) KeepOnlyRetaining(pkgs []models.Package, retainedPkgs []models.Package) error {
+	s.logger.Debug(logTag, "Keeping only packages %v retaining %v", pkgs, retainedPkgs)
 
 	installedBundles, err := s.packagesBc.List()
 	if err != nil {
@@ -111,37 +128,56 @@ func (s *compiledPackageApplier) KeepOnly(pkgs []models.Package) error {
 	}
 
 	for _, installedBundle := range installedBundles {
-		var shouldKeep bool
-
-		for _, pkg := range pkgs {
-			pkgBundle, err := s.packagesBc.Get(pkg)
-			if err != nil {
-				return bosherr.WrapError(err, "Getting package bundle")
-			}
-
-			if pkgBundle == installedBundle {
-				shouldKeep = true
-				break
-			}
+		shouldKeep, err := s.containsBundle(pkgs, installedBundle)
+		if err != nil {
+			return err
+		}
+
+		if shouldKeep {
+			continue
+		}
+
+		err = installedBundle.Disable()
+		if err != nil {
+			return bosherr.WrapError(err, "Disabling package bundle")
+		}
+
+		if !s.packagesBcOwner {
+			continue
+		}
+
+		shouldRetain, err := s.containsBundle(retainedPkgs, installedBundle)
+		if err != nil {
+			return err
+		}
+
+		if shouldRetain {
+			continue
 		}
 
-		if !shouldKeep {
-			err = installedBundle.Disable()
-			if err != nil {
-				return bosherr.WrapError(err, "Disabling package bundle")
-			}
-
-			if s.packagesBcOwner {
-				// If we uninstall the bundle first, and the disable failed (leaving the symlink),
-				// then the next time bundle collection will not include bundle in its list
-				// which means that symlink will never be deleted.
-				err = installedBundle.Uninstall()
-				if err != nil {
-					return bosherr.WrapError(err, "Uninstalling package bundle")
-				}
-			}
+		// If we uninstall the bundle first, and the disable failed (leaving the symlink),
+		// then the next time bundle collection will not include bundle in its list
+		// which means that symlink will never be deleted.
+		err = installedBundle.Uninstall()
+		if err != nil {
+			return bosherr.WrapError(err, "Uninstalling package bundle")
 		}
 	}
 
 	return nil
 }
+
+func (s *compiledPackageApplier) containsBundle(pkgs []models.Package, bundle bc.Bundle) (bool, error) {
+	for _, pkg := range pkgs {
+		pkgBundle, err := s.packagesBc.Get(pkg)
+		if err != nil {
+			return false, bosherr.WrapError(err, "Getting package bundle")
+		}
+
+		if pkgBundle == bundle {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -8,7 +8,7 @@ import (
 	"code.cloudfoundry.org/clock"
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	"github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator"
-	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
@@ -20,7 +20,7 @@ type compiledPackageApplierProvider struct {
 	name                  string
 
 	blobstore    blobstore_delegator.BlobstoreDelegator
-	compressor   boshcmd.Compressor
+	extractor    tarstream.Extractor
 	fs           boshsys.FileSystem
 	timeProvider clock.Clock
 	logger       boshlog.Logger
@@ -29,7 +29,7 @@ type compiledPackageApplierProvider struct {
 func NewCompiledPackageApplierProvider(
 	installPath, rootEnablePath, jobSpecificEnablePath, name string,
 	blobstore blobstore_delegator.BlobstoreDelegator,
-	compressor boshcmd.Compressor,
+	extractor tarstream.Extractor,
 	fs boshsys.FileSystem,
 	timeProvider clock.Clock,
 	logger boshlog.Logger,
@@ -40,7 +40,7 @@ func NewCompiledPackageApplierProvider(
 		jobSpecificEnablePath: jobSpecificEnablePath,
 		name:                  name,
 		blobstore:             blobstore,
-		compressor:            compressor,
+		extractor:             extractor,
 		fs:                    fs,
 		timeProvider:          timeProvider,
 		logger:                logger,
@@ -64,7 +64,7 @@ func (p compiledPackageApplierProvider) JobSpecific(jobName string) Applier {
 		os.FileMode(0755),
 		p.fs,
 		p.timeProvider,
-		p.compressor,
+		p.extractor,
 		p.logger,
 	)
 	return NewCompiledPackageApplier(packagesBc, false, p.blobstore, p.fs, p.logger)
@@ -78,7 +78,7 @@ func (p compiledPackageApplierProvider) RootBundleCollection() boshbc.BundleColl
 		os.FileMode(0755),
 		p.fs,
 		p.timeProvider,
-		p.compressor,
+		p.extractor,
 		p.logger,
 	)
 }
@@ -10,24 +10,24 @@ import (
 	"github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection/fakes"
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/packages"
 	fakeblobdelegator "github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator/blobstore_delegatorfakes"
-	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream/tarstreamfakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 var _ = Describe("compiledPackageApplierProvider", func() {
 	var (
-		blobstore  *fakeblobdelegator.FakeBlobstoreDelegator
-		compressor *fakecmd.FakeCompressor
-		fs         *fakesys.FakeFileSystem
-		fakeClock  *fakes.FakeClock
-		logger     boshlog.Logger
-		provider   ApplierProvider
+		blobstore *fakeblobdelegator.FakeBlobstoreDelegator
+		extractor *tarstreamfakes.FakeExtractor
+		fs        *fakesys.FakeFileSystem
+		fakeClock *fakes.FakeClock
+		logger    boshlog.Logger
+		provider  ApplierProvider
 	)
 
 	BeforeEach(func() {
 		blobstore = &fakeblobdelegator.FakeBlobstoreDelegator{}
-		compressor = fakecmd.NewFakeCompressor()
+		extractor = new(tarstreamfakes.FakeExtractor)
 		fs = fakesys.NewFakeFileSystem()
 		fakeClock = new(fakes.FakeClock)
 		logger = boshlog.NewLogger(boshlog.LevelNone)
@@ -37,7 +37,7 @@ var _ = Describe("compiledPackageApplierProvider", func() {
 			"fake-job-specific-enable-path",
 			"fake-name",
 			blobstore,
-			compressor,
+			extractor,
 			fs,
 			fakeClock,
 			logger,
@@ -54,7 +54,7 @@ var _ = Describe("compiledPackageApplierProvider", func() {
 					os.FileMode(0755),
 					fs,
 					fakeClock,
-					compressor,
+					extractor,
 					logger,
 				),
 				true,
@@ -76,7 +76,7 @@ var _ = Describe("compiledPackageApplierProvider", func() {
 					os.FileMode(0755),
 					fs,
 					fakeClock,
-					compressor,
+					extractor,
 					logger,
 				),
 
@@ -2,6 +2,8 @@ package packages_test
 
 import (
 	"errors"
+	"io"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -17,6 +19,16 @@ import (
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 )
 
+type fakeBlob struct {
+	io.Reader
+	closed bool
+}
+
+func (b *fakeBlob) Close() error {
+	b.closed = true
+	return nil
+}
+
 func buildPkg(bc *fakebc.FakeBundleCollection) (models.Package, *fakebc.FakeBundle) {
 	uuidGen := boshuuid.NewGenerator()
 	uuid, err := uuidGen.Generate()
@@ -46,11 +58,14 @@ func init() {
 			fs         *fakesys.FakeFileSystem
 			logger     boshlog.Logger
 			applier    Applier
+			blob       *fakeBlob
 		)
 
 		BeforeEach(func() {
 			packagesBc = fakebc.NewFakeBundleCollection()
 			blobstore = &fakeblobdelegator.FakeBlobstoreDelegator{}
+			blob = &fakeBlob{Reader: strings.NewReader("fake-blob")}
+			blobstore.OpenReturns(blob, nil)
 			fs = fakesys.NewFakeFileSystem()
 			logger = boshlog.NewLogger(boshlog.LevelNone)
 			applier = NewCompiledPackageApplier(packagesBc, true, blobstore, fs, logger)
@@ -75,24 +90,21 @@ func init() {
 					Expect(err.Error()).To(ContainSubstring("fake-install-error"))
 				})
 
-				It("downloads and later cleans up downloaded package blob", func() {
-					blobstore.GetReturns("/fake-blobstore-file-name", nil)
-
+				It("downloads and later closes the package blob", func() {
 					err := act()
 					Expect(err).ToNot(HaveOccurred())
-					fingerPrint, signedURL, blobID, headers := blobstore.GetArgsForCall(0)
+					fingerPrint, signedURL, blobID, headers := blobstore.OpenArgsForCall(0)
 					Expect(signedURL).To(Equal("fake-package/signed-url"))
 					Expect(blobID).To(Equal("fake-blobstore-id"))
 					Expect(headers).To(Equal(map[string]string{"key": "value"}))
 					Expect(fingerPrint).To(Equal(boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-blob-sha1"))))
 
-					// downloaded file is cleaned up
-					_, cleanupArg := blobstore.CleanUpArgsForCall(0)
-					Expect(cleanupArg).To(Equal("/fake-blobstore-file-name"))
+					// downloaded blob is closed
+					Expect(blob.closed).To(BeTrue())
 				})
 
 				It("returns error when downloading package blob fails", func() {
-					blobstore.GetReturns("", errors.New("fake-get-error"))
+					blobstore.OpenReturns(nil, errors.New("fake-get-error"))
 
 					err := act()
 					Expect(err).To(HaveOccurred())
@@ -100,12 +112,11 @@ func init() {
 				})
 
 				It("can process sha1 checksums in the new format", func() {
-					blobstore.GetReturns("/fake-blobstore-file-name", nil)
 					pkg.Source.Sha1 = boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "sha1:fake-blob-sha1"))
 
 					err := act()
 					Expect(err).ToNot(HaveOccurred())
-					fingerPrint, signedURL, blobID, headers := blobstore.GetArgsForCall(0)
+					fingerPrint, signedURL, blobID, headers := blobstore.OpenArgsForCall(0)
 					Expect(signedURL).To(Equal("fake-package/signed-url"))
 					Expect(blobID).To(Equal("fake-blobstore-id"))
 					Expect(headers).To(Equal(map[string]string{"key": "value"}))
@@ -113,12 +124,11 @@ func init() {
 				})
 
 				It("can process sha2 checksums", func() {
-					blobstore.GetReturns("/fake-blobstore-file-name", nil)
 					pkg.Source.Sha1 = boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA256, "sha256:fake-blob-sha256"))
 
 					err := act()
 					Expect(err).ToNot(HaveOccurred())
-					fingerPrint, signedURL, blobID, headers := blobstore.GetArgsForCall(0)
+					fingerPrint, signedURL, blobID, headers := blobstore.OpenArgsForCall(0)
 					Expect(signedURL).To(Equal("fake-package/signed-url"))
 					Expect(blobID).To(Equal("fake-blobstore-id"))
 					Expect(headers).To(Equal(map[string]string{"key": "value"}))
@@ -126,12 +136,11 @@ func init() {
 				})
 
 				It("installs bundle from archive", func() {
-					blobstore.GetReturns("/fake-blobstore-file-name", nil)
 					err := act()
 					Expect(err).ToNot(HaveOccurred())
 
-					// make sure that bundle install happened after decompression
-					Expect(bundle.InstallSourcePath).To(Equal("/fake-blobstore-file-name"))
+					Expect(bundle.InstallSource).To(Equal(blob))
+					Expect(bundle.InstallDigest).To(Equal(pkg.Source.Sha1))
 					Expect(bundle.InstallPathInBundle).To(Equal(""))
 				})
 			}
@@ -169,7 +178,7 @@ func init() {
 					It("does not download the package", func() {
 						err := act()
 						Expect(err).ToNot(HaveOccurred())
-						Expect(blobstore.GetCallCount()).To(Equal(0))
+						Expect(blobstore.OpenCallCount()).To(Equal(0))
 					})
 				})
 
@@ -229,7 +238,7 @@ func init() {
 					It("does not download the package", func() {
 						err := act()
 						Expect(err).ToNot(HaveOccurred())
-						Expect(blobstore.GetCallCount()).To(Equal(0))
+						Expect(blobstore.OpenCallCount()).To(Equal(0))
 					})
 				})
 
@@ -312,6 +321,21 @@ func init() {
 					Expect(bundle4.ActionsCalled).To(Equal([]string{}))
 				})
 
+				It("disables but does not uninstall retained packages", func() {
+					pkg1, bundle1 := buildPkg(packagesBc)
+					pkg2, bundle2 := buildPkg(packagesBc)
+					_, bundle3 := buildPkg(packagesBc)
+
+					packagesBc.ListBundles = []boshbc.Bundle{bundle1, bundle2, bundle3}
+
+					err := applier.KeepOnlyRetaining([]models.Package{pkg2}, []models.Package{pkg1, pkg2})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(bundle1.ActionsCalled).To(Equal([]string{"Disable"}))
+					Expect(bundle2.ActionsCalled).To(Equal([]string{}))
+					Expect(bundle3.ActionsCalled).To(Equal([]string{"Disable", "Uninstall"}))
+				})
+
 				ItReturnsErrors()
 
 				It("returns error when at least one bundle cannot be uninstalled", func() {
@@ -16,9 +16,15 @@ type FakeApplier struct {
 	ApplyError      error
 
 	KeptOnlyPackages []models.Package
+	RetainedPackages []models.Package
 	KeepOnlyErr      error
-	applyMutex       sync.Mutex
-	PrepareStub      func(pkg models.Package) error
+
+	// All packages but these are installed
+	NotInstalledPackages []models.Package
+	IsInstalledErr       error
+
+	applyMutex  sync.Mutex
+	PrepareStub func(pkg models.Package) error
 }
 
 func NewFakeApplier() *FakeApplier {
@@ -44,8 +50,24 @@ func (s *FakeApplier) Apply(pkg models.Package) error {
 	return s.ApplyError
 }
 
+func (s *FakeApplier) IsInstalled(pkg models.Package) (bool, error) {
+	for _, notInstalledPkg := range s.NotInstalledPackages {
+		if notInstalledPkg.Name == pkg.Name && notInstalledPkg.Version == pkg.Version {
+			return false, s.IsInstalledErr
+		}
+	}
+	return true, s.IsInstalledErr
+}
+
 func (s *FakeApplier) KeepOnly(pkgs []models.Package) error {
 	s.ActionsCalled = append(s.ActionsCalled, "KeepOnly")
 	s.KeptOnlyPackages = pkgs
 	return s.KeepOnlyErr
 }
+
+func (s *FakeApplier) KeepOnlyRetaining(pkgs []models.Package, retainedPkgs []models.Package) error {
+	s.ActionsCalled = append(s.ActionsCalled, "KeepOnlyRetaining")
+	s.KeptOnlyPackages = pkgs
+	s.RetainedPackages = retainedPkgs
+	return s.KeepOnlyErr
+}
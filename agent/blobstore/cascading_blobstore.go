@@ -1,6 +1,8 @@
 package blobstore
 
 import (
+	"io"
+
 	utilblobstore "github.com/cloudfoundry/bosh-utils/blobstore"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -43,6 +45,27 @@ func (b cascadingBlobstore) Get(blobID string, digest boshcrypto.Digest) (string
 	return b.innerBlobstore.Get(blobID, digest)
 }
 
+// Open streams blobs kept by the blob managers from where they are stored
+// without verifying them, callers must verify the digest while reading.
+// found is false for blobs of the inner blobstore since the external
+// blobstore CLIs can only download to files, some of them with parallel
+// ranged writes, so their output cannot be streamed through a pipe.
+func (b cascadingBlobstore) Open(blobID string) (reader io.ReadCloser, found bool, err error) {
+	for _, blobManager := range b.blobManagers {
+		if blobManager.BlobExists(blobID) {
+			file, _, err := blobManager.Fetch(blobID)
+			if err != nil {
+				return nil, false, err
+			}
+
+			b.logger.Debug(logTag, "Streaming blob from BlobManager. BlobID: %s", blobID)
+			return file, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
 func (b cascadingBlobstore) CleanUp(fileName string) error {
 	return b.innerBlobstore.CleanUp(fileName)
 }
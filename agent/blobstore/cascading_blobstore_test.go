@@ -1,6 +1,7 @@
 package blobstore_test
 
 import (
+	"io"
 	"math/rand"
 
 	. "github.com/onsi/ginkgo"
@@ -14,6 +15,7 @@ import (
 	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 var _ = Describe("cascadingBlobstore", func() {
@@ -130,6 +132,49 @@ var _ = Describe("cascadingBlobstore", func() {
 			})
 		})
 
+		Describe("Open", func() {
+			var opener interface {
+				Open(blobID string) (io.ReadCloser, bool, error)
+			}
+
+			BeforeEach(func() {
+				opener = cascadingBlobstore.(interface {
+					Open(blobID string) (io.ReadCloser, bool, error)
+				})
+			})
+
+			It("streams blobs kept by the blobManager", func() {
+				file := fakesys.NewFakeFile("/path/to/blob", fakesys.NewFakeFileSystem())
+				blobManager.BlobExistsReturns(true)
+				blobManager.FetchReturns(file, 200, nil)
+
+				reader, found, err := opener.Open("blobID")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(reader).To(Equal(file))
+
+				Expect(blobManager.FetchArgsForCall(0)).To(Equal("blobID"))
+				Expect(blobManager.GetPathCallCount()).To(Equal(0))
+			})
+
+			It("returns an error when the blobManager fails to fetch the blob", func() {
+				blobManager.BlobExistsReturns(true)
+				blobManager.FetchReturns(nil, 500, errors.New("fake-fetch-err"))
+
+				_, _, err := opener.Open("blobID")
+				Expect(err).To(MatchError("fake-fetch-err"))
+			})
+
+			It("does not open blobs of the inner blobstore", func() {
+				blobManager.BlobExistsReturns(false)
+
+				_, found, err := opener.Open("blobID")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+				Expect(innerBlobstore.GetCallCount()).To(Equal(0))
+			})
+		})
+
 		Describe("CleanUp", func() {
 			It("delegates the action to the inner blobstore", func() {
 				err := cascadingBlobstore.CleanUp("fileToDelete")
@@ -0,0 +1,314 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const compileCacheLogTag = "FileCompileCache"
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -o fakes/fake_compile_cache.go . CompileCache
+
+// CompileCache keeps compiled package tarballs keyed by the package source,
+// the compiled dependencies and the stemcell they were compiled on. Get
+// returns a copy of the cached tarball that the caller removes when done.
+type CompileCache interface {
+	Get(pkg Package, deps []boshmodels.Package) (tarballPath string, found bool, err error)
+	Put(pkg Package, deps []boshmodels.Package, tarballPath string, digest boshcrypto.MultipleDigest) error
+}
+
+type compileCacheEntry struct {
+	Key      string                    `json:"key"`
+	Size     int64                     `json:"size"`
+	Digest   boshcrypto.MultipleDigest `json:"digest"`
+	LastUsed time.Time                 `json:"last_used"`
+}
+
+type FileCompileCache struct {
+	fs          boshsys.FileSystem
+	dir         string
+	maxSize     int64
+	stemcell    string
+	timeService clock.Clock
+	logger      boshlog.Logger
+	lock        sync.Mutex
+}
+
+// NewFileCompileCache returns a cache that stores compiled tarballs in dir
+// and evicts the least recently used ones once they exceed maxSize bytes.
+// A maxSize of zero disables the cache.
+func NewFileCompileCache(
+	fs boshsys.FileSystem,
+	dir string,
+	maxSize int64,
+	stemcell string,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) *FileCompileCache {
+	return &FileCompileCache{
+		fs:          fs,
+		dir:         dir,
+		maxSize:     maxSize,
+		stemcell:    stemcell,
+		timeService: timeService,
+		logger:      logger,
+	}
+}
+
+func (c *FileCompileCache) Get(pkg Package, deps []boshmodels.Package) (string, bool, error) {
+	if c.maxSize <= 0 {
+		return "", false, nil
+	}
+
+	key, err := c.key(pkg, deps)
+	if err != nil {
+		return "", false, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entries, err := c.readIndex()
+	if err != nil {
+		return "", false, err
+	}
+
+	for i, entry := range entries {
+		if entry.Key != key {
+			continue
+		}
+
+		tarballPath := c.tarballPath(key)
+
+		checkedOutPath, err := c.checkOut(tarballPath)
+		if err != nil {
+			return "", false, err
+		}
+
+		err = entry.Digest.VerifyFilePath(checkedOutPath, c.fs)
+		if err != nil {
+			c.logger.Warn(compileCacheLogTag, "Dropping cached package %s/%s: %s", pkg.Name, pkg.Version, err.Error())
+			_ = c.fs.RemoveAll(checkedOutPath)
+			_ = c.fs.RemoveAll(tarballPath)
+			return "", false, c.writeIndex(append(entries[:i], entries[i+1:]...))
+		}
+
+		entries[i].LastUsed = c.timeService.Now()
+
+		err = c.writeIndex(entries)
+		if err != nil {
+			_ = c.fs.RemoveAll(checkedOutPath)
+			return "", false, err
+		}
+
+		c.logger.Debug(compileCacheLogTag, "Found package %s/%s with key %s", pkg.Name, pkg.Version, key)
+		return checkedOutPath, true, nil
+	}
+
+	return "", false, nil
+}
+
+// checkOut copies a cached tarball so that it can be used after the lock
+// is released, when a concurrent Put may evict it
+func (c *FileCompileCache) checkOut(tarballPath string) (string, error) {
+	file, err := c.fs.TempFile("bosh-agent-compile-cache")
+	if err != nil {
+		return "", bosherr.WrapError(err, "Creating temporary file for cached compiled package")
+	}
+
+	checkedOutPath := file.Name()
+
+	err = file.Close()
+	if err != nil {
+		_ = c.fs.RemoveAll(checkedOutPath)
+		return "", bosherr.WrapErrorf(err, "Closing %s", checkedOutPath)
+	}
+
+	err = c.fs.CopyFile(tarballPath, checkedOutPath)
+	if err != nil {
+		_ = c.fs.RemoveAll(checkedOutPath)
+		return "", bosherr.WrapError(err, "Copying cached compiled package")
+	}
+
+	return checkedOutPath, nil
+}
+
+func (c *FileCompileCache) Put(pkg Package, deps []boshmodels.Package, tarballPath string, digest boshcrypto.MultipleDigest) error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	key, err := c.key(pkg, deps)
+	if err != nil {
+		return err
+	}
+
+	stat, err := c.fs.Stat(tarballPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Checking size of %s", tarballPath)
+	}
+
+	if stat.Size() > c.maxSize {
+		c.logger.Debug(compileCacheLogTag, "Not caching package %s/%s of %d bytes", pkg.Name, pkg.Version, stat.Size())
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entries, err := c.readIndex()
+	if err != nil {
+		return err
+	}
+
+	err = c.fs.MkdirAll(c.dir, os.FileMode(0700))
+	if err != nil {
+		return bosherr.WrapError(err, "Creating compile cache directory")
+	}
+
+	cachedPath := c.tarballPath(key)
+	tmpPath := cachedPath + ".tmp"
+
+	err = c.fs.CopyFile(tarballPath, tmpPath)
+	if err != nil {
+		_ = c.fs.RemoveAll(tmpPath)
+		return bosherr.WrapError(err, "Copying compiled package into compile cache")
+	}
+
+	err = c.fs.Rename(tmpPath, cachedPath)
+	if err != nil {
+		_ = c.fs.RemoveAll(tmpPath)
+		return bosherr.WrapError(err, "Moving compiled package into compile cache")
+	}
+
+	retained := []compileCacheEntry{{
+		Key:      key,
+		Size:     stat.Size(),
+		Digest:   digest,
+		LastUsed: c.timeService.Now(),
+	}}
+	for _, entry := range entries {
+		if entry.Key != key {
+			retained = append(retained, entry)
+		}
+	}
+
+	return c.writeIndex(c.evict(retained))
+}
+
+// evict removes the least recently used tarballs until the remaining ones fit
+func (c *FileCompileCache) evict(entries []compileCacheEntry) []compileCacheEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+
+	var size int64
+	var retained []compileCacheEntry
+
+	for _, entry := range entries {
+		if size+entry.Size <= c.maxSize {
+			size += entry.Size
+			retained = append(retained, entry)
+			continue
+		}
+
+		c.logger.Debug(compileCacheLogTag, "Evicting compiled package with key %s", entry.Key)
+
+		err := c.fs.RemoveAll(c.tarballPath(entry.Key))
+		if err != nil {
+			c.logger.Warn(compileCacheLogTag, "Failed to evict compiled package with key %s: %s", entry.Key, err.Error())
+		}
+	}
+
+	return retained
+}
+
+// key identifies a compiled package by its source digest, the digests of
+// its compiled dependencies and the stemcell it is compiled on
+func (c *FileCompileCache) key(pkg Package, deps []boshmodels.Package) (string, error) {
+	lines := []string{
+		fmt.Sprintf("stemcell:%s", c.stemcell),
+		fmt.Sprintf("package:%s:%s", pkg.Name, pkg.Sha1.String()),
+	}
+
+	var depLines []string
+	for _, dep := range deps {
+		if dep.Source.Sha1 == nil {
+			return "", bosherr.Errorf("Missing digest of dependency %s", dep.Name)
+		}
+		depLines = append(depLines, fmt.Sprintf("dependency:%s:%s", dep.Name, dep.Source.Sha1.String()))
+	}
+	sort.Strings(depLines)
+
+	digest, err := boshcrypto.DigestAlgorithmSHA1.CreateDigest(strings.NewReader(strings.Join(append(lines, depLines...), "\n")))
+	if err != nil {
+		return "", bosherr.WrapError(err, "Calculating compile cache key")
+	}
+
+	return digest.String(), nil
+}
+
+func (c *FileCompileCache) tarballPath(key string) string {
+	return filepath.Join(c.dir, key+".tgz")
+}
+
+func (c *FileCompileCache) indexPath() string {
+	return filepath.Join(c.dir, "index.json")
+}
+
+func (c *FileCompileCache) readIndex() ([]compileCacheEntry, error) {
+	var entries []compileCacheEntry
+
+	if !c.fs.FileExists(c.indexPath()) {
+		return entries, nil
+	}
+
+	contents, err := c.fs.ReadFile(c.indexPath())
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading compile cache index")
+	}
+
+	err = json.Unmarshal(contents, &entries)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling compile cache index")
+	}
+
+	return entries, nil
+}
+
+func (c *FileCompileCache) writeIndex(entries []compileCacheEntry) error {
+	if entries == nil {
+		entries = []compileCacheEntry{}
+	}
+
+	contents, err := json.Marshal(entries)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling compile cache index")
+	}
+
+	err = c.fs.MkdirAll(c.dir, os.FileMode(0700))
+	if err != nil {
+		return bosherr.WrapError(err, "Creating compile cache directory")
+	}
+
+	err = c.fs.WriteFile(c.indexPath(), contents)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing compile cache index")
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package compiler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	. "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("FileCompileCache", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		timeService *fakeclock.FakeClock
+		logger      boshlog.Logger
+		cache       *FileCompileCache
+		pkg         Package
+		deps        []boshmodels.Package
+	)
+
+	sha1Of := func(contents string) boshcrypto.MultipleDigest {
+		digest, err := boshcrypto.DigestAlgorithmSHA1.CreateDigest(strings.NewReader(contents))
+		Expect(err).ToNot(HaveOccurred())
+		return boshcrypto.MustNewMultipleDigest(digest)
+	}
+
+	put := func(cache *FileCompileCache, pkg Package, deps []boshmodels.Package, contents string) {
+		tarballPath := "/tmp/" + pkg.Name + ".tgz"
+		Expect(fs.WriteFileString(tarballPath, contents)).To(Succeed())
+		Expect(cache.Put(pkg, deps, tarballPath, sha1Of(contents))).To(Succeed())
+	}
+
+	cachedTarballPaths := func() []string {
+		var entries []struct {
+			Key string `json:"key"`
+		}
+
+		contents, err := fs.ReadFile("/fake-compile-cache/index.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(json.Unmarshal(contents, &entries)).To(Succeed())
+
+		var paths []string
+		for _, entry := range entries {
+			paths = append(paths, "/fake-compile-cache/"+entry.Key+".tgz")
+		}
+		return paths
+	}
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		for i := 0; i < 10; i++ {
+			fs.ReturnTempFiles = append(fs.ReturnTempFiles, fakesys.NewFakeFile(fmt.Sprintf("/tmp/checked-out-%d", i), fs))
+		}
+		timeService = fakeclock.NewFakeClock(time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC))
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		cache = NewFileCompileCache(fs, "/fake-compile-cache", 10, "ubuntu-jammy/1.23/fake-git-sha1/amd64", timeService, logger)
+
+		pkg, deps = getCompileArgs()
+	})
+
+	It("does not find packages that were not added", func() {
+		_, found, err := cache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("finds added packages regardless of the order of their dependencies", func() {
+		put(cache, pkg, deps, "compiled")
+
+		tarballPath, found, err := cache.Get(pkg, []boshmodels.Package{deps[1], deps[0]})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+
+		contents, err := fs.ReadFileString(tarballPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contents).To(Equal("compiled"))
+		Expect(tarballPath).To(HavePrefix("/tmp/checked-out-"))
+	})
+
+	It("returns copies of cached packages that outlive their eviction", func() {
+		put(cache, pkg, deps, "compiled")
+
+		tarballPath, found, err := cache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+
+		otherPkg := pkg
+		otherPkg.Name = "other_pkg_name"
+		timeService.Increment(time.Minute)
+		put(cache, otherPkg, deps, "0123456789")
+
+		Expect(cachedTarballPaths()).To(HaveLen(1))
+
+		contents, err := fs.ReadFileString(tarballPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contents).To(Equal("compiled"))
+	})
+
+	It("is disabled without a size", func() {
+		cache = NewFileCompileCache(fs, "/fake-compile-cache", 0, "ubuntu-jammy/1.23/fake-git-sha1/amd64", timeService, logger)
+
+		put(cache, pkg, deps, "")
+
+		_, found, err := cache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+		Expect(fs.FileExists("/fake-compile-cache")).To(BeFalse())
+	})
+
+	It("does not find packages with a different source", func() {
+		put(cache, pkg, deps, "compiled")
+
+		pkg.Sha1 = sha1Of("other-source")
+
+		_, found, err := cache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("does not find packages compiled against different dependencies", func() {
+		put(cache, pkg, deps, "compiled")
+
+		deps[0].Source.Sha1 = sha1Of("other-dependency")
+
+		_, found, err := cache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("does not find packages compiled on a different stemcell", func() {
+		put(cache, pkg, deps, "compiled")
+
+		otherCache := NewFileCompileCache(fs, "/fake-compile-cache", 10, "ubuntu-jammy/1.24/fake-git-sha1/amd64", timeService, logger)
+
+		_, found, err := otherCache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("evicts the least recently used packages when exceeding its size", func() {
+		otherPkg := pkg
+		otherPkg.Name = "other_pkg_name"
+		thirdPkg := pkg
+		thirdPkg.Name = "third_pkg_name"
+
+		put(cache, otherPkg, deps, "bbbb")
+		evictedPath := cachedTarballPaths()[0]
+		timeService.Increment(time.Minute)
+		put(cache, pkg, deps, "aaaa")
+		timeService.Increment(time.Minute)
+
+		_, found, err := cache.Get(otherPkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+		timeService.Increment(time.Minute)
+
+		_, found, err = cache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+		timeService.Increment(time.Minute)
+
+		put(cache, thirdPkg, deps, "cccc")
+
+		_, found, err = cache.Get(otherPkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+
+		_, found, err = cache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+
+		_, found, err = cache.Get(thirdPkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+
+		Expect(fs.FileExists(evictedPath)).To(BeFalse())
+	})
+
+	It("does not add packages larger than the cache", func() {
+		put(cache, pkg, deps, "larger than ten bytes")
+
+		_, found, err := cache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+		Expect(fs.FileExists("/fake-compile-cache")).To(BeFalse())
+	})
+
+	It("drops cached packages that do not match their digest", func() {
+		put(cache, pkg, deps, "compiled")
+
+		tarballPath := cachedTarballPaths()[0]
+		Expect(fs.WriteFileString(tarballPath, "corrupted")).To(Succeed())
+
+		_, found, err := cache.Get(pkg, deps)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+		Expect(fs.FileExists(tarballPath)).To(BeFalse())
+	})
+
+	It("returns an error when a dependency has no digest", func() {
+		deps[0].Source.Sha1 = nil
+
+		_, _, err := cache.Get(pkg, deps)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Missing digest of dependency first_dep_name"))
+	})
+})
@@ -6,7 +6,16 @@ import (
 )
 
 type Compiler interface {
-	Compile(pkg Package, deps []boshmodels.Package) (blobID string, digest boshcrypto.Digest, err error)
+	Compile(pkg Package, deps []boshmodels.Package) (CompileResult, error)
+}
+
+type CompileResult struct {
+	BlobID string
+	Digest boshcrypto.Digest
+
+	// CacheHit is set when the compiled package was taken from the
+	// compile cache instead of running its packaging script.
+	CacheHit bool
 }
 
 type Package struct {
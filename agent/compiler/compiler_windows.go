@@ -7,20 +7,26 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-func (c concreteCompiler) runPackagingCommand(compilePath, enablePath string, pkg Package) error {
+func (c concreteCompiler) runPackagingCommand(pkg Package, paths SandboxPaths) error {
 	command := boshsys.Command{
 		Name: "powershell",
 		Args: []string{"-command", fmt.Sprintf("iex (get-content -raw %s)", PackagingScriptName)},
 		Env: map[string]string{
-			"BOSH_COMPILE_TARGET":  compilePath,
-			"BOSH_INSTALL_TARGET":  enablePath,
+			"BOSH_COMPILE_TARGET":  paths.CompilePath,
+			"BOSH_INSTALL_TARGET":  paths.EnablePath,
 			"BOSH_PACKAGE_NAME":    pkg.Name,
 			"BOSH_PACKAGE_VERSION": pkg.Version,
 		},
-		WorkingDir: compilePath,
+		WorkingDir: paths.CompilePath,
 	}
 
-	_, err := c.runner.RunCommand("compilation", PackagingScriptName, command)
+	// Fails when the sandbox is enabled since it relies on Linux namespaces
+	command, err := c.sandbox.Wrap(command, paths)
+	if err != nil {
+		return bosherr.WrapError(err, "Sandboxing packaging script")
+	}
+
+	_, err = c.runner.RunCommand("compilation", PackagingScriptName, command)
 	if err != nil {
 		return bosherr.WrapError(err, "Running packaging script")
 	}
@@ -2,6 +2,7 @@ package compiler
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
 
@@ -12,13 +13,19 @@ import (
 	"github.com/cloudfoundry/bosh-agent/agent/applier/packages"
 	boshcmdrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
 	"github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-const PackagingScriptName = "packaging"
+const (
+	PackagingScriptName = "packaging"
+
+	compilerLogTag = "concreteCompiler"
+)
 
 type CompileDirProvider interface {
 	CompileDir() string
@@ -26,47 +33,79 @@ type CompileDirProvider interface {
 
 type concreteCompiler struct {
 	compressor         boshcmd.Compressor
+	extractor          tarstream.Extractor
 	blobstore          blobstore_delegator.BlobstoreDelegator
 	fs                 boshsys.FileSystem
 	runner             boshcmdrunner.CmdRunner
 	compileDirProvider CompileDirProvider
 	packageApplier     packages.Applier
 	packagesBc         boshbc.BundleCollection
+	cache              CompileCache
+	sandbox            Sandbox
 	timeProvider       clock.Clock
+	logger             boshlog.Logger
 }
 
 func NewConcreteCompiler(
 	compressor boshcmd.Compressor,
+	extractor tarstream.Extractor,
 	blobstore blobstore_delegator.BlobstoreDelegator,
 	fs boshsys.FileSystem,
 	runner boshcmdrunner.CmdRunner,
 	compileDirProvider CompileDirProvider,
 	packageApplier packages.Applier,
 	packagesBc boshbc.BundleCollection,
+	cache CompileCache,
+	sandbox Sandbox,
 	timeProvider clock.Clock,
+	logger boshlog.Logger,
 ) Compiler {
 	return concreteCompiler{
 		compressor:         compressor,
+		extractor:          extractor,
 		blobstore:          blobstore,
 		fs:                 fs,
 		runner:             runner,
 		compileDirProvider: compileDirProvider,
 		packageApplier:     packageApplier,
 		packagesBc:         packagesBc,
+		cache:              cache,
+		sandbox:            sandbox,
 		timeProvider:       timeProvider,
+		logger:             logger,
 	}
 }
 
-func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (blobID string, digest boshcrypto.Digest, err error) {
+func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (result CompileResult, err error) {
+	cachedTarball, found, cacheErr := c.cache.Get(pkg, deps)
+	if cacheErr != nil {
+		c.logger.Warn(compilerLogTag, "Looking up package %s in compile cache: %s", pkg.Name, cacheErr.Error())
+	}
+
+	if found {
+		defer func() {
+			if err := c.fs.RemoveAll(cachedTarball); err != nil {
+				c.logger.Warn(compilerLogTag, "Removing copy of cached package %s: %s", pkg.Name, err.Error())
+			}
+		}()
+
+		uploadedBlobID, digest, err := c.blobstore.Write(pkg.UploadSignedURL, cachedTarball, pkg.BlobstoreHeaders)
+		if err != nil {
+			return CompileResult{}, bosherr.WrapError(err, "Uploading cached compiled package")
+		}
+
+		return CompileResult{BlobID: uploadedBlobID, Digest: digest, CacheHit: true}, nil
+	}
+
 	err = c.packageApplier.KeepOnly([]boshmodels.Package{})
 	if err != nil {
-		return "", nil, bosherr.WrapError(err, "Removing packages")
+		return CompileResult{}, bosherr.WrapError(err, "Removing packages")
 	}
 
 	for _, dep := range deps {
 		err := c.packageApplier.Apply(dep)
 		if err != nil {
-			return "", nil, bosherr.WrapErrorf(err, "Installing dependent package: '%s'", dep.Name)
+			return CompileResult{}, bosherr.WrapErrorf(err, "Installing dependent package: '%s'", dep.Name)
 		}
 	}
 
@@ -74,7 +113,7 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (blobI
 
 	err = c.fetchAndUncompress(pkg, compilePath)
 	if err != nil {
-		return "", nil, bosherr.WrapErrorf(err, "Fetching package %s", pkg.Name)
+		return CompileResult{}, bosherr.WrapErrorf(err, "Fetching package %s", pkg.Name)
 	}
 
 	defer func() {
@@ -91,30 +130,35 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (blobI
 
 	compiledPkgBundle, err := c.packagesBc.Get(compiledPkg)
 	if err != nil {
-		return "", nil, bosherr.WrapError(err, "Getting bundle for new package")
+		return CompileResult{}, bosherr.WrapError(err, "Getting bundle for new package")
 	}
 
 	installPath, err := compiledPkgBundle.InstallWithoutContents()
 	if err != nil {
-		return "", nil, bosherr.WrapError(err, "Setting up new package bundle")
+		return CompileResult{}, bosherr.WrapError(err, "Setting up new package bundle")
 	}
 
 	enablePath, err := compiledPkgBundle.Enable()
 	if err != nil {
-		return "", nil, bosherr.WrapError(err, "Enabling new package bundle")
+		return CompileResult{}, bosherr.WrapError(err, "Enabling new package bundle")
 	}
 
 	scriptPath := path.Join(compilePath, PackagingScriptName)
 
 	if c.fs.FileExists(scriptPath) {
-		if err := c.runPackagingCommand(compilePath, enablePath, pkg); err != nil {
-			return "", nil, bosherr.WrapError(err, "Running packaging script")
+		paths, err := c.sandboxPaths(compilePath, installPath, enablePath, deps)
+		if err != nil {
+			return CompileResult{}, bosherr.WrapError(err, "Preparing compilation sandbox")
+		}
+
+		if err := c.runPackagingCommand(pkg, paths); err != nil {
+			return CompileResult{}, bosherr.WrapError(err, "Running packaging script")
 		}
 	}
 
 	tmpPackageTar, err := c.compressor.CompressFilesInDir(installPath)
 	if err != nil {
-		return "", nil, bosherr.WrapError(err, "Compressing compiled package")
+		return CompileResult{}, bosherr.WrapError(err, "Compressing compiled package")
 	}
 
 	defer func() {
@@ -123,25 +167,59 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (blobI
 
 	uploadedBlobID, digest, err := c.blobstore.Write(pkg.UploadSignedURL, tmpPackageTar, pkg.BlobstoreHeaders)
 	if err != nil {
-		return "", nil, bosherr.WrapError(err, "Uploading compiled package")
+		return CompileResult{}, bosherr.WrapError(err, "Uploading compiled package")
+	}
+
+	cacheErr = c.cache.Put(pkg, deps, tmpPackageTar, digest)
+	if cacheErr != nil {
+		c.logger.Warn(compilerLogTag, "Adding package %s to compile cache: %s", pkg.Name, cacheErr.Error())
 	}
 
 	err = compiledPkgBundle.Disable()
 	if err != nil {
-		return "", nil, bosherr.WrapError(err, "Disabling compiled package")
+		return CompileResult{}, bosherr.WrapError(err, "Disabling compiled package")
 	}
 
 	err = compiledPkgBundle.Uninstall()
 	if err != nil {
-		return "", nil, bosherr.WrapError(err, "Uninstalling compiled package")
+		return CompileResult{}, bosherr.WrapError(err, "Uninstalling compiled package")
 	}
 
 	err = c.packageApplier.KeepOnly([]boshmodels.Package{})
 	if err != nil {
-		return "", nil, bosherr.WrapError(err, "Removing packages")
+		return CompileResult{}, bosherr.WrapError(err, "Removing packages")
 	}
 
-	return uploadedBlobID, digest, nil
+	return CompileResult{BlobID: uploadedBlobID, Digest: digest}, nil
+}
+
+// sandboxPaths lists where the compiled package and its dependencies are
+// installed, dependencies are enabled next to the compiled package
+func (c concreteCompiler) sandboxPaths(compilePath, installPath, enablePath string, deps []boshmodels.Package) (SandboxPaths, error) {
+	paths := SandboxPaths{
+		CompilePath: compilePath,
+		InstallPath: installPath,
+		EnablePath:  enablePath,
+	}
+
+	for _, dep := range deps {
+		depBundle, err := c.packagesBc.Get(boshmodels.LocalPackage{Name: dep.Name, Version: dep.Version})
+		if err != nil {
+			return SandboxPaths{}, bosherr.WrapErrorf(err, "Getting bundle for dependent package '%s'", dep.Name)
+		}
+
+		depInstallPath, err := depBundle.GetInstallPath()
+		if err != nil {
+			return SandboxPaths{}, bosherr.WrapErrorf(err, "Getting install path of dependent package '%s'", dep.Name)
+		}
+
+		paths.Dependencies = append(paths.Dependencies, SandboxDependency{
+			InstallPath: depInstallPath,
+			EnablePath:  path.Join(path.Dir(enablePath), dep.Name),
+		})
+	}
+
+	return paths, nil
 }
 
 func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string) error {
@@ -149,12 +227,16 @@ func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string) erro
 		return bosherr.Error(fmt.Sprintf("No blobstore reference for package '%s'", pkg.Name))
 	}
 
-	depFilePath, err := c.blobstore.Get(pkg.Sha1, pkg.PackageGetSignedURL, pkg.BlobstoreID, pkg.BlobstoreHeaders)
+	blob, err := c.blobstore.Open(pkg.Sha1, pkg.PackageGetSignedURL, pkg.BlobstoreID, pkg.BlobstoreHeaders)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Fetching package blob %s", pkg.BlobstoreID)
 	}
 
-	err = c.atomicDecompress(depFilePath, targetDir)
+	defer func() {
+		_ = blob.Close()
+	}()
+
+	err = c.atomicExtract(blob, pkg.Sha1, targetDir)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Uncompressing package %s", pkg.Name)
 	}
@@ -162,7 +244,7 @@ func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string) erro
 	return nil
 }
 
-func (c concreteCompiler) atomicDecompress(archivePath string, finalDir string) error {
+func (c concreteCompiler) atomicExtract(source io.Reader, digest boshcrypto.Digest, finalDir string) error {
 	tmpInstallPath := finalDir + "-bosh-agent-unpack"
 
 	{
@@ -189,9 +271,11 @@ func (c concreteCompiler) atomicDecompress(archivePath string, finalDir string)
 		}
 	}
 
-	err := c.compressor.DecompressFileToDir(archivePath, tmpInstallPath, boshcmd.CompressorOptions{})
+	// The digest is verified before the extracted files are moved into place
+	err := c.extractor.Extract(source, digest, tmpInstallPath, tarstream.Options{})
 	if err != nil {
-		return bosherr.WrapErrorf(err, "Decompressing files from %s to %s", archivePath, tmpInstallPath)
+		_ = c.fs.RemoveAll(tmpInstallPath)
+		return bosherr.WrapErrorf(err, "Extracting files to %s", tmpInstallPath)
 	}
 
 	return c.moveTmpDir(tmpInstallPath, finalDir)
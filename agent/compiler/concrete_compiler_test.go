@@ -3,8 +3,11 @@ package compiler_test
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -15,9 +18,13 @@ import (
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	fakepackages "github.com/cloudfoundry/bosh-agent/agent/applier/packages/fakes"
 	fakecmdrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner/fakes"
+	fakecomp "github.com/cloudfoundry/bosh-agent/agent/compiler/fakes"
 	fakeblobdelegator "github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator/blobstore_delegatorfakes"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream/tarstreamfakes"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
@@ -28,6 +35,16 @@ type FakeCompileDirProvider struct {
 
 func (cdp FakeCompileDirProvider) CompileDir() string { return cdp.Dir }
 
+type fakeBlob struct {
+	io.Reader
+	closed bool
+}
+
+func (b *fakeBlob) Close() error {
+	b.closed = true
+	return nil
+}
+
 func getCompileArgs() (Package, []boshmodels.Package) {
 	pkg := Package{
 		BlobstoreID:         "blobstore_id",
@@ -69,30 +86,46 @@ func init() {
 		var (
 			compiler       Compiler
 			compressor     *fakecmd.FakeCompressor
+			extractor      *tarstreamfakes.FakeExtractor
+			blob           *fakeBlob
 			blobstore      *fakeblobdelegator.FakeBlobstoreDelegator
 			fs             *fakesys.FakeFileSystem
 			runner         *fakecmdrunner.FakeFileLoggingCmdRunner
 			packageApplier *fakepackages.FakeApplier
 			packagesBc     *fakebc.FakeBundleCollection
+			compileCache   *fakecomp.FakeCompileCache
+			sandbox        *fakecomp.FakeSandbox
 		)
 
 		BeforeEach(func() {
 			compressor = fakecmd.NewFakeCompressor()
+			extractor = new(tarstreamfakes.FakeExtractor)
+			blob = &fakeBlob{Reader: strings.NewReader("fake-package-blob")}
 			blobstore = &fakeblobdelegator.FakeBlobstoreDelegator{}
+			blobstore.OpenReturns(blob, nil)
 			fs = fakesys.NewFakeFileSystem()
 			runner = fakecmdrunner.NewFakeFileLoggingCmdRunner()
 			packageApplier = fakepackages.NewFakeApplier()
 			packagesBc = fakebc.NewFakeBundleCollection()
+			compileCache = new(fakecomp.FakeCompileCache)
+			sandbox = new(fakecomp.FakeSandbox)
+			sandbox.WrapStub = func(command boshsys.Command, _ SandboxPaths) (boshsys.Command, error) {
+				return command, nil
+			}
 
 			compiler = NewConcreteCompiler(
 				compressor,
+				extractor,
 				blobstore,
 				fs,
 				runner,
 				FakeCompileDirProvider{Dir: "/fake-compile-dir"},
 				packageApplier,
 				packagesBc,
+				compileCache,
+				sandbox,
 				new(fakebc.FakeClock),
+				boshlog.NewLogger(boshlog.LevelNone),
 			)
 
 			fs.MkdirAll("/fake-compile-dir", os.ModePerm)
@@ -128,11 +161,12 @@ func init() {
 					),
 				), nil)
 
-				blobID, digest, err := compiler.Compile(pkg, pkgDeps)
+				result, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(blobID).To(Equal("fake-blob-id"))
-				Expect(digest.String()).To(Equal("978ad524a02039f261773fe93d94973ae7de6470"))
+				Expect(result.BlobID).To(Equal("fake-blob-id"))
+				Expect(result.Digest.String()).To(Equal("978ad524a02039f261773fe93d94973ae7de6470"))
+				Expect(result.CacheHit).To(BeFalse())
 			})
 
 			It("returns blob id and correct sha algo of created compiled package", func() {
@@ -146,13 +180,13 @@ func init() {
 				// Currently algo of source package is used for compilation pkg algo
 				pkg.Sha1 = boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA256, "fakesha"))
 
-				_, digest, err := compiler.Compile(pkg, pkgDeps)
+				result, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 				// echo -n fake-contents|shasum -a 256
-				Expect(digest.String()).To(Equal("sha256:d12d3a3ee8dcdc9e7ea3416fd618298ea50abde2cf434313c6c3edb213f441cd"))
+				Expect(result.Digest.String()).To(Equal("sha256:d12d3a3ee8dcdc9e7ea3416fd618298ea50abde2cf434313c6c3edb213f441cd"))
 
-				Expect(blobstore.GetCallCount()).To(Equal(1))
-				fingerprint, signedURL, blobID, headers := blobstore.GetArgsForCall(0)
+				Expect(blobstore.OpenCallCount()).To(Equal(1))
+				fingerprint, signedURL, blobID, headers := blobstore.OpenArgsForCall(0)
 				Expect(signedURL).To(Equal("/some/signed/url"))
 				Expect(blobID).To(Equal("blobstore_id"))
 				Expect(headers).To(Equal(map[string]string{"key": "value"}))
@@ -160,7 +194,7 @@ func init() {
 			})
 
 			It("cleans up all packages before and after applying dependent packages", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.ActionsCalled).To(Equal([]string{"KeepOnly", "Apply", "Apply", "KeepOnly"}))
 				Expect(packageApplier.KeptOnlyPackages).To(BeEmpty())
@@ -169,7 +203,7 @@ func init() {
 			It("returns an error if cleaning up packages fails", func() {
 				packageApplier.KeepOnlyErr = errors.New("fake-keep-only-error")
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
 			})
@@ -182,7 +216,7 @@ func init() {
 					return nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-error"))
 			})
@@ -195,7 +229,7 @@ func init() {
 					return nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-mkdir-error"))
 			})
@@ -208,7 +242,7 @@ func init() {
 					return nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-error"))
 			})
@@ -216,34 +250,61 @@ func init() {
 			It("returns an error if creating temporary compile target directory during uncompression fails", func() {
 				fs.RegisterMkdirAllError("/fake-compile-dir/pkg_name-bosh-agent-unpack", errors.New("fake-mkdir-error"))
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-mkdir-error"))
 			})
 
+			It("closes the package blob after extracting it", func() {
+				_, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blob.closed).To(BeTrue())
+			})
+
+			It("returns an error if opening the package blob fails", func() {
+				blobstore.OpenReturns(nil, errors.New("fake-open-error"))
+
+				_, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-open-error"))
+				Expect(extractor.ExtractCallCount()).To(Equal(0))
+			})
+
+			It("does not move the extracted files into place if extraction or verification fails", func() {
+				extractor.ExtractReturns(errors.New("fake-extract-error"))
+
+				_, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-extract-error"))
+				Expect(blob.closed).To(BeTrue())
+				Expect(fs.RenameOldPaths).To(BeEmpty())
+				Expect(fs.FileExists("/fake-compile-dir/pkg_name-bosh-agent-unpack")).To(BeFalse())
+				Expect(runner.RunCommands).To(BeEmpty())
+			})
+
 			It("returns an error if target directory is empty during uncompression", func() {
 				pkg.BlobstoreID = ""
 				pkg.PackageGetSignedURL = ""
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("No blobstore reference for package '%s'", pkg.Name))
 			})
 
 			It("installs dependent packages", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.AppliedPackages).To(Equal(pkgDeps))
 			})
 
 			It("cleans up the compile directory", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(fs.FileExists("/fake-compile-dir/pkg_name")).To(BeFalse())
 			})
 
 			It("installs, enables and later cleans up bundle", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(bundle.ActionsCalled).To(Equal([]string{
 					"InstallWithoutContents",
@@ -265,7 +326,7 @@ func init() {
 					return nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-error"))
 			})
@@ -273,14 +334,13 @@ func init() {
 			Context("when packaging script exists", func() {
 				const packagingScriptContents = "hi"
 				BeforeEach(func() {
-					compressor.DecompressFileToDirCallBack = func() {
-						filename := "/fake-compile-dir/pkg_name/" + PackagingScriptName
-						fs.WriteFileString(filename, packagingScriptContents)
+					extractor.ExtractStub = func(_ io.Reader, _ boshcrypto.Digest, targetDir string, _ tarstream.Options) error {
+						return fs.WriteFileString(filepath.Join(targetDir, PackagingScriptName), packagingScriptContents)
 					}
 				})
 
 				It("runs packaging script ", func() {
-					_, _, err := compiler.Compile(pkg, pkgDeps)
+					_, err := compiler.Compile(pkg, pkgDeps)
 					Expect(err).ToNot(HaveOccurred())
 
 					expectedCmd := boshsys.Command{
@@ -308,28 +368,67 @@ func init() {
 					Expect(runner.RunCommandTaskName).To(Equal(PackagingScriptName))
 				})
 
+				It("runs packaging script in the sandbox with access to the dependent packages", func() {
+					packagesBc.FakeGet(boshmodels.LocalPackage{Name: "first_dep_name", Version: "first_dep_version"}).GetDirPath = "/fake-dir/data/packages/first_dep_name/first_dep_version"
+					packagesBc.FakeGet(boshmodels.LocalPackage{Name: "sec_dep_name", Version: "sec_dep_version"}).GetDirPath = "/fake-dir/data/packages/sec_dep_name/sec_dep_version"
+
+					sandboxedCmd := boshsys.Command{Name: "fake-sandbox"}
+					sandbox.WrapReturns(sandboxedCmd, nil)
+
+					_, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(sandbox.WrapCallCount()).To(Equal(1))
+					cmd, paths := sandbox.WrapArgsForCall(0)
+					Expect(cmd.WorkingDir).To(Equal("/fake-compile-dir/pkg_name"))
+					Expect(paths).To(Equal(SandboxPaths{
+						CompilePath: "/fake-compile-dir/pkg_name",
+						InstallPath: "/fake-dir/data/packages/pkg_name/pkg_version",
+						EnablePath:  "/fake-dir/packages/pkg_name",
+						Dependencies: []SandboxDependency{
+							{InstallPath: "/fake-dir/data/packages/first_dep_name/first_dep_version", EnablePath: "/fake-dir/packages/first_dep_name"},
+							{InstallPath: "/fake-dir/data/packages/sec_dep_name/sec_dep_version", EnablePath: "/fake-dir/packages/sec_dep_name"},
+						},
+					}))
+
+					Expect(runner.RunCommands).To(Equal([]boshsys.Command{sandboxedCmd}))
+				})
+
+				It("does not run packaging script when it cannot be sandboxed", func() {
+					sandbox.WrapReturns(boshsys.Command{}, errors.New("fake-sandbox-error"))
+
+					_, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-sandbox-error"))
+					Expect(runner.RunCommands).To(BeEmpty())
+				})
+
 				It("propagates the error from packaging script", func() {
 					runner.RunCommandErr = errors.New("fake-packaging-error")
 
-					_, _, err := compiler.Compile(pkg, pkgDeps)
+					_, err := compiler.Compile(pkg, pkgDeps)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-packaging-error"))
 				})
 			})
 
 			It("does not run packaging script when script does not exist", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(runner.RunCommands).To(BeEmpty())
 			})
 
 			It("compresses compiled package", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 
-				// archive was downloaded from the blobstore and decompress to this temp dir
-				Expect(compressor.DecompressFileToDirDirs[0]).To(Equal("/fake-compile-dir/pkg_name-bosh-agent-unpack"))
-				Expect(compressor.DecompressFileToDirTarballPaths[0]).To(BeEmpty())
+				// archive was streamed from the blobstore and extracted to this temp dir
+				Expect(extractor.ExtractCallCount()).To(Equal(1))
+				source, digest, targetDir, options := extractor.ExtractArgsForCall(0)
+				Expect(source).To(Equal(blob))
+				Expect(digest).To(Equal(pkg.Sha1))
+				Expect(targetDir).To(Equal("/fake-compile-dir/pkg_name-bosh-agent-unpack"))
+				Expect(options).To(Equal(tarstream.Options{}))
 
 				// contents were moved from the temp dir to the install/enable dir
 				Expect(fs.RenameOldPaths[0]).To(Equal("/fake-compile-dir/pkg_name-bosh-agent-unpack"))
@@ -343,7 +442,7 @@ func init() {
 			It("uploads compressed package to blobstore", func() {
 				compressor.CompressFilesInDirTarballPath = "/tmp/compressed-compiled-package"
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 
 				_, filePathArg, headers := blobstore.WriteArgsForCall(0)
@@ -354,11 +453,78 @@ func init() {
 			It("returs error if uploading compressed package fails", func() {
 				blobstore.WriteReturns("", boshcrypto.MultipleDigest{}, errors.New("fake-create-err"))
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-create-err"))
 			})
 
+			It("adds the uploaded package to the compile cache", func() {
+				uploadedDigest := boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "uploaded-sha1"))
+				blobstore.WriteReturns("fake-blob-id", uploadedDigest, nil)
+
+				_, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(compileCache.PutCallCount()).To(Equal(1))
+				cachedPkg, cachedDeps, tarballPath, digest := compileCache.PutArgsForCall(0)
+				Expect(cachedPkg).To(Equal(pkg))
+				Expect(cachedDeps).To(Equal(pkgDeps))
+				Expect(tarballPath).To(Equal("/tmp/compressed-compiled-package"))
+				Expect(digest).To(Equal(uploadedDigest))
+			})
+
+			It("does not fail compilation when the compile cache fails", func() {
+				compileCache.GetReturns("", false, errors.New("fake-get-error"))
+				compileCache.PutReturns(errors.New("fake-put-error"))
+
+				_, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobstore.WriteCallCount()).To(Equal(1))
+			})
+
+			Context("when the compiled package is in the compile cache", func() {
+				BeforeEach(func() {
+					compileCache.GetReturns("/fake-compile-cache/fake-key.tgz", true, nil)
+					Expect(fs.WriteFileString("/fake-compile-cache/fake-key.tgz", "cached")).To(Succeed())
+					blobstore.WriteReturns("fake-blob-id", boshcrypto.MustNewMultipleDigest(
+						boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "cached-sha1"),
+					), nil)
+				})
+
+				It("uploads the cached package without compiling it", func() {
+					result, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(result.BlobID).To(Equal("fake-blob-id"))
+					Expect(result.Digest.String()).To(Equal("cached-sha1"))
+					Expect(result.CacheHit).To(BeTrue())
+
+					cachedPkg, cachedDeps := compileCache.GetArgsForCall(0)
+					Expect(cachedPkg).To(Equal(pkg))
+					Expect(cachedDeps).To(Equal(pkgDeps))
+
+					_, filePathArg, headers := blobstore.WriteArgsForCall(0)
+					Expect(filePathArg).To(Equal("/fake-compile-cache/fake-key.tgz"))
+					Expect(headers).To(Equal(map[string]string{"key": "value"}))
+
+					Expect(blobstore.OpenCallCount()).To(Equal(0))
+					Expect(packageApplier.ActionsCalled).To(BeEmpty())
+					Expect(runner.RunCommands).To(BeEmpty())
+					Expect(compressor.CompressFilesInDirDir).To(BeEmpty())
+					Expect(compileCache.PutCallCount()).To(Equal(0))
+					Expect(fs.FileExists("/fake-compile-cache/fake-key.tgz")).To(BeFalse())
+				})
+
+				It("returns an error if uploading the cached package fails", func() {
+					blobstore.WriteReturns("", boshcrypto.MultipleDigest{}, errors.New("fake-create-err"))
+
+					_, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-create-err"))
+					Expect(fs.FileExists("/fake-compile-cache/fake-key.tgz")).To(BeFalse())
+				})
+			})
+
 			It("cleans up compressed package after uploading it to blobstore", func() {
 				var beforeCleanUpTarballPath, afterCleanUpTarballPath string
 
@@ -367,7 +533,7 @@ func init() {
 					return "my-blob-id", boshcrypto.MultipleDigest{}, nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 
 				// Compressed package is not cleaned up before blobstore upload
@@ -3,6 +3,7 @@ package compiler_test
 import (
 	"errors"
 	"os"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -13,9 +14,12 @@ import (
 	fakepackages "github.com/cloudfoundry/bosh-agent/agent/applier/packages/fakes"
 	fakecmdrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner/fakes"
 	. "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	fakecomp "github.com/cloudfoundry/bosh-agent/agent/compiler/fakes"
 	fakeblobdelegator "github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator/blobstore_delegatorfakes"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream/tarstreamfakes"
 
 	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
@@ -24,32 +28,41 @@ func init() {
 		var (
 			compiler       Compiler
 			compressor     *fakecmd.FakeCompressor
+			extractor      *tarstreamfakes.FakeExtractor
 			blobstore      *fakeblobdelegator.FakeBlobstoreDelegator
 			fs             *fakesys.FakeFileSystem
 			runner         *fakecmdrunner.FakeFileLoggingCmdRunner
 			packageApplier *fakepackages.FakeApplier
 			packagesBc     *fakebc.FakeBundleCollection
+			compileCache   *fakecomp.FakeCompileCache
 			fakeClock      *fakebc.FakeClock
 		)
 
 		BeforeEach(func() {
 			compressor = fakecmd.NewFakeCompressor()
+			extractor = new(tarstreamfakes.FakeExtractor)
 			blobstore = &fakeblobdelegator.FakeBlobstoreDelegator{}
+			blobstore.OpenReturns(&fakeBlob{Reader: strings.NewReader("fake-package-blob")}, nil)
 			fs = fakesys.NewFakeFileSystem()
 			runner = fakecmdrunner.NewFakeFileLoggingCmdRunner()
 			packageApplier = fakepackages.NewFakeApplier()
 			packagesBc = fakebc.NewFakeBundleCollection()
+			compileCache = new(fakecomp.FakeCompileCache)
 			fakeClock = new(fakebc.FakeClock)
 
 			compiler = NewConcreteCompiler(
 				compressor,
+				extractor,
 				blobstore,
 				fs,
 				runner,
 				FakeCompileDirProvider{Dir: "/fake-compile-dir"},
 				packageApplier,
 				packagesBc,
+				compileCache,
+				new(fakecomp.FakeSandbox),
 				fakeClock,
+				boshlog.NewLogger(boshlog.LevelNone),
 			)
 
 			fs.MkdirAll("/fake-compile-dir", os.ModePerm)
@@ -92,7 +105,7 @@ func init() {
 					return nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(fs.RenameOldPaths[0]).To(Equal("/fake-compile-dir/pkg_name-bosh-agent-unpack"))
@@ -116,7 +129,7 @@ func init() {
 				fakeClock.NowReturns(startTime)
 				fakeClock.SinceReturns(CompileTimeout + time.Second)
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).To(MatchError(ContainSubstring("can't perform filesystem rename")))
 
 				Expect(fakeClock.SinceCallCount()).To(Equal(1))
@@ -7,11 +7,12 @@ import (
 )
 
 type FakeCompiler struct {
-	CompilePkg    boshcomp.Package
-	CompileDeps   []boshmodels.Package
-	CompileBlobID string
-	CompileDigest boshcrypto.Digest
-	CompileErr    error
+	CompilePkg      boshcomp.Package
+	CompileDeps     []boshmodels.Package
+	CompileBlobID   string
+	CompileDigest   boshcrypto.Digest
+	CompileCacheHit bool
+	CompileErr      error
 }
 
 func NewFakeCompiler() (c *FakeCompiler) {
@@ -19,11 +20,12 @@ func NewFakeCompiler() (c *FakeCompiler) {
 	return
 }
 
-func (c *FakeCompiler) Compile(pkg boshcomp.Package, deps []boshmodels.Package) (blobID string, digest boshcrypto.Digest, err error) {
+func (c *FakeCompiler) Compile(pkg boshcomp.Package, deps []boshmodels.Package) (boshcomp.CompileResult, error) {
 	c.CompilePkg = pkg
 	c.CompileDeps = deps
-	blobID = c.CompileBlobID
-	digest = c.CompileDigest
-	err = c.CompileErr
-	return
+	return boshcomp.CompileResult{
+		BlobID:   c.CompileBlobID,
+		Digest:   c.CompileDigest,
+		CacheHit: c.CompileCacheHit,
+	}, c.CompileErr
 }
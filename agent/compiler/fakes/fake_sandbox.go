@@ -0,0 +1,119 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/bosh-agent/agent/compiler"
+	"github.com/cloudfoundry/bosh-utils/system"
+)
+
+type FakeSandbox struct {
+	WrapStub        func(system.Command, compiler.SandboxPaths) (system.Command, error)
+	wrapMutex       sync.RWMutex
+	wrapArgsForCall []struct {
+		arg1 system.Command
+		arg2 compiler.SandboxPaths
+	}
+	wrapReturns struct {
+		result1 system.Command
+		result2 error
+	}
+	wrapReturnsOnCall map[int]struct {
+		result1 system.Command
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeSandbox) Wrap(arg1 system.Command, arg2 compiler.SandboxPaths) (system.Command, error) {
+	fake.wrapMutex.Lock()
+	ret, specificReturn := fake.wrapReturnsOnCall[len(fake.wrapArgsForCall)]
+	fake.wrapArgsForCall = append(fake.wrapArgsForCall, struct {
+		arg1 system.Command
+		arg2 compiler.SandboxPaths
+	}{arg1, arg2})
+	stub := fake.WrapStub
+	fakeReturns := fake.wrapReturns
+	fake.recordInvocation("Wrap", []interface{}{arg1, arg2})
+	fake.wrapMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSandbox) WrapCallCount() int {
+	fake.wrapMutex.RLock()
+	defer fake.wrapMutex.RUnlock()
+	return len(fake.wrapArgsForCall)
+}
+
+func (fake *FakeSandbox) WrapCalls(stub func(system.Command, compiler.SandboxPaths) (system.Command, error)) {
+	fake.wrapMutex.Lock()
+	defer fake.wrapMutex.Unlock()
+	fake.WrapStub = stub
+}
+
+func (fake *FakeSandbox) WrapArgsForCall(i int) (system.Command, compiler.SandboxPaths) {
+	fake.wrapMutex.RLock()
+	defer fake.wrapMutex.RUnlock()
+	argsForCall := fake.wrapArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSandbox) WrapReturns(result1 system.Command, result2 error) {
+	fake.wrapMutex.Lock()
+	defer fake.wrapMutex.Unlock()
+	fake.WrapStub = nil
+	fake.wrapReturns = struct {
+		result1 system.Command
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSandbox) WrapReturnsOnCall(i int, result1 system.Command, result2 error) {
+	fake.wrapMutex.Lock()
+	defer fake.wrapMutex.Unlock()
+	fake.WrapStub = nil
+	if fake.wrapReturnsOnCall == nil {
+		fake.wrapReturnsOnCall = make(map[int]struct {
+			result1 system.Command
+			result2 error
+		})
+	}
+	fake.wrapReturnsOnCall[i] = struct {
+		result1 system.Command
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSandbox) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.wrapMutex.RLock()
+	defer fake.wrapMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeSandbox) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ compiler.Sandbox = new(FakeSandbox)
@@ -0,0 +1,202 @@
+package compiler
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const sandboxLogTag = "namespaceSandbox"
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -o fakes/fake_sandbox.go . Sandbox
+
+// Sandbox confines packaging scripts to the directories they compile in,
+// install into and depend on.
+type Sandbox interface {
+	Wrap(command boshsys.Command, paths SandboxPaths) (boshsys.Command, error)
+}
+
+type SandboxPaths struct {
+	CompilePath string
+	InstallPath string
+	EnablePath  string
+
+	Dependencies []SandboxDependency
+}
+
+type SandboxDependency struct {
+	InstallPath string
+	EnablePath  string
+}
+
+type namespaceSandbox struct {
+	fs       boshsys.FileSystem
+	runner   boshsys.CmdRunner
+	rootPath string
+	options  boshsettings.CompileSandbox
+	logger   boshlog.Logger
+}
+
+// NewNamespaceSandbox returns a sandbox that runs commands in separate
+// mount, PID and network namespaces with a read-only root file system
+// assembled below rootPath. Commands are returned unchanged when the
+// sandbox is not enabled.
+func NewNamespaceSandbox(
+	fs boshsys.FileSystem,
+	runner boshsys.CmdRunner,
+	rootPath string,
+	options boshsettings.CompileSandbox,
+	logger boshlog.Logger,
+) Sandbox {
+	if options.User == "" {
+		options.User = "vcap"
+	}
+
+	return namespaceSandbox{
+		fs:       fs,
+		runner:   runner,
+		rootPath: rootPath,
+		options:  options,
+		logger:   logger,
+	}
+}
+
+func (s namespaceSandbox) Wrap(command boshsys.Command, paths SandboxPaths) (boshsys.Command, error) {
+	if !s.options.Enabled {
+		return command, nil
+	}
+
+	err := s.checkSupport()
+	if err != nil {
+		return boshsys.Command{}, err
+	}
+
+	s.logger.Debug(sandboxLogTag, "Sandboxing %s in %s as %s", command.Name, paths.CompilePath, s.options.User)
+
+	args := []string{"--mount", "--pid", "--net", "--fork", "--", "bash", "-e", "-c", s.script(paths), "bosh-compile-sandbox", command.Name}
+
+	return boshsys.Command{
+		Name:       "unshare",
+		Args:       append(args, command.Args...),
+		Env:        command.Env,
+		WorkingDir: command.WorkingDir,
+	}, nil
+}
+
+// checkSupport makes sure that compilation fails instead of silently running
+// packaging scripts unconfined when the sandbox cannot be set up
+func (s namespaceSandbox) checkSupport() error {
+	if runtime.GOOS != "linux" {
+		return bosherr.Errorf("Compilation sandbox is enabled but not supported on %s", runtime.GOOS)
+	}
+
+	for _, namespace := range []string{"mnt", "pid", "net"} {
+		if !s.fs.FileExists(path.Join("/proc/self/ns", namespace)) {
+			return bosherr.Errorf("Compilation sandbox is enabled but the kernel does not support %s namespaces", namespace)
+		}
+	}
+
+	for _, cmdName := range []string{"unshare", "mount", "chroot", "setpriv"} {
+		if !s.runner.CommandExists(cmdName) {
+			return bosherr.Errorf("Compilation sandbox is enabled but '%s' is not installed", cmdName)
+		}
+	}
+
+	return nil
+}
+
+// script builds the root file system of the sandbox in a private tmpfs and
+// runs the command passed as arguments in it as the unprivileged user. Only
+// system directories, dependency packages and the compile and install paths
+// are visible, and only the latter two are writable.
+func (s namespaceSandbox) script(paths SandboxPaths) string {
+	var script strings.Builder
+
+	fmt.Fprintf(&script, "root=%s\n", shellQuote(s.rootPath))
+	fmt.Fprintf(&script, "user_id=$(id -u %s)\n", shellQuote(s.options.User))
+	fmt.Fprintf(&script, "group_id=$(id -g %s)\n", shellQuote(s.options.User))
+
+	script.WriteString(`
+bind() {
+  mkdir -p "$root$1"
+  mount --bind "$1" "$root$1"
+  if [ "$2" = ro ]; then mount -o remount,bind,ro,nosuid,nodev "$root$1"; fi
+}
+
+link() {
+  mkdir -p "$root$(dirname "$2")"
+  ln -s "$1" "$root$2"
+}
+
+mkdir -p "$root"
+mount -t tmpfs -o mode=0755 bosh-compile-sandbox "$root"
+
+for dir in /bin /sbin /lib /lib32 /lib64 /libx32 /usr /etc; do
+  if [ -L "$dir" ]; then
+    ln -s "$(readlink "$dir")" "$root$dir"
+  elif [ -d "$dir" ]; then
+    bind "$dir" ro
+  fi
+done
+
+mkdir -p "$root/dev" "$root/proc" "$root/tmp"
+mount --rbind /dev "$root/dev"
+mount -t proc -o nosuid,nodev,noexec proc "$root/proc"
+mount -t tmpfs -o mode=1777,nosuid,nodev bosh-compile-tmp "$root/tmp"
+
+if command -v ip >/dev/null; then ip link set lo up; fi
+`)
+
+	for _, dep := range paths.Dependencies {
+		fmt.Fprintf(&script, "bind %s ro\n", shellQuote(dep.InstallPath))
+		fmt.Fprintf(&script, "link %s %s\n", shellQuote(dep.InstallPath), shellQuote(dep.EnablePath))
+	}
+
+	installPath := shellQuote(paths.InstallPath)
+	compilePath := shellQuote(paths.CompilePath)
+
+	fmt.Fprintf(&script, "chown -R \"$user_id:$group_id\" %s %s\n", installPath, compilePath)
+	fmt.Fprintf(&script, "bind %s rw\n", installPath)
+	fmt.Fprintf(&script, "link %s %s\n", installPath, shellQuote(paths.EnablePath))
+	fmt.Fprintf(&script, "bind %s rw\n", compilePath)
+
+	script.WriteString("\nstatus=0\n(\n")
+
+	limits := []struct {
+		flag  string
+		value uint64
+	}{
+		{"-v", s.options.MaxMemoryInMB * 1024},
+		{"-u", s.options.MaxProcesses},
+		{"-n", s.options.MaxOpenFiles},
+		{"-t", s.options.MaxCPUTimeInSeconds},
+	}
+
+	for _, limit := range limits {
+		if limit.value > 0 {
+			fmt.Fprintf(&script, "  ulimit %s %d\n", limit.flag, limit.value)
+		}
+	}
+
+	fmt.Fprintf(&script, `  exec chroot "$root" setpriv --reuid="$user_id" --regid="$group_id" --init-groups --no-new-privs --inh-caps=-all \
+    bash -c 'cd "$0" && exec "$@"' %s "$@"
+) || status=$?
+
+`, compilePath)
+
+	// Compiled packages are owned by root like the ones installed from tarballs
+	fmt.Fprintf(&script, "chown -R 0:0 %s\n", installPath)
+	script.WriteString("exit $status\n")
+
+	return script.String()
+}
+
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
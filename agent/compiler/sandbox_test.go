@@ -0,0 +1,153 @@
+package compiler_test
+
+import (
+	"runtime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("namespaceSandbox", func() {
+	var (
+		fs      *fakesys.FakeFileSystem
+		runner  *fakesys.FakeCmdRunner
+		options boshsettings.CompileSandbox
+		command boshsys.Command
+		paths   SandboxPaths
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		runner = fakesys.NewFakeCmdRunner()
+		options = boshsettings.CompileSandbox{}
+
+		command = boshsys.Command{
+			Name:       "bash",
+			Args:       []string{"-x", "packaging"},
+			Env:        map[string]string{"BOSH_INSTALL_TARGET": "/fake-dir/packages/pkg_name"},
+			WorkingDir: "/fake-compile-dir/pkg_name",
+		}
+
+		paths = SandboxPaths{
+			CompilePath: "/fake-compile-dir/pkg_name",
+			InstallPath: "/fake-dir/data/packages/pkg_name/pkg_version",
+			EnablePath:  "/fake-dir/packages/pkg_name",
+			Dependencies: []SandboxDependency{
+				{InstallPath: "/fake-dir/data/packages/dep_name/dep_version", EnablePath: "/fake-dir/packages/dep_name"},
+			},
+		}
+	})
+
+	wrap := func() (boshsys.Command, error) {
+		sandbox := NewNamespaceSandbox(fs, runner, "/fake-dir/data/compile_sandbox", options, boshlog.NewLogger(boshlog.LevelNone))
+		return sandbox.Wrap(command, paths)
+	}
+
+	It("returns the command unchanged when it is not enabled", func() {
+		wrapped, err := wrap()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wrapped).To(Equal(command))
+	})
+
+	Context("when it is enabled", func() {
+		BeforeEach(func() {
+			if runtime.GOOS != "linux" {
+				Skip("namespaces are only supported on linux")
+			}
+
+			options.Enabled = true
+
+			for _, namespace := range []string{"mnt", "pid", "net"} {
+				Expect(fs.WriteFileString("/proc/self/ns/"+namespace, "")).To(Succeed())
+			}
+
+			runner.CommandExistsValue = true
+		})
+
+		It("runs the command in new mount, pid and network namespaces", func() {
+			wrapped, err := wrap()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(wrapped.Name).To(Equal("unshare"))
+			Expect(wrapped.Args[:8]).To(Equal([]string{"--mount", "--pid", "--net", "--fork", "--", "bash", "-e", "-c"}))
+			Expect(wrapped.Args[9:]).To(Equal([]string{"bosh-compile-sandbox", "bash", "-x", "packaging"}))
+			Expect(wrapped.Env).To(Equal(command.Env))
+			Expect(wrapped.WorkingDir).To(Equal(command.WorkingDir))
+		})
+
+		It("only makes the dependencies visible and the compile and install paths writable", func() {
+			wrapped, err := wrap()
+			Expect(err).ToNot(HaveOccurred())
+
+			script := wrapped.Args[8]
+			Expect(script).To(ContainSubstring("root='/fake-dir/data/compile_sandbox'\n"))
+			Expect(script).To(ContainSubstring("bind '/fake-dir/data/packages/dep_name/dep_version' ro\n"))
+			Expect(script).To(ContainSubstring("link '/fake-dir/data/packages/dep_name/dep_version' '/fake-dir/packages/dep_name'\n"))
+			Expect(script).To(ContainSubstring("bind '/fake-dir/data/packages/pkg_name/pkg_version' rw\n"))
+			Expect(script).To(ContainSubstring("link '/fake-dir/data/packages/pkg_name/pkg_version' '/fake-dir/packages/pkg_name'\n"))
+			Expect(script).To(ContainSubstring("bind '/fake-compile-dir/pkg_name' rw\n"))
+		})
+
+		It("runs the command as vcap by default", func() {
+			wrapped, err := wrap()
+			Expect(err).ToNot(HaveOccurred())
+
+			script := wrapped.Args[8]
+			Expect(script).To(ContainSubstring("user_id=$(id -u 'vcap')\n"))
+			Expect(script).To(ContainSubstring(`setpriv --reuid="$user_id" --regid="$group_id" --init-groups --no-new-privs`))
+		})
+
+		It("runs the command as the configured user", func() {
+			options.User = "fake-user"
+
+			wrapped, err := wrap()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wrapped.Args[8]).To(ContainSubstring("user_id=$(id -u 'fake-user')\n"))
+		})
+
+		It("quotes paths in the script", func() {
+			paths.CompilePath = "/fake-compile-dir/it's"
+
+			wrapped, err := wrap()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wrapped.Args[8]).To(ContainSubstring(`bind '/fake-compile-dir/it'\''s' rw`))
+		})
+
+		It("applies only the configured resource limits", func() {
+			options.MaxMemoryInMB = 2048
+			options.MaxProcesses = 512
+
+			wrapped, err := wrap()
+			Expect(err).ToNot(HaveOccurred())
+
+			script := wrapped.Args[8]
+			Expect(script).To(ContainSubstring("ulimit -v 2097152\n"))
+			Expect(script).To(ContainSubstring("ulimit -u 512\n"))
+			Expect(script).ToNot(ContainSubstring("ulimit -n"))
+			Expect(script).ToNot(ContainSubstring("ulimit -t"))
+		})
+
+		It("returns an error when the kernel does not support a namespace", func() {
+			Expect(fs.RemoveAll("/proc/self/ns/pid")).To(Succeed())
+
+			_, err := wrap()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("kernel does not support pid namespaces"))
+		})
+
+		It("returns an error when a required command is missing", func() {
+			runner.CommandExistsValue = false
+			runner.AvailableCommands = map[string]bool{"unshare": true, "mount": true, "chroot": true}
+
+			_, err := wrap()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("'setpriv' is not installed"))
+		})
+	})
+})
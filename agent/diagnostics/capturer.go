@@ -0,0 +1,292 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	capturerLogTag = "diagnosticsCapturer"
+
+	defaultLogLines   = 100
+	maxLogFiles       = 20
+	maxTailBytes      = 64 * 1024
+	maxKernelMessages = 100
+	maxSnapshots      = 5
+
+	snapshotTimeFormat = "20060102T150405Z"
+)
+
+// Service and job names end up in paths, so only names that cannot
+// leave the directories they are joined to are accepted
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . Capturer
+
+type Capturer interface {
+	// Capture writes a diagnostic snapshot for the given monit service
+	// and returns the path of the snapshot file. Services that are not
+	// known to the job supervisor are refused.
+	Capture(service string, options boshsettings.Diagnostics) (string, error)
+}
+
+type Snapshot struct {
+	Service        string                 `json:"service"`
+	Job            string                 `json:"job,omitempty"`
+	CapturedAt     int64                  `json:"captured_at"`
+	Vitals         *boshvitals.Vitals     `json:"vitals,omitempty"`
+	Processes      []boshjobsuper.Process `json:"processes,omitempty"`
+	Logs           map[string][]string    `json:"logs,omitempty"`
+	KernelMessages []string               `json:"kernel_messages,omitempty"`
+
+	// Parts of the snapshot that could not be collected
+	Errors []string `json:"errors,omitempty"`
+}
+
+type concreteCapturer struct {
+	fs            boshsys.FileSystem
+	runner        boshsys.CmdRunner
+	vitalsService boshvitals.Service
+	jobSupervisor boshjobsuper.JobSupervisor
+	dirProvider   boshdir.Provider
+	timeService   clock.Clock
+	logger        boshlog.Logger
+}
+
+func NewCapturer(
+	fs boshsys.FileSystem,
+	runner boshsys.CmdRunner,
+	vitalsService boshvitals.Service,
+	jobSupervisor boshjobsuper.JobSupervisor,
+	dirProvider boshdir.Provider,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) Capturer {
+	return concreteCapturer{
+		fs:            fs,
+		runner:        runner,
+		vitalsService: vitalsService,
+		jobSupervisor: jobSupervisor,
+		dirProvider:   dirProvider,
+		timeService:   timeService,
+		logger:        logger,
+	}
+}
+
+// Capture collects as much of the snapshot as it can; parts that fail are
+// recorded in Snapshot.Errors instead of failing the whole capture. Logs are
+// read from the log directory of the job the service belongs to. Only the
+// most recent snapshots of each service are kept.
+func (c concreteCapturer) Capture(service string, options boshsettings.Diagnostics) (string, error) {
+	if !validName(service) {
+		return "", bosherr.Errorf("Invalid service name '%s'", service)
+	}
+
+	processes, err := c.jobSupervisor.Processes()
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Getting processes to look up service '%s'", service)
+	}
+
+	process, found := findProcess(processes, service)
+	if !found {
+		return "", bosherr.Errorf("Unknown service '%s'", service)
+	}
+
+	logLines := options.LogLines
+	if logLines <= 0 {
+		logLines = defaultLogLines
+	}
+
+	capturedAt := c.timeService.Now().UTC()
+
+	snapshot := Snapshot{
+		Service:    service,
+		CapturedAt: capturedAt.Unix(),
+		Processes:  processes,
+	}
+
+	vitals, err := c.vitalsService.Get()
+	if err != nil {
+		snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("Getting vitals: %s", err.Error()))
+	} else {
+		snapshot.Vitals = &vitals
+	}
+
+	snapshotDir := filepath.Join(c.dirProvider.AgentLogsDir(), "diagnostics")
+
+	if validName(process.Job) {
+		snapshot.Job = process.Job
+		jobLogDir := c.dirProvider.JobLogDir(process.Job)
+
+		if c.fs.FileExists(jobLogDir) {
+			snapshotDir = filepath.Join(jobLogDir, "diagnostics")
+
+			snapshot.Logs, err = c.tailLogs(jobLogDir, logLines)
+			if err != nil {
+				snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("Reading logs: %s", err.Error()))
+			}
+		}
+	}
+
+	snapshot.KernelMessages, err = c.kernelMessages()
+	if err != nil {
+		snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("Reading kernel messages: %s", err.Error()))
+	}
+
+	snapshotBytes, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", bosherr.WrapError(err, "Marshalling snapshot")
+	}
+
+	err = c.fs.MkdirAll(snapshotDir, os.FileMode(0750))
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Creating %s", snapshotDir)
+	}
+
+	snapshotPath := filepath.Join(snapshotDir, fmt.Sprintf("%s-%s.json", service, capturedAt.Format(snapshotTimeFormat)))
+
+	err = c.fs.WriteFile(snapshotPath, snapshotBytes)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Writing %s", snapshotPath)
+	}
+
+	c.removeOldSnapshots(snapshotDir, service)
+
+	return snapshotPath, nil
+}
+
+func (c concreteCapturer) tailLogs(logDir string, lines int) (map[string][]string, error) {
+	logPaths, err := c.fs.Glob(filepath.Join(logDir, "*.log"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(logPaths)
+	if len(logPaths) > maxLogFiles {
+		logPaths = logPaths[:maxLogFiles]
+	}
+
+	logs := map[string][]string{}
+
+	for _, logPath := range logPaths {
+		tail, err := c.tailFile(logPath, lines)
+		if err != nil {
+			return logs, bosherr.WrapErrorf(err, "Reading %s", logPath)
+		}
+
+		logs[filepath.Base(logPath)] = tail
+	}
+
+	return logs, nil
+}
+
+func (c concreteCapturer) tailFile(path string, lines int) ([]string, error) {
+	file, err := c.fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := info.Size() - maxTailBytes
+	if offset < 0 {
+		offset = 0
+	}
+
+	buf := make([]byte, info.Size()-offset)
+
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && n < len(buf) {
+		return nil, err
+	}
+
+	return lastLines(string(buf[:n]), lines), nil
+}
+
+func (c concreteCapturer) kernelMessages() ([]string, error) {
+	stdout, _, _, err := c.runner.RunCommandQuietly("dmesg")
+	if err != nil {
+		return nil, err
+	}
+
+	return lastLines(stdout, maxKernelMessages), nil
+}
+
+// removeOldSnapshots removes all but the most recent snapshots of the
+// service, leaving the ones of services whose name starts with its name
+func (c concreteCapturer) removeOldSnapshots(snapshotDir, service string) {
+	paths, err := c.fs.Glob(filepath.Join(snapshotDir, service+"-*.json"))
+	if err != nil {
+		c.logger.Warn(capturerLogTag, "Failed to list snapshots in %s: %s", snapshotDir, err.Error())
+		return
+	}
+
+	var snapshots []string
+
+	for _, path := range paths {
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), service+"-"), ".json")
+
+		_, err := time.Parse(snapshotTimeFormat, timestamp)
+		if err == nil {
+			snapshots = append(snapshots, path)
+		}
+	}
+
+	sort.Strings(snapshots)
+
+	for len(snapshots) > maxSnapshots {
+		err = c.fs.RemoveAll(snapshots[0])
+		if err != nil {
+			c.logger.Warn(capturerLogTag, "Failed to remove snapshot %s: %s", snapshots[0], err.Error())
+		}
+
+		snapshots = snapshots[1:]
+	}
+}
+
+func findProcess(processes []boshjobsuper.Process, name string) (boshjobsuper.Process, bool) {
+	for _, process := range processes {
+		if process.Name == name {
+			return process, true
+		}
+	}
+
+	return boshjobsuper.Process{}, false
+}
+
+func validName(name string) bool {
+	return nameRegexp.MatchString(name) && name != "." && name != ".."
+}
+
+func lastLines(s string, count int) []string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+
+	return lines
+}
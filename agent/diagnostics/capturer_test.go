@@ -0,0 +1,213 @@
+package diagnostics_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/diagnostics"
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	"github.com/cloudfoundry/bosh-agent/platform/vitals/vitalsfakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("concreteCapturer", func() {
+	var (
+		fs            *fakesys.FakeFileSystem
+		runner        *fakesys.FakeCmdRunner
+		vitalsService *vitalsfakes.FakeService
+		jobSupervisor *fakejobsuper.FakeJobSupervisor
+		timeService   *fakeclock.FakeClock
+		capturer      Capturer
+	)
+
+	readSnapshot := func(path string) Snapshot {
+		contents, err := fs.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		var snapshot Snapshot
+		err = json.Unmarshal(contents, &snapshot)
+		Expect(err).ToNot(HaveOccurred())
+
+		return snapshot
+	}
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		runner = fakesys.NewFakeCmdRunner()
+		vitalsService = &vitalsfakes.FakeService{}
+		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
+		timeService = fakeclock.NewFakeClock(time.Date(2021, 5, 22, 20, 7, 41, 0, time.UTC))
+
+		capturer = NewCapturer(
+			fs,
+			runner,
+			vitalsService,
+			jobSupervisor,
+			boshdir.NewProvider("/var/vcap"),
+			timeService,
+			boshlog.NewLogger(boshlog.LevelNone),
+		)
+
+		vitalsService.GetReturns(boshvitals.Vitals{Load: []string{"1.00", "0.50", "0.25"}}, nil)
+		jobSupervisor.ProcessesStatus = []boshjobsuper.Process{
+			{Name: "web", Job: "web", State: "failing"},
+			{Name: "web_metrics", Job: "web", State: "running"},
+			{Name: "web-worker", State: "running"},
+		}
+		runner.AddCmdResult("dmesg", fakesys.FakeCmdResult{Stdout: "[1.0] boot\n[2.0] Out of memory: Killed process 123 (web)\n"})
+	})
+
+	Context("when the service has a job log directory", func() {
+		BeforeEach(func() {
+			err := fs.MkdirAll("/var/vcap/data/sys/log/web", 0750)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = fs.WriteFileString("/var/vcap/data/sys/log/web/web.stdout.log", "line 1\nline 2\nline 3\n")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = fs.WriteFileString("/var/vcap/data/sys/log/web/web.stderr.log", "")
+			Expect(err).ToNot(HaveOccurred())
+
+			fs.SetGlob("/var/vcap/data/sys/log/web/*.log", []string{
+				"/var/vcap/data/sys/log/web/web.stdout.log",
+				"/var/vcap/data/sys/log/web/web.stderr.log",
+			})
+		})
+
+		It("writes a snapshot of logs, vitals, processes and kernel messages into it", func() {
+			path, err := capturer.Capture("web", boshsettings.Diagnostics{Enabled: true, LogLines: 2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal("/var/vcap/data/sys/log/web/diagnostics/web-20210522T200741Z.json"))
+
+			Expect(readSnapshot(path)).To(Equal(Snapshot{
+				Service:    "web",
+				Job:        "web",
+				CapturedAt: timeService.Now().Unix(),
+				Vitals:     &boshvitals.Vitals{Load: []string{"1.00", "0.50", "0.25"}},
+				Processes:  jobSupervisor.ProcessesStatus,
+				Logs: map[string][]string{
+					"web.stdout.log": {"line 2", "line 3"},
+					"web.stderr.log": nil,
+				},
+				KernelMessages: []string{"[1.0] boot", "[2.0] Out of memory: Killed process 123 (web)"},
+			}))
+		})
+
+		It("writes snapshots of the other services of the job into it", func() {
+			path, err := capturer.Capture("web_metrics", boshsettings.Diagnostics{Enabled: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal("/var/vcap/data/sys/log/web/diagnostics/web_metrics-20210522T200741Z.json"))
+
+			snapshot := readSnapshot(path)
+			Expect(snapshot.Service).To(Equal("web_metrics"))
+			Expect(snapshot.Job).To(Equal("web"))
+			Expect(snapshot.Logs).To(HaveKey("web.stdout.log"))
+		})
+
+		It("keeps only the most recent snapshots of the service", func() {
+			var snapshots []string
+			for i := 0; i < 6; i++ {
+				snapshots = append(snapshots, fmt.Sprintf("/var/vcap/data/sys/log/web/diagnostics/web-2021052%dT000000Z.json", i))
+				err := fs.WriteFileString(snapshots[i], "{}")
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			otherSnapshot := "/var/vcap/data/sys/log/web/diagnostics/web-worker-20210519T000000Z.json"
+			err := fs.WriteFileString(otherSnapshot, "{}")
+			Expect(err).ToNot(HaveOccurred())
+
+			fs.SetGlob("/var/vcap/data/sys/log/web/diagnostics/web-*.json", append([]string{otherSnapshot}, snapshots...))
+
+			_, err = capturer.Capture("web", boshsettings.Diagnostics{Enabled: true})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists(snapshots[0])).To(BeFalse())
+			for _, snapshot := range snapshots[1:] {
+				Expect(fs.FileExists(snapshot)).To(BeTrue())
+			}
+			Expect(fs.FileExists(otherSnapshot)).To(BeTrue())
+		})
+	})
+
+	Context("when the service does not have a job log directory", func() {
+		It("writes the snapshot into the agent log directory", func() {
+			path, err := capturer.Capture("web-worker", boshsettings.Diagnostics{Enabled: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal("/var/vcap/bosh/log/diagnostics/web-worker-20210522T200741Z.json"))
+			Expect(readSnapshot(path).Logs).To(BeNil())
+		})
+	})
+
+	It("records the parts that could not be collected", func() {
+		vitalsService.GetReturns(boshvitals.Vitals{}, errors.New("fake-vitals-err"))
+		runner = fakesys.NewFakeCmdRunner()
+		runner.AddCmdResult("dmesg", fakesys.FakeCmdResult{Error: errors.New("fake-dmesg-err")})
+		capturer = NewCapturer(fs, runner, vitalsService, jobSupervisor, boshdir.NewProvider("/var/vcap"), timeService, boshlog.NewLogger(boshlog.LevelNone))
+
+		path, err := capturer.Capture("web", boshsettings.Diagnostics{Enabled: true})
+		Expect(err).ToNot(HaveOccurred())
+
+		snapshot := readSnapshot(path)
+		Expect(snapshot.Vitals).To(BeNil())
+		Expect(strings.Join(snapshot.Errors, "\n")).To(And(
+			ContainSubstring("fake-vitals-err"),
+			ContainSubstring("fake-dmesg-err"),
+		))
+	})
+
+	It("refuses service names that could leave the snapshot directories", func() {
+		for _, service := range []string{"..", "../../../etc/cron.d/x", "web/..", "web*", ""} {
+			jobSupervisor.ProcessesStatus = append(jobSupervisor.ProcessesStatus, boshjobsuper.Process{Name: service})
+
+			_, err := capturer.Capture(service, boshsettings.Diagnostics{Enabled: true})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid service name"))
+		}
+
+		Expect(fs.WriteFileCallCount).To(Equal(0))
+	})
+
+	It("refuses services that are not known to the job supervisor", func() {
+		_, err := capturer.Capture("unknown", boshsettings.Diagnostics{Enabled: true})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Unknown service 'unknown'"))
+		Expect(fs.WriteFileCallCount).To(Equal(0))
+	})
+
+	It("ignores job names that could leave the log directory", func() {
+		jobSupervisor.ProcessesStatus = []boshjobsuper.Process{{Name: "web", Job: "../.."}}
+
+		path, err := capturer.Capture("web", boshsettings.Diagnostics{Enabled: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(path).To(Equal("/var/vcap/bosh/log/diagnostics/web-20210522T200741Z.json"))
+		Expect(readSnapshot(path).Job).To(BeEmpty())
+	})
+
+	It("returns an error when the processes cannot be listed", func() {
+		jobSupervisor.ProcessesError = errors.New("fake-processes-err")
+
+		_, err := capturer.Capture("web", boshsettings.Diagnostics{Enabled: true})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-processes-err"))
+	})
+
+	It("returns an error when the snapshot cannot be written", func() {
+		fs.WriteFileError = errors.New("fake-write-err")
+
+		_, err := capturer.Capture("web", boshsettings.Diagnostics{Enabled: true})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-write-err"))
+	})
+})
@@ -0,0 +1,119 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package diagnosticsfakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/bosh-agent/agent/diagnostics"
+	"github.com/cloudfoundry/bosh-agent/settings"
+)
+
+type FakeCapturer struct {
+	CaptureStub        func(string, settings.Diagnostics) (string, error)
+	captureMutex       sync.RWMutex
+	captureArgsForCall []struct {
+		arg1 string
+		arg2 settings.Diagnostics
+	}
+	captureReturns struct {
+		result1 string
+		result2 error
+	}
+	captureReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeCapturer) Capture(arg1 string, arg2 settings.Diagnostics) (string, error) {
+	fake.captureMutex.Lock()
+	ret, specificReturn := fake.captureReturnsOnCall[len(fake.captureArgsForCall)]
+	fake.captureArgsForCall = append(fake.captureArgsForCall, struct {
+		arg1 string
+		arg2 settings.Diagnostics
+	}{arg1, arg2})
+	stub := fake.CaptureStub
+	fakeReturns := fake.captureReturns
+	fake.recordInvocation("Capture", []interface{}{arg1, arg2})
+	fake.captureMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeCapturer) CaptureCallCount() int {
+	fake.captureMutex.RLock()
+	defer fake.captureMutex.RUnlock()
+	return len(fake.captureArgsForCall)
+}
+
+func (fake *FakeCapturer) CaptureCalls(stub func(string, settings.Diagnostics) (string, error)) {
+	fake.captureMutex.Lock()
+	defer fake.captureMutex.Unlock()
+	fake.CaptureStub = stub
+}
+
+func (fake *FakeCapturer) CaptureArgsForCall(i int) (string, settings.Diagnostics) {
+	fake.captureMutex.RLock()
+	defer fake.captureMutex.RUnlock()
+	argsForCall := fake.captureArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeCapturer) CaptureReturns(result1 string, result2 error) {
+	fake.captureMutex.Lock()
+	defer fake.captureMutex.Unlock()
+	fake.CaptureStub = nil
+	fake.captureReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCapturer) CaptureReturnsOnCall(i int, result1 string, result2 error) {
+	fake.captureMutex.Lock()
+	defer fake.captureMutex.Unlock()
+	fake.CaptureStub = nil
+	if fake.captureReturnsOnCall == nil {
+		fake.captureReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.captureReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCapturer) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.captureMutex.RLock()
+	defer fake.captureMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeCapturer) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ diagnostics.Capturer = new(FakeCapturer)
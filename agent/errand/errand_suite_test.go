@@ -1,4 +1,4 @@
-package tarpath_test
+package errand_test
 
 import (
 	. "github.com/onsi/ginkgo"
@@ -7,7 +7,7 @@ import (
 	"testing"
 )
 
-func TestTarpath(t *testing.T) {
+func TestErrand(t *testing.T) {
 	RegisterFailHandler(Fail)
-	RunSpecs(t, "Tarpath Suite")
+	RunSpecs(t, "Errand Suite")
 }
@@ -0,0 +1,133 @@
+package errand
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . RunHistory
+
+// RunHistory keeps the latest runs of errands so that one-off
+// operational tasks run on the instance can be audited
+type RunHistory interface {
+	Record(run Run) error
+	List() ([]Run, error)
+}
+
+// Run is a finished run of an errand. The arguments and environment
+// of the run are not kept since they may contain credentials.
+type Run struct {
+	Errand     string    `json:"errand"`
+	Caller     string    `json:"caller,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	ExitCode   int       `json:"exit_code"`
+	LogPath    string    `json:"log_path"`
+
+	// Set when the errand could not be run
+	Error string `json:"error,omitempty"`
+}
+
+type fileRunHistory struct {
+	fs      boshsys.FileSystem
+	path    string
+	maxRuns int
+
+	lock *sync.Mutex
+}
+
+// NewFileRunHistory keeps the latest maxRuns runs in a JSON file at path.
+// The logs of older runs are removed.
+func NewFileRunHistory(fs boshsys.FileSystem, path string, maxRuns int) RunHistory {
+	return fileRunHistory{
+		fs:      fs,
+		path:    path,
+		maxRuns: maxRuns,
+
+		lock: &sync.Mutex{},
+	}
+}
+
+func (h fileRunHistory) Record(run Run) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	runs, err := h.read()
+	if err != nil {
+		return err
+	}
+
+	runs = append(runs, run)
+
+	var dropped []Run
+	if len(runs) > h.maxRuns {
+		dropped = runs[:len(runs)-h.maxRuns]
+		runs = runs[len(runs)-h.maxRuns:]
+	}
+
+	runsBytes, err := json.Marshal(runs)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling errand runs")
+	}
+
+	err = h.fs.WriteFile(h.path, runsBytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing errand runs")
+	}
+
+	return h.removeLogs(dropped, runs)
+}
+
+// removeLogs removes the logs of dropped runs unless a kept run logged
+// to the same file
+func (h fileRunHistory) removeLogs(dropped, kept []Run) error {
+	keptLogs := map[string]bool{}
+	for _, run := range kept {
+		keptLogs[run.LogPath] = true
+	}
+
+	for _, run := range dropped {
+		if run.LogPath == "" || keptLogs[run.LogPath] {
+			continue
+		}
+
+		err := h.fs.RemoveAll(run.LogPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing log of errand run %s", run.LogPath)
+		}
+	}
+
+	return nil
+}
+
+// List returns the runs from the oldest to the latest
+func (h fileRunHistory) List() ([]Run, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.read()
+}
+
+func (h fileRunHistory) read() ([]Run, error) {
+	runs := []Run{}
+
+	if !h.fs.FileExists(h.path) {
+		return runs, nil
+	}
+
+	contents, err := h.fs.ReadFile(h.path)
+	if err != nil {
+		return runs, bosherr.WrapError(err, "Reading errand runs")
+	}
+
+	err = json.Unmarshal(contents, &runs)
+	if err != nil {
+		return []Run{}, bosherr.WrapError(err, "Unmarshalling errand runs")
+	}
+
+	return runs, nil
+}
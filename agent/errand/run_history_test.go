@@ -0,0 +1,100 @@
+package errand_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/errand"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("fileRunHistory", func() {
+	var (
+		fs      *fakesys.FakeFileSystem
+		history RunHistory
+		now     time.Time
+	)
+
+	run := func(errand string, exitCode int) Run {
+		return Run{
+			Errand:     errand,
+			Caller:     "fake-caller",
+			StartedAt:  now,
+			FinishedAt: now.Add(time.Minute),
+			ExitCode:   exitCode,
+			LogPath:    "/var/vcap/sys/log/" + errand + "/errand.log",
+		}
+	}
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		history = NewFileRunHistory(fs, "/var/vcap/bosh/errand_runs.json", 2)
+		now = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	})
+
+	It("returns no runs before any errand ran", func() {
+		Expect(history.List()).To(BeEmpty())
+	})
+
+	It("returns the recorded runs from the oldest to the latest", func() {
+		Expect(history.Record(run("smoke-tests", 0))).To(Succeed())
+		Expect(history.Record(run("migrate", 1))).To(Succeed())
+
+		Expect(history.List()).To(Equal([]Run{run("smoke-tests", 0), run("migrate", 1)}))
+	})
+
+	It("keeps the recorded runs across restarts of the agent", func() {
+		Expect(history.Record(run("smoke-tests", 0))).To(Succeed())
+
+		history = NewFileRunHistory(fs, "/var/vcap/bosh/errand_runs.json", 2)
+		Expect(history.List()).To(Equal([]Run{run("smoke-tests", 0)}))
+	})
+
+	It("keeps only the latest runs", func() {
+		Expect(history.Record(run("first", 0))).To(Succeed())
+		Expect(history.Record(run("second", 0))).To(Succeed())
+		Expect(history.Record(run("third", 0))).To(Succeed())
+
+		Expect(history.List()).To(Equal([]Run{run("second", 0), run("third", 0)}))
+	})
+
+	It("removes the logs of runs that are no longer kept", func() {
+		for _, errand := range []string{"first", "second", "third"} {
+			Expect(fs.WriteFileString(run(errand, 0).LogPath, "fake-log")).To(Succeed())
+			Expect(history.Record(run(errand, 0))).To(Succeed())
+		}
+
+		Expect(fs.FileExists(run("first", 0).LogPath)).To(BeFalse())
+		Expect(fs.FileExists(run("second", 0).LogPath)).To(BeTrue())
+		Expect(fs.FileExists(run("third", 0).LogPath)).To(BeTrue())
+	})
+
+	It("keeps logs that kept runs logged to", func() {
+		Expect(fs.WriteFileString(run("first", 0).LogPath, "fake-log")).To(Succeed())
+
+		Expect(history.Record(run("first", 0))).To(Succeed())
+		Expect(history.Record(run("second", 0))).To(Succeed())
+		Expect(history.Record(run("first", 1))).To(Succeed())
+
+		Expect(fs.FileExists(run("first", 0).LogPath)).To(BeTrue())
+	})
+
+	It("returns an error when the runs cannot be written", func() {
+		fs.WriteFileError = errors.New("fake-write-error")
+
+		err := history.Record(run("smoke-tests", 0))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+	})
+
+	It("returns an error when the runs cannot be parsed", func() {
+		Expect(fs.WriteFileString("/var/vcap/bosh/errand_runs.json", "not-json")).To(Succeed())
+
+		_, err := history.List()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unmarshalling errand runs"))
+	})
+})
@@ -2,21 +2,33 @@ package blobstore_delegator
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	httpblobprovider "github.com/cloudfoundry/bosh-agent/agent/httpblobprovider"
 	"github.com/cloudfoundry/bosh-utils/blobstore"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
+// blobOpener is implemented by blobstores that can stream some of their
+// blobs instead of downloading them to a temporary file
+type blobOpener interface {
+	Open(blobID string) (reader io.ReadCloser, found bool, err error)
+}
+
 type BlobstoreDelegatorImpl struct {
-	h httpblobprovider.HTTPBlobProvider
-	b blobstore.DigestBlobstore
+	h  httpblobprovider.HTTPBlobProvider
+	b  blobstore.DigestBlobstore
+	fs boshsys.FileSystem
 }
 
-func NewBlobstoreDelegator(hp httpblobprovider.HTTPBlobProvider, bp blobstore.DigestBlobstore) *BlobstoreDelegatorImpl {
+func NewBlobstoreDelegator(hp httpblobprovider.HTTPBlobProvider, bp blobstore.DigestBlobstore, fs boshsys.FileSystem) *BlobstoreDelegatorImpl {
 	return &BlobstoreDelegatorImpl{
-		h: hp,
-		b: bp,
+		h:  hp,
+		b:  bp,
+		fs: fs,
 	}
 }
 
@@ -30,6 +42,43 @@ func (b *BlobstoreDelegatorImpl) Get(digest boshcrypto.Digest, signedURL, blobID
 	return b.h.Get(signedURL, digest, headers)
 }
 
+// Open returns a stream of the blob contents. Signed URL responses and
+// blobs the blobstore can open are streamed directly and must be verified
+// by the caller while reading. Other blobs fetched by ID are downloaded and
+// verified by the blobstore first and are cleaned up when the returned
+// reader is closed.
+func (b *BlobstoreDelegatorImpl) Open(digest boshcrypto.Digest, signedURL, blobID string, headers map[string]string) (io.ReadCloser, error) {
+	if signedURL == "" {
+		if blobID == "" {
+			return nil, fmt.Errorf("Both signedURL and blobID are blank which is invalid")
+		}
+
+		if opener, ok := b.b.(blobOpener); ok {
+			reader, found, err := opener.Open(blobID)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Opening blob %s", blobID)
+			}
+			if found {
+				return reader, nil
+			}
+		}
+
+		fileName, err := b.b.Get(blobID, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := b.fs.OpenFile(fileName, os.O_RDONLY, 0)
+		if err != nil {
+			_ = b.b.CleanUp(fileName)
+			return nil, bosherr.WrapErrorf(err, "Opening blob %s", blobID)
+		}
+
+		return blobFile{File: file, fileName: fileName, blobstore: b.b}, nil
+	}
+	return b.h.Open(signedURL, headers)
+}
+
 func (b *BlobstoreDelegatorImpl) Write(signedURL, path string, headers map[string]string) (string, boshcrypto.MultipleDigest, error) {
 	if signedURL == "" {
 		return b.b.Create(path)
@@ -52,3 +101,20 @@ func (b *BlobstoreDelegatorImpl) Delete(signedURL, blobID string) (err error) {
 	}
 	return b.b.Delete(blobID)
 }
+
+type blobFile struct {
+	boshsys.File
+	fileName  string
+	blobstore blobstore.DigestBlobstore
+}
+
+func (f blobFile) Close() error {
+	closeErr := f.File.Close()
+
+	err := f.blobstore.CleanUp(f.fileName)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Cleaning up blob file %s", f.fileName)
+	}
+
+	return closeErr
+}
@@ -1,6 +1,8 @@
 package blobstore_delegator
 
 import (
+	"io"
+
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 )
 
@@ -8,6 +10,7 @@ import (
 
 type BlobstoreDelegator interface {
 	Get(digest boshcrypto.Digest, signedURL, blobID string, headers map[string]string) (fileName string, err error)
+	Open(digest boshcrypto.Digest, signedURL, blobID string, headers map[string]string) (io.ReadCloser, error)
 	Write(signedURL, path string, headers map[string]string) (string, boshcrypto.MultipleDigest, error)
 	CleanUp(signedURL, path string) error
 	Delete(signedURL, blobID string) error
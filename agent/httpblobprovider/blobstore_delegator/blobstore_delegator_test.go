@@ -2,6 +2,9 @@ package blobstore_delegator_test
 
 import (
 	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -11,13 +14,27 @@ import (
 	fakeblobstore "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
+type openingBlobstore struct {
+	*fakeblobstore.FakeDigestBlobstore
+
+	reader io.ReadCloser
+	found  bool
+	err    error
+}
+
+func (b openingBlobstore) Open(blobID string) (io.ReadCloser, bool, error) {
+	return b.reader, b.found, b.err
+}
+
 var _ = Describe("BlobstoreDelegator", func() {
 	var (
 		blobstoreDelegator   blobstore_delegator.BlobstoreDelegator
 		fakeHTTPBlobProvider *fakeblobprovider.FakeHTTPBlobProvider
 		fakeBlobManager      *fakeblobstore.FakeDigestBlobstore
+		fs                   *fakesys.FakeFileSystem
 
 		digest = boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "some-digest"))
 	)
@@ -25,8 +42,9 @@ var _ = Describe("BlobstoreDelegator", func() {
 	BeforeEach(func() {
 		fakeHTTPBlobProvider = &fakeblobprovider.FakeHTTPBlobProvider{}
 		fakeBlobManager = &fakeblobstore.FakeDigestBlobstore{}
+		fs = fakesys.NewFakeFileSystem()
 
-		blobstoreDelegator = blobstore_delegator.NewBlobstoreDelegator(fakeHTTPBlobProvider, fakeBlobManager)
+		blobstoreDelegator = blobstore_delegator.NewBlobstoreDelegator(fakeHTTPBlobProvider, fakeBlobManager, fs)
 	})
 
 	Context("Get", func() {
@@ -98,6 +116,126 @@ var _ = Describe("BlobstoreDelegator", func() {
 		})
 	})
 
+	Context("Open", func() {
+		Context("when there is a signed URL provided", func() {
+			It("streams the response from the HTTP blobstore", func() {
+				body := ioutil.NopCloser(strings.NewReader("fake-contents"))
+				fakeHTTPBlobProvider.OpenReturns(body, nil)
+
+				reader, err := blobstoreDelegator.Open(digest, "some-signed-url", "", map[string]string{"key": "value"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reader).To(Equal(body))
+
+				Expect(fakeBlobManager.GetCallCount()).To(Equal(0))
+				Expect(fakeHTTPBlobProvider.OpenCallCount()).To(Equal(1))
+
+				signedURLArg, headersArg := fakeHTTPBlobProvider.OpenArgsForCall(0)
+				Expect(signedURLArg).To(Equal("some-signed-url"))
+				Expect(headersArg).To(Equal(map[string]string{"key": "value"}))
+			})
+
+			It("errors when there is an error", func() {
+				fakeError := errors.New("some error")
+				fakeHTTPBlobProvider.OpenReturns(nil, fakeError)
+
+				_, err := blobstoreDelegator.Open(digest, "some-signed-url", "", nil)
+				Expect(err).To(MatchError(fakeError))
+			})
+		})
+
+		Context("when there is no signed URL provided", func() {
+			It("opens the blob fetched from the local blobstore and cleans it up on close", func() {
+				Expect(fs.WriteFileString("/some/path/to/a/file", "fake-contents")).To(Succeed())
+				fakeBlobManager.GetReturns("/some/path/to/a/file", nil)
+
+				reader, err := blobstoreDelegator.Open(digest, "", "1234", nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				fetchedBlobID, digestArg := fakeBlobManager.GetArgsForCall(0)
+				Expect(fetchedBlobID).To(Equal("1234"))
+				Expect(digestArg).To(Equal(digest))
+
+				contents, err := ioutil.ReadAll(reader)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(Equal("fake-contents"))
+				Expect(fakeBlobManager.CleanUpCallCount()).To(Equal(0))
+
+				Expect(reader.Close()).To(Succeed())
+				Expect(fakeBlobManager.CleanUpCallCount()).To(Equal(1))
+				Expect(fakeBlobManager.CleanUpArgsForCall(0)).To(Equal("/some/path/to/a/file"))
+			})
+
+			It("errors when fetching the blob fails", func() {
+				fakeError := errors.New("some error")
+				fakeBlobManager.GetReturns("", fakeError)
+
+				_, err := blobstoreDelegator.Open(digest, "", "1234", nil)
+				Expect(err).To(MatchError(fakeError))
+				Expect(fakeHTTPBlobProvider.OpenCallCount()).To(Equal(0))
+			})
+
+			It("cleans up the blob when opening it fails", func() {
+				fakeBlobManager.GetReturns("/some/path/to/a/file", nil)
+				fs.OpenFileErr = errors.New("fake-open-err")
+
+				_, err := blobstoreDelegator.Open(digest, "", "1234", nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-open-err"))
+				Expect(fakeBlobManager.CleanUpCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the blobstore can open the blob", func() {
+			var blobstore openingBlobstore
+
+			BeforeEach(func() {
+				blobstore = openingBlobstore{FakeDigestBlobstore: fakeBlobManager}
+			})
+
+			It("streams the blob without downloading it first", func() {
+				blobstore.reader = ioutil.NopCloser(strings.NewReader("fake-contents"))
+				blobstore.found = true
+				blobstoreDelegator = blobstore_delegator.NewBlobstoreDelegator(fakeHTTPBlobProvider, blobstore, fs)
+
+				reader, err := blobstoreDelegator.Open(digest, "", "1234", nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				contents, err := ioutil.ReadAll(reader)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(Equal("fake-contents"))
+				Expect(fakeBlobManager.GetCallCount()).To(Equal(0))
+			})
+
+			It("downloads blobs that the blobstore cannot open", func() {
+				Expect(fs.WriteFileString("/some/path/to/a/file", "fake-contents")).To(Succeed())
+				fakeBlobManager.GetReturns("/some/path/to/a/file", nil)
+				blobstoreDelegator = blobstore_delegator.NewBlobstoreDelegator(fakeHTTPBlobProvider, blobstore, fs)
+
+				reader, err := blobstoreDelegator.Open(digest, "", "1234", nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reader.Close()).To(Succeed())
+				Expect(fakeBlobManager.GetCallCount()).To(Equal(1))
+			})
+
+			It("returns an error when opening the blob fails", func() {
+				blobstore.err = errors.New("fake-open-err")
+				blobstoreDelegator = blobstore_delegator.NewBlobstoreDelegator(fakeHTTPBlobProvider, blobstore, fs)
+
+				_, err := blobstoreDelegator.Open(digest, "", "1234", nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-open-err"))
+				Expect(fakeBlobManager.GetCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when neither signedURL nor blobID are provided", func() {
+			It("returns an error", func() {
+				_, err := blobstoreDelegator.Open(digest, "", "", nil)
+				Expect(err).To(MatchError(errors.New("Both signedURL and blobID are blank which is invalid")))
+			})
+		})
+	})
+
 	Context("Write", func() {
 		Context("when there is a signed URL provided", func() {
 			It("reaches out to the HTTP blobstore", func() {
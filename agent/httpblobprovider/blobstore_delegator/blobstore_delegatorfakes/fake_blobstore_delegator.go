@@ -2,6 +2,7 @@
 package blobstore_delegatorfakes
 
 import (
+	"io"
 	"sync"
 
 	"github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator"
@@ -49,6 +50,22 @@ type FakeBlobstoreDelegator struct {
 		result1 string
 		result2 error
 	}
+	OpenStub        func(crypto.Digest, string, string, map[string]string) (io.ReadCloser, error)
+	openMutex       sync.RWMutex
+	openArgsForCall []struct {
+		arg1 crypto.Digest
+		arg2 string
+		arg3 string
+		arg4 map[string]string
+	}
+	openReturns struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	openReturnsOnCall map[int]struct {
+		result1 io.ReadCloser
+		result2 error
+	}
 	WriteStub        func(string, string, map[string]string) (string, crypto.MultipleDigest, error)
 	writeMutex       sync.RWMutex
 	writeArgsForCall []struct {
@@ -261,6 +278,73 @@ func (fake *FakeBlobstoreDelegator) GetReturnsOnCall(i int, result1 string, resu
 	}{result1, result2}
 }
 
+func (fake *FakeBlobstoreDelegator) Open(arg1 crypto.Digest, arg2 string, arg3 string, arg4 map[string]string) (io.ReadCloser, error) {
+	fake.openMutex.Lock()
+	ret, specificReturn := fake.openReturnsOnCall[len(fake.openArgsForCall)]
+	fake.openArgsForCall = append(fake.openArgsForCall, struct {
+		arg1 crypto.Digest
+		arg2 string
+		arg3 string
+		arg4 map[string]string
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.OpenStub
+	fakeReturns := fake.openReturns
+	fake.recordInvocation("Open", []interface{}{arg1, arg2, arg3, arg4})
+	fake.openMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeBlobstoreDelegator) OpenCallCount() int {
+	fake.openMutex.RLock()
+	defer fake.openMutex.RUnlock()
+	return len(fake.openArgsForCall)
+}
+
+func (fake *FakeBlobstoreDelegator) OpenCalls(stub func(crypto.Digest, string, string, map[string]string) (io.ReadCloser, error)) {
+	fake.openMutex.Lock()
+	defer fake.openMutex.Unlock()
+	fake.OpenStub = stub
+}
+
+func (fake *FakeBlobstoreDelegator) OpenArgsForCall(i int) (crypto.Digest, string, string, map[string]string) {
+	fake.openMutex.RLock()
+	defer fake.openMutex.RUnlock()
+	argsForCall := fake.openArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeBlobstoreDelegator) OpenReturns(result1 io.ReadCloser, result2 error) {
+	fake.openMutex.Lock()
+	defer fake.openMutex.Unlock()
+	fake.OpenStub = nil
+	fake.openReturns = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBlobstoreDelegator) OpenReturnsOnCall(i int, result1 io.ReadCloser, result2 error) {
+	fake.openMutex.Lock()
+	defer fake.openMutex.Unlock()
+	fake.OpenStub = nil
+	if fake.openReturnsOnCall == nil {
+		fake.openReturnsOnCall = make(map[int]struct {
+			result1 io.ReadCloser
+			result2 error
+		})
+	}
+	fake.openReturnsOnCall[i] = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeBlobstoreDelegator) Write(arg1 string, arg2 string, arg3 map[string]string) (string, crypto.MultipleDigest, error) {
 	fake.writeMutex.Lock()
 	ret, specificReturn := fake.writeReturnsOnCall[len(fake.writeArgsForCall)]
@@ -339,6 +423,8 @@ func (fake *FakeBlobstoreDelegator) Invocations() map[string][][]interface{} {
 	defer fake.deleteMutex.RUnlock()
 	fake.getMutex.RLock()
 	defer fake.getMutex.RUnlock()
+	fake.openMutex.RLock()
+	defer fake.openMutex.RUnlock()
 	fake.writeMutex.RLock()
 	defer fake.writeMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
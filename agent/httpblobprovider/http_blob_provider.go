@@ -123,6 +123,32 @@ func (h *HTTPBlobImpl) Get(signedURL string, digest boshcrypto.Digest, headers m
 	return file.Name(), nil
 }
 
+// Open starts a GET request for the signed URL and returns the response body
+// for streaming. The caller is responsible for verifying the contents and
+// closing the returned reader.
+func (h *HTTPBlobImpl) Open(signedURL string, headers map[string]string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", signedURL, strings.NewReader(""))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating Get Request")
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Excuting GET request")
+	}
+
+	if !isSuccess(resp) {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("Error executing GET, response was %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
 func isSuccess(resp *http.Response) bool {
 	return resp.StatusCode >= 200 && resp.StatusCode < 300
 }
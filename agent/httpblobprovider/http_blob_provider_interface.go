@@ -1,6 +1,8 @@
 package httpblobprovider
 
 import (
+	"io"
+
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 )
 
@@ -9,4 +11,5 @@ import (
 type HTTPBlobProvider interface {
 	Upload(signedURL, filepath string, headers map[string]string) (boshcrypto.MultipleDigest, error)
 	Get(signedURL string, digest boshcrypto.Digest, headers map[string]string) (string, error)
+	Open(signedURL string, headers map[string]string) (io.ReadCloser, error)
 }
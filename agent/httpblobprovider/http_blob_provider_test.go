@@ -2,6 +2,7 @@ package httpblobprovider_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 
@@ -111,6 +112,40 @@ var _ = Describe("HTTPBlobImpl", func() {
 		})
 	})
 
+	Describe("Open", func() {
+		It("returns the response body", func() {
+			server.RouteToHandler("GET", "/success-get-signed-url",
+				ghttp.CombineHandlers(
+					ghttp.RespondWith(http.StatusOK, "abc"),
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						Expect(r.Header.Get("key")).To(Equal("value"))
+					}),
+				),
+			)
+
+			body, err := blobProvider.Open(fmt.Sprintf("%s/success-get-signed-url", server.URL()), map[string]string{"key": "value"})
+			Expect(err).NotTo(HaveOccurred())
+			defer body.Close()
+
+			content, err := ioutil.ReadAll(body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(content).To(Equal([]byte("abc")))
+		})
+
+		It("returns an error when the server responds with a bad status code", func() {
+			server.RouteToHandler("GET", "/bad-get-signed-url",
+				ghttp.CombineHandlers(
+					ghttp.RespondWith(http.StatusBadRequest, "fake-bad-contents"),
+				),
+			)
+
+			_, err := blobProvider.Open(fmt.Sprintf("%s/bad-get-signed-url", server.URL()), nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("response was 400"))
+			Expect(err.Error()).ToNot(ContainSubstring(fmt.Sprintf("%s/bad-get-signed-url", server.URL())))
+		})
+	})
+
 	Describe("Upload", func() {
 		testUpload := func(filepath, signedURL string) (boshcrypto.MultipleDigest, error) {
 			err := fakeFileSystem.WriteFileString(filepath, "abc")
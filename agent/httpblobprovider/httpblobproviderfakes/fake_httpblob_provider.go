@@ -2,6 +2,7 @@
 package httpblobproviderfakes
 
 import (
+	"io"
 	"sync"
 
 	"github.com/cloudfoundry/bosh-agent/agent/httpblobprovider"
@@ -24,6 +25,20 @@ type FakeHTTPBlobProvider struct {
 		result1 string
 		result2 error
 	}
+	OpenStub        func(string, map[string]string) (io.ReadCloser, error)
+	openMutex       sync.RWMutex
+	openArgsForCall []struct {
+		arg1 string
+		arg2 map[string]string
+	}
+	openReturns struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	openReturnsOnCall map[int]struct {
+		result1 io.ReadCloser
+		result2 error
+	}
 	UploadStub        func(string, string, map[string]string) (crypto.MultipleDigest, error)
 	uploadMutex       sync.RWMutex
 	uploadArgsForCall []struct {
@@ -109,6 +124,71 @@ func (fake *FakeHTTPBlobProvider) GetReturnsOnCall(i int, result1 string, result
 	}{result1, result2}
 }
 
+func (fake *FakeHTTPBlobProvider) Open(arg1 string, arg2 map[string]string) (io.ReadCloser, error) {
+	fake.openMutex.Lock()
+	ret, specificReturn := fake.openReturnsOnCall[len(fake.openArgsForCall)]
+	fake.openArgsForCall = append(fake.openArgsForCall, struct {
+		arg1 string
+		arg2 map[string]string
+	}{arg1, arg2})
+	stub := fake.OpenStub
+	fakeReturns := fake.openReturns
+	fake.recordInvocation("Open", []interface{}{arg1, arg2})
+	fake.openMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeHTTPBlobProvider) OpenCallCount() int {
+	fake.openMutex.RLock()
+	defer fake.openMutex.RUnlock()
+	return len(fake.openArgsForCall)
+}
+
+func (fake *FakeHTTPBlobProvider) OpenCalls(stub func(string, map[string]string) (io.ReadCloser, error)) {
+	fake.openMutex.Lock()
+	defer fake.openMutex.Unlock()
+	fake.OpenStub = stub
+}
+
+func (fake *FakeHTTPBlobProvider) OpenArgsForCall(i int) (string, map[string]string) {
+	fake.openMutex.RLock()
+	defer fake.openMutex.RUnlock()
+	argsForCall := fake.openArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeHTTPBlobProvider) OpenReturns(result1 io.ReadCloser, result2 error) {
+	fake.openMutex.Lock()
+	defer fake.openMutex.Unlock()
+	fake.OpenStub = nil
+	fake.openReturns = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeHTTPBlobProvider) OpenReturnsOnCall(i int, result1 io.ReadCloser, result2 error) {
+	fake.openMutex.Lock()
+	defer fake.openMutex.Unlock()
+	fake.OpenStub = nil
+	if fake.openReturnsOnCall == nil {
+		fake.openReturnsOnCall = make(map[int]struct {
+			result1 io.ReadCloser
+			result2 error
+		})
+	}
+	fake.openReturnsOnCall[i] = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeHTTPBlobProvider) Upload(arg1 string, arg2 string, arg3 map[string]string) (crypto.MultipleDigest, error) {
 	fake.uploadMutex.Lock()
 	ret, specificReturn := fake.uploadReturnsOnCall[len(fake.uploadArgsForCall)]
@@ -180,6 +260,8 @@ func (fake *FakeHTTPBlobProvider) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.getMutex.RLock()
 	defer fake.getMutex.RUnlock()
+	fake.openMutex.RLock()
+	defer fake.openMutex.RUnlock()
 	fake.uploadMutex.RLock()
 	defer fake.uploadMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -6,11 +6,23 @@ import (
 )
 
 func BuildCommand(path string) boshsys.Command {
-	return boshsys.Command{
+	return BuildCommandWithEnv(path, nil, nil)
+}
+
+// BuildCommandWithEnv passes args to the script and sets env in
+// addition to PATH, which env cannot override
+func BuildCommandWithEnv(path string, args []string, env map[string]string) boshsys.Command {
+	command := boshsys.Command{
 		Name: "powershell",
-		Args: []string{path},
-		Env: map[string]string{
-			"PATH": boshenv.Path(),
-		},
+		Args: append([]string{path}, args...),
+		Env:  map[string]string{},
+	}
+
+	for key, val := range env {
+		command.Env[key] = val
 	}
+
+	command.Env["PATH"] = boshenv.Path()
+
+	return command
 }
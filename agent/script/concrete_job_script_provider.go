@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"time"
 
 	"code.cloudfoundry.org/clock"
 
@@ -37,7 +38,7 @@ func NewConcreteJobScriptProvider(
 	}
 }
 
-func (p ConcreteJobScriptProvider) NewScript(jobName string, scriptName string, scriptEnv map[string]string) Script {
+func (p ConcreteJobScriptProvider) NewScript(jobName string, scriptName string, scriptEnv map[string]string, timeout time.Duration) Script {
 	path := path.Join(p.dirProvider.JobBinDir(jobName), scriptName+ScriptExt)
 
 	stdoutLogFilename := fmt.Sprintf("%s.stdout.log", scriptName)
@@ -46,7 +47,7 @@ func (p ConcreteJobScriptProvider) NewScript(jobName string, scriptName string,
 	stderrLogFilename := fmt.Sprintf("%s.stderr.log", scriptName)
 	stderrLogPath := filepath.Join(p.dirProvider.LogsDir(), jobName, stderrLogFilename)
 
-	return NewScript(p.fs, p.cmdRunner, jobName, path, stdoutLogPath, stderrLogPath, scriptEnv)
+	return NewScript(p.fs, p.cmdRunner, jobName, path, stdoutLogPath, stderrLogPath, scriptEnv, timeout, p.timeService)
 }
 
 func (p ConcreteJobScriptProvider) NewDrainScript(jobName string, params boshdrain.ScriptParams) CancellableScript {
@@ -58,3 +59,7 @@ func (p ConcreteJobScriptProvider) NewDrainScript(jobName string, params boshdra
 func (p ConcreteJobScriptProvider) NewParallelScript(scriptName string, scripts []Script) CancellableScript {
 	return NewParallelScript(scriptName, scripts, p.logger)
 }
+
+func (p ConcreteJobScriptProvider) NewLimitedParallelScript(scriptName string, scripts []Script, maxConcurrency int) ReportingParallelScript {
+	return NewLimitedParallelScript(scriptName, scripts, maxConcurrency, p.logger)
+}
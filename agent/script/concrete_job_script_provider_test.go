@@ -1,6 +1,8 @@
 package script_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -38,7 +40,7 @@ var _ = Describe("ConcreteJobScriptProvider", func() {
 
 	Describe("NewScript", func() {
 		It("returns script with relative job paths to the base directory", func() {
-			script := scriptProvider.NewScript("myjob", "the-best-hook-ever", scriptEnv)
+			script := scriptProvider.NewScript("myjob", "the-best-hook-ever", scriptEnv, time.Minute)
 			Expect(script.Tag()).To(Equal("myjob"))
 
 			expPath := "/the/base/dir/jobs/myjob/bin/the-best-hook-ever" + boshscript.ScriptExt
@@ -65,4 +67,12 @@ var _ = Describe("ConcreteJobScriptProvider", func() {
 			Expect(script).To(Equal(boshscript.NewParallelScript("foo", scripts, logger)))
 		})
 	})
+
+	Describe("NewLimitedParallelScript", func() {
+		It("returns parallel script running a limited number of scripts at the same time", func() {
+			scripts := []boshscript.Script{&scriptfakes.FakeScript{}}
+			script := scriptProvider.NewLimitedParallelScript("foo", scripts, 3)
+			Expect(script).To(Equal(boshscript.NewLimitedParallelScript("foo", scripts, 3, logger)))
+		})
+	})
 })
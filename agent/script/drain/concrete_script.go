@@ -1,8 +1,10 @@
 package drain
 
 import (
+	"encoding/json"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/clock"
@@ -25,6 +27,38 @@ type ConcreteScript struct {
 	logger      boshlog.Logger
 
 	cancelCh chan struct{}
+	progress *scriptProgress
+}
+
+// Progress is the latest status reported by a drain script
+// that uses the JSON line protocol
+type Progress struct {
+	Status   string `json:"status,omitempty"`
+	Progress int    `json:"progress,omitempty"`
+}
+
+// ProgressReporter is implemented by drain scripts that
+// can report their progress while they run
+type ProgressReporter interface {
+	Progress() (Progress, bool)
+}
+
+// jsonReport is the last line printed by a drain script that opted into
+// the JSON line protocol, e.g. {"wait": 10, "status": "draining", "progress": 40}.
+// A script that is not done is run again as a status check after waiting,
+// a script that is done is finished after waiting. Without a wait it is
+// finished right away, just like a script returning 0.
+type jsonReport struct {
+	Wait     int    `json:"wait"`
+	Done     bool   `json:"done"`
+	Status   string `json:"status"`
+	Progress int    `json:"progress"`
+}
+
+type scriptProgress struct {
+	lock     sync.Mutex
+	progress Progress
+	reported bool
 }
 
 func NewConcreteScript(
@@ -50,6 +84,7 @@ func NewConcreteScript(
 		logger: logger,
 
 		cancelCh: make(chan struct{}, 1),
+		progress: &scriptProgress{},
 	}
 }
 
@@ -75,6 +110,15 @@ func (s ConcreteScript) Run() error {
 	}
 }
 
+// Progress returns the latest progress reported by the script
+// and whether the script reported any
+func (s ConcreteScript) Progress() (Progress, bool) {
+	s.progress.lock.Lock()
+	defer s.progress.lock.Unlock()
+
+	return s.progress.progress, s.progress.reported
+}
+
 func (s ConcreteScript) Cancel() error {
 	select {
 	case s.cancelCh <- struct{}{}:
@@ -147,10 +191,46 @@ func (s ConcreteScript) runOnce(params ScriptParams) (int, error) {
 		return 0, bosherr.WrapError(result.Error, "Running drain script")
 	}
 
-	value, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+	stdout := strings.TrimSpace(result.Stdout)
+
+	if strings.HasPrefix(stdout, "{") {
+		return s.parseJSONReport(stdout)
+	}
+
+	value, err := strconv.Atoi(stdout)
 	if err != nil {
 		return 0, bosherr.WrapError(err, "Script did not return a signed integer")
 	}
 
 	return value, nil
 }
+
+// parseJSONReport records the progress of the last line printed by the
+// script and translates it into the value of the integer protocol
+func (s ConcreteScript) parseJSONReport(stdout string) (int, error) {
+	lines := strings.Split(stdout, "\n")
+
+	var report jsonReport
+
+	err := json.Unmarshal([]byte(strings.TrimSpace(lines[len(lines)-1])), &report)
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Script did not return a valid JSON report")
+	}
+
+	if report.Wait < 0 {
+		return 0, bosherr.Errorf("Script reported a negative wait of %d seconds", report.Wait)
+	}
+
+	s.progress.lock.Lock()
+	s.progress.progress = Progress{Status: report.Status, Progress: report.Progress}
+	s.progress.reported = true
+	s.progress.lock.Unlock()
+
+	s.logger.Debug(s.logTag, "Script %s reported status '%s' (%d%%)", s.tag, report.Status, report.Progress)
+
+	if report.Done {
+		return report.Wait, nil
+	}
+
+	return -report.Wait, nil
+}
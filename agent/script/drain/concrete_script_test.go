@@ -183,6 +183,57 @@ var _ = Describe("ConcreteScript", func() {
 			Expect(fakeClock.SleepArgsForCall(1)).To(Equal(0 * time.Second))
 		})
 
+		Context("when the script reports JSON lines", func() {
+			It("calls the script again after the wait until it is done and records its progress", func() {
+				runner.AddProcess(jobChangedFullCommand,
+					&fakesys.FakeProcess{WaitResult: boshsys.Result{Stdout: `{"status": "starting"}` + "\n" + `{"wait": 10, "status": "draining 120 connections", "progress": 40}` + "\n"}})
+				runner.AddProcess(jobCheckStatusFullCommand,
+					&fakesys.FakeProcess{WaitResult: boshsys.Result{Stdout: `{"wait": 3, "done": true, "status": "drained", "progress": 100}`}})
+
+				_, reported := script.Progress()
+				Expect(reported).To(BeFalse())
+
+				err := script.Run()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeClock.SleepCallCount()).To(Equal(2))
+				Expect(fakeClock.SleepArgsForCall(0)).To(Equal(10 * time.Second))
+				Expect(fakeClock.SleepArgsForCall(1)).To(Equal(3 * time.Second))
+
+				progress, reported := script.Progress()
+				Expect(reported).To(BeTrue())
+				Expect(progress).To(Equal(Progress{Status: "drained", Progress: 100}))
+			})
+
+			It("is done when the script does not wait", func() {
+				runner.AddProcess(jobChangedFullCommand,
+					&fakesys.FakeProcess{WaitResult: boshsys.Result{Stdout: `{"status": "nothing to drain"}`}})
+
+				err := script.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeClock.SleepCallCount()).To(Equal(1))
+				Expect(fakeClock.SleepArgsForCall(0)).To(Equal(0 * time.Second))
+			})
+
+			It("returns error when the last line is not valid JSON", func() {
+				runner.AddProcess(jobChangedFullCommand,
+					&fakesys.FakeProcess{WaitResult: boshsys.Result{Stdout: "{\"wait\": 10}\ndone"}})
+
+				err := script.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Script did not return a valid JSON report"))
+			})
+
+			It("returns error when the script reports a negative wait", func() {
+				runner.AddProcess(jobChangedFullCommand,
+					&fakesys.FakeProcess{WaitResult: boshsys.Result{Stdout: `{"wait": -5}`}})
+
+				err := script.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Script reported a negative wait of -5 seconds"))
+			})
+		})
+
 		It("returns error with non integer stdout", func() {
 			runner.AddProcess(jobChangedFullCommand,
 				&fakesys.FakeProcess{WaitResult: boshsys.Result{Stdout: "hello!"}})
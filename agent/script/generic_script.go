@@ -1,16 +1,23 @@
 package script
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/clock"
 
 	"github.com/cloudfoundry/bosh-agent/agent/script/cmd"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
 const (
 	fileOpenFlag int         = os.O_RDWR | os.O_CREATE | os.O_APPEND
 	fileOpenPerm os.FileMode = os.FileMode(0640)
+
+	scriptKillGracePeriod = 10 * time.Second
 )
 
 type GenericScript struct {
@@ -24,6 +31,9 @@ type GenericScript struct {
 	stderrLogPath string
 
 	env map[string]string
+
+	timeout     time.Duration
+	timeService clock.Clock
 }
 
 func NewScript(
@@ -34,6 +44,8 @@ func NewScript(
 	stdoutLogPath string,
 	stderrLogPath string,
 	env map[string]string,
+	timeout time.Duration,
+	timeService clock.Clock,
 ) GenericScript {
 	return GenericScript{
 		fs:     fs,
@@ -46,6 +58,9 @@ func NewScript(
 		stderrLogPath: stderrLogPath,
 
 		env: env,
+
+		timeout:     timeout,
+		timeService: timeService,
 	}
 }
 
@@ -54,19 +69,28 @@ func (s GenericScript) Path() string { return s.path }
 func (s GenericScript) Exists() bool { return s.fs.FileExists(s.path) }
 
 func (s GenericScript) Run() error {
+	_, err := s.RunWithResult()
+	return err
+}
+
+// RunWithResult terminates the script once it runs longer than
+// its timeout, if it has one, and fails with a timeout error
+func (s GenericScript) RunWithResult() (RunResult, error) {
+	result := RunResult{ExitStatus: -1}
+
 	err := s.ensureContainingDir(s.stdoutLogPath)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	err = s.ensureContainingDir(s.stderrLogPath)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	stdoutFile, err := s.fs.OpenFile(s.stdoutLogPath, fileOpenFlag, fileOpenPerm)
 	if err != nil {
-		return err
+		return result, err
 	}
 	defer func() {
 		_ = stdoutFile.Close()
@@ -74,23 +98,64 @@ func (s GenericScript) Run() error {
 
 	stderrFile, err := s.fs.OpenFile(s.stderrLogPath, fileOpenFlag, fileOpenPerm)
 	if err != nil {
-		return err
+		return result, err
 	}
 	defer func() {
 		_ = stderrFile.Close()
 	}()
 
+	stdoutTail := NewTailBuffer(scriptOutputTailSize)
+	stderrTail := NewTailBuffer(scriptOutputTailSize)
+
 	command := cmd.BuildCommand(s.path)
-	command.Stdout = stdoutFile
-	command.Stderr = stderrFile
+	command.Stdout = io.MultiWriter(stdoutFile, stdoutTail)
+	command.Stderr = io.MultiWriter(stderrFile, stderrTail)
 
 	for key, val := range s.env {
 		command.Env[key] = val
 	}
 
-	_, _, _, err = s.runner.RunComplexCommand(command)
+	startedAt := s.timeService.Now()
 
-	return err
+	result.ExitStatus, result.TimedOut, err = s.run(command)
+
+	result.Duration = s.timeService.Since(startedAt)
+	result.StdoutTail = stdoutTail.String()
+	result.StderrTail = stderrTail.String()
+
+	return result, err
+}
+
+func (s GenericScript) run(command boshsys.Command) (int, bool, error) {
+	if s.timeout <= 0 {
+		_, _, exitStatus, err := s.runner.RunComplexCommand(command)
+		return exitStatus, false, err
+	}
+
+	process, err := s.runner.RunComplexCommandAsync(command)
+	if err != nil {
+		return -1, false, err
+	}
+
+	timer := s.timeService.NewTimer(s.timeout)
+	defer timer.Stop()
+
+	processExitedCh := process.Wait()
+
+	select {
+	case result := <-processExitedCh:
+		return result.ExitStatus, false, result.Error
+
+	case <-timer.C():
+		err := process.TerminateNicely(scriptKillGracePeriod)
+		if err != nil {
+			return -1, true, bosherr.WrapErrorf(err, "Terminating script after timeout of %s", s.timeout)
+		}
+
+		result := <-processExitedCh
+
+		return result.ExitStatus, true, bosherr.Errorf("Script timed out after %s", s.timeout)
+	}
 }
 
 func (s GenericScript) ensureContainingDir(fullLogFilename string) error {
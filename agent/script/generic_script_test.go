@@ -3,20 +3,25 @@ package script_test
 import (
 	"errors"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
+	"code.cloudfoundry.org/clock/fakeclock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
 	boshenv "github.com/cloudfoundry/bosh-agent/agent/script/pathenv"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
-	"runtime"
 )
 
 var _ = Describe("GenericScript", func() {
 	var (
 		fs            *fakesys.FakeFileSystem
 		cmdRunner     *fakesys.FakeCmdRunner
+		timeService   *fakeclock.FakeClock
 		genericScript boshscript.GenericScript
 		stdoutLogPath string
 		stderrLogPath string
@@ -27,6 +32,7 @@ var _ = Describe("GenericScript", func() {
 	BeforeEach(func() {
 		fs = fakesys.NewFakeFileSystem()
 		cmdRunner = fakesys.NewFakeCmdRunner()
+		timeService = fakeclock.NewFakeClock(time.Now())
 		stdoutLogPath = filepath.Join("base", "stdout", "logdir", "stdout.log")
 		stderrLogPath = filepath.Join("base", "stderr", "logdir", "stderr.log")
 		scriptEnv = map[string]string{
@@ -42,6 +48,8 @@ var _ = Describe("GenericScript", func() {
 			stdoutLogPath,
 			stderrLogPath,
 			scriptEnv,
+			0,
+			timeService,
 		)
 		if runtime.GOOS == "windows" {
 			fullCommand = "powershell /path-to-script"
@@ -162,4 +170,96 @@ var _ = Describe("GenericScript", func() {
 			})
 		})
 	})
+
+	Describe("RunWithResult", func() {
+		It("returns the exit status and the output of the script", func() {
+			cmdRunner.AddCmdResult(fullCommand, fakesys.FakeCmdResult{
+				Stdout:     "fake-stdout",
+				Stderr:     "fake-stderr",
+				ExitStatus: 1,
+				Error:      errors.New("fake-command-error"),
+			})
+
+			result, err := genericScript.RunWithResult()
+			Expect(err).To(MatchError("fake-command-error"))
+			Expect(result).To(Equal(boshscript.RunResult{
+				ExitStatus: 1,
+				StdoutTail: "fake-stdout",
+				StderrTail: "fake-stderr",
+			}))
+		})
+
+		It("keeps only the tail of long output", func() {
+			cmdRunner.AddCmdResult(fullCommand, fakesys.FakeCmdResult{
+				Stdout: strings.Repeat("a", 8*1024) + "the end",
+			})
+
+			result, err := genericScript.RunWithResult()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.StdoutTail).To(HaveLen(4 * 1024))
+			Expect(result.StdoutTail).To(HaveSuffix("the end"))
+		})
+
+		Context("when the script has a timeout", func() {
+			var process *fakesys.FakeProcess
+
+			BeforeEach(func() {
+				genericScript = boshscript.NewScript(
+					fs,
+					cmdRunner,
+					"my-tag",
+					"/path-to-script",
+					stdoutLogPath,
+					stderrLogPath,
+					scriptEnv,
+					time.Minute,
+					timeService,
+				)
+
+				process = &fakesys.FakeProcess{}
+				cmdRunner.AddProcess(fullCommand, process)
+			})
+
+			It("returns the result of a script that finishes in time", func() {
+				process.WaitResult = boshsys.Result{ExitStatus: 0}
+
+				result, err := genericScript.RunWithResult()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.TimedOut).To(BeFalse())
+				Expect(process.TerminatedNicely).To(BeFalse())
+			})
+
+			It("terminates a script that runs past its timeout", func() {
+				process.TerminatedNicelyCallBack = func(p *fakesys.FakeProcess) {
+					_, _ = p.Stdout.Write([]byte("fake-stdout"))
+					p.WaitCh <- boshsys.Result{ExitStatus: 143}
+				}
+
+				type runResult struct {
+					result boshscript.RunResult
+					err    error
+				}
+
+				resultCh := make(chan runResult, 1)
+				go func() {
+					result, err := genericScript.RunWithResult()
+					resultCh <- runResult{result, err}
+				}()
+
+				timeService.WaitForWatcherAndIncrement(time.Minute)
+
+				var r runResult
+				Eventually(resultCh).Should(Receive(&r))
+
+				Expect(r.err).To(MatchError("Script timed out after 1m0s"))
+				Expect(r.result).To(Equal(boshscript.RunResult{
+					ExitStatus: 143,
+					Duration:   time.Minute,
+					TimedOut:   true,
+					StdoutTail: "fake-stdout",
+				}))
+				Expect(process.TerminateNicelyKillGracePeriod).To(Equal(10 * time.Second))
+			})
+		})
+	})
 })
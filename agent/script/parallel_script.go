@@ -8,8 +8,9 @@ import (
 )
 
 type ParallelScript struct {
-	name       string
-	allScripts []Script
+	name           string
+	allScripts     []Script
+	maxConcurrency int
 
 	logTag string
 	logger boshlog.Logger
@@ -17,13 +18,20 @@ type ParallelScript struct {
 
 type scriptResult struct {
 	Script Script
-	Error  error
+	Result ScriptResult
 }
 
 func NewParallelScript(name string, scripts []Script, logger boshlog.Logger) ParallelScript {
+	return NewLimitedParallelScript(name, scripts, 0, logger)
+}
+
+// NewLimitedParallelScript runs at most maxConcurrency scripts at
+// the same time, or all of them when maxConcurrency is not positive
+func NewLimitedParallelScript(name string, scripts []Script, maxConcurrency int, logger boshlog.Logger) ParallelScript {
 	return ParallelScript{
-		name:       name,
-		allScripts: scripts,
+		name:           name,
+		allScripts:     scripts,
+		maxConcurrency: maxConcurrency,
 
 		logTag: "ParallelScript",
 		logger: logger,
@@ -35,35 +43,61 @@ func (s ParallelScript) Path() string { return "" }
 func (s ParallelScript) Exists() bool { return true }
 
 func (s ParallelScript) Run() error {
+	_, err := s.RunWithResults()
+	return err
+}
+
+// RunWithResults returns the result of every script that exists by job name
+func (s ParallelScript) RunWithResults() (map[string]ScriptResult, error) {
 	existingScripts := s.findExistingScripts(s.allScripts)
 
 	s.logger.Info(s.logTag, "Will run %d %s scripts in parallel", len(existingScripts), s.name)
 
 	resultsChan := make(chan scriptResult)
 
+	var slots chan struct{}
+	if s.maxConcurrency > 0 {
+		slots = make(chan struct{}, s.maxConcurrency)
+	}
+
 	for _, script := range existingScripts {
 		script := script
-		go func() { resultsChan <- scriptResult{script, script.Run()} }()
+		go func() {
+			if slots != nil {
+				slots <- struct{}{}
+			}
+
+			result := s.runScript(script)
+
+			if slots != nil {
+				<-slots
+			}
+
+			resultsChan <- scriptResult{script, result}
+		}()
 	}
 
+	results := map[string]ScriptResult{}
+
 	var failedScripts, passedScripts []string
 
 	for i := 0; i < len(existingScripts); i++ {
 		select {
 		case r := <-resultsChan:
 			jobName := r.Script.Tag()
+			results[jobName] = r.Result
 
-			if r.Error == nil {
+			if r.Result.Error == nil {
 				passedScripts = append(passedScripts, jobName)
 				s.logger.Info(s.logTag, "'%s' script has successfully executed", r.Script.Path())
 			} else {
 				failedScripts = append(failedScripts, jobName)
-				s.logger.Error(s.logTag, "'%s' script has failed with error: %s", r.Script.Path(), r.Error)
+				s.logger.Error(s.logTag, "'%s' script has failed with error: %s", r.Script.Path(), r.Result.Error)
 			}
 		}
 	}
 
-	return s.summarizeErrs(passedScripts, failedScripts)
+	return results, s.summarizeErrs(passedScripts, failedScripts)
 }
 
 func (s ParallelScript) Cancel() error {
@@ -82,6 +116,20 @@ func (s ParallelScript) Cancel() error {
 	return nil
 }
 
+func (s ParallelScript) runScript(script Script) ScriptResult {
+	if script, ok := script.(ReportingScript); ok {
+		result, err := script.RunWithResult()
+		return ScriptResult{RunResult: result, Error: err}
+	}
+
+	err := script.Run()
+	if err != nil {
+		return ScriptResult{RunResult: RunResult{ExitStatus: -1}, Error: err}
+	}
+
+	return ScriptResult{}
+}
+
 func (s ParallelScript) findExistingScripts(all []Script) []Script {
 	var existing []Script
 
@@ -203,6 +203,91 @@ var _ = Describe("ParallelScript", func() {
 		})
 	})
 
+	Describe("RunWithResults", func() {
+		var (
+			reportingScript *scriptfakes.FakeReportingScript
+			plainScript     *scriptfakes.FakeScript
+		)
+
+		BeforeEach(func() {
+			reportingScript = &scriptfakes.FakeReportingScript{}
+			reportingScript.TagReturns("fake-job-1")
+			reportingScript.ExistsReturns(true)
+			scripts = append(scripts, reportingScript)
+
+			plainScript = &scriptfakes.FakeScript{}
+			plainScript.TagReturns("fake-job-2")
+			plainScript.ExistsReturns(true)
+			scripts = append(scripts, plainScript)
+		})
+
+		It("returns the result of every script by job name", func() {
+			reportingScript.RunWithResultReturns(boshscript.RunResult{
+				ExitStatus: 2,
+				Duration:   time.Second,
+				StderrTail: "fake-stderr",
+			}, errors.New("fake-error"))
+
+			results, err := parallelScript.RunWithResults()
+			Expect(err).To(MatchError("1 of 2 run-me scripts failed. Failed Jobs: fake-job-1. Successful Jobs: fake-job-2."))
+			Expect(results).To(Equal(map[string]boshscript.ScriptResult{
+				"fake-job-1": {
+					RunResult: boshscript.RunResult{ExitStatus: 2, Duration: time.Second, StderrTail: "fake-stderr"},
+					Error:     errors.New("fake-error"),
+				},
+				"fake-job-2": {},
+			}))
+			Expect(reportingScript.RunCallCount()).To(Equal(0))
+		})
+
+		It("reports an unknown exit status for failed scripts that do not report results", func() {
+			plainScript.RunReturns(errors.New("fake-error"))
+
+			results, err := parallelScript.RunWithResults()
+			Expect(err).To(HaveOccurred())
+			Expect(results["fake-job-2"]).To(Equal(boshscript.ScriptResult{
+				RunResult: boshscript.RunResult{ExitStatus: -1},
+				Error:     errors.New("fake-error"),
+			}))
+		})
+
+		It("does not run more scripts at the same time than allowed", func() {
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			parallelScript = boshscript.NewLimitedParallelScript("run-me", scripts, 1, logger)
+
+			lock := sync.Mutex{}
+			running, maxRunning := 0, 0
+
+			track := func() {
+				lock.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				lock.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				lock.Lock()
+				running--
+				lock.Unlock()
+			}
+
+			reportingScript.RunWithResultStub = func() (boshscript.RunResult, error) {
+				track()
+				return boshscript.RunResult{}, nil
+			}
+			plainScript.RunStub = func() error {
+				track()
+				return nil
+			}
+
+			_, err := parallelScript.RunWithResults()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(maxRunning).To(Equal(1))
+		})
+	})
+
 	Describe("Cancel", func() {
 		Context("when there are no scripts", func() {
 			BeforeEach(func() {
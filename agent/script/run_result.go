@@ -0,0 +1,57 @@
+package script
+
+import (
+	"sync"
+	"time"
+)
+
+// Scripts keep this many of the last bytes they wrote to stdout and stderr
+const scriptOutputTailSize = 4 * 1024
+
+// RunResult describes a single run of a job's script
+type RunResult struct {
+	// -1 when the script did not exit on its own
+	ExitStatus int
+	Duration   time.Duration
+	TimedOut   bool
+
+	StdoutTail string
+	StderrTail string
+}
+
+// ScriptResult is the result of a job's script run by a ParallelScript
+type ScriptResult struct {
+	RunResult
+	Error error
+}
+
+// TailBuffer keeps the last bytes written to it
+type TailBuffer struct {
+	lock sync.Mutex
+	size int
+	buf  []byte
+}
+
+func NewTailBuffer(size int) *TailBuffer {
+	return &TailBuffer{size: size}
+}
+
+func (b *TailBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.buf = append(b.buf, p...)
+
+	if len(b.buf) > b.size {
+		b.buf = append([]byte{}, b.buf[len(b.buf)-b.size:]...)
+	}
+
+	return len(p), nil
+}
+
+func (b *TailBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return string(b.buf)
+}
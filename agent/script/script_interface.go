@@ -1,6 +1,8 @@
 package script
 
 import (
+	"time"
+
 	boshdrain "github.com/cloudfoundry/bosh-agent/agent/script/drain"
 )
 
@@ -9,9 +11,10 @@ import (
 //counterfeiter:generate . JobScriptProvider
 
 type JobScriptProvider interface {
-	NewScript(jobName string, scriptName string, scriptEnv map[string]string) Script
+	NewScript(jobName string, scriptName string, scriptEnv map[string]string, timeout time.Duration) Script
 	NewDrainScript(jobName string, params boshdrain.ScriptParams) CancellableScript
 	NewParallelScript(scriptName string, scripts []Script) CancellableScript
+	NewLimitedParallelScript(scriptName string, scripts []Script, maxConcurrency int) ReportingParallelScript
 }
 
 //counterfeiter:generate . Script
@@ -30,3 +33,19 @@ type CancellableScript interface {
 	Script
 	Cancel() error
 }
+
+//counterfeiter:generate . ReportingScript
+
+// ReportingScript is implemented by scripts that
+// can report how a run of the script went
+type ReportingScript interface {
+	Script
+	RunWithResult() (RunResult, error)
+}
+
+//counterfeiter:generate . ReportingParallelScript
+
+type ReportingParallelScript interface {
+	CancellableScript
+	RunWithResults() (map[string]ScriptResult, error)
+}
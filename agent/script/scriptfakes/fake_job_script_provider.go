@@ -3,6 +3,7 @@ package scriptfakes
 
 import (
 	"sync"
+	"time"
 
 	"github.com/cloudfoundry/bosh-agent/agent/script"
 	"github.com/cloudfoundry/bosh-agent/agent/script/drain"
@@ -21,6 +22,19 @@ type FakeJobScriptProvider struct {
 	newDrainScriptReturnsOnCall map[int]struct {
 		result1 script.CancellableScript
 	}
+	NewLimitedParallelScriptStub        func(string, []script.Script, int) script.ReportingParallelScript
+	newLimitedParallelScriptMutex       sync.RWMutex
+	newLimitedParallelScriptArgsForCall []struct {
+		arg1 string
+		arg2 []script.Script
+		arg3 int
+	}
+	newLimitedParallelScriptReturns struct {
+		result1 script.ReportingParallelScript
+	}
+	newLimitedParallelScriptReturnsOnCall map[int]struct {
+		result1 script.ReportingParallelScript
+	}
 	NewParallelScriptStub        func(string, []script.Script) script.CancellableScript
 	newParallelScriptMutex       sync.RWMutex
 	newParallelScriptArgsForCall []struct {
@@ -33,12 +47,13 @@ type FakeJobScriptProvider struct {
 	newParallelScriptReturnsOnCall map[int]struct {
 		result1 script.CancellableScript
 	}
-	NewScriptStub        func(string, string, map[string]string) script.Script
+	NewScriptStub        func(string, string, map[string]string, time.Duration) script.Script
 	newScriptMutex       sync.RWMutex
 	newScriptArgsForCall []struct {
 		arg1 string
 		arg2 string
 		arg3 map[string]string
+		arg4 time.Duration
 	}
 	newScriptReturns struct {
 		result1 script.Script
@@ -112,6 +127,74 @@ func (fake *FakeJobScriptProvider) NewDrainScriptReturnsOnCall(i int, result1 sc
 	}{result1}
 }
 
+func (fake *FakeJobScriptProvider) NewLimitedParallelScript(arg1 string, arg2 []script.Script, arg3 int) script.ReportingParallelScript {
+	var arg2Copy []script.Script
+	if arg2 != nil {
+		arg2Copy = make([]script.Script, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.newLimitedParallelScriptMutex.Lock()
+	ret, specificReturn := fake.newLimitedParallelScriptReturnsOnCall[len(fake.newLimitedParallelScriptArgsForCall)]
+	fake.newLimitedParallelScriptArgsForCall = append(fake.newLimitedParallelScriptArgsForCall, struct {
+		arg1 string
+		arg2 []script.Script
+		arg3 int
+	}{arg1, arg2Copy, arg3})
+	stub := fake.NewLimitedParallelScriptStub
+	fakeReturns := fake.newLimitedParallelScriptReturns
+	fake.recordInvocation("NewLimitedParallelScript", []interface{}{arg1, arg2Copy, arg3})
+	fake.newLimitedParallelScriptMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeJobScriptProvider) NewLimitedParallelScriptCallCount() int {
+	fake.newLimitedParallelScriptMutex.RLock()
+	defer fake.newLimitedParallelScriptMutex.RUnlock()
+	return len(fake.newLimitedParallelScriptArgsForCall)
+}
+
+func (fake *FakeJobScriptProvider) NewLimitedParallelScriptCalls(stub func(string, []script.Script, int) script.ReportingParallelScript) {
+	fake.newLimitedParallelScriptMutex.Lock()
+	defer fake.newLimitedParallelScriptMutex.Unlock()
+	fake.NewLimitedParallelScriptStub = stub
+}
+
+func (fake *FakeJobScriptProvider) NewLimitedParallelScriptArgsForCall(i int) (string, []script.Script, int) {
+	fake.newLimitedParallelScriptMutex.RLock()
+	defer fake.newLimitedParallelScriptMutex.RUnlock()
+	argsForCall := fake.newLimitedParallelScriptArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeJobScriptProvider) NewLimitedParallelScriptReturns(result1 script.ReportingParallelScript) {
+	fake.newLimitedParallelScriptMutex.Lock()
+	defer fake.newLimitedParallelScriptMutex.Unlock()
+	fake.NewLimitedParallelScriptStub = nil
+	fake.newLimitedParallelScriptReturns = struct {
+		result1 script.ReportingParallelScript
+	}{result1}
+}
+
+func (fake *FakeJobScriptProvider) NewLimitedParallelScriptReturnsOnCall(i int, result1 script.ReportingParallelScript) {
+	fake.newLimitedParallelScriptMutex.Lock()
+	defer fake.newLimitedParallelScriptMutex.Unlock()
+	fake.NewLimitedParallelScriptStub = nil
+	if fake.newLimitedParallelScriptReturnsOnCall == nil {
+		fake.newLimitedParallelScriptReturnsOnCall = make(map[int]struct {
+			result1 script.ReportingParallelScript
+		})
+	}
+	fake.newLimitedParallelScriptReturnsOnCall[i] = struct {
+		result1 script.ReportingParallelScript
+	}{result1}
+}
+
 func (fake *FakeJobScriptProvider) NewParallelScript(arg1 string, arg2 []script.Script) script.CancellableScript {
 	var arg2Copy []script.Script
 	if arg2 != nil {
@@ -179,20 +262,21 @@ func (fake *FakeJobScriptProvider) NewParallelScriptReturnsOnCall(i int, result1
 	}{result1}
 }
 
-func (fake *FakeJobScriptProvider) NewScript(arg1 string, arg2 string, arg3 map[string]string) script.Script {
+func (fake *FakeJobScriptProvider) NewScript(arg1 string, arg2 string, arg3 map[string]string, arg4 time.Duration) script.Script {
 	fake.newScriptMutex.Lock()
 	ret, specificReturn := fake.newScriptReturnsOnCall[len(fake.newScriptArgsForCall)]
 	fake.newScriptArgsForCall = append(fake.newScriptArgsForCall, struct {
 		arg1 string
 		arg2 string
 		arg3 map[string]string
-	}{arg1, arg2, arg3})
+		arg4 time.Duration
+	}{arg1, arg2, arg3, arg4})
 	stub := fake.NewScriptStub
 	fakeReturns := fake.newScriptReturns
-	fake.recordInvocation("NewScript", []interface{}{arg1, arg2, arg3})
+	fake.recordInvocation("NewScript", []interface{}{arg1, arg2, arg3, arg4})
 	fake.newScriptMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3)
+		return stub(arg1, arg2, arg3, arg4)
 	}
 	if specificReturn {
 		return ret.result1
@@ -206,17 +290,17 @@ func (fake *FakeJobScriptProvider) NewScriptCallCount() int {
 	return len(fake.newScriptArgsForCall)
 }
 
-func (fake *FakeJobScriptProvider) NewScriptCalls(stub func(string, string, map[string]string) script.Script) {
+func (fake *FakeJobScriptProvider) NewScriptCalls(stub func(string, string, map[string]string, time.Duration) script.Script) {
 	fake.newScriptMutex.Lock()
 	defer fake.newScriptMutex.Unlock()
 	fake.NewScriptStub = stub
 }
 
-func (fake *FakeJobScriptProvider) NewScriptArgsForCall(i int) (string, string, map[string]string) {
+func (fake *FakeJobScriptProvider) NewScriptArgsForCall(i int) (string, string, map[string]string, time.Duration) {
 	fake.newScriptMutex.RLock()
 	defer fake.newScriptMutex.RUnlock()
 	argsForCall := fake.newScriptArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *FakeJobScriptProvider) NewScriptReturns(result1 script.Script) {
@@ -247,6 +331,8 @@ func (fake *FakeJobScriptProvider) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.newDrainScriptMutex.RLock()
 	defer fake.newDrainScriptMutex.RUnlock()
+	fake.newLimitedParallelScriptMutex.RLock()
+	defer fake.newLimitedParallelScriptMutex.RUnlock()
 	fake.newParallelScriptMutex.RLock()
 	defer fake.newParallelScriptMutex.RUnlock()
 	fake.newScriptMutex.RLock()
@@ -0,0 +1,268 @@
+package tarstream
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const logTag = "tarstreamExtractor"
+
+type Options struct {
+	// PathInArchive limits extraction to entries below the given directory
+	// and strips it from the extracted names. Entries may be stored with or
+	// without a leading "./" depending on how the director archived them.
+	PathInArchive string
+}
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . Extractor
+
+type Extractor interface {
+	// Extract decompresses a gzipped tarball read from source into targetDir
+	// while computing its digest. The whole source is consumed and verified
+	// against digest before Extract returns, so callers must treat the
+	// contents of targetDir as untrusted until Extract succeeds.
+	Extract(source io.Reader, digest boshcrypto.Digest, targetDir string, options Options) error
+}
+
+type extractor struct {
+	fs     boshsys.FileSystem
+	logger boshlog.Logger
+}
+
+func NewExtractor(fs boshsys.FileSystem, logger boshlog.Logger) Extractor {
+	return extractor{fs: fs, logger: logger}
+}
+
+func (e extractor) Extract(source io.Reader, digest boshcrypto.Digest, targetDir string, options Options) error {
+	pr, pw := io.Pipe()
+	verified := make(chan error, 1)
+
+	go func() {
+		err := digest.Verify(pr)
+		// Verify may give up early, keep reading so that extraction is not blocked
+		_, _ = io.Copy(io.Discard, pr)
+		verified <- err
+	}()
+
+	body := io.TeeReader(source, pw)
+
+	err := e.extract(body, targetDir, options)
+	if err == nil {
+		// Tar padding and the gzip trailer must be part of the digest as well
+		_, err = io.Copy(io.Discard, body)
+		if err != nil {
+			err = bosherr.WrapError(err, "Reading remainder of archive")
+		}
+	}
+
+	if err != nil {
+		pw.CloseWithError(err)
+		<-verified
+		return err
+	}
+
+	_ = pw.Close()
+
+	err = <-verified
+	if err != nil {
+		return bosherr.WrapError(err, "Verifying archive digest")
+	}
+
+	return nil
+}
+
+func (e extractor) extract(source io.Reader, targetDir string, options Options) error {
+	gzipReader, err := gzip.NewReader(source)
+	if err != nil {
+		return bosherr.WrapError(err, "Opening gzip stream")
+	}
+
+	defer gzipReader.Close()
+
+	err = e.fs.MkdirAll(targetDir, os.FileMode(0755))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating target directory %s", targetDir)
+	}
+
+	tarReader := tar.NewReader(gzipReader)
+
+	// Symlinks are only created once everything else is extracted so that
+	// later entries cannot be written through them outside of targetDir
+	var symlinks []*tar.Header
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return e.extractSymlinks(symlinks, targetDir, options)
+		}
+		if err != nil {
+			return bosherr.WrapError(err, "Reading tar entry")
+		}
+
+		name, ok, err := entryName(header.Name, options.PathInArchive)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		if header.Typeflag == tar.TypeSymlink {
+			symlinks = append(symlinks, header)
+			continue
+		}
+
+		err = e.extractEntry(tarReader, header, name, targetDir, options)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Extracting %s", header.Name)
+		}
+	}
+}
+
+func (e extractor) extractSymlinks(symlinks []*tar.Header, targetDir string, options Options) error {
+	for _, header := range symlinks {
+		name, _, err := entryName(header.Name, options.PathInArchive)
+		if err != nil {
+			return err
+		}
+
+		err = e.extractEntry(nil, header, name, targetDir, options)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Extracting %s", header.Name)
+		}
+	}
+
+	return nil
+}
+
+func (e extractor) extractEntry(tarReader *tar.Reader, header *tar.Header, name, targetDir string, options Options) error {
+	targetPath := filepath.Join(targetDir, filepath.FromSlash(name))
+	mode := header.FileInfo().Mode().Perm()
+
+	checkedName := name
+	if header.Typeflag == tar.TypeSymlink {
+		// An existing symlink at the path itself is replaced, not followed
+		checkedName = path.Dir(name)
+	}
+
+	err := e.refuseSymlinks(targetDir, checkedName)
+	if err != nil {
+		return err
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		err = e.fs.MkdirAll(targetPath, mode)
+		if err != nil {
+			return err
+		}
+		return e.fs.Chmod(targetPath, mode)
+
+	case tar.TypeReg, tar.TypeRegA:
+		err = e.fs.MkdirAll(filepath.Dir(targetPath), os.FileMode(0755))
+		if err != nil {
+			return err
+		}
+
+		file, err := e.fs.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(file, tarReader)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+
+		return e.fs.Chmod(targetPath, mode)
+
+	case tar.TypeSymlink:
+		err = e.fs.MkdirAll(filepath.Dir(targetPath), os.FileMode(0755))
+		if err != nil {
+			return err
+		}
+		return e.fs.Symlink(header.Linkname, targetPath)
+
+	case tar.TypeLink:
+		linkName, ok, err := entryName(header.Linkname, options.PathInArchive)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return bosherr.Errorf("Hard link target %s is outside of %s", header.Linkname, options.PathInArchive)
+		}
+
+		err = e.refuseSymlinks(targetDir, linkName)
+		if err != nil {
+			return err
+		}
+
+		return e.fs.CopyFile(filepath.Join(targetDir, filepath.FromSlash(linkName)), targetPath)
+
+	default:
+		e.logger.Debug(logTag, "Skipping unsupported entry %s of type %c", header.Name, header.Typeflag)
+		return nil
+	}
+}
+
+// refuseSymlinks returns an error when any existing component of name below
+// targetDir is a symlink, since following it could leave targetDir
+func (e extractor) refuseSymlinks(targetDir, name string) error {
+	currentPath := targetDir
+
+	for _, component := range strings.Split(name, "/") {
+		if component == "." {
+			continue
+		}
+
+		currentPath = filepath.Join(currentPath, component)
+
+		info, err := e.fs.Lstat(currentPath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Checking %s", currentPath)
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return bosherr.Errorf("Refusing to extract %s through symlink %s", name, currentPath)
+		}
+	}
+
+	return nil
+}
+
+// entryName returns the name of the entry relative to the extraction
+// directory and whether it should be extracted at all.
+func entryName(name, pathInArchive string) (string, bool, error) {
+	cleanName := path.Clean(strings.TrimPrefix(name, "./"))
+
+	if path.IsAbs(cleanName) || cleanName == ".." || strings.HasPrefix(cleanName, "../") {
+		return "", false, bosherr.Errorf("Refusing to extract %s outside of target directory", name)
+	}
+
+	if pathInArchive == "" {
+		return cleanName, cleanName != ".", nil
+	}
+
+	prefix := path.Clean(pathInArchive) + "/"
+	if !strings.HasPrefix(cleanName, prefix) {
+		return "", false, nil
+	}
+
+	return strings.TrimPrefix(cleanName, prefix), true, nil
+}
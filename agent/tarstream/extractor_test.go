@@ -0,0 +1,259 @@
+package tarstream_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	mode     int64
+	body     string
+	linkname string
+}
+
+func buildTgz(entries []tarEntry) []byte {
+	buffer := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Mode:     entry.mode,
+			Size:     int64(len(entry.body)),
+			Linkname: entry.linkname,
+		}
+		Expect(tarWriter.WriteHeader(header)).To(Succeed())
+		_, err := tarWriter.Write([]byte(entry.body))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	Expect(tarWriter.Close()).To(Succeed())
+	Expect(gzipWriter.Close()).To(Succeed())
+
+	return buffer.Bytes()
+}
+
+func sha1Of(contents []byte) boshcrypto.Digest {
+	digest, err := boshcrypto.DigestAlgorithmSHA1.CreateDigest(bytes.NewReader(contents))
+	Expect(err).NotTo(HaveOccurred())
+	return digest
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("fake-read-err") }
+
+var _ = Describe("Extractor", func() {
+	var (
+		tmp       string
+		targetDir string
+		extractor tarstream.Extractor
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmp, err = os.MkdirTemp("", "tarstream")
+		Expect(err).NotTo(HaveOccurred())
+
+		targetDir = filepath.Join(tmp, "target")
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		extractor = tarstream.NewExtractor(boshsys.NewOsFileSystem(logger), logger)
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmp)
+	})
+
+	It("extracts directories, files and symlinks", func() {
+		if runtime.GOOS == "windows" {
+			Skip("symlinks require elevated privileges on windows")
+		}
+
+		tgz := buildTgz([]tarEntry{
+			{name: "./", typeflag: tar.TypeDir, mode: 0755},
+			{name: "./bin/", typeflag: tar.TypeDir, mode: 0750},
+			{name: "./bin/run", typeflag: tar.TypeReg, mode: 0755, body: "#!/bin/bash"},
+			{name: "./README", typeflag: tar.TypeReg, mode: 0644, body: "read me"},
+			{name: "./current", typeflag: tar.TypeSymlink, linkname: "bin/run"},
+			{name: "./README.copy", typeflag: tar.TypeLink, linkname: "./README"},
+		})
+
+		err := extractor.Extract(bytes.NewReader(tgz), sha1Of(tgz), targetDir, tarstream.Options{})
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := os.ReadFile(filepath.Join(targetDir, "bin", "run"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("#!/bin/bash"))
+
+		info, err := os.Stat(filepath.Join(targetDir, "bin", "run"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+
+		info, err = os.Stat(filepath.Join(targetDir, "bin"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0750)))
+
+		link, err := os.Readlink(filepath.Join(targetDir, "current"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link).To(Equal("bin/run"))
+
+		contents, err = os.ReadFile(filepath.Join(targetDir, "README.copy"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("read me"))
+	})
+
+	It("extracts only entries below the path in archive with and without a leading ./", func() {
+		tgz := buildTgz([]tarEntry{
+			{name: "./job-a/", typeflag: tar.TypeDir, mode: 0755},
+			{name: "./job-a/monit", typeflag: tar.TypeReg, mode: 0644, body: "monit a"},
+			{name: "job-b/monit", typeflag: tar.TypeReg, mode: 0644, body: "monit b"},
+			{name: "job-b/templates/ctl", typeflag: tar.TypeReg, mode: 0644, body: "ctl b"},
+		})
+
+		err := extractor.Extract(bytes.NewReader(tgz), sha1Of(tgz), targetDir, tarstream.Options{PathInArchive: "job-b"})
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := os.ReadFile(filepath.Join(targetDir, "monit"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("monit b"))
+
+		contents, err = os.ReadFile(filepath.Join(targetDir, "templates", "ctl"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("ctl b"))
+
+		Expect(filepath.Join(targetDir, "job-a")).NotTo(BeADirectory())
+
+		err = extractor.Extract(bytes.NewReader(tgz), sha1Of(tgz), filepath.Join(tmp, "other"), tarstream.Options{PathInArchive: "job-a"})
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err = os.ReadFile(filepath.Join(tmp, "other", "monit"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("monit a"))
+	})
+
+	It("verifies the digest over the whole stream including trailing data", func() {
+		tgz := buildTgz([]tarEntry{
+			{name: "file", typeflag: tar.TypeReg, mode: 0644, body: "contents"},
+		})
+		withTrailer := append(append([]byte{}, tgz...), []byte("trailing")...)
+
+		err := extractor.Extract(bytes.NewReader(withTrailer), sha1Of(tgz), targetDir, tarstream.Options{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Verifying archive digest"))
+
+		err = extractor.Extract(bytes.NewReader(withTrailer), sha1Of(withTrailer), filepath.Join(tmp, "other"), tarstream.Options{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns an error when the digest does not match", func() {
+		tgz := buildTgz([]tarEntry{
+			{name: "file", typeflag: tar.TypeReg, mode: 0644, body: "contents"},
+		})
+
+		err := extractor.Extract(bytes.NewReader(tgz), sha1Of([]byte("other")), targetDir, tarstream.Options{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Verifying archive digest"))
+	})
+
+	It("refuses to extract entries outside of the target directory", func() {
+		tgz := buildTgz([]tarEntry{
+			{name: "../escaped", typeflag: tar.TypeReg, mode: 0644, body: "contents"},
+		})
+
+		err := extractor.Extract(bytes.NewReader(tgz), sha1Of(tgz), targetDir, tarstream.Options{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("outside of target directory"))
+		Expect(filepath.Join(tmp, "escaped")).NotTo(BeAnExistingFile())
+	})
+
+	Context("when the archive contains symlinks pointing outside of the target directory", func() {
+		var outsideDir string
+
+		BeforeEach(func() {
+			if runtime.GOOS == "windows" {
+				Skip("symlinks require elevated privileges on windows")
+			}
+
+			outsideDir = filepath.Join(tmp, "outside")
+			Expect(os.MkdirAll(outsideDir, 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(outsideDir, "secret"), []byte("secret"), 0600)).To(Succeed())
+		})
+
+		It("does not write files through them", func() {
+			tgz := buildTgz([]tarEntry{
+				{name: "./escape", typeflag: tar.TypeSymlink, linkname: outsideDir},
+				{name: "./escape/evil", typeflag: tar.TypeReg, mode: 0644, body: "evil"},
+			})
+
+			err := extractor.Extract(bytes.NewReader(tgz), sha1Of(tgz), targetDir, tarstream.Options{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(filepath.Join(outsideDir, "evil")).NotTo(BeAnExistingFile())
+
+			link, err := os.Readlink(filepath.Join(targetDir, "escape"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link).To(Equal(outsideDir))
+		})
+
+		It("does not copy hard linked files through them", func() {
+			tgz := buildTgz([]tarEntry{
+				{name: "./escape", typeflag: tar.TypeSymlink, linkname: outsideDir},
+				{name: "./stolen", typeflag: tar.TypeLink, linkname: "./escape/secret"},
+			})
+
+			err := extractor.Extract(bytes.NewReader(tgz), sha1Of(tgz), targetDir, tarstream.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(filepath.Join(targetDir, "stolen")).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses to write through symlinks that already exist in the target directory", func() {
+			Expect(os.MkdirAll(targetDir, 0755)).To(Succeed())
+			Expect(os.Symlink(outsideDir, filepath.Join(targetDir, "escape"))).To(Succeed())
+
+			tgz := buildTgz([]tarEntry{
+				{name: "./escape/secret", typeflag: tar.TypeReg, mode: 0644, body: "overwritten"},
+			})
+
+			err := extractor.Extract(bytes.NewReader(tgz), sha1Of(tgz), targetDir, tarstream.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("through symlink"))
+
+			contents, err := os.ReadFile(filepath.Join(outsideDir, "secret"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("secret"))
+		})
+	})
+
+	It("returns an error when the source is not a gzipped tarball", func() {
+		contents := []byte("not a tarball")
+
+		err := extractor.Extract(bytes.NewReader(contents), sha1Of(contents), targetDir, tarstream.Options{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Opening gzip stream"))
+	})
+
+	It("returns an error when reading the source fails", func() {
+		err := extractor.Extract(failingReader{}, sha1Of([]byte{}), targetDir, tarstream.Options{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-read-err"))
+	})
+})
@@ -0,0 +1,13 @@
+package tarstream_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTarstream(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Tarstream Suite")
+}
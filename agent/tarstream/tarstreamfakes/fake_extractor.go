@@ -0,0 +1,119 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package tarstreamfakes
+
+import (
+	"io"
+	"sync"
+
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
+	"github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+type FakeExtractor struct {
+	ExtractStub        func(io.Reader, crypto.Digest, string, tarstream.Options) error
+	extractMutex       sync.RWMutex
+	extractArgsForCall []struct {
+		arg1 io.Reader
+		arg2 crypto.Digest
+		arg3 string
+		arg4 tarstream.Options
+	}
+	extractReturns struct {
+		result1 error
+	}
+	extractReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeExtractor) Extract(arg1 io.Reader, arg2 crypto.Digest, arg3 string, arg4 tarstream.Options) error {
+	fake.extractMutex.Lock()
+	ret, specificReturn := fake.extractReturnsOnCall[len(fake.extractArgsForCall)]
+	fake.extractArgsForCall = append(fake.extractArgsForCall, struct {
+		arg1 io.Reader
+		arg2 crypto.Digest
+		arg3 string
+		arg4 tarstream.Options
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.ExtractStub
+	fakeReturns := fake.extractReturns
+	fake.recordInvocation("Extract", []interface{}{arg1, arg2, arg3, arg4})
+	fake.extractMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeExtractor) ExtractCallCount() int {
+	fake.extractMutex.RLock()
+	defer fake.extractMutex.RUnlock()
+	return len(fake.extractArgsForCall)
+}
+
+func (fake *FakeExtractor) ExtractCalls(stub func(io.Reader, crypto.Digest, string, tarstream.Options) error) {
+	fake.extractMutex.Lock()
+	defer fake.extractMutex.Unlock()
+	fake.ExtractStub = stub
+}
+
+func (fake *FakeExtractor) ExtractArgsForCall(i int) (io.Reader, crypto.Digest, string, tarstream.Options) {
+	fake.extractMutex.RLock()
+	defer fake.extractMutex.RUnlock()
+	argsForCall := fake.extractArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeExtractor) ExtractReturns(result1 error) {
+	fake.extractMutex.Lock()
+	defer fake.extractMutex.Unlock()
+	fake.ExtractStub = nil
+	fake.extractReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeExtractor) ExtractReturnsOnCall(i int, result1 error) {
+	fake.extractMutex.Lock()
+	defer fake.extractMutex.Unlock()
+	fake.ExtractStub = nil
+	if fake.extractReturnsOnCall == nil {
+		fake.extractReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.extractReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeExtractor) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.extractMutex.RLock()
+	defer fake.extractMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeExtractor) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ tarstream.Extractor = new(FakeExtractor)
@@ -118,6 +118,7 @@ func (service asyncTaskService) processTasks() {
 		task.Func = nil
 		task.CancelFunc = nil
 		task.EndFunc = nil
+		task.ProgressFunc = nil
 
 		service.taskSem <- func() {
 			service.currentTasks[task.ID] = task
@@ -41,4 +41,15 @@ var _ = Describe("Task", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
+
+	Describe("Progress", func() {
+		It("returns the value of the progress function", func() {
+			task.ProgressFunc = func() interface{} { return "fake-progress" }
+			Expect(task.Progress()).To(Equal("fake-progress"))
+		})
+
+		It("returns nil when progress function is not set", func() {
+			Expect(task.Progress()).To(BeNil())
+		})
+	})
 })
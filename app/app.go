@@ -2,7 +2,9 @@ package app
 
 import (
 	"fmt"
+	"net/http"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"code.cloudfoundry.org/clock"
@@ -11,6 +13,7 @@ import (
 
 	boshagent "github.com/cloudfoundry/bosh-agent/agent"
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
 	boshapplier "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
@@ -20,9 +23,11 @@ import (
 	"github.com/cloudfoundry/bosh-agent/agent/bootonce"
 	boshrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	"github.com/cloudfoundry/bosh-agent/agent/diagnostics"
 	httpblobprovider "github.com/cloudfoundry/bosh-agent/agent/httpblobprovider"
 	"github.com/cloudfoundry/bosh-agent/agent/httpblobprovider/blobstore_delegator"
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
+	"github.com/cloudfoundry/bosh-agent/agent/tarstream"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshinf "github.com/cloudfoundry/bosh-agent/infrastructure"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
@@ -30,9 +35,11 @@ import (
 	boshmbus "github.com/cloudfoundry/bosh-agent/mbus"
 	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshsigar "github.com/cloudfoundry/bosh-agent/sigar"
+	"github.com/cloudfoundry/bosh-agent/webhook"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -121,6 +128,12 @@ func (app *app) Setup(opts Options) error {
 		return bosherr.WrapError(err, "Running bootstrap")
 	}
 
+	specHistory := boshas.NewFileSpecHistory(
+		app.platform.GetFs(),
+		filepath.Join(app.dirProvider.BoshDir(), "spec_history.json"),
+		settingsService.GetSettings().Env.GetRetainedApplySpecs(),
+	)
+
 	// For storing large non-sensitive blobs
 	inconsiderateBlobManager, err := boshagentblobstore.NewBlobManager(app.dirProvider.BlobsDir())
 	if err != nil {
@@ -148,6 +161,19 @@ func (app *app) Setup(opts Options) error {
 		return bosherr.WrapError(err, "Getting mbus handler")
 	}
 
+	uuidGen := boshuuid.NewGenerator()
+
+	webhookPublisher := webhook.NewPublisher(
+		settingsService,
+		&http.Client{Timeout: 10 * time.Second},
+		uuidGen,
+		timeService,
+		webhook.DefaultPublisherOptions,
+		app.logger,
+	)
+
+	mbusHandler = webhook.NewHandler(mbusHandler, webhookPublisher)
+
 	monitClientProvider := boshmonit.NewProvider(app.platform, app.logger)
 
 	monitClient, err := monitClientProvider.Get()
@@ -178,6 +204,7 @@ func (app *app) Setup(opts Options) error {
 	blobstoreDelegator := blobstore_delegator.NewBlobstoreDelegator(
 		httpblobprovider.NewHTTPBlobImpl(app.platform.GetFs(), blobstoreHTTPClient),
 		blobstore,
+		app.platform.GetFs(),
 	)
 
 	applier, compiler := app.buildApplierAndCompiler(
@@ -185,11 +212,10 @@ func (app *app) Setup(opts Options) error {
 		blobstoreDelegator,
 		jobSupervisor,
 		settingsService.GetSettings(),
+		specHistory,
 		timeService,
 	)
 
-	uuidGen := boshuuid.NewGenerator()
-
 	taskService := boshtask.NewAsyncTaskService(uuidGen, app.logger)
 
 	taskManager := boshtask.NewManagerProvider().NewManager(
@@ -216,6 +242,7 @@ func (app *app) Setup(opts Options) error {
 		compiler,
 		jobSupervisor,
 		specService,
+		specHistory,
 		jobScriptProvider,
 		app.logger,
 		blobstoreDelegator,
@@ -229,6 +256,7 @@ func (app *app) Setup(opts Options) error {
 		taskManager,
 		actionFactory,
 		actionRunner,
+		webhookPublisher,
 	)
 
 	startManager := bootonce.NewStartManager(
@@ -237,6 +265,32 @@ func (app *app) Setup(opts Options) error {
 		app.dirProvider,
 	)
 
+	alertCollector := boshalert.NewMultiCollector(
+		boshalert.NewOOMCollector(app.platform.GetFs(), settingsService, timeService),
+		boshalert.NewReadOnlyMountCollector(
+			boshdisk.NewProcMountsSearcher(app.platform.GetFs()),
+			app.platform.GetFs(),
+			settingsService,
+			timeService,
+		),
+		boshalert.NewDiskUsageCollector(
+			app.platform.GetVitalsService(),
+			boshalert.DefaultDiskThresholds,
+			settingsService,
+			timeService,
+		),
+	)
+
+	diagnosticsCapturer := diagnostics.NewCapturer(
+		app.platform.GetFs(),
+		app.platform.GetRunner(),
+		app.platform.GetVitalsService(),
+		jobSupervisor,
+		app.dirProvider,
+		timeService,
+		app.logger,
+	)
+
 	app.agent = boshagent.New(
 		app.logger,
 		mbusHandler,
@@ -249,6 +303,8 @@ func (app *app) Setup(opts Options) error {
 		uuidGen,
 		timeService,
 		startManager,
+		alertCollector,
+		diagnosticsCapturer,
 	)
 
 	return nil
@@ -270,9 +326,11 @@ func (app *app) buildApplierAndCompiler(
 	blobstoreDelegator blobstore_delegator.BlobstoreDelegator,
 	jobSupervisor boshjobsuper.JobSupervisor,
 	settings boshsettings.Settings,
+	specHistory boshas.SpecHistory,
 	timeService clock.Clock,
 ) (boshapplier.Applier, boshcomp.Compiler) {
 	fileSystem := app.platform.GetFs()
+	extractor := tarstream.NewExtractor(fileSystem, app.logger)
 
 	jobsBc := boshbc.NewFileBundleCollection(
 		dirProvider.DataDir(),
@@ -281,7 +339,7 @@ func (app *app) buildApplierAndCompiler(
 		os.FileMode(0750),
 		fileSystem,
 		timeService,
-		app.platform.GetCompressor(),
+		extractor,
 		app.logger,
 	)
 
@@ -291,7 +349,7 @@ func (app *app) buildApplierAndCompiler(
 		dirProvider.JobsDir(),
 		"packages",
 		blobstoreDelegator,
-		app.platform.GetCompressor(),
+		extractor,
 		fileSystem,
 		timeService,
 		app.logger,
@@ -315,6 +373,7 @@ func (app *app) buildApplierAndCompiler(
 		jobSupervisor,
 		dirProvider,
 		settings,
+		specHistory,
 	)
 
 	cmdRunner := boshrunner.NewFileLoggingCmdRunner(
@@ -324,15 +383,36 @@ func (app *app) buildApplierAndCompiler(
 		10*1024, // 10 Kb
 	)
 
+	compileCache := boshcomp.NewFileCompileCache(
+		fileSystem,
+		filepath.Join(dirProvider.DataDir(), "compile_cache"),
+		settings.Env.GetCompileCacheSizeInBytes(),
+		app.stemcellInfo(),
+		timeService,
+		app.logger,
+	)
+
+	compileSandbox := boshcomp.NewNamespaceSandbox(
+		fileSystem,
+		app.platform.GetRunner(),
+		filepath.Join(dirProvider.DataDir(), "compile_sandbox"),
+		settings.Env.Bosh.CompileSandbox,
+		app.logger,
+	)
+
 	compiler := boshcomp.NewConcreteCompiler(
 		app.platform.GetCompressor(),
+		extractor,
 		blobstoreDelegator,
 		fileSystem,
 		cmdRunner,
 		dirProvider,
 		packageApplierProvider.Root(),
 		packageApplierProvider.RootBundleCollection(),
+		compileCache,
+		compileSandbox,
 		clock.NewClock(),
+		app.logger,
 	)
 
 	return applier, compiler
@@ -352,6 +432,17 @@ func (app *app) logStemcellInfo() {
 	app.logger.Info(app.logTag, msg)
 }
 
+// stemcellInfo identifies the stemcell packages are compiled on
+func (app *app) stemcellInfo() string {
+	return fmt.Sprintf(
+		"%s/%s/%s/%s",
+		app.fileContents(filepath.Join(app.dirProvider.EtcDir(), "operating_system")),
+		app.fileContents(filepath.Join(app.dirProvider.EtcDir(), "stemcell_version")),
+		app.fileContents(filepath.Join(app.dirProvider.EtcDir(), "stemcell_git_sha1")),
+		runtime.GOARCH,
+	)
+}
+
 func (app *app) fileContents(path string) string {
 	contents, err := app.fs.ReadFileString(path)
 	if err != nil || len(contents) == 0 {
@@ -0,0 +1,142 @@
+package jobsupervisor
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	alertHTTPHandlerLogTag = "alertHTTPHandler"
+	alertHTTPMaxBodySize   = 32 * 1024
+)
+
+// alertRequest accepts both the MonitAlert shape and the generic Alert shape.
+// An alert is treated as generic when it has a title.
+type alertRequest struct {
+	ID          string `json:"id"`
+	Service     string `json:"service"`
+	Event       string `json:"event"`
+	Action      string `json:"action"`
+	Date        string `json:"date"`
+	Description string `json:"description"`
+
+	Severity  boshalert.SeverityLevel `json:"severity"`
+	Title     string                  `json:"title"`
+	Summary   string                  `json:"summary"`
+	CreatedAt int64                   `json:"created_at"`
+}
+
+type alertHTTPHandler struct {
+	handler  JobFailureHandler
+	username string
+	password string
+	logger   boshlog.Logger
+}
+
+func newAlertHTTPHandler(handler JobFailureHandler, username, password string, logger boshlog.Logger) http.Handler {
+	return alertHTTPHandler{
+		handler:  handler,
+		username: username,
+		password: password,
+		logger:   logger,
+	}
+}
+
+func (h alertHTTPHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/alerts" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorized(req) {
+		w.Header().Set("WWW-Authenticate", `Basic realm=""`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var request alertRequest
+
+	err := json.NewDecoder(io.LimitReader(req.Body, alertHTTPMaxBodySize)).Decode(&request)
+	if err != nil {
+		h.logger.Warn(alertHTTPHandlerLogTag, "Failed to decode alert: %s", err.Error())
+		http.Error(w, "Invalid alert: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	monitAlert, valid := request.monitAlert()
+	if !valid {
+		http.Error(w, "Invalid alert: expected either service and event, or title and a severity between 1 and 4", http.StatusBadRequest)
+		return
+	}
+
+	err = h.handler(monitAlert)
+	if err != nil {
+		h.logger.Error(alertHTTPHandlerLogTag, "Failed to handle alert: %s", err.Error())
+		http.Error(w, "Handling alert failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h alertHTTPHandler) authorized(req *http.Request) bool {
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(h.username)) == 1
+	passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(h.password)) == 1
+
+	return usernameMatches && passwordMatches
+}
+
+func (r alertRequest) monitAlert() (boshalert.MonitAlert, bool) {
+	if r.Title != "" {
+		if !r.Severity.Valid() {
+			return boshalert.MonitAlert{}, false
+		}
+
+		createdAt := time.Now()
+		if r.CreatedAt > 0 {
+			createdAt = time.Unix(r.CreatedAt, 0)
+		}
+
+		return boshalert.MonitAlert{
+			ID:          r.ID,
+			Date:        createdAt.Format(time.RFC1123Z),
+			Description: r.Summary,
+			Severity:    r.Severity,
+			Title:       r.Title,
+		}, true
+	}
+
+	if r.Service == "" || r.Event == "" {
+		return boshalert.MonitAlert{}, false
+	}
+
+	date := r.Date
+	if date == "" {
+		date = time.Now().Format(time.RFC1123Z)
+	}
+
+	return boshalert.MonitAlert{
+		ID:          r.ID,
+		Service:     r.Service,
+		Event:       r.Event,
+		Action:      r.Action,
+		Date:        date,
+		Description: r.Description,
+	}, true
+}
@@ -0,0 +1,204 @@
+package cgroup
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . Manager
+
+// Manager maintains one cgroup v2 group per job under a BOSH owned subtree.
+type Manager interface {
+	// Configure creates the job's group if necessary and (re)writes all of
+	// its limits; limits left unset are reset to the kernel defaults.
+	Configure(name string, limits Limits) error
+	AddProcess(name string, pid int) error
+	Usage(name string) (Usage, error)
+}
+
+// Limits are the per job resource limits. Values are in the format of the
+// matching cgroup v2 interface file, e.g. "50000 100000" for CPUMax or
+// "512M" for MemoryMax.
+type Limits struct {
+	CPUWeight int    `json:"cpu_weight,omitempty"`
+	CPUMax    string `json:"cpu_max,omitempty"`
+	MemoryMax string `json:"memory_max,omitempty"`
+	PidsMax   int    `json:"pids_max,omitempty"`
+}
+
+// IsEmpty returns true when no limit is set.
+func (l Limits) IsEmpty() bool {
+	return l == Limits{}
+}
+
+// Merge returns l with every limit set in override replaced.
+func (l Limits) Merge(override Limits) Limits {
+	if override.CPUWeight != 0 {
+		l.CPUWeight = override.CPUWeight
+	}
+	if override.CPUMax != "" {
+		l.CPUMax = override.CPUMax
+	}
+	if override.MemoryMax != "" {
+		l.MemoryMax = override.MemoryMax
+	}
+	if override.PidsMax != 0 {
+		l.PidsMax = override.PidsMax
+	}
+	return l
+}
+
+type Usage struct {
+	MemoryCurrent int64
+	CPUUsageUsec  int64
+	PidsCurrent   int
+}
+
+var controllers = []string{"cpu", "memory", "pids"}
+
+type manager struct {
+	fs   boshsys.FileSystem
+	root string
+}
+
+// NewManager returns a Manager keeping job groups under root, which must be
+// a direct child of a cgroup v2 mount such as /sys/fs/cgroup/bosh.
+func NewManager(fs boshsys.FileSystem, root string) Manager {
+	return manager{fs: fs, root: root}
+}
+
+func (m manager) Configure(name string, limits Limits) error {
+	mountPoint := path.Dir(m.root)
+
+	if !m.fs.FileExists(path.Join(mountPoint, "cgroup.controllers")) {
+		return bosherr.Errorf("cgroup v2 is not mounted at %s", mountPoint)
+	}
+
+	err := m.fs.MkdirAll(m.root, 0755)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating cgroup %s", m.root)
+	}
+
+	for _, dir := range []string{mountPoint, m.root} {
+		err = m.enableControllers(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	groupPath := path.Join(m.root, name)
+
+	err = m.fs.MkdirAll(groupPath, 0755)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating cgroup %s", groupPath)
+	}
+
+	values := []struct {
+		file  string
+		value string
+	}{
+		{"cpu.weight", "100"},
+		{"cpu.max", "max"},
+		{"memory.max", "max"},
+		{"pids.max", "max"},
+	}
+
+	if limits.CPUWeight != 0 {
+		values[0].value = strconv.Itoa(limits.CPUWeight)
+	}
+	if limits.CPUMax != "" {
+		values[1].value = limits.CPUMax
+	}
+	if limits.MemoryMax != "" {
+		values[2].value = limits.MemoryMax
+	}
+	if limits.PidsMax != 0 {
+		values[3].value = strconv.Itoa(limits.PidsMax)
+	}
+
+	for _, v := range values {
+		err = m.fs.WriteFileString(path.Join(groupPath, v.file), v.value)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Setting %s of cgroup %s to '%s'", v.file, groupPath, v.value)
+		}
+	}
+
+	return nil
+}
+
+func (m manager) enableControllers(dir string) error {
+	var enable []string
+	for _, controller := range controllers {
+		enable = append(enable, "+"+controller)
+	}
+
+	err := m.fs.WriteFileString(path.Join(dir, "cgroup.subtree_control"), strings.Join(enable, " "))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Enabling cgroup controllers in %s", dir)
+	}
+
+	return nil
+}
+
+func (m manager) AddProcess(name string, pid int) error {
+	procsPath := path.Join(m.root, name, "cgroup.procs")
+
+	err := m.fs.WriteFileString(procsPath, strconv.Itoa(pid))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Adding process %d to cgroup %s", pid, name)
+	}
+
+	return nil
+}
+
+func (m manager) Usage(name string) (Usage, error) {
+	var usage Usage
+	groupPath := path.Join(m.root, name)
+
+	memory, err := m.readInt(path.Join(groupPath, "memory.current"))
+	if err != nil {
+		return usage, err
+	}
+	usage.MemoryCurrent = memory
+
+	pids, err := m.readInt(path.Join(groupPath, "pids.current"))
+	if err != nil {
+		return usage, err
+	}
+	usage.PidsCurrent = int(pids)
+
+	cpuStat, err := m.fs.ReadFileString(path.Join(groupPath, "cpu.stat"))
+	if err != nil {
+		return usage, bosherr.WrapErrorf(err, "Reading cpu.stat of cgroup %s", name)
+	}
+
+	for _, line := range strings.Split(cpuStat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usage.CPUUsageUsec, err = strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return usage, bosherr.WrapErrorf(err, "Parsing cpu usage of cgroup %s", name)
+			}
+		}
+	}
+
+	return usage, nil
+}
+
+func (m manager) readInt(filePath string) (int64, error) {
+	content, err := m.fs.ReadFileString(filePath)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Reading %s", filePath)
+	}
+
+	value, err := strconv.ParseInt(strings.TrimSpace(content), 10, 64)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Parsing %s", filePath)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,101 @@
+package cgroup_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("Manager", func() {
+	var (
+		fs      *fakesys.FakeFileSystem
+		manager Manager
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		manager = NewManager(fs, "/sys/fs/cgroup/bosh")
+	})
+
+	Describe("Configure", func() {
+		Context("when cgroup v2 is mounted", func() {
+			BeforeEach(func() {
+				err := fs.WriteFileString("/sys/fs/cgroup/cgroup.controllers", "cpuset cpu io memory pids")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("enables controllers and writes the job's limits", func() {
+				err := manager.Configure("web", Limits{CPUWeight: 50, CPUMax: "50000 100000", MemoryMax: "512M", PidsMax: 100})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fs.ReadFileString("/sys/fs/cgroup/cgroup.subtree_control")).To(Equal("+cpu +memory +pids"))
+				Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/cgroup.subtree_control")).To(Equal("+cpu +memory +pids"))
+				Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/web/cpu.weight")).To(Equal("50"))
+				Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/web/cpu.max")).To(Equal("50000 100000"))
+				Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/web/memory.max")).To(Equal("512M"))
+				Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/web/pids.max")).To(Equal("100"))
+			})
+
+			It("resets limits that are not set", func() {
+				err := manager.Configure("web", Limits{MemoryMax: "1G"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/web/cpu.weight")).To(Equal("100"))
+				Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/web/cpu.max")).To(Equal("max"))
+				Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/web/memory.max")).To(Equal("1G"))
+				Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/web/pids.max")).To(Equal("max"))
+			})
+
+			It("returns an error when a limit cannot be written", func() {
+				fs.WriteFileErrors["/sys/fs/cgroup/bosh/web/memory.max"] = errors.New("fake-write-error")
+				err := manager.Configure("web", Limits{MemoryMax: "lots"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Setting memory.max of cgroup /sys/fs/cgroup/bosh/web to 'lots'"))
+			})
+		})
+
+		It("returns an error when cgroup v2 is not mounted", func() {
+			err := manager.Configure("web", Limits{MemoryMax: "1G"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("cgroup v2 is not mounted at /sys/fs/cgroup"))
+		})
+	})
+
+	Describe("AddProcess", func() {
+		It("writes the pid to the job's cgroup.procs", func() {
+			err := manager.AddProcess("web", 1234)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.ReadFileString("/sys/fs/cgroup/bosh/web/cgroup.procs")).To(Equal("1234"))
+		})
+	})
+
+	Describe("Usage", func() {
+		It("reads memory, cpu and pids usage", func() {
+			Expect(fs.WriteFileString("/sys/fs/cgroup/bosh/web/memory.current", "2097152\n")).To(Succeed())
+			Expect(fs.WriteFileString("/sys/fs/cgroup/bosh/web/pids.current", "7\n")).To(Succeed())
+			Expect(fs.WriteFileString("/sys/fs/cgroup/bosh/web/cpu.stat", "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n")).To(Succeed())
+
+			usage, err := manager.Usage("web")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(usage).To(Equal(Usage{MemoryCurrent: 2097152, CPUUsageUsec: 1500000, PidsCurrent: 7}))
+		})
+
+		It("returns an error when the group does not exist", func() {
+			_, err := manager.Usage("web")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Limits", func() {
+		It("merges overrides over defaults", func() {
+			limits := Limits{CPUWeight: 10, MemoryMax: "1G"}.Merge(Limits{MemoryMax: "2G", PidsMax: 5})
+			Expect(limits).To(Equal(Limits{CPUWeight: 10, MemoryMax: "2G", PidsMax: 5}))
+			Expect(limits.IsEmpty()).To(BeFalse())
+			Expect(Limits{}.IsEmpty()).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,394 @@
+package jobsupervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	cgroupJobSupervisorLogTag = "cgroupJobSupervisor"
+	cgroupJobsFileName        = "cgroup_jobs.json"
+)
+
+type CgroupOptions struct {
+	// Time between two checks that every process of a limited job,
+	// including processes restarted by the delegate, is in the job's cgroup.
+	// Processes forked before their parent was placed are only moved on the
+	// next check, so it should be short.
+	PlacementInterval time.Duration
+}
+
+type cgroupProcess struct {
+	Group   string `json:"group"`
+	PidFile string `json:"pid_file"`
+}
+
+// cgroupJobs is persisted so that limits are restored after a reboot,
+// which recreates the cgroup hierarchy but does not re-run start
+type cgroupJobs struct {
+	Limits    map[string]cgroup.Limits `json:"limits"`
+	Processes map[string]cgroupProcess `json:"processes"`
+}
+
+type cgroupJobSupervisor struct {
+	delegate    JobSupervisor
+	manager     cgroup.Manager
+	fs          boshsys.FileSystem
+	dirProvider boshdir.Provider
+	options     CgroupOptions
+	timeService clock.Clock
+	logger      boshlog.Logger
+
+	lock      sync.RWMutex
+	limits    map[string]cgroup.Limits
+	processes map[string]cgroupProcess
+}
+
+// NewCgroupJobSupervisor places the processes of jobs with resource limits
+// into a cgroup per job. Processes are found through the pidfiles of the
+// monit process checks, so the delegate does not need to know about cgroups.
+func NewCgroupJobSupervisor(
+	delegate JobSupervisor,
+	manager cgroup.Manager,
+	fs boshsys.FileSystem,
+	dirProvider boshdir.Provider,
+	options CgroupOptions,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) JobSupervisor {
+	return &cgroupJobSupervisor{
+		delegate:    delegate,
+		manager:     manager,
+		fs:          fs,
+		dirProvider: dirProvider,
+		options:     options,
+		timeService: timeService,
+		logger:      logger,
+		limits:      map[string]cgroup.Limits{},
+		processes:   map[string]cgroupProcess{},
+	}
+}
+
+func (c *cgroupJobSupervisor) Reload() error {
+	return c.delegate.Reload()
+}
+
+func (c *cgroupJobSupervisor) Start() error {
+	err := c.delegate.Start()
+	if err != nil {
+		return err
+	}
+
+	c.placeAll()
+
+	return nil
+}
+
+func (c *cgroupJobSupervisor) Stop() error {
+	return c.delegate.Stop()
+}
+
+func (c *cgroupJobSupervisor) StopAndWait() (StopResult, error) {
+	return c.delegate.StopAndWait()
+}
+
+func (c *cgroupJobSupervisor) Unmonitor() error {
+	return c.delegate.Unmonitor()
+}
+
+func (c *cgroupJobSupervisor) StartService(name string) error {
+	err := c.delegate.StartService(name)
+	if err != nil {
+		return err
+	}
+
+	c.placeAll()
+
+	return nil
+}
+
+func (c *cgroupJobSupervisor) StopService(name string) error {
+	return c.delegate.StopService(name)
+}
+
+func (c *cgroupJobSupervisor) Status() string {
+	return c.delegate.Status()
+}
+
+// Processes attaches the usage of the job's cgroup to every process of a
+// job with resource limits
+func (c *cgroupJobSupervisor) Processes() ([]Process, error) {
+	processes, err := c.delegate.Processes()
+	if err != nil {
+		return processes, err
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	usages := map[string]*CgroupVitals{}
+
+	for i, process := range processes {
+		cgroupProcess, found := c.processes[process.Name]
+		if !found {
+			continue
+		}
+
+		vitals, found := usages[cgroupProcess.Group]
+		if !found {
+			usage, err := c.manager.Usage(cgroupProcess.Group)
+			if err != nil {
+				c.logger.Warn(cgroupJobSupervisorLogTag, "Failed to read usage of cgroup %s: %s", cgroupProcess.Group, err.Error())
+			} else {
+				vitals = &CgroupVitals{
+					MemoryKb:     int(usage.MemoryCurrent / 1024),
+					CPUUsageUsec: usage.CPUUsageUsec,
+					Pids:         usage.PidsCurrent,
+				}
+			}
+
+			usages[cgroupProcess.Group] = vitals
+		}
+
+		processes[i].Cgroup = vitals
+	}
+
+	return processes, nil
+}
+
+func (c *cgroupJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	err := c.delegate.AddJob(jobName, configName, jobIndex, configPath)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, found := c.limits[jobName]; !found {
+		return nil
+	}
+
+	config, err := c.fs.ReadFileString(configPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading job config from file")
+	}
+
+	processes, err := parseMonitProcesses(config)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing job config %s", configPath)
+	}
+
+	for _, process := range processes {
+		if process.PidFile == "" {
+			return bosherr.Errorf("Process %s of job %s with resource limits must have a pidfile", process.Name, jobName)
+		}
+
+		c.processes[process.Name] = cgroupProcess{Group: jobName, PidFile: process.PidFile}
+	}
+
+	return c.save()
+}
+
+func (c *cgroupJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	if limits.IsEmpty() {
+		return nil
+	}
+
+	err := c.manager.Configure(jobName, limits)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Configuring cgroup of job %s", jobName)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.limits[jobName] = limits
+
+	return c.save()
+}
+
+func (c *cgroupJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return c.delegate.SetStartDependencies(jobName, dependencies)
+}
+
+// RemoveAllJobs forgets about all cgroups but does not remove them:
+// a cgroup can only be removed once its processes are gone.
+func (c *cgroupJobSupervisor) RemoveAllJobs() error {
+	c.lock.Lock()
+	c.limits = map[string]cgroup.Limits{}
+	c.processes = map[string]cgroupProcess{}
+	err := c.save()
+	c.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return c.delegate.RemoveAllJobs()
+}
+
+// MonitorJobFailures keeps placing processes in the background for as long
+// as the delegate monitors job failures, since the delegate may restart
+// processes at any time.
+func (c *cgroupJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	c.restore()
+
+	go c.placeContinuously()
+
+	return c.delegate.MonitorJobFailures(handler)
+}
+
+func (c *cgroupJobSupervisor) HealthRecorder(status string) {
+	c.delegate.HealthRecorder(status)
+}
+
+func (c *cgroupJobSupervisor) placeContinuously() {
+	defer c.logger.HandlePanic("Job Cgroup Placement")
+
+	ticker := c.timeService.NewTicker(c.options.PlacementInterval)
+	defer ticker.Stop()
+
+	for range ticker.C() {
+		c.placeAll()
+	}
+}
+
+// placeAll moves the process of every pidfile and its descendants into the
+// job's cgroup. Processes that are not running yet are skipped.
+func (c *cgroupJobSupervisor) placeAll() {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	names := make([]string, 0, len(c.processes))
+	for name := range c.processes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		process := c.processes[name]
+
+		contents, err := c.fs.ReadFileString(process.PidFile)
+		if err != nil {
+			continue
+		}
+
+		pid, err := strconv.Atoi(strings.TrimSpace(contents))
+		if err != nil {
+			continue
+		}
+
+		for _, pid := range c.processTree(pid) {
+			err = c.manager.AddProcess(process.Group, pid)
+			if err != nil {
+				c.logger.Warn(cgroupJobSupervisorLogTag, "Failed to place process %d of %s: %s", pid, name, err.Error())
+			}
+		}
+	}
+}
+
+// restore reloads the jobs saved before the agent restarted and recreates
+// their cgroups, unless jobs were already added since then
+func (c *cgroupJobSupervisor) restore() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.limits) > 0 || !c.fs.FileExists(c.jobsFilePath()) {
+		return
+	}
+
+	contents, err := c.fs.ReadFile(c.jobsFilePath())
+	if err != nil {
+		c.logger.Error(cgroupJobSupervisorLogTag, "Failed to read saved cgroup jobs: %s", err.Error())
+		return
+	}
+
+	var jobs cgroupJobs
+
+	err = json.Unmarshal(contents, &jobs)
+	if err != nil {
+		c.logger.Error(cgroupJobSupervisorLogTag, "Failed to unmarshal saved cgroup jobs: %s", err.Error())
+		return
+	}
+
+	for jobName, limits := range jobs.Limits {
+		err = c.manager.Configure(jobName, limits)
+		if err != nil {
+			c.logger.Error(cgroupJobSupervisorLogTag, "Failed to configure cgroup of job %s: %s", jobName, err.Error())
+			continue
+		}
+
+		c.limits[jobName] = limits
+	}
+
+	for name, process := range jobs.Processes {
+		if _, found := c.limits[process.Group]; found {
+			c.processes[name] = process
+		}
+	}
+}
+
+func (c *cgroupJobSupervisor) save() error {
+	contents, err := json.Marshal(cgroupJobs{Limits: c.limits, Processes: c.processes})
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling cgroup jobs")
+	}
+
+	err = c.fs.WriteFile(c.jobsFilePath(), contents)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving cgroup jobs")
+	}
+
+	return nil
+}
+
+func (c *cgroupJobSupervisor) jobsFilePath() string {
+	return filepath.Join(c.dirProvider.BoshDir(), cgroupJobsFileName)
+}
+
+// processTree returns pid and all of its descendants known to /proc.
+// Children are listed per thread, so the children of every thread are
+// collected and not only the ones forked by the main thread.
+func (c *cgroupJobSupervisor) processTree(pid int) []int {
+	pids := []int{pid}
+	seen := map[int]bool{pid: true}
+
+	for i := 0; i < len(pids); i++ {
+		childrenPaths, err := c.fs.Glob(fmt.Sprintf("/proc/%d/task/*/children", pids[i]))
+		if err != nil {
+			continue
+		}
+
+		for _, childrenPath := range childrenPaths {
+			children, err := c.fs.ReadFileString(childrenPath)
+			if err != nil {
+				continue
+			}
+
+			for _, field := range strings.Fields(children) {
+				child, err := strconv.Atoi(field)
+				if err == nil && !seen[child] {
+					seen[child] = true
+					pids = append(pids, child)
+				}
+			}
+		}
+	}
+
+	return pids
+}
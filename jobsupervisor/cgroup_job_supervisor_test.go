@@ -0,0 +1,217 @@
+package jobsupervisor_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup/cgroupfakes"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("cgroupJobSupervisor", func() {
+	const placementInterval = 5 * time.Second
+
+	var (
+		fs             *fakesys.FakeFileSystem
+		manager        *cgroupfakes.FakeManager
+		timeService    *fakeclock.FakeClock
+		fakeSupervisor *fakes.FakeJobSupervisor
+		supervisor     JobSupervisor
+	)
+
+	newSupervisor := func() JobSupervisor {
+		return NewCgroupJobSupervisor(
+			fakeSupervisor,
+			manager,
+			fs,
+			boshdir.NewProvider("/var/vcap"),
+			CgroupOptions{PlacementInterval: placementInterval},
+			timeService,
+			boshlog.NewLogger(boshlog.LevelNone),
+		)
+	}
+
+	addedProcesses := func() map[int]string {
+		added := map[int]string{}
+		for i := 0; i < manager.AddProcessCallCount(); i++ {
+			name, pid := manager.AddProcessArgsForCall(i)
+			added[pid] = name
+		}
+		return added
+	}
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		manager = &cgroupfakes.FakeManager{}
+		timeService = fakeclock.NewFakeClock(time.Now())
+		fakeSupervisor = fakes.NewFakeJobSupervisor()
+		supervisor = newSupervisor()
+
+		err := fs.WriteFileString("/var/vcap/jobs/web/monit", `check process web
+  with pidfile /var/vcap/sys/run/web/web.pid
+  start program "/var/vcap/jobs/web/bin/ctl start"
+  group vcap
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = fs.WriteFileString("/var/vcap/jobs/web/worker.monit", `check process worker
+  with pidfile /var/vcap/sys/run/web/worker.pid
+  start program "/var/vcap/jobs/web/bin/worker_ctl start"
+  group vcap
+`)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("delegates job management to the underlying job supervisor", func() {
+		err := supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fakeSupervisor.AddJobArgs).To(Equal([]fakes.AddJobArgs{
+			{Name: "web", ConfigName: "web", Index: 0, ConfigPath: "/var/vcap/jobs/web/monit"},
+		}))
+
+		err = supervisor.RemoveAllJobs()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fakeSupervisor.RemovedAllJobs).To(BeTrue())
+	})
+
+	Context("when the job has resource limits", func() {
+		BeforeEach(func() {
+			err := supervisor.SetResourceLimits("web", cgroup.Limits{MemoryMax: "512M"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")).To(Succeed())
+			Expect(supervisor.AddJob("web", "web_worker", 0, "/var/vcap/jobs/web/worker.monit")).To(Succeed())
+
+			Expect(fs.WriteFileString("/var/vcap/sys/run/web/web.pid", "100\n")).To(Succeed())
+			Expect(fs.WriteFileString("/var/vcap/sys/run/web/worker.pid", "200")).To(Succeed())
+			Expect(fs.WriteFileString("/proc/100/task/100/children", "101 102")).To(Succeed())
+			Expect(fs.WriteFileString("/proc/100/task/104/children", "105")).To(Succeed())
+			Expect(fs.WriteFileString("/proc/102/task/102/children", "103")).To(Succeed())
+
+			fs.GlobStub = func(pattern string) ([]string, error) {
+				return map[string][]string{
+					"/proc/100/task/*/children": {"/proc/100/task/100/children", "/proc/100/task/104/children"},
+					"/proc/102/task/*/children": {"/proc/102/task/102/children"},
+				}[pattern], nil
+			}
+		})
+
+		It("configures the job's cgroup", func() {
+			Expect(manager.ConfigureCallCount()).To(Equal(1))
+			name, limits := manager.ConfigureArgsForCall(0)
+			Expect(name).To(Equal("web"))
+			Expect(limits).To(Equal(cgroup.Limits{MemoryMax: "512M"}))
+		})
+
+		It("places the processes of all the job's configs and their descendants after start", func() {
+			Expect(supervisor.Start()).To(Succeed())
+			Expect(fakeSupervisor.Started).To(BeTrue())
+
+			Expect(addedProcesses()).To(Equal(map[int]string{
+				100: "web", 101: "web", 102: "web", 103: "web", 105: "web", 200: "web",
+			}))
+		})
+
+		It("keeps placing processes restarted by the delegate", func() {
+			go func() {
+				defer GinkgoRecover()
+				Expect(supervisor.MonitorJobFailures(func(boshalert.MonitAlert) error { return nil })).To(Succeed())
+			}()
+
+			Expect(fs.WriteFileString("/var/vcap/sys/run/web/worker.pid", "300")).To(Succeed())
+			timeService.WaitForWatcherAndIncrement(placementInterval)
+
+			Eventually(addedProcesses).Should(HaveKeyWithValue(300, "web"))
+		})
+
+		It("skips processes without a running pid", func() {
+			Expect(fs.RemoveAll("/var/vcap/sys/run/web/worker.pid")).To(Succeed())
+
+			Expect(supervisor.StartService("web")).To(Succeed())
+			Expect(addedProcesses()).ToNot(HaveKey(200))
+			Expect(addedProcesses()).To(HaveKey(100))
+		})
+
+		It("attaches the cgroup usage to the job's processes", func() {
+			manager.UsageReturns(cgroup.Usage{MemoryCurrent: 2097152, CPUUsageUsec: 500, PidsCurrent: 5}, nil)
+			fakeSupervisor.ProcessesStatus = []Process{
+				{Name: "web", State: "running"},
+				{Name: "worker", State: "running"},
+				{Name: "other", State: "running"},
+			}
+
+			processes, err := supervisor.Processes()
+			Expect(err).ToNot(HaveOccurred())
+
+			vitals := &CgroupVitals{MemoryKb: 2048, CPUUsageUsec: 500, Pids: 5}
+			Expect(processes).To(Equal([]Process{
+				{Name: "web", State: "running", Cgroup: vitals},
+				{Name: "worker", State: "running", Cgroup: vitals},
+				{Name: "other", State: "running"},
+			}))
+			Expect(manager.UsageCallCount()).To(Equal(1))
+		})
+
+		It("restores the jobs after the agent restarted", func() {
+			supervisor = newSupervisor()
+			manager.ConfigureReturns(nil)
+
+			go func() {
+				defer GinkgoRecover()
+				Expect(supervisor.MonitorJobFailures(func(boshalert.MonitAlert) error { return nil })).To(Succeed())
+			}()
+
+			Eventually(manager.ConfigureCallCount).Should(Equal(2))
+			name, limits := manager.ConfigureArgsForCall(1)
+			Expect(name).To(Equal("web"))
+			Expect(limits).To(Equal(cgroup.Limits{MemoryMax: "512M"}))
+
+			timeService.WaitForWatcherAndIncrement(placementInterval)
+			Eventually(addedProcesses).Should(HaveKeyWithValue(200, "web"))
+		})
+
+		It("forgets the jobs when all jobs are removed", func() {
+			Expect(supervisor.RemoveAllJobs()).To(Succeed())
+			Expect(supervisor.Start()).To(Succeed())
+
+			Expect(manager.AddProcessCallCount()).To(Equal(0))
+		})
+	})
+
+	It("does not configure cgroups for jobs without limits", func() {
+		Expect(supervisor.SetResourceLimits("web", cgroup.Limits{})).To(Succeed())
+		Expect(supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")).To(Succeed())
+		Expect(fs.WriteFileString("/var/vcap/sys/run/web/web.pid", "100")).To(Succeed())
+		Expect(supervisor.Start()).To(Succeed())
+
+		Expect(manager.ConfigureCallCount()).To(Equal(0))
+		Expect(manager.AddProcessCallCount()).To(Equal(0))
+	})
+
+	It("returns an error when the cgroup cannot be configured", func() {
+		manager.ConfigureReturns(errors.New("fake-configure-error"))
+
+		err := supervisor.SetResourceLimits("web", cgroup.Limits{PidsMax: 10})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Configuring cgroup of job web: fake-configure-error"))
+	})
+
+	It("returns an error when a process of a limited job has no pidfile", func() {
+		Expect(fs.WriteFileString("/var/vcap/jobs/web/monit", "check process web\n  matching \"web\"\n  start program \"/bin/web\"\n")).To(Succeed())
+		Expect(supervisor.SetResourceLimits("web", cgroup.Limits{PidsMax: 10})).To(Succeed())
+
+		err := supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Process web of job web with resource limits must have a pidfile"))
+	})
+})
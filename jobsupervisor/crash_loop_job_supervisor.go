@@ -0,0 +1,397 @@
+package jobsupervisor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	crashLoopJobSupervisorLogTag = "crashLoopJobSupervisor"
+
+	backoffState     = "backoff"
+	quarantinedState = "quarantined"
+)
+
+type CrashLoopOptions struct {
+	// Restarts within this window count towards the threshold
+	Window time.Duration
+
+	// Number of restarts within the window that make a crash loop
+	Threshold int
+
+	// Time a crash looping service stays stopped before it is started
+	// again, doubled with every further backoff up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Number of backoffs after which a service that keeps
+	// crashing is quarantined until the next start
+	MaxBackoffs int
+}
+
+type crashLoopService struct {
+	restarts    []time.Time
+	backoffs    int
+	state       string
+	since       time.Time
+	retryAt     time.Time
+	restartedAt time.Time
+	cancel      chan struct{}
+}
+
+type crashLoopJobSupervisor struct {
+	delegate    JobSupervisor
+	fs          boshsys.FileSystem
+	options     CrashLoopOptions
+	timeService clock.Clock
+	logger      boshlog.Logger
+
+	lock     sync.Mutex
+	jobs     map[string]string
+	services map[string]*crashLoopService
+}
+
+// NewCrashLoopJobSupervisor counts the restarts the delegate reports for
+// every service. A service restarted Threshold times within the window is
+// stopped and started again after an exponential backoff. When it keeps
+// crashing after MaxBackoffs backoffs it is quarantined: it stays stopped
+// and a single critical alert is raised. Restart alerts of services in
+// backoff or quarantine are not passed on. Starting the service or all
+// jobs ends the quarantine.
+func NewCrashLoopJobSupervisor(
+	delegate JobSupervisor,
+	fs boshsys.FileSystem,
+	options CrashLoopOptions,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) JobSupervisor {
+	return &crashLoopJobSupervisor{
+		delegate:    delegate,
+		fs:          fs,
+		options:     options,
+		timeService: timeService,
+		logger:      logger,
+		jobs:        map[string]string{},
+		services:    map[string]*crashLoopService{},
+	}
+}
+
+func (c *crashLoopJobSupervisor) Reload() error {
+	return c.delegate.Reload()
+}
+
+func (c *crashLoopJobSupervisor) Start() error {
+	c.lock.Lock()
+	c.clear(func(string, *crashLoopService) bool { return true })
+	c.lock.Unlock()
+
+	return c.delegate.Start()
+}
+
+// Stop cancels all pending backoffs so that no service is started
+// after it was stopped. Quarantined services stay quarantined.
+func (c *crashLoopJobSupervisor) Stop() error {
+	c.lock.Lock()
+	c.clear(func(_ string, service *crashLoopService) bool { return service.state != quarantinedState })
+	c.lock.Unlock()
+
+	return c.delegate.Stop()
+}
+
+func (c *crashLoopJobSupervisor) StopAndWait() (StopResult, error) {
+	c.lock.Lock()
+	c.clear(func(_ string, service *crashLoopService) bool { return service.state != quarantinedState })
+	c.lock.Unlock()
+
+	return c.delegate.StopAndWait()
+}
+
+func (c *crashLoopJobSupervisor) Unmonitor() error {
+	return c.delegate.Unmonitor()
+}
+
+// StartService ends the quarantine of the service, or of all services of
+// the job with the given name
+func (c *crashLoopJobSupervisor) StartService(name string) error {
+	c.lock.Lock()
+	c.clear(func(service string, _ *crashLoopService) bool { return c.belongsTo(service, name) })
+	c.lock.Unlock()
+
+	return c.delegate.StartService(name)
+}
+
+func (c *crashLoopJobSupervisor) StopService(name string) error {
+	c.lock.Lock()
+	c.clear(func(service string, state *crashLoopService) bool {
+		return state.state != quarantinedState && c.belongsTo(service, name)
+	})
+	c.lock.Unlock()
+
+	return c.delegate.StopService(name)
+}
+
+func (c *crashLoopJobSupervisor) Status() string {
+	return c.delegate.Status()
+}
+
+// Processes replaces the state of services in backoff or quarantine
+// and attaches the details of their crash loop
+func (c *crashLoopJobSupervisor) Processes() ([]Process, error) {
+	processes, err := c.delegate.Processes()
+	if err != nil {
+		return processes, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i, process := range processes {
+		service, found := c.services[process.Name]
+		if !found || service.state == "" {
+			continue
+		}
+
+		vitals := &QuarantineVitals{
+			State:    service.state,
+			Restarts: len(service.restarts),
+			Backoffs: service.backoffs,
+			Since:    service.since.Unix(),
+		}
+
+		if !service.retryAt.IsZero() {
+			vitals.RetryAt = service.retryAt.Unix()
+		}
+
+		processes[i].State = service.state
+		processes[i].Quarantine = vitals
+	}
+
+	return processes, nil
+}
+
+// AddJob remembers which job every process check belongs to so that
+// starting the job ends the quarantine of its services
+func (c *crashLoopJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	err := c.delegate.AddJob(jobName, configName, jobIndex, configPath)
+	if err != nil {
+		return err
+	}
+
+	config, err := c.fs.ReadFileString(configPath)
+	if err != nil {
+		c.logger.Warn(crashLoopJobSupervisorLogTag, "Failed to read job config %s: %s", configPath, err.Error())
+		return nil
+	}
+
+	processes, err := parseMonitProcesses(config)
+	if err != nil {
+		c.logger.Warn(crashLoopJobSupervisorLogTag, "Failed to parse job config %s: %s", configPath, err.Error())
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, process := range processes {
+		c.jobs[process.Name] = jobName
+	}
+
+	return nil
+}
+
+func (c *crashLoopJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	return c.delegate.SetResourceLimits(jobName, limits)
+}
+
+func (c *crashLoopJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return c.delegate.SetStartDependencies(jobName, dependencies)
+}
+
+func (c *crashLoopJobSupervisor) RemoveAllJobs() error {
+	c.lock.Lock()
+	c.clear(func(string, *crashLoopService) bool { return true })
+	c.jobs = map[string]string{}
+	c.lock.Unlock()
+
+	return c.delegate.RemoveAllJobs()
+}
+
+func (c *crashLoopJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	return c.delegate.MonitorJobFailures(func(alert boshalert.MonitAlert) error {
+		return c.handleAlert(alert, handler)
+	})
+}
+
+func (c *crashLoopJobSupervisor) HealthRecorder(status string) {
+	c.delegate.HealthRecorder(status)
+}
+
+func (c *crashLoopJobSupervisor) handleAlert(alert boshalert.MonitAlert, handler JobFailureHandler) error {
+	if !strings.EqualFold(alert.Action, "restart") {
+		return handler(alert)
+	}
+
+	c.lock.Lock()
+
+	service, found := c.services[alert.Service]
+	if !found {
+		service = &crashLoopService{}
+		c.services[alert.Service] = service
+	}
+
+	if service.state != "" {
+		c.lock.Unlock()
+		return nil
+	}
+
+	now := c.timeService.Now()
+
+	var restarts []time.Time
+	for _, restart := range service.restarts {
+		if now.Sub(restart) < c.options.Window {
+			restarts = append(restarts, restart)
+		}
+	}
+	service.restarts = append(restarts, now)
+
+	crashedAfterBackoff := service.backoffs > 0 && now.Sub(service.restartedAt) < c.options.Window
+	if !crashedAfterBackoff {
+		service.backoffs = 0
+	}
+
+	if !crashedAfterBackoff && len(service.restarts) < c.options.Threshold {
+		c.lock.Unlock()
+		return handler(alert)
+	}
+
+	if service.backoffs == 0 {
+		service.since = now
+	}
+
+	service.backoffs++
+
+	if service.backoffs > c.options.MaxBackoffs {
+		service.state = quarantinedState
+		service.retryAt = time.Time{}
+
+		c.logger.Error(crashLoopJobSupervisorLogTag, "Service %s kept crashing after %d backoffs, quarantining it", alert.Service, c.options.MaxBackoffs)
+
+		quarantineAlert := boshalert.MonitAlert{
+			ID:          alert.ID,
+			Service:     alert.Service,
+			Event:       "crash loop",
+			Action:      "stop",
+			Date:        now.Format(time.RFC1123Z),
+			Description: fmt.Sprintf("restarted %d times within %s and kept crashing after %d backoffs, quarantined until the next start", len(service.restarts), c.options.Window, c.options.MaxBackoffs),
+			Severity:    boshalert.SeverityCritical,
+		}
+
+		c.lock.Unlock()
+
+		c.stopService(alert.Service)
+
+		return handler(quarantineAlert)
+	}
+
+	delay := c.backoff(service.backoffs)
+
+	service.state = backoffState
+	service.retryAt = now.Add(delay)
+	service.cancel = make(chan struct{})
+	cancel := service.cancel
+
+	c.logger.Warn(crashLoopJobSupervisorLogTag, "Service %s restarted %d times within %s, starting it again in %s", alert.Service, len(service.restarts), c.options.Window, delay)
+
+	c.lock.Unlock()
+
+	c.stopService(alert.Service)
+
+	go c.startAfter(alert.Service, delay, cancel)
+
+	return nil
+}
+
+func (c *crashLoopJobSupervisor) startAfter(name string, delay time.Duration, cancel chan struct{}) {
+	defer c.logger.HandlePanic("Crash Loop Backoff")
+
+	timer := c.timeService.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-cancel:
+		return
+	case <-timer.C():
+	}
+
+	c.lock.Lock()
+
+	service, found := c.services[name]
+	if !found || service.cancel != cancel {
+		c.lock.Unlock()
+		return
+	}
+
+	service.state = ""
+	service.retryAt = time.Time{}
+	service.restartedAt = c.timeService.Now()
+	service.cancel = nil
+
+	c.lock.Unlock()
+
+	c.logger.Info(crashLoopJobSupervisorLogTag, "Starting service %s after backoff", name)
+
+	err := c.delegate.StartService(name)
+	if err != nil {
+		c.logger.Error(crashLoopJobSupervisorLogTag, "Failed to start service %s after backoff: %s", name, err.Error())
+	}
+}
+
+func (c *crashLoopJobSupervisor) stopService(name string) {
+	err := c.delegate.StopService(name)
+	if err != nil {
+		c.logger.Error(crashLoopJobSupervisorLogTag, "Failed to stop crash looping service %s: %s", name, err.Error())
+	}
+}
+
+func (c *crashLoopJobSupervisor) backoff(backoffs int) time.Duration {
+	delay := c.options.InitialBackoff
+
+	for i := 1; i < backoffs && delay < c.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > c.options.MaxBackoff {
+		delay = c.options.MaxBackoff
+	}
+
+	return delay
+}
+
+// clear forgets the crash loops of all matching services and
+// cancels their pending backoffs
+func (c *crashLoopJobSupervisor) clear(matches func(name string, service *crashLoopService) bool) {
+	for name, service := range c.services {
+		if !matches(name, service) {
+			continue
+		}
+
+		if service.cancel != nil {
+			close(service.cancel)
+		}
+
+		delete(c.services, name)
+	}
+}
+
+func (c *crashLoopJobSupervisor) belongsTo(service, name string) bool {
+	return service == name || c.jobs[service] == name
+}
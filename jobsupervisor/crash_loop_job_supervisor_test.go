@@ -0,0 +1,259 @@
+package jobsupervisor_test
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+// alertingJobSupervisor lets tests raise alerts through the handler
+// passed to MonitorJobFailures
+type alertingJobSupervisor struct {
+	*fakes.FakeJobSupervisor
+
+	lock    sync.Mutex
+	handler JobFailureHandler
+	started []string
+
+	stopping func(name string)
+}
+
+func (s *alertingJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	s.handler = handler
+	return nil
+}
+
+func (s *alertingJobSupervisor) Processes() ([]Process, error) {
+	return append([]Process{}, s.ProcessesStatus...), s.ProcessesError
+}
+
+func (s *alertingJobSupervisor) StopService(name string) error {
+	if s.stopping != nil {
+		s.stopping(name)
+	}
+	return s.FakeJobSupervisor.StopService(name)
+}
+
+func (s *alertingJobSupervisor) StartService(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.started = append(s.started, name)
+	return nil
+}
+
+func (s *alertingJobSupervisor) StartedServices() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]string{}, s.started...)
+}
+
+var _ = Describe("crashLoopJobSupervisor", func() {
+	const (
+		window         = 10 * time.Minute
+		initialBackoff = 30 * time.Second
+	)
+
+	var (
+		fs             *fakesys.FakeFileSystem
+		timeService    *fakeclock.FakeClock
+		fakeSupervisor *alertingJobSupervisor
+		handledAlerts  []boshalert.MonitAlert
+		supervisor     JobSupervisor
+	)
+
+	restart := func(service string) {
+		err := fakeSupervisor.handler(boshalert.MonitAlert{Service: service, Event: "does not exist", Action: "restart"})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	crashLoop := func(service string) {
+		for i := 0; i < 3; i++ {
+			restart(service)
+		}
+	}
+
+	processes := func() []Process {
+		processes, err := supervisor.Processes()
+		Expect(err).ToNot(HaveOccurred())
+		return processes
+	}
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		timeService = fakeclock.NewFakeClock(time.Now())
+		fakeSupervisor = &alertingJobSupervisor{FakeJobSupervisor: fakes.NewFakeJobSupervisor()}
+		fakeSupervisor.ProcessesStatus = []Process{{Name: "web", State: "running"}, {Name: "worker", State: "running"}}
+		handledAlerts = nil
+
+		supervisor = NewCrashLoopJobSupervisor(
+			fakeSupervisor,
+			fs,
+			CrashLoopOptions{
+				Window:         window,
+				Threshold:      3,
+				InitialBackoff: initialBackoff,
+				MaxBackoff:     45 * time.Second,
+				MaxBackoffs:    2,
+			},
+			timeService,
+			boshlog.NewLogger(boshlog.LevelNone),
+		)
+
+		err := supervisor.MonitorJobFailures(func(alert boshalert.MonitAlert) error {
+			handledAlerts = append(handledAlerts, alert)
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("passes on alerts of services that restart less often than the threshold", func() {
+		restart("web")
+		restart("web")
+		timeService.Increment(window)
+		restart("web")
+
+		Expect(handledAlerts).To(HaveLen(3))
+		Expect(fakeSupervisor.StoppedServices).To(BeEmpty())
+		Expect(processes()).To(Equal([]Process{{Name: "web", State: "running"}, {Name: "worker", State: "running"}}))
+	})
+
+	It("passes on alerts other than restarts", func() {
+		for i := 0; i < 5; i++ {
+			Expect(fakeSupervisor.handler(boshalert.MonitAlert{Service: "web", Event: "health check failed", Action: "alert"})).To(Succeed())
+		}
+
+		Expect(handledAlerts).To(HaveLen(5))
+		Expect(fakeSupervisor.StoppedServices).To(BeEmpty())
+	})
+
+	It("stops a crash looping service and starts it again after a backoff", func() {
+		crashLoop("web")
+
+		Expect(handledAlerts).To(HaveLen(2))
+		Expect(fakeSupervisor.StoppedServices).To(Equal([]string{"web"}))
+		Expect(processes()[0]).To(Equal(Process{
+			Name:  "web",
+			State: "backoff",
+			Quarantine: &QuarantineVitals{
+				State:    "backoff",
+				Restarts: 3,
+				Backoffs: 1,
+				Since:    timeService.Now().Unix(),
+				RetryAt:  timeService.Now().Add(initialBackoff).Unix(),
+			},
+		}))
+
+		restart("web")
+		Expect(handledAlerts).To(HaveLen(2))
+
+		timeService.WaitForWatcherAndIncrement(initialBackoff)
+
+		Eventually(fakeSupervisor.StartedServices).Should(Equal([]string{"web"}))
+		Expect(processes()[0]).To(Equal(Process{Name: "web", State: "running"}))
+	})
+
+	It("doubles the backoff when the service keeps crashing and then quarantines it with a single critical alert", func() {
+		crashLoop("web")
+		timeService.WaitForWatcherAndIncrement(initialBackoff)
+		Eventually(fakeSupervisor.StartedServices).Should(HaveLen(1))
+
+		restart("web")
+		Expect(processes()[0].Quarantine.RetryAt).To(Equal(timeService.Now().Add(45 * time.Second).Unix()))
+		timeService.WaitForWatcherAndIncrement(45 * time.Second)
+		Eventually(fakeSupervisor.StartedServices).Should(HaveLen(2))
+
+		restart("web")
+		restart("web")
+
+		Expect(fakeSupervisor.StoppedServices).To(Equal([]string{"web", "web", "web"}))
+		Expect(handledAlerts).To(HaveLen(3))
+		Expect(handledAlerts[2].Service).To(Equal("web"))
+		Expect(handledAlerts[2].Event).To(Equal("crash loop"))
+		Expect(handledAlerts[2].Action).To(Equal("stop"))
+		Expect(handledAlerts[2].Severity).To(Equal(boshalert.SeverityCritical))
+
+		Expect(processes()[0].State).To(Equal("quarantined"))
+		Expect(processes()[0].Quarantine.Backoffs).To(Equal(3))
+		Expect(processes()[0].Quarantine.RetryAt).To(BeZero())
+	})
+
+	It("does not hold its lock while the delegate stops a crash looping service", func() {
+		var stoppingProcesses []Process
+		fakeSupervisor.stopping = func(string) {
+			stoppingProcesses = processes()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			crashLoop("web")
+		}()
+
+		Eventually(done).Should(BeClosed())
+		Expect(stoppingProcesses[0].State).To(Equal("backoff"))
+	})
+
+	It("forgets the backoffs of a service that stopped crashing", func() {
+		crashLoop("web")
+		timeService.WaitForWatcherAndIncrement(initialBackoff)
+		Eventually(fakeSupervisor.StartedServices).Should(HaveLen(1))
+
+		timeService.Increment(window)
+		restart("web")
+
+		Expect(handledAlerts).To(HaveLen(3))
+		Expect(processes()[0].Quarantine).To(BeNil())
+	})
+
+	It("ends the quarantine on start", func() {
+		crashLoop("web")
+		timeService.WaitForWatcherAndIncrement(initialBackoff)
+		Eventually(fakeSupervisor.StartedServices).Should(HaveLen(1))
+		restart("web")
+		timeService.WaitForWatcherAndIncrement(45 * time.Second)
+		Eventually(fakeSupervisor.StartedServices).Should(HaveLen(2))
+		restart("web")
+		Expect(processes()[0].State).To(Equal("quarantined"))
+
+		Expect(supervisor.Start()).To(Succeed())
+
+		Expect(fakeSupervisor.Started).To(BeTrue())
+		Expect(processes()[0]).To(Equal(Process{Name: "web", State: "running"}))
+	})
+
+	It("ends the quarantine of the services of a started job", func() {
+		Expect(fs.WriteFileString("/var/vcap/jobs/app/monit", "check process web\n  with pidfile /var/vcap/sys/run/web.pid\n  start program \"/bin/web\"\n")).To(Succeed())
+		Expect(supervisor.AddJob("app", "app", 0, "/var/vcap/jobs/app/monit")).To(Succeed())
+
+		crashLoop("web")
+		crashLoop("worker")
+
+		Expect(supervisor.StartService("app")).To(Succeed())
+
+		Expect(processes()[0].Quarantine).To(BeNil())
+		Expect(processes()[1].Quarantine).ToNot(BeNil())
+	})
+
+	It("does not start services in backoff after they were stopped", func() {
+		crashLoop("web")
+
+		_, err := supervisor.StopAndWait()
+		Expect(err).ToNot(HaveOccurred())
+
+		timeService.Increment(initialBackoff)
+		Consistently(fakeSupervisor.StartedServices).Should(BeEmpty())
+		Expect(processes()[0].Quarantine).To(BeNil())
+	})
+})
@@ -1,5 +1,7 @@
 package jobsupervisor
 
+import "github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+
 type dummyJobSupervisor struct {
 	status    string
 	processes []Process
@@ -24,15 +26,31 @@ func (s *dummyJobSupervisor) Stop() error {
 	return nil
 }
 
-func (s *dummyJobSupervisor) StopAndWait() error {
+func (s *dummyJobSupervisor) StopAndWait() (StopResult, error) {
 	s.status = "stopped"
-	return nil
+	return StopResult{}, nil
 }
 
 func (s *dummyJobSupervisor) Unmonitor() error {
 	return nil
 }
 
+func (s *dummyJobSupervisor) StartService(name string) error {
+	return nil
+}
+
+func (s *dummyJobSupervisor) StopService(name string) error {
+	return nil
+}
+
+func (s *dummyJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	return nil
+}
+
+func (s *dummyJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return nil
+}
+
 func (s *dummyJobSupervisor) Status() (status string) {
 	return s.status
 }
@@ -41,7 +59,7 @@ func (s *dummyJobSupervisor) Processes() ([]Process, error) {
 	return s.processes, nil
 }
 
-func (s *dummyJobSupervisor) AddJob(jobName string, jobIndex int, configPath string) error {
+func (s *dummyJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
 	return nil
 }
 
@@ -5,6 +5,7 @@ import (
 
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 	bosherror "github.com/cloudfoundry/bosh-utils/errors"
 )
 
@@ -70,7 +71,7 @@ func (d *dummyNatsJobSupervisor) Reload() error {
 	return nil
 }
 
-func (d *dummyNatsJobSupervisor) AddJob(jobName string, jobIndex int, configPath string) error {
+func (d *dummyNatsJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
 	return nil
 }
 
@@ -91,14 +92,30 @@ func (d *dummyNatsJobSupervisor) Stop() error {
 	return nil
 }
 
-func (d *dummyNatsJobSupervisor) StopAndWait() error {
-	return d.Stop()
+func (d *dummyNatsJobSupervisor) StopAndWait() (StopResult, error) {
+	return StopResult{}, d.Stop()
 }
 
 func (d *dummyNatsJobSupervisor) Unmonitor() error {
 	return nil
 }
 
+func (d *dummyNatsJobSupervisor) StartService(name string) error {
+	return nil
+}
+
+func (d *dummyNatsJobSupervisor) StopService(name string) error {
+	return nil
+}
+
+func (d *dummyNatsJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	return nil
+}
+
+func (d *dummyNatsJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return nil
+}
+
 func (d *dummyNatsJobSupervisor) RemoveAllJobs() error {
 	return nil
 }
@@ -0,0 +1,116 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor"
+)
+
+type FakeJobHealthProber struct {
+	ProbeJobStub        func(string) (jobsupervisor.HealthProbeResult, bool)
+	probeJobMutex       sync.RWMutex
+	probeJobArgsForCall []struct {
+		arg1 string
+	}
+	probeJobReturns struct {
+		result1 jobsupervisor.HealthProbeResult
+		result2 bool
+	}
+	probeJobReturnsOnCall map[int]struct {
+		result1 jobsupervisor.HealthProbeResult
+		result2 bool
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeJobHealthProber) ProbeJob(arg1 string) (jobsupervisor.HealthProbeResult, bool) {
+	fake.probeJobMutex.Lock()
+	ret, specificReturn := fake.probeJobReturnsOnCall[len(fake.probeJobArgsForCall)]
+	fake.probeJobArgsForCall = append(fake.probeJobArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ProbeJobStub
+	fakeReturns := fake.probeJobReturns
+	fake.recordInvocation("ProbeJob", []interface{}{arg1})
+	fake.probeJobMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeJobHealthProber) ProbeJobCallCount() int {
+	fake.probeJobMutex.RLock()
+	defer fake.probeJobMutex.RUnlock()
+	return len(fake.probeJobArgsForCall)
+}
+
+func (fake *FakeJobHealthProber) ProbeJobCalls(stub func(string) (jobsupervisor.HealthProbeResult, bool)) {
+	fake.probeJobMutex.Lock()
+	defer fake.probeJobMutex.Unlock()
+	fake.ProbeJobStub = stub
+}
+
+func (fake *FakeJobHealthProber) ProbeJobArgsForCall(i int) string {
+	fake.probeJobMutex.RLock()
+	defer fake.probeJobMutex.RUnlock()
+	argsForCall := fake.probeJobArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeJobHealthProber) ProbeJobReturns(result1 jobsupervisor.HealthProbeResult, result2 bool) {
+	fake.probeJobMutex.Lock()
+	defer fake.probeJobMutex.Unlock()
+	fake.ProbeJobStub = nil
+	fake.probeJobReturns = struct {
+		result1 jobsupervisor.HealthProbeResult
+		result2 bool
+	}{result1, result2}
+}
+
+func (fake *FakeJobHealthProber) ProbeJobReturnsOnCall(i int, result1 jobsupervisor.HealthProbeResult, result2 bool) {
+	fake.probeJobMutex.Lock()
+	defer fake.probeJobMutex.Unlock()
+	fake.ProbeJobStub = nil
+	if fake.probeJobReturnsOnCall == nil {
+		fake.probeJobReturnsOnCall = make(map[int]struct {
+			result1 jobsupervisor.HealthProbeResult
+			result2 bool
+		})
+	}
+	fake.probeJobReturnsOnCall[i] = struct {
+		result1 jobsupervisor.HealthProbeResult
+		result2 bool
+	}{result1, result2}
+}
+
+func (fake *FakeJobHealthProber) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.probeJobMutex.RLock()
+	defer fake.probeJobMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeJobHealthProber) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ jobsupervisor.JobHealthProber = new(FakeJobHealthProber)
@@ -1,9 +1,11 @@
 package fakes
 
 import (
+	"sync"
+
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
-	"sync"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 )
 
 type FakeJobSupervisor struct {
@@ -18,13 +20,26 @@ type FakeJobSupervisor struct {
 	Started  bool
 	StartErr error
 
-	Stopped          bool
-	StopErr          error
-	StoppedAndWaited bool
+	Stopped           bool
+	StopErr           error
+	StoppedAndWaited  bool
+	StopAndWaitResult boshjobsuper.StopResult
 
 	Unmonitored  bool
 	UnmonitorErr error
 
+	StartedServices []string
+	StartServiceErr error
+
+	StoppedServices []string
+	StopServiceErr  error
+
+	ResourceLimits       map[string]cgroup.Limits
+	SetResourceLimitsErr error
+
+	StartDependencies       map[string][]string
+	SetStartDependenciesErr error
+
 	StatusStatus    string
 	ProcessesStatus []boshjobsuper.Process
 	ProcessesError  error
@@ -37,6 +52,7 @@ type FakeJobSupervisor struct {
 
 type AddJobArgs struct {
 	Name       string
+	ConfigName string
 	Index      int
 	ConfigPath string
 }
@@ -54,9 +70,10 @@ func (m *FakeJobSupervisor) Reload() error {
 	return m.ReloadErr
 }
 
-func (m *FakeJobSupervisor) AddJob(jobName string, jobIndex int, configPath string) error {
+func (m *FakeJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
 	args := AddJobArgs{
 		Name:       jobName,
+		ConfigName: configName,
 		Index:      jobIndex,
 		ConfigPath: configPath,
 	}
@@ -79,10 +96,10 @@ func (m *FakeJobSupervisor) Stop() error {
 	return m.StopErr
 }
 
-func (m *FakeJobSupervisor) StopAndWait() error {
+func (m *FakeJobSupervisor) StopAndWait() (boshjobsuper.StopResult, error) {
 	m.Stopped = true
 	m.StoppedAndWaited = true
-	return m.StopErr
+	return m.StopAndWaitResult, m.StopErr
 }
 
 func (m *FakeJobSupervisor) Unmonitor() error {
@@ -90,6 +107,32 @@ func (m *FakeJobSupervisor) Unmonitor() error {
 	return m.UnmonitorErr
 }
 
+func (m *FakeJobSupervisor) StartService(name string) error {
+	m.StartedServices = append(m.StartedServices, name)
+	return m.StartServiceErr
+}
+
+func (m *FakeJobSupervisor) StopService(name string) error {
+	m.StoppedServices = append(m.StoppedServices, name)
+	return m.StopServiceErr
+}
+
+func (m *FakeJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	if m.ResourceLimits == nil {
+		m.ResourceLimits = map[string]cgroup.Limits{}
+	}
+	m.ResourceLimits[jobName] = limits
+	return m.SetResourceLimitsErr
+}
+
+func (m *FakeJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	if m.StartDependencies == nil {
+		m.StartDependencies = map[string][]string{}
+	}
+	m.StartDependencies[jobName] = dependencies
+	return m.SetStartDependenciesErr
+}
+
 func (m *FakeJobSupervisor) Status() string {
 	return m.StatusStatus
 }
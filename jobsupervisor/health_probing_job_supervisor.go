@@ -0,0 +1,370 @@
+package jobsupervisor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/agent/script/cmd"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	healthProbingJobSupervisorLogTag = "healthProbingJobSupervisor"
+
+	healthProbeScriptName      = "health"
+	healthProbeMaxOutputSize   = 1024
+	healthProbeKillGracePeriod = 5 * time.Second
+
+	unhealthyState = "unhealthy"
+)
+
+type HealthProbeOptions struct {
+	// Time between two runs of all job health probes
+	Interval time.Duration
+
+	// Probes that do not exit within this time are killed and reported as unhealthy
+	Timeout time.Duration
+}
+
+type HealthProbeResult struct {
+	Healthy   bool   `json:"healthy"`
+	ExitCode  int    `json:"exit_code"`
+	TimedOut  bool   `json:"timed_out,omitempty"`
+	Output    string `json:"output,omitempty"`
+	CheckedAt int64  `json:"checked_at"`
+}
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -o fakes/fake_job_health_prober.go . JobHealthProber
+
+// JobHealthProber runs the health probe of a single job on demand
+type JobHealthProber interface {
+	// ProbeJob runs the job's health probe and records its result like the
+	// periodic probes do. It returns false when the job has no health probe.
+	ProbeJob(job string) (HealthProbeResult, bool)
+}
+
+type HealthProbingJobSupervisor interface {
+	JobSupervisor
+	JobHealthProber
+}
+
+type healthProbingJobSupervisor struct {
+	delegate    JobSupervisor
+	fs          boshsys.FileSystem
+	dirProvider boshdir.Provider
+	options     HealthProbeOptions
+	prober      healthProber
+	timeService clock.Clock
+	logger      boshlog.Logger
+
+	lock    sync.RWMutex
+	jobs    []string
+	results map[string]HealthProbeResult
+}
+
+// NewHealthProbingJobSupervisor periodically runs the optional bin/health
+// script of every job added to the supervisor. A job is healthy when its
+// probe exits 0 within the timeout. While the delegate reports all processes
+// running, any unhealthy job turns the overall status into 'unhealthy'.
+func NewHealthProbingJobSupervisor(
+	delegate JobSupervisor,
+	fs boshsys.FileSystem,
+	runner boshsys.CmdRunner,
+	dirProvider boshdir.Provider,
+	options HealthProbeOptions,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) HealthProbingJobSupervisor {
+	return &healthProbingJobSupervisor{
+		delegate:    delegate,
+		fs:          fs,
+		dirProvider: dirProvider,
+		options:     options,
+		prober:      healthProber{runner: runner, timeout: options.Timeout, timeService: timeService, logger: logger},
+		timeService: timeService,
+		logger:      logger,
+		results:     map[string]HealthProbeResult{},
+	}
+}
+
+func (h *healthProbingJobSupervisor) Reload() error {
+	return h.delegate.Reload()
+}
+
+func (h *healthProbingJobSupervisor) Start() error {
+	return h.delegate.Start()
+}
+
+func (h *healthProbingJobSupervisor) Stop() error {
+	return h.delegate.Stop()
+}
+
+func (h *healthProbingJobSupervisor) StopAndWait() (StopResult, error) {
+	return h.delegate.StopAndWait()
+}
+
+func (h *healthProbingJobSupervisor) Unmonitor() error {
+	return h.delegate.Unmonitor()
+}
+
+func (h *healthProbingJobSupervisor) StartService(name string) error {
+	return h.delegate.StartService(name)
+}
+
+func (h *healthProbingJobSupervisor) StopService(name string) error {
+	return h.delegate.StopService(name)
+}
+
+func (h *healthProbingJobSupervisor) Status() string {
+	status := h.delegate.Status()
+	if status != "running" {
+		return status
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for _, result := range h.results {
+		if !result.Healthy {
+			return unhealthyState
+		}
+	}
+
+	return status
+}
+
+// Processes attaches each job's latest probe result to the process with the
+// same name. Jobs whose name does not match a process are listed as separate
+// entries so that no probe result is hidden from get_state.
+func (h *healthProbingJobSupervisor) Processes() ([]Process, error) {
+	processes, err := h.delegate.Processes()
+	if err != nil {
+		return processes, err
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	matched := map[string]bool{}
+
+	for i, process := range processes {
+		result, found := h.results[process.Name]
+		if !found {
+			continue
+		}
+
+		processes[i].Health = &result
+		matched[process.Name] = true
+	}
+
+	for _, job := range h.jobs {
+		result, found := h.results[job]
+		if !found || matched[job] {
+			continue
+		}
+
+		state := "running"
+		if !result.Healthy {
+			state = unhealthyState
+		}
+
+		processes = append(processes, Process{Name: job, State: state, Health: &result})
+	}
+
+	return processes, nil
+}
+
+func (h *healthProbingJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	err := h.delegate.AddJob(jobName, configName, jobIndex, configPath)
+	if err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, job := range h.jobs {
+		if job == jobName {
+			return nil
+		}
+	}
+
+	h.jobs = append(h.jobs, jobName)
+
+	return nil
+}
+
+func (h *healthProbingJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	return h.delegate.SetResourceLimits(jobName, limits)
+}
+
+func (h *healthProbingJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return h.delegate.SetStartDependencies(jobName, dependencies)
+}
+
+func (h *healthProbingJobSupervisor) RemoveAllJobs() error {
+	h.lock.Lock()
+	h.jobs = nil
+	h.results = map[string]HealthProbeResult{}
+	h.lock.Unlock()
+
+	return h.delegate.RemoveAllJobs()
+}
+
+// MonitorJobFailures runs the health probes in the background for as long as
+// the delegate monitors job failures. A job becoming unhealthy is reported to
+// the handler like any other job failure.
+func (h *healthProbingJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	go h.probeContinuously(handler)
+
+	return h.delegate.MonitorJobFailures(handler)
+}
+
+func (h *healthProbingJobSupervisor) HealthRecorder(status string) {
+	h.delegate.HealthRecorder(status)
+}
+
+func (h *healthProbingJobSupervisor) probeContinuously(handler JobFailureHandler) {
+	defer h.logger.HandlePanic("Job Health Probes")
+
+	ticker := h.timeService.NewTicker(h.options.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C() {
+		h.probeAll(handler)
+	}
+}
+
+func (h *healthProbingJobSupervisor) probeAll(handler JobFailureHandler) {
+	h.lock.RLock()
+	jobs := make([]string, len(h.jobs))
+	copy(jobs, h.jobs)
+	h.lock.RUnlock()
+
+	// Probes of stopped jobs are expected to fail
+	if h.delegate.Status() == "stopped" {
+		h.lock.Lock()
+		h.results = map[string]HealthProbeResult{}
+		h.lock.Unlock()
+		return
+	}
+
+	sort.Strings(jobs)
+
+	for _, job := range jobs {
+		h.lock.RLock()
+		previous, found := h.results[job]
+		h.lock.RUnlock()
+
+		result, probed := h.ProbeJob(job)
+		if !probed || result.Healthy || (found && !previous.Healthy) {
+			continue
+		}
+
+		err := handler(boshalert.MonitAlert{
+			Service:     job,
+			Event:       "health check failed",
+			Action:      "alert",
+			Date:        h.timeService.Now().Format(time.RFC1123Z),
+			Description: h.describe(result),
+		})
+		if err != nil {
+			h.logger.Error(healthProbingJobSupervisorLogTag, "Failed to handle health check failure of '%s': %s", job, err.Error())
+		}
+	}
+}
+
+func (h *healthProbingJobSupervisor) ProbeJob(job string) (HealthProbeResult, bool) {
+	probePath := filepath.Join(h.dirProvider.JobBinDir(job), healthProbeScriptName)
+	if !h.fs.FileExists(probePath) {
+		return HealthProbeResult{}, false
+	}
+
+	result := h.prober.probe(job, probePath)
+
+	h.lock.Lock()
+	h.results[job] = result
+	h.lock.Unlock()
+
+	return result, true
+}
+
+// healthProber runs a job's health probe script and kills it
+// when it does not exit within the timeout
+type healthProber struct {
+	runner      boshsys.CmdRunner
+	timeout     time.Duration
+	timeService clock.Clock
+	logger      boshlog.Logger
+}
+
+func (p healthProber) probe(job, probePath string) HealthProbeResult {
+	p.logger.Debug(healthProbingJobSupervisorLogTag, "Running health probe for '%s'", job)
+
+	result := HealthProbeResult{ExitCode: -1}
+
+	process, err := p.runner.RunComplexCommandAsync(cmd.BuildCommand(probePath))
+	if err != nil {
+		p.logger.Error(healthProbingJobSupervisorLogTag, "Failed to run health probe for '%s': %s", job, err.Error())
+		result.Output = err.Error()
+		result.CheckedAt = p.timeService.Now().Unix()
+		return result
+	}
+
+	resultCh := process.Wait()
+	timer := p.timeService.NewTimer(p.timeout)
+	defer timer.Stop()
+
+	var processResult boshsys.Result
+
+	select {
+	case processResult = <-resultCh:
+	case <-timer.C():
+		result.TimedOut = true
+
+		err = process.TerminateNicely(healthProbeKillGracePeriod)
+		if err != nil {
+			p.logger.Error(healthProbingJobSupervisorLogTag, "Failed to terminate health probe for '%s': %s", job, err.Error())
+		}
+
+		processResult = <-resultCh
+	}
+
+	result.ExitCode = processResult.ExitStatus
+	result.Healthy = !result.TimedOut && processResult.Error == nil && processResult.ExitStatus == 0
+	result.Output = tail(strings.TrimSpace(processResult.Stdout+processResult.Stderr), healthProbeMaxOutputSize)
+	result.CheckedAt = p.timeService.Now().Unix()
+
+	return result
+}
+
+func (h *healthProbingJobSupervisor) describe(result HealthProbeResult) string {
+	if result.TimedOut {
+		return fmt.Sprintf("health probe timed out after %s", h.options.Timeout)
+	}
+
+	description := fmt.Sprintf("health probe exited with %d", result.ExitCode)
+	if result.Output != "" {
+		description = fmt.Sprintf("%s: %s", description, result.Output)
+	}
+
+	return description
+}
+
+func tail(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+
+	return s[len(s)-size:]
+}
@@ -0,0 +1,210 @@
+package jobsupervisor_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("healthProbingJobSupervisor", func() {
+	const (
+		probeInterval = 30 * time.Second
+		probeTimeout  = 10 * time.Second
+		webProbePath  = "/var/vcap/jobs/web/bin/health"
+	)
+
+	var (
+		fs             *fakesys.FakeFileSystem
+		runner         *fakesys.FakeCmdRunner
+		timeService    *fakeclock.FakeClock
+		fakeSupervisor *fakes.FakeJobSupervisor
+		handledAlerts  chan boshalert.MonitAlert
+		supervisor     HealthProbingJobSupervisor
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		runner = fakesys.NewFakeCmdRunner()
+		timeService = fakeclock.NewFakeClock(time.Now())
+		fakeSupervisor = fakes.NewFakeJobSupervisor()
+		fakeSupervisor.StatusStatus = "running"
+		handledAlerts = make(chan boshalert.MonitAlert, 10)
+
+		supervisor = NewHealthProbingJobSupervisor(
+			fakeSupervisor,
+			fs,
+			runner,
+			boshdir.NewProvider("/var/vcap"),
+			HealthProbeOptions{Interval: probeInterval, Timeout: probeTimeout},
+			timeService,
+			boshlog.NewLogger(boshlog.LevelNone),
+		)
+
+		err := supervisor.AddJob("web", "web", 0, "/fake-config-path")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	startProbing := func() {
+		err := supervisor.MonitorJobFailures(func(alert boshalert.MonitAlert) error {
+			handledAlerts <- alert
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	runProbes := func() {
+		timeService.WaitForWatcherAndIncrement(probeInterval)
+	}
+
+	addProbeResult := func(result boshsys.Result) {
+		runner.AddProcess(webProbePath, &fakesys.FakeProcess{WaitResult: result})
+	}
+
+	It("delegates job management to the underlying job supervisor", func() {
+		Expect(fakeSupervisor.AddJobArgs).To(Equal([]fakes.AddJobArgs{
+			{Name: "web", ConfigName: "web", Index: 0, ConfigPath: "/fake-config-path"},
+		}))
+
+		err := supervisor.RemoveAllJobs()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fakeSupervisor.RemovedAllJobs).To(BeTrue())
+	})
+
+	It("does not probe jobs without a health probe on demand", func() {
+		_, found := supervisor.ProbeJob("web")
+		Expect(found).To(BeFalse())
+		Expect(runner.RunComplexCommands).To(BeEmpty())
+	})
+
+	Context("when the job has a health probe", func() {
+		BeforeEach(func() {
+			err := fs.WriteFileString(webProbePath, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			fakeSupervisor.ProcessesStatus = []Process{{Name: "web", State: "running"}}
+		})
+
+		It("reports running and attaches the result when the probe passes", func() {
+			addProbeResult(boshsys.Result{Stdout: "ok\n", ExitStatus: 0})
+			startProbing()
+			runProbes()
+
+			Eventually(func() []Process {
+				processes, _ := supervisor.Processes()
+				return processes
+			}).Should(Equal([]Process{{
+				Name:  "web",
+				State: "running",
+				Health: &HealthProbeResult{
+					Healthy:   true,
+					ExitCode:  0,
+					Output:    "ok",
+					CheckedAt: timeService.Now().Unix(),
+				},
+			}}))
+
+			Expect(supervisor.Status()).To(Equal("running"))
+			Expect(handledAlerts).ToNot(Receive())
+		})
+
+		It("probes the job on demand and records the result without raising alerts", func() {
+			addProbeResult(boshsys.Result{Stdout: "starting", ExitStatus: 1, Error: errors.New("exit 1")})
+
+			result, found := supervisor.ProbeJob("web")
+			Expect(found).To(BeTrue())
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Output).To(Equal("starting"))
+
+			Expect(supervisor.Status()).To(Equal("unhealthy"))
+			Expect(handledAlerts).ToNot(Receive())
+		})
+
+		It("reports unhealthy and raises a single alert while the probe keeps failing", func() {
+			addProbeResult(boshsys.Result{Stdout: "503 from /health", ExitStatus: 1, Error: errors.New("exit 1")})
+			addProbeResult(boshsys.Result{Stdout: "503 from /health", ExitStatus: 1, Error: errors.New("exit 1")})
+			startProbing()
+
+			runProbes()
+			Eventually(supervisor.Status).Should(Equal("unhealthy"))
+
+			var alert boshalert.MonitAlert
+			Eventually(handledAlerts).Should(Receive(&alert))
+			Expect(alert.Service).To(Equal("web"))
+			Expect(alert.Event).To(Equal("health check failed"))
+			Expect(alert.Description).To(Equal("health probe exited with 1: 503 from /health"))
+
+			runProbes()
+			Eventually(func() int { return len(runner.RunComplexCommands) }).Should(Equal(2))
+			Consistently(handledAlerts).ShouldNot(Receive())
+		})
+
+		It("kills probes that do not finish within the timeout", func() {
+			process := &fakesys.FakeProcess{}
+			process.TerminatedNicelyCallBack = func(p *fakesys.FakeProcess) {
+				p.WaitCh <- boshsys.Result{ExitStatus: 143, Error: errors.New("killed")}
+			}
+			runner.AddProcess(webProbePath, process)
+			startProbing()
+
+			runProbes()
+			Eventually(timeService.WatcherCount).Should(Equal(2))
+			timeService.Increment(probeTimeout)
+
+			Eventually(supervisor.Status).Should(Equal("unhealthy"))
+			Expect(process.TerminatedNicely).To(BeTrue())
+
+			processes, err := supervisor.Processes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processes[0].Health.TimedOut).To(BeTrue())
+		})
+
+		It("does not report unhealthy when the delegate is not running", func() {
+			addProbeResult(boshsys.Result{ExitStatus: 1, Error: errors.New("exit 1")})
+			startProbing()
+			runProbes()
+			Eventually(supervisor.Status).Should(Equal("unhealthy"))
+
+			fakeSupervisor.StatusStatus = "failing"
+			Expect(supervisor.Status()).To(Equal("failing"))
+		})
+
+		It("lists jobs without a matching process separately", func() {
+			fakeSupervisor.ProcessesStatus = []Process{{Name: "web-worker", State: "running"}}
+			addProbeResult(boshsys.Result{ExitStatus: 2, Error: errors.New("exit 2")})
+			startProbing()
+			runProbes()
+
+			Eventually(func() int {
+				processes, _ := supervisor.Processes()
+				return len(processes)
+			}).Should(Equal(2))
+
+			processes, err := supervisor.Processes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processes[1].Name).To(Equal("web"))
+			Expect(processes[1].State).To(Equal("unhealthy"))
+			Expect(processes[1].Health.ExitCode).To(Equal(2))
+		})
+	})
+
+	Context("when the job does not have a health probe", func() {
+		It("reports the status of the underlying job supervisor", func() {
+			startProbing()
+			runProbes()
+
+			Consistently(func() int { return len(runner.RunComplexCommands) }).Should(Equal(0))
+			Expect(supervisor.Status()).To(Equal("running"))
+		})
+	})
+})
@@ -2,6 +2,7 @@ package jobsupervisor
 
 import (
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 )
 
 type Process struct {
@@ -10,6 +11,30 @@ type Process struct {
 	Uptime UptimeVitals `json:"uptime,omitempty"`
 	Memory MemoryVitals `json:"mem,omitempty"`
 	CPU    CPUVitals    `json:"cpu,omitempty"`
+
+	// Job the process belongs to, if known to the supervisor
+	Job string `json:"job,omitempty"`
+
+	// Details of the running process read from /proc
+	PID       int            `json:"pid,omitempty"`
+	ParentPID int            `json:"ppid,omitempty"`
+	Children  []ChildProcess `json:"children,omitempty"`
+	FDs       int            `json:"fds,omitempty"`
+	Threads   int            `json:"threads,omitempty"`
+	StartTime int64          `json:"start_time,omitempty"`
+
+	// Number of times the process was restarted since its job was added
+	Restarts int `json:"restarts"`
+
+	// Number of all descendants as counted by monit
+	ChildCount int `json:"child_count,omitempty"`
+
+	Failure *FailureVitals `json:"failure,omitempty"`
+	Checks  *CheckVitals   `json:"checks,omitempty"`
+
+	Health     *HealthProbeResult `json:"health,omitempty"`
+	Cgroup     *CgroupVitals      `json:"cgroup,omitempty"`
+	Quarantine *QuarantineVitals  `json:"quarantine,omitempty"`
 }
 
 type UptimeVitals struct {
@@ -25,6 +50,106 @@ type CPUVitals struct {
 	Total float64 `json:"total"`
 }
 
+// ChildProcess is a descendant of a supervised process
+type ChildProcess struct {
+	PID      int            `json:"pid"`
+	Name     string         `json:"name"`
+	State    string         `json:"state"`
+	Threads  int            `json:"threads"`
+	Children []ChildProcess `json:"children,omitempty"`
+}
+
+// FailureVitals explains why the supervisor considers a process failing
+type FailureVitals struct {
+	Message string   `json:"message,omitempty"`
+	Checks  []string `json:"checks,omitempty"`
+}
+
+// CheckVitals are the results of the connection, file, filesystem and
+// program checks monit runs for a service
+type CheckVitals struct {
+	Ports       []PortCheckVitals       `json:"ports,omitempty"`
+	UnixSockets []UnixSocketCheckVitals `json:"unix_sockets,omitempty"`
+	File        *FileCheckVitals        `json:"file,omitempty"`
+	Filesystem  *FilesystemCheckVitals  `json:"filesystem,omitempty"`
+	Program     *ProgramCheckVitals     `json:"program,omitempty"`
+}
+
+type PortCheckVitals struct {
+	Host         string  `json:"host"`
+	Port         int     `json:"port"`
+	Protocol     string  `json:"protocol"`
+	Type         string  `json:"type"`
+	ResponseTime float64 `json:"response_time"`
+	Failed       bool    `json:"failed"`
+}
+
+type UnixSocketCheckVitals struct {
+	Path         string  `json:"path"`
+	Protocol     string  `json:"protocol"`
+	ResponseTime float64 `json:"response_time"`
+	Failed       bool    `json:"failed"`
+}
+
+type FileCheckVitals struct {
+	Mode      string `json:"mode"`
+	UID       int    `json:"uid"`
+	GID       int    `json:"gid"`
+	Timestamp int64  `json:"timestamp"`
+	Size      int64  `json:"size"`
+
+	ChecksumType string `json:"checksum_type,omitempty"`
+	Checksum     string `json:"checksum,omitempty"`
+}
+
+type FilesystemCheckVitals struct {
+	Type         string  `json:"type,omitempty"`
+	Flags        string  `json:"flags,omitempty"`
+	BlockPercent float64 `json:"block_percent"`
+	BlockUsageMb float64 `json:"block_usage_mb"`
+	BlockTotalMb float64 `json:"block_total_mb"`
+	InodePercent float64 `json:"inode_percent"`
+	InodeUsage   int64   `json:"inode_usage"`
+	InodeTotal   int64   `json:"inode_total"`
+}
+
+type ProgramCheckVitals struct {
+	Started  int64  `json:"started"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output,omitempty"`
+}
+
+// CgroupVitals is the usage of the cgroup of a process' job,
+// which is shared by all processes of that job.
+type CgroupVitals struct {
+	MemoryKb     int   `json:"mem_kb"`
+	CPUUsageUsec int64 `json:"cpu_usage_usec"`
+	Pids         int   `json:"pids"`
+}
+
+// QuarantineVitals describes a process that kept crashing. It is either
+// waiting to be restarted after a backoff or quarantined until the next start.
+type QuarantineVitals struct {
+	State    string `json:"state"`
+	Restarts int    `json:"restarts"`
+	Backoffs int    `json:"backoffs"`
+	Since    int64  `json:"since"`
+	RetryAt  int64  `json:"retry_at,omitempty"`
+}
+
+// StopResult lists the processes that had to be killed
+// because they did not stop within their job's stop deadline
+type StopResult struct {
+	ForceKilled []ForceKilledProcess `json:"force_killed"`
+}
+
+type ForceKilledProcess struct {
+	Job     string `json:"job"`
+	Process string `json:"process"`
+	PID     int    `json:"pid"`
+	Signal  string `json:"signal"`
+}
+
 type JobFailureHandler func(boshalert.MonitAlert) error
 
 type JobSupervisor interface {
@@ -33,7 +158,7 @@ type JobSupervisor interface {
 	// Actions taken on all services
 	Start() error
 	Stop() error
-	StopAndWait() error
+	StopAndWait() (StopResult, error)
 
 	// Start and Stop should still function after Unmonitor.
 	// Calling Start after Unmonitor should re-monitor all jobs.
@@ -41,10 +166,25 @@ type JobSupervisor interface {
 	// (Monit complies to above requirements.)
 	Unmonitor() error
 
+	// Actions taken on the services of a single job, or on a single service
+	// when name does not match a job
+	StartService(name string) error
+	StopService(name string) error
+
 	Status() string
 	Processes() ([]Process, error)
 	// Job management
-	AddJob(jobName string, jobIndex int, configPath string) error
+	// AddJob adds the monit config at configPath of the job with the given
+	// name. configName is the job name for the job's monit file and
+	// <job>_<name> for its additional <name>.monit files.
+	AddJob(jobName string, configName string, jobIndex int, configPath string) error
+	// SetResourceLimits is called before AddJob of the same job and also
+	// covers the job's additional <job>_<name> configs. Limits are forgotten
+	// by RemoveAllJobs.
+	SetResourceLimits(jobName string, limits cgroup.Limits) error
+	// SetStartDependencies lists the jobs that have to be running before
+	// the job is started. Like SetResourceLimits it is called before AddJob.
+	SetStartDependencies(jobName string, dependencies []string) error
 	RemoveAllJobs() error
 
 	MonitorJobFailures(handler JobFailureHandler) error
@@ -10,6 +10,7 @@ import (
 const (
 	statusWithMultipleServiceFixturePath = "test_assets/monit_status_multiple.xml"
 	statusFixturePath                    = "test_assets/monit_status.xml"
+	statusWithChecksFixturePath          = "test_assets/monit_status_checks.xml"
 )
 
 func readFixture(relativePath string) []byte {
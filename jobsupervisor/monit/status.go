@@ -20,17 +20,120 @@ type servicesTag struct {
 	Services []serviceTag `xml:"service"`
 }
 
+var serviceTypes = map[string]string{
+	"0": TypeFilesystem,
+	"1": TypeDirectory,
+	"2": TypeFile,
+	"3": TypeProcess,
+	"4": TypeHost,
+	"5": TypeSystem,
+	"6": TypeFifo,
+	"7": TypeProgram,
+}
+
+// eventDescriptions are the events of monit's status bitmask in the
+// order of their bits, described like monit describes them in alerts
+var eventDescriptions = []struct {
+	mask        int
+	description string
+}{
+	{0x1, "checksum failed"},
+	{0x2, "resource limit matched"},
+	{0x4, "timeout"},
+	{0x8, "timestamp failed"},
+	{0x10, "size failed"},
+	{0x20, "connection failed"},
+	{0x40, "permission failed"},
+	{0x80, "uid failed"},
+	{0x100, "gid failed"},
+	{0x200, "does not exist"},
+	{0x400, "invalid type"},
+	{0x800, "data access error"},
+	{0x1000, "execution failed"},
+	{0x2000, "filesystem flags failed"},
+	{0x4000, "icmp failed"},
+	{0x8000, "content failed"},
+	{0x10000, "monit instance failed"},
+	{0x20000, "action done"},
+	{0x40000, "pid failed"},
+	{0x80000, "ppid failed"},
+	{0x100000, "heartbeat failed"},
+	{0x200000, "status failed"},
+	{0x400000, "uptime failed"},
+}
+
 type serviceTag struct {
 	XMLName       xml.Name  `xml:"service"`
 	Name          string    `xml:"name,attr"`
 	Pending       int       `xml:"pendingaction"`
-	Status        int       `xml:"status"`
+	Status        int       `xml:"status"` // Bitmask of failed events, see eventDescriptions
 	StatusMessage string    `xml:"status_message"`
 	Monitor       int       `xml:"monitor"`
 	Uptime        int       `xml:"uptime"`
+	Pid           int       `xml:"pid"`
+	PPid          int       `xml:"ppid"`
 	Children      int       `xml:"children"`
 	Memory        memoryTag `xml:"memory"`
 	CPU           cpuTag    `xml:"cpu"`
+
+	// Numeric service type, see serviceTypes
+	Type string `xml:"type"`
+
+	// Connection checks of process services
+	Ports       []portTag       `xml:"port"`
+	UnixSockets []unixSocketTag `xml:"unix"`
+
+	// Attributes of file and filesystem services
+	Mode      string      `xml:"mode"`
+	UID       int         `xml:"uid"`
+	GID       int         `xml:"gid"`
+	Timestamp int64       `xml:"timestamp"`
+	Size      int64       `xml:"size"`
+	Checksum  checksumTag `xml:"checksum"`
+	FSType    string      `xml:"fstype"`
+	Flags     string      `xml:"flags"`
+	FSFlags   string      `xml:"fsflags"`
+	Block     *blockTag   `xml:"block"`
+	Inode     *inodeTag   `xml:"inode"`
+	Program   *programTag `xml:"program"`
+}
+
+type portTag struct {
+	Hostname     string  `xml:"hostname"`
+	PortNumber   int     `xml:"portnumber"`
+	Request      string  `xml:"request"`
+	Protocol     string  `xml:"protocol"`
+	Type         string  `xml:"type"`
+	ResponseTime float64 `xml:"responsetime"`
+}
+
+type unixSocketTag struct {
+	Path         string  `xml:"path"`
+	Protocol     string  `xml:"protocol"`
+	ResponseTime float64 `xml:"responsetime"`
+}
+
+type checksumTag struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type blockTag struct {
+	Percent float64 `xml:"percent"`
+	Usage   float64 `xml:"usage"`
+	Total   float64 `xml:"total"`
+}
+
+type inodeTag struct {
+	Percent float64 `xml:"percent"`
+	Usage   int64   `xml:"usage"`
+	Total   int64   `xml:"total"`
+}
+
+type programTag struct {
+	Started int64  `xml:"started"`
+	Status  int    `xml:"status"`
+	Output  string `xml:"output"`
 }
 
 type memoryTag struct {
@@ -72,6 +175,113 @@ func (s serviceTag) StatusString() string {
 	}
 }
 
+// FailedChecks describes the events set in the status bitmask
+func (s serviceTag) FailedChecks() []string {
+	var checks []string
+
+	for _, event := range eventDescriptions {
+		if s.Status&event.mask != 0 {
+			checks = append(checks, event.description)
+		}
+	}
+
+	return checks
+}
+
+// PortChecks returns the TCP and UDP connection checks. Monit reports a
+// response time of -1 for connections that failed.
+func (s serviceTag) PortChecks() []PortCheck {
+	var checks []PortCheck
+
+	for _, port := range s.Ports {
+		checks = append(checks, PortCheck{
+			Hostname:     port.Hostname,
+			Port:         port.PortNumber,
+			Request:      port.Request,
+			Protocol:     port.Protocol,
+			Type:         port.Type,
+			ResponseTime: port.ResponseTime,
+			Failed:       port.ResponseTime < 0,
+		})
+	}
+
+	return checks
+}
+
+func (s serviceTag) UnixSocketChecks() []UnixSocketCheck {
+	var checks []UnixSocketCheck
+
+	for _, socket := range s.UnixSockets {
+		checks = append(checks, UnixSocketCheck{
+			Path:         socket.Path,
+			Protocol:     socket.Protocol,
+			ResponseTime: socket.ResponseTime,
+			Failed:       socket.ResponseTime < 0,
+		})
+	}
+
+	return checks
+}
+
+func (s serviceTag) FileCheck() *FileCheck {
+	if serviceTypes[s.Type] != TypeFile {
+		return nil
+	}
+
+	return &FileCheck{
+		Mode:         s.Mode,
+		UID:          s.UID,
+		GID:          s.GID,
+		Timestamp:    s.Timestamp,
+		Size:         s.Size,
+		ChecksumType: s.Checksum.Type,
+		Checksum:     s.Checksum.Value,
+	}
+}
+
+// FilesystemCheck returns the usage of a filesystem service. Monit 5.2
+// reports its flags as <flags>, later versions as <fsflags>.
+func (s serviceTag) FilesystemCheck() *FilesystemCheck {
+	if serviceTypes[s.Type] != TypeFilesystem {
+		return nil
+	}
+
+	check := &FilesystemCheck{
+		Type:  s.FSType,
+		Flags: s.FSFlags,
+	}
+
+	if check.Flags == "" {
+		check.Flags = s.Flags
+	}
+
+	if s.Block != nil {
+		check.BlockPercent = s.Block.Percent
+		check.BlockUsageMb = s.Block.Usage
+		check.BlockTotalMb = s.Block.Total
+	}
+
+	if s.Inode != nil {
+		check.InodePercent = s.Inode.Percent
+		check.InodeUsage = s.Inode.Usage
+		check.InodeTotal = s.Inode.Total
+	}
+
+	return check
+}
+
+func (s serviceTag) ProgramCheck() *ProgramCheck {
+	if s.Program == nil {
+		return nil
+	}
+
+	return &ProgramCheck{
+		Started:  s.Program.Started,
+		ExitCode: s.Program.Status,
+		Output:   s.Program.Output,
+	}
+}
+
 func (t serviceGroupsTag) Get(name string) (group serviceGroupTag, found bool) {
 	for _, g := range t.ServiceGroups {
 		if g.Name == name {
@@ -110,9 +320,19 @@ func (status status) ServicesInGroup(name string) (services []Service) {
 				StatusMessage:        serviceTag.StatusMessage,
 				Monitored:            serviceTag.Monitor > 0,
 				Uptime:               serviceTag.Uptime,
+				Pid:                  serviceTag.Pid,
+				ParentPid:            serviceTag.PPid,
 				MemoryPercentTotal:   serviceTag.Memory.PercentTotal,
 				MemoryKilobytesTotal: serviceTag.Memory.KilobyteTotal,
 				CPUPercentTotal:      serviceTag.CPU.PercentTotal,
+				Type:                 serviceTypes[serviceTag.Type],
+				Children:             serviceTag.Children,
+				FailedChecks:         serviceTag.FailedChecks(),
+				Ports:                serviceTag.PortChecks(),
+				UnixSockets:          serviceTag.UnixSocketChecks(),
+				File:                 serviceTag.FileCheck(),
+				Filesystem:           serviceTag.FilesystemCheck(),
+				Program:              serviceTag.ProgramCheck(),
 			}
 			services = append(services, service)
 		}
@@ -1,5 +1,16 @@
 package monit
 
+const (
+	TypeFilesystem = "filesystem"
+	TypeDirectory  = "directory"
+	TypeFile       = "file"
+	TypeProcess    = "process"
+	TypeHost       = "host"
+	TypeSystem     = "system"
+	TypeFifo       = "fifo"
+	TypeProgram    = "program"
+)
+
 const (
 	StatusUnknown  = "unknown"
 	StatusStarting = "starting"
@@ -20,7 +31,65 @@ type Service struct {
 	Status               string
 	StatusMessage        string
 	Uptime               int
+	Pid                  int
+	ParentPid            int
 	MemoryPercentTotal   float64
 	MemoryKilobytesTotal int
 	CPUPercentTotal      float64
+
+	Type     string
+	Children int
+
+	// Descriptions of the checks that failed, e.g. 'connection failed'
+	FailedChecks []string
+
+	Ports       []PortCheck
+	UnixSockets []UnixSocketCheck
+	File        *FileCheck
+	Filesystem  *FilesystemCheck
+	Program     *ProgramCheck
+}
+
+type PortCheck struct {
+	Hostname     string
+	Port         int
+	Request      string
+	Protocol     string
+	Type         string
+	ResponseTime float64
+	Failed       bool
+}
+
+type UnixSocketCheck struct {
+	Path         string
+	Protocol     string
+	ResponseTime float64
+	Failed       bool
+}
+
+type FileCheck struct {
+	Mode         string
+	UID          int
+	GID          int
+	Timestamp    int64
+	Size         int64
+	ChecksumType string
+	Checksum     string
+}
+
+type FilesystemCheck struct {
+	Type         string
+	Flags        string
+	BlockPercent float64
+	BlockUsageMb float64
+	BlockTotalMb float64
+	InodePercent float64
+	InodeUsage   int64
+	InodeTotal   int64
+}
+
+type ProgramCheck struct {
+	Started  int64
+	ExitCode int
+	Output   string
 }
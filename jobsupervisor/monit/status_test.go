@@ -51,7 +51,7 @@ var _ = Describe("status", func() {
 				Service{Monitored: false, Name: "unmonitored-stop-pending", Status: "unknown", Pending: true},
 				Service{Monitored: false, Name: "unmonitored", Status: "unknown", Pending: false},
 				Service{Monitored: false, Name: "stopped", Status: "unknown", Pending: false},
-				Service{Monitored: true, Name: "failing", Status: "failing", Pending: false},
+				Service{Monitored: true, Name: "failing", Status: "failing", Pending: false, FailedChecks: []string{"does not exist"}},
 			}
 
 			services := status.ServicesInGroup("vcap")
@@ -99,9 +99,13 @@ var _ = Describe("status", func() {
 					Status:               "running",
 					StatusMessage:        "",
 					Uptime:               880183,
+					Pid:                  1,
+					ParentPid:            0,
 					MemoryPercentTotal:   0,
 					MemoryKilobytesTotal: 4004,
 					CPUPercentTotal:      0,
+					Type:                 "process",
+					Children:             163,
 				},
 			}
 
@@ -113,5 +117,92 @@ var _ = Describe("status", func() {
 			}
 		})
 
+		It("returns the results of the checks of every service", func() {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err := io.Copy(w, bytes.NewReader(readFixture(statusWithChecksFixturePath)))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			client := NewHTTPClient(
+				ts.Listener.Addr().String(),
+				"fake-user",
+				"fake-pass",
+				http.DefaultClient,
+				http.DefaultClient,
+				boshlog.NewLogger(boshlog.LevelNone),
+			)
+
+			status, err := client.Status()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(status.ServicesInGroup("vcap")).To(Equal([]Service{
+				{
+					Name:                 "web",
+					Monitored:            true,
+					Errored:              true,
+					Status:               "failing",
+					StatusMessage:        "failed protocol test [HTTP] at INET[localhost:8080] via TCP",
+					Uptime:               120,
+					Pid:                  4242,
+					ParentPid:            1,
+					MemoryPercentTotal:   1.5,
+					MemoryKilobytesTotal: 1536,
+					CPUPercentTotal:      0.7,
+					Type:                 "process",
+					Children:             2,
+					FailedChecks:         []string{"connection failed"},
+					Ports: []PortCheck{
+						{Hostname: "localhost", Port: 8080, Request: "/healthz", Protocol: "HTTP", Type: "TCP", ResponseTime: -1, Failed: true},
+						{Hostname: "localhost", Port: 8081, Protocol: "DEFAULT", Type: "TCP", ResponseTime: 0.002},
+					},
+					UnixSockets: []UnixSocketCheck{
+						{Path: "/var/vcap/sys/run/web/web.sock", Protocol: "DEFAULT", ResponseTime: 0.001},
+					},
+				},
+				{
+					Name:         "web_config",
+					Monitored:    true,
+					Status:       "failing",
+					Type:         "file",
+					FailedChecks: []string{"checksum failed", "permission failed"},
+					File: &FileCheck{
+						Mode:         "640",
+						UID:          1000,
+						GID:          1000,
+						Timestamp:    1386971700,
+						Size:         2048,
+						ChecksumType: "MD5",
+						Checksum:     "b1946ac92492d2347c6235b4d2611184",
+					},
+				},
+				{
+					Name:         "store",
+					Monitored:    true,
+					Status:       "failing",
+					Type:         "filesystem",
+					FailedChecks: []string{"resource limit matched"},
+					Filesystem: &FilesystemCheck{
+						Flags:        "4096",
+						BlockPercent: 91.5,
+						BlockUsageMb: 9150,
+						BlockTotalMb: 10000,
+						InodePercent: 10,
+						InodeUsage:   6553,
+						InodeTotal:   65536,
+					},
+				},
+				{
+					Name:         "web_ping",
+					Monitored:    true,
+					Status:       "failing",
+					Type:         "program",
+					FailedChecks: []string{"status failed"},
+					Program:      &ProgramCheck{Started: 1386971800, ExitCode: 3, Output: "connection refused"},
+				},
+			}))
+		})
 	})
 })
@@ -1,8 +1,13 @@
 package jobsupervisor
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"os"
 	"path"
+	"regexp"
 	"strings"
 	"time"
 
@@ -10,6 +15,7 @@ import (
 	"github.com/pivotal/go-smtpd/smtpd"
 
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 	boshmonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -17,7 +23,35 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-const monitJobSupervisorLogTag = "monitJobSupervisor"
+const (
+	monitJobSupervisorLogTag = "monitJobSupervisor"
+
+	// Job scripts read the credentials for the HTTP alert intake from
+	// <monit dir>/alerts.user, formatted like monit.user as 'username:password'
+	alertsUsername         = "alerts"
+	alertsCredentialsFile  = "alerts.user"
+	alertsPasswordByteSize = 16
+
+	// Job configs are added to a staging directory and only replace the
+	// configs monit reads once `monit -t` accepted the staging control file
+	stagingJobsDirSuffix   = ".staging"
+	stagingControlFileName = "monitrc.staging"
+
+	// The live job configs are moved aside while the staged ones are
+	// swapped in and restored if that fails
+	previousJobsDirSuffix = ".previous"
+
+	// Control file monit is started with, see the monit runit service
+	monitControlFileName = "monitrc"
+
+	// Every <index>_<config name>.monitrc is accompanied by a
+	// <index>_<config name>.job file naming the job it belongs to
+	jobNameFileSuffix = ".job"
+)
+
+// monitConfigErrorRegexp matches errors of `monit -t` such as
+// /var/vcap/monit/job.staging/0000_web.monitrc:3: syntax error 'foo'
+var monitConfigErrorRegexp = regexp.MustCompile(`(?m)^(\S+\.monitrc):(\d+):\s*(.*)$`)
 
 type monitJobSupervisor struct {
 	fs                    boshsys.FileSystem
@@ -26,6 +60,7 @@ type monitJobSupervisor struct {
 	logger                boshlog.Logger
 	dirProvider           boshdir.Provider
 	jobFailuresServerPort int
+	alertsServerPort      int
 	reloadOptions         MonitReloadOptions
 	timeService           clock.Clock
 }
@@ -49,6 +84,7 @@ func NewMonitJobSupervisor(
 	logger boshlog.Logger,
 	dirProvider boshdir.Provider,
 	jobFailuresServerPort int,
+	alertsServerPort int,
 	reloadOptions MonitReloadOptions,
 	timeService clock.Clock,
 ) JobSupervisor {
@@ -59,6 +95,7 @@ func NewMonitJobSupervisor(
 		logger:                logger,
 		dirProvider:           dirProvider,
 		jobFailuresServerPort: jobFailuresServerPort,
+		alertsServerPort:      alertsServerPort,
 		reloadOptions:         reloadOptions,
 		timeService:           timeService,
 	}
@@ -67,6 +104,11 @@ func NewMonitJobSupervisor(
 func (m monitJobSupervisor) Reload() error {
 	var currentIncarnation int
 
+	err := m.swapJobConfigs()
+	if err != nil {
+		return err
+	}
+
 	oldIncarnation, err := m.getIncarnation()
 	if err != nil {
 		return bosherr.WrapError(err, "Getting monit incarnation")
@@ -115,6 +157,127 @@ func (m monitJobSupervisor) Reload() error {
 	)
 }
 
+// swapJobConfigs validates all job configs added since the last reload and
+// moves them into place. Invalid configs are not swapped in so that monit
+// keeps supervising the jobs with their previous configs.
+func (m monitJobSupervisor) swapJobConfigs() error {
+	stagingDir := m.stagingJobsDir()
+	if !m.fs.FileExists(stagingDir) {
+		return nil
+	}
+
+	controlFile, err := m.stagingControlFile(stagingDir)
+	if err != nil {
+		return err
+	}
+
+	controlFilePath := path.Join(m.dirProvider.MonitDir(), stagingControlFileName)
+
+	err = m.fs.WriteFileString(controlFilePath, controlFile)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing staging monit control file")
+	}
+
+	defer func() {
+		_ = m.fs.RemoveAll(controlFilePath)
+	}()
+
+	// monit refuses control files that can be read by others
+	err = m.fs.Chmod(controlFilePath, 0600)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Chmoding %s", controlFilePath)
+	}
+
+	stdout, stderr, _, err := m.runner.RunCommand("monit", "-t", "-c", controlFilePath)
+	if err != nil {
+		return m.configError(stdout+stderr, err)
+	}
+
+	jobsDir := m.dirProvider.MonitJobsDir()
+	previousDir := jobsDir + previousJobsDirSuffix
+
+	err = m.fs.RemoveAll(previousDir)
+	if err != nil {
+		return bosherr.WrapError(err, "Removing previous job configs")
+	}
+
+	hasPrevious := m.fs.FileExists(jobsDir)
+
+	if hasPrevious {
+		err = m.fs.Rename(jobsDir, previousDir)
+		if err != nil {
+			return bosherr.WrapError(err, "Moving previous job configs aside")
+		}
+	}
+
+	err = m.fs.Rename(stagingDir, jobsDir)
+	if err != nil {
+		if hasPrevious {
+			restoreErr := m.fs.Rename(previousDir, jobsDir)
+			if restoreErr != nil {
+				m.logger.Error(monitJobSupervisorLogTag, "Failed to restore previous job configs: %s", restoreErr.Error())
+			}
+		}
+
+		return bosherr.WrapError(err, "Moving job configs into place")
+	}
+
+	err = m.fs.RemoveAll(previousDir)
+	if err != nil {
+		m.logger.Warn(monitJobSupervisorLogTag, "Failed to remove previous job configs: %s", err.Error())
+	}
+
+	return nil
+}
+
+// stagingControlFile returns the control file monit runs with, with the
+// include of the job configs pointed at the staging directory, so that
+// `monit -t` checks the staged configs together with everything they may
+// conflict with
+func (m monitJobSupervisor) stagingControlFile(stagingDir string) (string, error) {
+	controlFilePath := path.Join(m.dirProvider.EtcDir(), monitControlFileName)
+
+	controlFile, err := m.fs.ReadFileString(controlFilePath)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Reading monit control file %s", controlFilePath)
+	}
+
+	jobsDirPrefix := m.dirProvider.MonitJobsDir() + "/"
+	includesJobs := false
+
+	lines := strings.Split(controlFile, "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "include" || !strings.HasPrefix(fields[1], jobsDirPrefix) {
+			continue
+		}
+
+		lines[i] = fmt.Sprintf("include %s/%s", stagingDir, strings.TrimPrefix(fields[1], jobsDirPrefix))
+		includesJobs = true
+	}
+
+	if !includesJobs {
+		lines = append(lines, fmt.Sprintf("include %s/*.monitrc\n", stagingDir))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// configError names the job and line of the first error reported by
+// `monit -t`, if any
+func (m monitJobSupervisor) configError(output string, err error) error {
+	matches := monitConfigErrorRegexp.FindStringSubmatch(output)
+	if matches == nil {
+		return bosherr.WrapErrorf(err, "Validating monit config: %s", strings.TrimSpace(output))
+	}
+
+	return bosherr.Errorf("Invalid monit config of job '%s' at line %s: %s", m.jobOf(matches[1]), matches[2], matches[3])
+}
+
+func (m monitJobSupervisor) stagingJobsDir() string {
+	return m.dirProvider.MonitJobsDir() + stagingJobsDirSuffix
+}
+
 func (m monitJobSupervisor) incarnationChanged(incarnation int) bool {
 	currentIncarnation, err := m.getIncarnation()
 	if err != nil {
@@ -175,7 +338,13 @@ func (m monitJobSupervisor) Stop() error {
 	return nil
 }
 
-func (m monitJobSupervisor) StopAndWait() error {
+// StopAndWait does not kill services that do not stop, see
+// stopDeadlineJobSupervisor
+func (m monitJobSupervisor) StopAndWait() (StopResult, error) {
+	return StopResult{}, m.stopAndWait()
+}
+
+func (m monitJobSupervisor) stopAndWait() error {
 	timer := m.timeService.NewTimer(5 * time.Minute)
 
 	for {
@@ -267,6 +436,138 @@ func (m monitJobSupervisor) Unmonitor() error {
 	return nil
 }
 
+func (m monitJobSupervisor) StartService(name string) error {
+	services, err := m.servicesFor(name)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		m.logger.Debug(monitJobSupervisorLogTag, "Starting service %s", service)
+		err = m.client.StartService(service)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Starting service %s", service)
+		}
+	}
+
+	return nil
+}
+
+func (m monitJobSupervisor) StopService(name string) error {
+	services, err := m.servicesFor(name)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		m.logger.Debug(monitJobSupervisorLogTag, "Stopping service %s", service)
+		err = m.client.StopService(service)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Stopping service %s", service)
+		}
+	}
+
+	return nil
+}
+
+// servicesFor returns the services of the job with the given name, including
+// the ones of its additional *.monit files, or the service with that name
+func (m monitJobSupervisor) servicesFor(name string) ([]string, error) {
+	configPaths, err := m.fs.Glob(path.Join(m.dirProvider.MonitJobsDir(), "*.monitrc"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing job configs")
+	}
+
+	var services []string
+
+	for _, configPath := range configPaths {
+		if m.jobOf(configPath) != name {
+			continue
+		}
+
+		config, err := m.fs.ReadFileString(configPath)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading job config %s", configPath)
+		}
+
+		processes, err := parseMonitProcesses(config)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing job config %s", configPath)
+		}
+
+		for _, process := range processes {
+			services = append(services, process.Name)
+		}
+	}
+
+	if len(services) > 0 {
+		return services, nil
+	}
+
+	vcapServices, err := m.client.ServicesInGroup("vcap")
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting vcap services")
+	}
+
+	for _, service := range vcapServices {
+		if service == name {
+			return []string{service}, nil
+		}
+	}
+
+	return nil, bosherr.Errorf("Unknown job or service '%s'", name)
+}
+
+// serviceJobs maps the services of all job configs to their jobs
+func (m monitJobSupervisor) serviceJobs() map[string]string {
+	jobs := map[string]string{}
+
+	configPaths, err := m.fs.Glob(path.Join(m.dirProvider.MonitJobsDir(), "*.monitrc"))
+	if err != nil {
+		m.logger.Warn(monitJobSupervisorLogTag, "Failed to list job configs: %s", err.Error())
+		return jobs
+	}
+
+	for _, configPath := range configPaths {
+		config, err := m.fs.ReadFileString(configPath)
+		if err != nil {
+			continue
+		}
+
+		processes, err := parseMonitProcesses(config)
+		if err != nil {
+			continue
+		}
+
+		jobName := m.jobOf(configPath)
+
+		for _, process := range processes {
+			jobs[process.Name] = jobName
+		}
+	}
+
+	return jobs
+}
+
+// jobOf returns the job the config belongs to. Configs added before
+// their job was recorded belong to the job named like the config.
+func (m monitJobSupervisor) jobOf(configPath string) string {
+	basePath := strings.TrimSuffix(configPath, ".monitrc")
+
+	jobName, err := m.fs.ReadFileString(basePath + jobNameFileSuffix)
+	if err == nil {
+		return strings.TrimSpace(jobName)
+	}
+
+	// Job configs are named <index>_<config name>.monitrc
+	configName := path.Base(basePath)
+	if i := strings.Index(configName, "_"); i >= 0 {
+		configName = configName[i+1:]
+	}
+
+	return configName
+}
+
 func (m monitJobSupervisor) Status() (status string) {
 	status = "running"
 
@@ -303,9 +604,12 @@ func (m monitJobSupervisor) Processes() (processes []Process, err error) {
 		return processes, bosherr.WrapError(err, "Getting service status")
 	}
 
+	jobs := m.serviceJobs()
+
 	for _, service := range monitStatus.ServicesInGroup("vcap") {
 		process := Process{
 			Name:  service.Name,
+			Job:   jobs[service.Name],
 			State: service.Status,
 			Uptime: UptimeVitals{
 				Secs: service.Uptime,
@@ -318,12 +622,97 @@ func (m monitJobSupervisor) Processes() (processes []Process, err error) {
 				Total: service.CPUPercentTotal,
 			},
 		}
+		if service.Pid > 0 {
+			process.PID = service.Pid
+		}
+		process.ChildCount = service.Children
+		process.Failure = monitFailure(service)
+		process.Checks = monitChecks(service)
 		processes = append(processes, process)
 	}
 
 	return
 }
 
+// monitFailure explains why monit considers the service failing
+func monitFailure(service boshmonit.Service) *FailureVitals {
+	if service.Status != boshmonit.StatusFailing && !service.Errored {
+		return nil
+	}
+
+	if service.StatusMessage == "" && len(service.FailedChecks) == 0 {
+		return nil
+	}
+
+	return &FailureVitals{
+		Message: service.StatusMessage,
+		Checks:  service.FailedChecks,
+	}
+}
+
+func monitChecks(service boshmonit.Service) *CheckVitals {
+	checks := &CheckVitals{}
+
+	for _, port := range service.Ports {
+		checks.Ports = append(checks.Ports, PortCheckVitals{
+			Host:         port.Hostname,
+			Port:         port.Port,
+			Protocol:     port.Protocol,
+			Type:         port.Type,
+			ResponseTime: port.ResponseTime,
+			Failed:       port.Failed,
+		})
+	}
+
+	for _, socket := range service.UnixSockets {
+		checks.UnixSockets = append(checks.UnixSockets, UnixSocketCheckVitals{
+			Path:         socket.Path,
+			Protocol:     socket.Protocol,
+			ResponseTime: socket.ResponseTime,
+			Failed:       socket.Failed,
+		})
+	}
+
+	if file := service.File; file != nil {
+		checks.File = &FileCheckVitals{
+			Mode:         file.Mode,
+			UID:          file.UID,
+			GID:          file.GID,
+			Timestamp:    file.Timestamp,
+			Size:         file.Size,
+			ChecksumType: file.ChecksumType,
+			Checksum:     file.Checksum,
+		}
+	}
+
+	if filesystem := service.Filesystem; filesystem != nil {
+		checks.Filesystem = &FilesystemCheckVitals{
+			Type:         filesystem.Type,
+			Flags:        filesystem.Flags,
+			BlockPercent: filesystem.BlockPercent,
+			BlockUsageMb: filesystem.BlockUsageMb,
+			BlockTotalMb: filesystem.BlockTotalMb,
+			InodePercent: filesystem.InodePercent,
+			InodeUsage:   filesystem.InodeUsage,
+			InodeTotal:   filesystem.InodeTotal,
+		}
+	}
+
+	if program := service.Program; program != nil {
+		checks.Program = &ProgramCheckVitals{
+			Started:  program.Started,
+			ExitCode: program.ExitCode,
+			Output:   program.Output,
+		}
+	}
+
+	if checks.Ports == nil && checks.UnixSockets == nil && checks.File == nil && checks.Filesystem == nil && checks.Program == nil {
+		return nil
+	}
+
+	return checks
+}
+
 func (m monitJobSupervisor) getIncarnation() (int, error) {
 	monitStatus, err := m.client.Status()
 	if err != nil {
@@ -333,9 +722,9 @@ func (m monitJobSupervisor) getIncarnation() (int, error) {
 	return monitStatus.GetIncarnation()
 }
 
-func (m monitJobSupervisor) AddJob(jobName string, jobIndex int, configPath string) error {
-	targetFilename := fmt.Sprintf("%04d_%s.monitrc", jobIndex, jobName)
-	targetConfigPath := path.Join(m.dirProvider.MonitJobsDir(), targetFilename)
+func (m monitJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	targetFilename := fmt.Sprintf("%04d_%s", jobIndex, configName)
+	targetConfigPath := path.Join(m.stagingJobsDir(), targetFilename+".monitrc")
 
 	configContent, err := m.fs.ReadFile(configPath)
 	if err != nil {
@@ -347,14 +736,62 @@ func (m monitJobSupervisor) AddJob(jobName string, jobIndex int, configPath stri
 		return bosherr.WrapError(err, "Writing to job config file")
 	}
 
+	err = m.fs.WriteFileString(path.Join(m.stagingJobsDir(), targetFilename+jobNameFileSuffix), jobName)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing job name file")
+	}
+
 	return nil
 }
 
+// SetResourceLimits is a no-op since monit cannot place processes in
+// cgroups, see cgroupJobSupervisor
+func (m monitJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	return nil
+}
+
+// SetStartDependencies is a no-op since monit starts all services at
+// once, see startOrderJobSupervisor
+func (m monitJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return nil
+}
+
+// RemoveAllJobs empties the staging directory. The jobs are removed from
+// monit on the next reload.
 func (m monitJobSupervisor) RemoveAllJobs() error {
-	return m.fs.RemoveAll(m.dirProvider.MonitJobsDir())
+	err := m.fs.RemoveAll(m.stagingJobsDir())
+	if err != nil {
+		return bosherr.WrapError(err, "Removing staged job configs")
+	}
+
+	err = m.fs.MkdirAll(m.stagingJobsDir(), 0755)
+	if err != nil {
+		return bosherr.WrapError(err, "Creating staging directory for job configs")
+	}
+
+	return nil
 }
 
-func (m monitJobSupervisor) MonitorJobFailures(handler JobFailureHandler) (err error) {
+// MonitorJobFailures receives monit alerts through an embedded SMTP server.
+// When an alerts server port is configured it also accepts alerts as JSON
+// posted to http://127.0.0.1:<port>/alerts, which allows job scripts and
+// health checkers to raise alerts without going through monit.
+func (m monitJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	if m.alertsServerPort > 0 {
+		// Monit only reports failures over SMTP, so alerts from jobs must not
+		// take job failure monitoring down with them
+		go func() {
+			err := m.listenForHTTPAlerts(handler)
+			if err != nil {
+				m.logger.Error(monitJobSupervisorLogTag, "Failed to listen for HTTP alerts: %s", err.Error())
+			}
+		}()
+	}
+
+	return m.listenForSMTPAlerts(handler)
+}
+
+func (m monitJobSupervisor) listenForSMTPAlerts(handler JobFailureHandler) (err error) {
 	alertHandler := func(smtpd.Connection, smtpd.MailAddress) (env smtpd.Envelope, err error) {
 		env = &alertEnvelope{
 			new(smtpd.BasicEnvelope),
@@ -376,6 +813,92 @@ func (m monitJobSupervisor) MonitorJobFailures(handler JobFailureHandler) (err e
 	return
 }
 
+func (m monitJobSupervisor) listenForHTTPAlerts(handler JobFailureHandler) error {
+	password, err := m.writeAlertsCredentials()
+	if err != nil {
+		return bosherr.WrapError(err, "Writing alerts credentials")
+	}
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf("127.0.0.1:%d", m.alertsServerPort),
+		Handler: newAlertHTTPHandler(handler, alertsUsername, password, m.logger),
+	}
+
+	err = server.ListenAndServe()
+	if err != nil {
+		return bosherr.WrapError(err, "Listen for HTTP alerts")
+	}
+
+	return nil
+}
+
+func (m monitJobSupervisor) writeAlertsCredentials() (string, error) {
+	passwordBytes := make([]byte, alertsPasswordByteSize)
+
+	_, err := rand.Read(passwordBytes)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Generating password")
+	}
+
+	password := hex.EncodeToString(passwordBytes)
+	credentialsPath := path.Join(m.dirProvider.MonitDir(), alertsCredentialsFile)
+
+	// The credentials are written to a file only root and vcap can read and
+	// renamed into place so they are never readable by anyone else, not even
+	// while they are being written
+	tmpPath := credentialsPath + ".tmp"
+
+	err = m.fs.RemoveAll(tmpPath)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Removing %s", tmpPath)
+	}
+
+	err = m.writeCredentialsFile(tmpPath, fmt.Sprintf("%s:%s", alertsUsername, password))
+	if err != nil {
+		_ = m.fs.RemoveAll(tmpPath)
+		return "", err
+	}
+
+	err = m.fs.Rename(tmpPath, credentialsPath)
+	if err != nil {
+		_ = m.fs.RemoveAll(tmpPath)
+		return "", bosherr.WrapErrorf(err, "Renaming %s to %s", tmpPath, credentialsPath)
+	}
+
+	return password, nil
+}
+
+func (m monitJobSupervisor) writeCredentialsFile(filePath, credentials string) error {
+	file, err := m.fs.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating %s", filePath)
+	}
+
+	_, err = file.Write([]byte(credentials))
+	if err != nil {
+		_ = file.Close()
+		return bosherr.WrapErrorf(err, "Writing %s", filePath)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Closing %s", filePath)
+	}
+
+	err = m.fs.Chown(filePath, "root:vcap")
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Chowning %s", filePath)
+	}
+
+	// OpenFile applies the umask, which may have cleared the group bit
+	err = m.fs.Chmod(filePath, 0640)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Chmoding %s", filePath)
+	}
+
+	return nil
+}
+
 func (m monitJobSupervisor) stoppedFilePath() string {
 	return path.Join(m.dirProvider.MonitDir(), "stopped")
 }
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"net/smtp"
 	"os"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/clock/fakeclock"
@@ -33,6 +34,7 @@ var _ = Describe("monitJobSupervisor", func() {
 		logger                boshlog.Logger
 		dirProvider           boshdir.Provider
 		jobFailuresServerPort int
+		alertsServerPort      int
 		monit                 JobSupervisor
 		timeService           *fakeclock.FakeClock
 	)
@@ -54,6 +56,7 @@ var _ = Describe("monitJobSupervisor", func() {
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 		dirProvider = boshdir.NewProvider("/var/vcap")
 		jobFailuresServerPort = getJobFailureServerPort()
+		alertsServerPort = jobFailuresServerPort + 1000
 		timeService = fakeclock.NewFakeClock(time.Now())
 
 		monit = NewMonitJobSupervisor(
@@ -63,6 +66,7 @@ var _ = Describe("monitJobSupervisor", func() {
 			logger,
 			dirProvider,
 			jobFailuresServerPort,
+			alertsServerPort,
 			MonitReloadOptions{
 				MaxTries:               3,
 				MaxCheckTries:          10,
@@ -164,6 +168,138 @@ var _ = Describe("monitJobSupervisor", func() {
 			Expect(client.StatusCalledTimes).To(Equal(3))
 		})
 
+		Context("when job configs were staged", func() {
+			const controlFilePath = "/var/vcap/monit/monitrc.staging"
+
+			BeforeEach(func() {
+				client.Incarnations = []int{1, 2}
+
+				fs.WriteFileString("/var/vcap/monit/job/0000_old.monitrc", "old-config")
+				fs.WriteFileString("/some/config/path", "new-config")
+				fs.WriteFileString("/var/vcap/bosh/etc/monitrc", `set daemon 10
+set httpd port 2822 and use address 127.0.0.1
+  allow cleartext /var/vcap/monit/monit.user
+
+include /var/vcap/monit/*.monitrc
+include /var/vcap/monit/job/*.monitrc
+`)
+
+				Expect(monit.RemoveAllJobs()).To(Succeed())
+				Expect(monit.AddJob("web", "web", 0, "/some/config/path")).To(Succeed())
+			})
+
+			It("validates the staged configs and moves them into place before reloading monit", func() {
+				runner.SetCmdCallback("monit -t -c "+controlFilePath, func() {
+					controlFile, err := fs.ReadFileString(controlFilePath)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(controlFile).To(Equal(`set daemon 10
+set httpd port 2822 and use address 127.0.0.1
+  allow cleartext /var/vcap/monit/monit.user
+
+include /var/vcap/monit/*.monitrc
+include /var/vcap/monit/job.staging/*.monitrc
+`))
+					Expect(fs.GetFileTestStat(controlFilePath).FileMode).To(Equal(os.FileMode(0600)))
+				})
+
+				err := monit.Reload()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(runner.RunCommands[0]).To(Equal([]string{"monit", "-t", "-c", controlFilePath}))
+				Expect(runner.RunCommands[1]).To(Equal([]string{"sv", "kill", "monit"}))
+
+				Expect(fs.FileExists("/var/vcap/monit/job/0000_old.monitrc")).To(BeFalse())
+				Expect(fs.ReadFileString("/var/vcap/monit/job/0000_web.monitrc")).To(Equal("new-config"))
+				Expect(fs.FileExists("/var/vcap/monit/job.staging")).To(BeFalse())
+				Expect(fs.FileExists("/var/vcap/monit/job.previous")).To(BeFalse())
+				Expect(fs.FileExists(controlFilePath)).To(BeFalse())
+			})
+
+			It("keeps the previous configs and names the job and line of an invalid config", func() {
+				runner.AddCmdResult("monit -t -c "+controlFilePath, fakesys.FakeCmdResult{
+					Stderr:     "/var/vcap/monit/job.staging/0000_web.monitrc:3: syntax error 'fo'\n",
+					ExitStatus: 1,
+					Error:      errors.New("fake-exit-error"),
+				})
+
+				err := monit.Reload()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Invalid monit config of job 'web' at line 3: syntax error 'fo'"))
+
+				Expect(runner.RunCommands).To(HaveLen(1))
+				Expect(fs.ReadFileString("/var/vcap/monit/job/0000_old.monitrc")).To(Equal("old-config"))
+				Expect(fs.FileExists("/var/vcap/monit/job/0000_web.monitrc")).To(BeFalse())
+			})
+
+			It("names the job of an invalid additional config", func() {
+				fs.WriteFileString("/some/other/config/path", "broken-config")
+				Expect(monit.AddJob("web", "web_extra", 1, "/some/other/config/path")).To(Succeed())
+
+				runner.AddCmdResult("monit -t -c "+controlFilePath, fakesys.FakeCmdResult{
+					Stderr:     "/var/vcap/monit/job.staging/0001_web_extra.monitrc:1: syntax error 'broken'\n",
+					ExitStatus: 1,
+					Error:      errors.New("fake-exit-error"),
+				})
+
+				err := monit.Reload()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Invalid monit config of job 'web' at line 1: syntax error 'broken'"))
+			})
+
+			It("restores the previous configs when the staged configs cannot be moved into place", func() {
+				fs.RenameStub = func(oldPath, newPath string) error {
+					if oldPath == "/var/vcap/monit/job.staging" {
+						return errors.New("fake-rename-error")
+					}
+					return nil
+				}
+
+				err := monit.Reload()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Moving job configs into place: fake-rename-error"))
+
+				Expect(fs.ReadFileString("/var/vcap/monit/job/0000_old.monitrc")).To(Equal("old-config"))
+				Expect(fs.FileExists("/var/vcap/monit/job.previous")).To(BeFalse())
+				Expect(runner.RunCommands).To(HaveLen(1))
+			})
+
+			It("includes the staged configs when the control file does not include the job configs", func() {
+				fs.WriteFileString("/var/vcap/bosh/etc/monitrc", "set daemon 10\n")
+
+				runner.SetCmdCallback("monit -t -c "+controlFilePath, func() {
+					controlFile, err := fs.ReadFileString(controlFilePath)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(controlFile).To(Equal("set daemon 10\n\ninclude /var/vcap/monit/job.staging/*.monitrc\n"))
+				})
+
+				err := monit.Reload()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("keeps the previous configs when the control file cannot be read", func() {
+				Expect(fs.RemoveAll("/var/vcap/bosh/etc/monitrc")).To(Succeed())
+
+				err := monit.Reload()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Reading monit control file /var/vcap/bosh/etc/monitrc"))
+
+				Expect(runner.RunCommands).To(BeEmpty())
+				Expect(fs.ReadFileString("/var/vcap/monit/job/0000_old.monitrc")).To(Equal("old-config"))
+			})
+
+			It("returns the output of monit when it does not name a config file", func() {
+				runner.AddCmdResult("monit -t -c "+controlFilePath, fakesys.FakeCmdResult{
+					Stderr:     "monit: fatal error\n",
+					ExitStatus: 1,
+					Error:      errors.New("fake-exit-error"),
+				})
+
+				err := monit.Reload()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Validating monit config: monit: fatal error: fake-exit-error"))
+			})
+		})
+
 		Context("when fetching the incarnation fails", func() {
 			Context("before reloading monit", func() {
 				BeforeEach(func() {
@@ -254,9 +390,106 @@ var _ = Describe("monitJobSupervisor", func() {
 		})
 	})
 
+	Describe("StartService and StopService", func() {
+		BeforeEach(func() {
+			fs.WriteFileString("/var/vcap/monit/job/0000_web.monitrc", `check process web
+  start program "/var/vcap/jobs/web/bin/ctl start"
+  group vcap
+
+check process web_worker
+  start program "/var/vcap/jobs/web/bin/worker_ctl start"
+  group vcap
+`)
+			fs.WriteFileString("/var/vcap/monit/job/0000_web.job", "web")
+			fs.WriteFileString("/var/vcap/monit/job/0000_web_metrics.monitrc", `check process web_metrics
+  start program "/var/vcap/jobs/web/bin/metrics_ctl start"
+  group vcap
+`)
+			fs.WriteFileString("/var/vcap/monit/job/0000_web_metrics.job", "web")
+			fs.WriteFileString("/var/vcap/monit/job/0001_database.monitrc", `check process postgres
+  start program "/var/vcap/jobs/database/bin/ctl start"
+  group vcap
+`)
+			fs.WriteFileString("/var/vcap/monit/job/0002_web_ui.monitrc", `check process web_ui
+  start program "/var/vcap/jobs/web_ui/bin/ctl start"
+  group vcap
+`)
+			fs.WriteFileString("/var/vcap/monit/job/0002_web_ui.job", "web_ui")
+			fs.SetGlob("/var/vcap/monit/job/*.monitrc", []string{
+				"/var/vcap/monit/job/0000_web.monitrc",
+				"/var/vcap/monit/job/0000_web_metrics.monitrc",
+				"/var/vcap/monit/job/0001_database.monitrc",
+				"/var/vcap/monit/job/0002_web_ui.monitrc",
+			})
+		})
+
+		It("starts and stops every service of a job, including its additional monit files", func() {
+			err := monit.StartService("web")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.StartServiceNames).To(Equal([]string{"web", "web_worker", "web_metrics"}))
+
+			err = monit.StopService("web")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.StopServiceNames).To(Equal([]string{"web", "web_worker", "web_metrics"}))
+		})
+
+		It("does not start or stop jobs whose name starts with the name of the job", func() {
+			err := monit.StartService("web_ui")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.StartServiceNames).To(Equal([]string{"web_ui"}))
+
+			err = monit.StopService("web_ui")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.StopServiceNames).To(Equal([]string{"web_ui"}))
+		})
+
+		It("starts the services of configs added before their job was recorded", func() {
+			err := monit.StartService("database")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.StartServiceNames).To(Equal([]string{"postgres"}))
+		})
+
+		It("starts and stops a single service in group vcap", func() {
+			client.ServicesInGroupServices = []string{"web", "postgres"}
+
+			err := monit.StartService("postgres")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.StartServiceNames).To(Equal([]string{"postgres"}))
+
+			err = monit.StopService("postgres")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.StopServiceNames).To(Equal([]string{"postgres"}))
+		})
+
+		It("does not leave the stopped state of the other jobs", func() {
+			fs.WriteFileString("/var/vcap/monit/stopped", "")
+
+			err := monit.StartService("web")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists("/var/vcap/monit/stopped")).To(BeTrue())
+		})
+
+		It("returns an error for unknown names", func() {
+			client.ServicesInGroupServices = []string{"web", "postgres"}
+
+			err := monit.StartService("unknown")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unknown job or service 'unknown'"))
+			Expect(client.StartServiceNames).To(BeEmpty())
+		})
+
+		It("returns an error when monit fails to stop a service", func() {
+			client.StopServiceErr = errors.New("fake-stop-err")
+
+			err := monit.StopService("database")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-stop-err"))
+		})
+	})
+
 	Describe("StopAndWait", func() {
 		It("stop stops each monit service in group vcap", func() {
-			err := monit.StopAndWait()
+			_, err := monit.StopAndWait()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(runner.RunCommands)).To(Equal(1))
 			Expect(runner.RunCommands[0]).To(Equal([]string{"monit", "stop", "-g", "vcap"}))
@@ -295,6 +528,7 @@ var _ = Describe("monitJobSupervisor", func() {
 				logger,
 				dirProvider,
 				jobFailuresServerPort,
+				alertsServerPort,
 				MonitReloadOptions{
 					MaxTries:               3,
 					MaxCheckTries:          10,
@@ -303,7 +537,7 @@ var _ = Describe("monitJobSupervisor", func() {
 				timeService,
 			)
 
-			err := monit.StopAndWait()
+			_, err := monit.StopAndWait()
 			Expect(err).To(BeNil())
 		})
 
@@ -318,7 +552,8 @@ var _ = Describe("monitJobSupervisor", func() {
 
 				errchan := make(chan error)
 				go func() {
-					errchan <- monit.StopAndWait()
+					_, err := monit.StopAndWait()
+					errchan <- err
 				}()
 
 				Eventually(timeService.WatcherCount).Should(Equal(2)) // we hit the sleep
@@ -361,7 +596,8 @@ var _ = Describe("monitJobSupervisor", func() {
 
 				errchan := make(chan error)
 				go func() {
-					errchan <- monit.StopAndWait()
+					_, err := monit.StopAndWait()
+					errchan <- err
 				}()
 
 				failureMessage := "Timed out waiting for services 'foo' to no longer be pending after 5 minutes"
@@ -381,7 +617,8 @@ var _ = Describe("monitJobSupervisor", func() {
 
 				errchan := make(chan error)
 				go func() {
-					errchan <- monit.StopAndWait()
+					_, err := monit.StopAndWait()
+					errchan <- err
 				}()
 
 				Eventually(timeService.WatcherCount).Should(Equal(2)) // we hit the pending sleep
@@ -411,7 +648,8 @@ var _ = Describe("monitJobSupervisor", func() {
 
 				errchan := make(chan error)
 				go func() {
-					errchan <- monit.StopAndWait()
+					_, err := monit.StopAndWait()
+					errchan <- err
 				}()
 
 				Eventually(timeService.WatcherCount).Should(Equal(2)) // we hit the sleep
@@ -435,7 +673,8 @@ var _ = Describe("monitJobSupervisor", func() {
 
 				errchan := make(chan error)
 				go func() {
-					errchan <- monit.StopAndWait()
+					_, err := monit.StopAndWait()
+					errchan <- err
 				}()
 
 				Eventually(timeService.WatcherCount).Should(Equal(2)) // we hit the sleep
@@ -459,7 +698,7 @@ var _ = Describe("monitJobSupervisor", func() {
 
 				runner.AddCmdResult("monit stop -g vcap", fakeErrorResult)
 
-				err := monit.StopAndWait()
+				_, err := monit.StopAndWait()
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(Equal(fmt.Sprintf("%s%s", "Stop all services: ", fakeErrorResult.Error)))
 			})
@@ -499,6 +738,7 @@ var _ = Describe("monitJobSupervisor", func() {
 					logger,
 					dirProvider,
 					jobFailuresServerPort,
+					alertsServerPort,
 					MonitReloadOptions{
 						MaxTries:               3,
 						MaxCheckTries:          10,
@@ -507,7 +747,7 @@ var _ = Describe("monitJobSupervisor", func() {
 					timeService,
 				)
 
-				err := monit.StopAndWait()
+				_, err := monit.StopAndWait()
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(Equal("Stopping services '[test-service]' errored"))
 			})
@@ -552,13 +792,15 @@ var _ = Describe("monitJobSupervisor", func() {
 					logger,
 					dirProvider,
 					jobFailuresServerPort,
+					alertsServerPort,
 					MonitReloadOptions{},
 					timeService,
 				)
 
 				errchan := make(chan error)
 				go func() {
-					errchan <- monit.StopAndWait()
+					_, err := monit.StopAndWait()
+					errchan <- err
 				}()
 
 				failureMessage := "Timed out waiting for services 'unmonitored-start-pending, initializing, running, running-stop-pending, unmonitored-stop-pending, failing' to stop after 5 minutes"
@@ -571,7 +813,7 @@ var _ = Describe("monitJobSupervisor", func() {
 		})
 
 		It("creates stopped file", func() {
-			err := monit.StopAndWait()
+			_, err := monit.StopAndWait()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(fs.FileExists("/var/vcap/monit/stopped")).To(BeTrue())
 		})
@@ -657,6 +899,25 @@ var _ = Describe("monitJobSupervisor", func() {
 	})
 
 	Describe("Processes", func() {
+		It("names the job of the processes of job configs", func() {
+			fs.WriteFileString("/var/vcap/monit/job/0000_web_metrics.monitrc", "check process fake-service-1\n  start program \"/bin/metrics\"\n  group vcap\n")
+			fs.WriteFileString("/var/vcap/monit/job/0000_web_metrics.job", "web")
+			fs.SetGlob("/var/vcap/monit/job/*.monitrc", []string{"/var/vcap/monit/job/0000_web_metrics.monitrc"})
+
+			client.StatusStatus = fakemonit.FakeMonitStatus{
+				Services: []boshmonit.Service{
+					{Name: "fake-service-1", Monitored: true, Status: "running"},
+					{Name: "fake-service-2", Monitored: true, Status: "running"},
+				},
+			}
+
+			processes, err := monit.Processes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processes).To(HaveLen(2))
+			Expect(processes[0].Job).To(Equal("web"))
+			Expect(processes[1].Job).To(BeEmpty())
+		})
+
 		It("returns all processes", func() {
 			client.StatusStatus = fakemonit.FakeMonitStatus{
 				Services: []boshmonit.Service{
@@ -668,15 +929,22 @@ var _ = Describe("monitJobSupervisor", func() {
 						MemoryPercentTotal:   0.4,
 						MemoryKilobytesTotal: 100,
 						CPUPercentTotal:      0.5,
+						Pid:                  4242,
+						Children:             2,
 					},
 					boshmonit.Service{
 						Name:                 "fake-service-2",
 						Monitored:            true,
 						Status:               "failing",
+						StatusMessage:        "failed protocol test [HTTP] at INET[localhost:8080] via TCP",
 						Uptime:               1235,
 						MemoryPercentTotal:   0.6,
 						MemoryKilobytesTotal: 200,
 						CPUPercentTotal:      0.7,
+						FailedChecks:         []string{"connection failed"},
+						Ports: []boshmonit.PortCheck{
+							{Hostname: "localhost", Port: 8080, Protocol: "HTTP", Type: "TCP", ResponseTime: -1, Failed: true},
+						},
 					},
 				},
 			}
@@ -697,6 +965,8 @@ var _ = Describe("monitJobSupervisor", func() {
 					CPU: CPUVitals{
 						Total: 0.5,
 					},
+					PID:        4242,
+					ChildCount: 2,
 				},
 				Process{
 					Name:  "fake-service-2",
@@ -711,6 +981,62 @@ var _ = Describe("monitJobSupervisor", func() {
 					CPU: CPUVitals{
 						Total: 0.7,
 					},
+					Failure: &FailureVitals{
+						Message: "failed protocol test [HTTP] at INET[localhost:8080] via TCP",
+						Checks:  []string{"connection failed"},
+					},
+					Checks: &CheckVitals{
+						Ports: []PortCheckVitals{
+							{Host: "localhost", Port: 8080, Protocol: "HTTP", Type: "TCP", ResponseTime: -1, Failed: true},
+						},
+					},
+				},
+			}))
+		})
+
+		It("returns the results of file, filesystem and program checks", func() {
+			client.StatusStatus = fakemonit.FakeMonitStatus{
+				Services: []boshmonit.Service{
+					{
+						Name:      "fake-config",
+						Monitored: true,
+						Status:    "running",
+						File:      &boshmonit.FileCheck{Mode: "640", UID: 1000, GID: 1000, Size: 2048, ChecksumType: "MD5", Checksum: "fake-md5"},
+					},
+					{
+						Name:       "fake-store",
+						Monitored:  true,
+						Status:     "running",
+						Filesystem: &boshmonit.FilesystemCheck{Flags: "4096", BlockPercent: 91.5, BlockUsageMb: 9150, BlockTotalMb: 10000, InodeUsage: 6553, InodeTotal: 65536},
+					},
+					{
+						Name:         "fake-ping",
+						Monitored:    true,
+						Status:       "failing",
+						FailedChecks: []string{"status failed"},
+						Program:      &boshmonit.ProgramCheck{Started: 1386971800, ExitCode: 3, Output: "connection refused"},
+					},
+				},
+			}
+
+			processes, err := monit.Processes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processes).To(Equal([]Process{
+				{
+					Name:   "fake-config",
+					State:  "running",
+					Checks: &CheckVitals{File: &FileCheckVitals{Mode: "640", UID: 1000, GID: 1000, Size: 2048, ChecksumType: "MD5", Checksum: "fake-md5"}},
+				},
+				{
+					Name:   "fake-store",
+					State:  "running",
+					Checks: &CheckVitals{Filesystem: &FilesystemCheckVitals{Flags: "4096", BlockPercent: 91.5, BlockUsageMb: 9150, BlockTotalMb: 10000, InodeUsage: 6553, InodeTotal: 65536}},
+				},
+				{
+					Name:    "fake-ping",
+					State:   "failing",
+					Failure: &FailureVitals{Checks: []string{"status failed"}},
+					Checks:  &CheckVitals{Program: &ProgramCheckVitals{Started: 1386971800, ExitCode: 3, Output: "connection refused"}},
 				},
 			}))
 		})
@@ -769,6 +1095,165 @@ var _ = Describe("monitJobSupervisor", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(didHandleAlert).To(BeFalse())
 		})
+
+		It("keeps monitoring job failures when alerts cannot be received over HTTP", func() {
+			fs.OpenFileErr = errors.New("fake-open-file-err")
+
+			handledAlerts := make(chan boshalert.MonitAlert, 1)
+			failureHandler := func(alert boshalert.MonitAlert) error {
+				handledAlerts <- alert
+				return nil
+			}
+
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- monit.MonitorJobFailures(failureHandler)
+			}()
+
+			msg := `Message-id: <1304319946.0@localhost>
+ Service: nats
+ Event: does not exist
+ Action: restart
+ Date: Sun, 22 May 2011 20:07:41 +0500
+ Description: process is not running`
+
+			err := doJobFailureEmail(msg, jobFailuresServerPort)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(handledAlerts).Should(Receive())
+			Expect(errCh).ToNot(Receive())
+			Expect(fs.FileExists("/var/vcap/monit/alerts.user")).To(BeFalse())
+		})
+	})
+
+	Describe("MonitorJobFailures over HTTP", func() {
+		var (
+			handledAlerts chan boshalert.MonitAlert
+			alertsURL     string
+		)
+
+		postAlert := func(body string, username, password string) *http.Response {
+			req, err := http.NewRequest("POST", alertsURL, strings.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+			req.SetBasicAuth(username, password)
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			return resp
+		}
+
+		readPassword := func() string {
+			credentials, err := fs.ReadFileString("/var/vcap/monit/alerts.user")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(credentials).To(HavePrefix("alerts:"))
+			return strings.TrimPrefix(credentials, "alerts:")
+		}
+
+		BeforeEach(func() {
+			handledAlerts = make(chan boshalert.MonitAlert, 1)
+			alertsURL = fmt.Sprintf("http://127.0.0.1:%d/alerts", alertsServerPort)
+
+			failureHandler := func(alert boshalert.MonitAlert) error {
+				handledAlerts <- alert
+				return nil
+			}
+
+			Expect(fs.MkdirAll("/var/vcap/monit", 0755)).To(Succeed())
+
+			go monit.MonitorJobFailures(failureHandler)
+
+			Eventually(func() error {
+				resp, err := http.Get(alertsURL)
+				if err == nil {
+					resp.Body.Close()
+				}
+				return err
+			}).ShouldNot(HaveOccurred())
+		})
+
+		It("writes credentials readable by jobs", func() {
+			readPassword()
+			Expect(fs.FileExists("/var/vcap/monit/alerts.user.tmp")).To(BeFalse())
+
+			stat := fs.GetFileTestStat("/var/vcap/monit/alerts.user")
+			Expect(stat.FileMode).To(Equal(os.FileMode(0640)))
+			Expect(stat.Username).To(Equal("root"))
+			Expect(stat.Groupname).To(Equal("vcap"))
+		})
+
+		It("accepts alerts in the monit alert shape", func() {
+			password := readPassword()
+
+			resp := postAlert(`{
+				"id": "fake-id",
+				"service": "nats",
+				"event": "does not exist",
+				"action": "restart",
+				"date": "Sun, 22 May 2011 20:07:41 +0500",
+				"description": "process is not running"
+			}`, "alerts", password)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+			Expect(<-handledAlerts).To(Equal(boshalert.MonitAlert{
+				ID:          "fake-id",
+				Service:     "nats",
+				Event:       "does not exist",
+				Action:      "restart",
+				Date:        "Sun, 22 May 2011 20:07:41 +0500",
+				Description: "process is not running",
+			}))
+		})
+
+		It("accepts alerts in the generic alert shape", func() {
+			password := readPassword()
+
+			resp := postAlert(`{
+				"severity": 4,
+				"title": "my-job - queue backlog - alert",
+				"summary": "queue has 1000 pending items",
+				"created_at": 1306076861
+			}`, "alerts", password)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+			Expect(<-handledAlerts).To(Equal(boshalert.MonitAlert{
+				Date:        time.Unix(1306076861, 0).Format(time.RFC1123Z),
+				Description: "queue has 1000 pending items",
+				Severity:    boshalert.SeverityWarning,
+				Title:       "my-job - queue backlog - alert",
+			}))
+		})
+
+		It("rejects requests with invalid credentials", func() {
+			readPassword()
+
+			resp := postAlert(`{"service": "nats", "event": "does not exist"}`, "alerts", "wrong-password")
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(handledAlerts).ToNot(Receive())
+		})
+
+		It("rejects alerts that have neither shape", func() {
+			password := readPassword()
+
+			resp := postAlert(`{"service": "nats"}`, "alerts", password)
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+			resp = postAlert(`not-json`, "alerts", password)
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(handledAlerts).ToNot(Receive())
+		})
+
+		It("rejects generic alerts with an unknown severity", func() {
+			password := readPassword()
+
+			for _, severity := range []int{-1, 0, 5} {
+				resp := postAlert(fmt.Sprintf(`{"severity": %d, "title": "fake-title"}`, severity), "alerts", password)
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			}
+			Expect(handledAlerts).ToNot(Receive())
+		})
 	})
 
 	Describe("AddJob", func() {
@@ -778,14 +1263,30 @@ var _ = Describe("monitJobSupervisor", func() {
 
 		Context("when reading configuration from config path succeeds", func() {
 			Context("when writing job configuration succeeds", func() {
-				It("returns no error because monit can track added job in jobs directory", func() {
-					err := monit.AddJob("router", 0, "/some/config/path")
+				It("stages the job config until the next reload", func() {
+					err := monit.AddJob("router", "router", 0, "/some/config/path")
+					Expect(err).ToNot(HaveOccurred())
+
+					writtenConfig, err := fs.ReadFileString(
+						dirProvider.MonitJobsDir() + ".staging/0000_router.monitrc")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(writtenConfig).To(Equal("fake-config"))
+					Expect(fs.FileExists(dirProvider.MonitJobsDir() + "/0000_router.monitrc")).To(BeFalse())
+				})
+
+				It("records the job an additional config belongs to", func() {
+					err := monit.AddJob("router", "router_health", 0, "/some/config/path")
 					Expect(err).ToNot(HaveOccurred())
 
 					writtenConfig, err := fs.ReadFileString(
-						dirProvider.MonitJobsDir() + "/0000_router.monitrc")
+						dirProvider.MonitJobsDir() + ".staging/0000_router_health.monitrc")
 					Expect(err).ToNot(HaveOccurred())
 					Expect(writtenConfig).To(Equal("fake-config"))
+
+					jobName, err := fs.ReadFileString(
+						dirProvider.MonitJobsDir() + ".staging/0000_router_health.job")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(jobName).To(Equal("router"))
 				})
 			})
 
@@ -793,7 +1294,7 @@ var _ = Describe("monitJobSupervisor", func() {
 				It("returns error", func() {
 					fs.WriteFileError = errors.New("fake-write-error")
 
-					err := monit.AddJob("router", 0, "/some/config/path")
+					err := monit.AddJob("router", "router", 0, "/some/config/path")
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-write-error"))
 				})
@@ -804,7 +1305,7 @@ var _ = Describe("monitJobSupervisor", func() {
 			It("returns error", func() {
 				fs.ReadFileError = errors.New("fake-read-error")
 
-				err := monit.AddJob("router", 0, "/some/config/path")
+				err := monit.AddJob("router", "router", 0, "/some/config/path")
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-read-error"))
 			})
@@ -813,16 +1314,18 @@ var _ = Describe("monitJobSupervisor", func() {
 
 	Describe("RemoveAllJobs", func() {
 		Context("when jobs directory removal succeeds", func() {
-			It("does not return error because all jobs are removed from monit", func() {
+			It("empties the staging directory so that the jobs are removed on the next reload", func() {
 				jobsDir := dirProvider.MonitJobsDir()
 				jobBasename := "/0000_router.monitrc"
 				fs.WriteFileString(jobsDir+jobBasename, "fake-added-job")
+				fs.WriteFileString(jobsDir+".staging"+jobBasename, "fake-staged-job")
 
 				err := monit.RemoveAllJobs()
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(fs.FileExists(jobsDir)).To(BeFalse())
-				Expect(fs.FileExists(jobsDir + jobBasename)).To(BeFalse())
+				Expect(fs.FileExists(jobsDir + ".staging")).To(BeTrue())
+				Expect(fs.FileExists(jobsDir + ".staging" + jobBasename)).To(BeFalse())
+				Expect(fs.FileExists(jobsDir + jobBasename)).To(BeTrue())
 			})
 		})
 
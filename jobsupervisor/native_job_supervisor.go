@@ -0,0 +1,750 @@
+package jobsupervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	nativeJobSupervisorLogTag = "nativeJobSupervisor"
+
+	// Jobs supervised natively ship <job dir>/processes.json
+	nativeProcessConfigFileName = "processes.json"
+)
+
+type NativeProcessConfig struct {
+	Processes []NativeProcess `json:"processes"`
+}
+
+type NativeProcess struct {
+	Name       string            `json:"name"`
+	Executable string            `json:"executable"`
+	Args       []string          `json:"args"`
+	Env        map[string]string `json:"env"`
+	User       string            `json:"user"`
+	WorkingDir string            `json:"working_dir"`
+}
+
+type NativeOptions struct {
+	// Delay before the first restart of a crashed process;
+	// doubled after every consecutive crash up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Processes running longer than this are restarted with InitialBackoff again
+	BackoffResetAfter time.Duration
+
+	// Time between SIGTERM and SIGKILL sent to the process group when stopping
+	StopTimeout time.Duration
+}
+
+type nativeJobSupervisor struct {
+	fs            boshsys.FileSystem
+	dirProvider   boshdir.Provider
+	cgroupManager cgroup.Manager
+	options       NativeOptions
+	timeService   clock.Clock
+	logger        boshlog.Logger
+
+	lock      sync.Mutex
+	processes map[string]*nativeProcess
+	limits    map[string]cgroup.Limits
+	alerts    chan boshalert.MonitAlert
+
+	// Processes killed with SIGKILL since the last StopAndWait
+	forceKilled []ForceKilledProcess
+}
+
+type nativeProcess struct {
+	jobName string
+	spec    NativeProcess
+
+	// Name of the job cgroup the process is placed in, if any
+	cgroup string
+
+	state     string
+	pid       int
+	startedAt time.Time
+	restarts  int
+	monitored bool
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewNativeJobSupervisor supervises job processes from the agent itself
+// instead of monit. Every process runs in its own process group so that
+// stopping it also stops everything it spawned.
+func NewNativeJobSupervisor(
+	fs boshsys.FileSystem,
+	dirProvider boshdir.Provider,
+	cgroupManager cgroup.Manager,
+	options NativeOptions,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) JobSupervisor {
+	return &nativeJobSupervisor{
+		fs:            fs,
+		dirProvider:   dirProvider,
+		cgroupManager: cgroupManager,
+		options:       options,
+		timeService:   timeService,
+		logger:        logger,
+		processes:     map[string]*nativeProcess{},
+		limits:        map[string]cgroup.Limits{},
+		alerts:        make(chan boshalert.MonitAlert, 100),
+	}
+}
+
+func (n *nativeJobSupervisor) Reload() error {
+	return nil
+}
+
+func (n *nativeJobSupervisor) Start() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	err := n.startProcesses(n.processNames())
+	if err != nil {
+		return err
+	}
+
+	err = n.fs.RemoveAll(n.stoppedFilePath())
+	if err != nil {
+		return bosherr.WrapError(err, "Removing stopped File")
+	}
+
+	return nil
+}
+
+func (n *nativeJobSupervisor) StartService(name string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	names, err := n.processNamesFor(name)
+	if err != nil {
+		return err
+	}
+
+	return n.startProcesses(names)
+}
+
+// StopService waits for the processes to stop so that
+// they can be started again right away
+func (n *nativeJobSupervisor) StopService(name string) error {
+	n.lock.Lock()
+
+	names, err := n.processNamesFor(name)
+	if err != nil {
+		n.lock.Unlock()
+		return err
+	}
+
+	var doneChs []chan struct{}
+
+	for _, name := range names {
+		process := n.processes[name]
+		n.stopProcess(process)
+
+		if process.doneCh != nil {
+			doneChs = append(doneChs, process.doneCh)
+		}
+	}
+
+	n.lock.Unlock()
+
+	for _, doneCh := range doneChs {
+		<-doneCh
+	}
+
+	return nil
+}
+
+func (n *nativeJobSupervisor) Stop() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.stopAll()
+
+	err := n.fs.WriteFileString(n.stoppedFilePath(), "")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating stopped File")
+	}
+
+	return nil
+}
+
+func (n *nativeJobSupervisor) StopAndWait() (StopResult, error) {
+	n.lock.Lock()
+	n.forceKilled = nil
+	n.lock.Unlock()
+
+	err := n.Stop()
+	if err != nil {
+		return StopResult{}, err
+	}
+
+	n.waitForAll()
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return StopResult{ForceKilled: n.forceKilled}, nil
+}
+
+// Unmonitor keeps processes running but does not restart them
+// or report their failures until the next Start.
+func (n *nativeJobSupervisor) Unmonitor() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for _, process := range n.processes {
+		process.monitored = false
+	}
+
+	return nil
+}
+
+func (n *nativeJobSupervisor) Status() string {
+	if n.fs.FileExists(n.stoppedFilePath()) {
+		return "stopped"
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	status := "running"
+
+	for _, process := range n.processes {
+		switch process.state {
+		case "running":
+		case "starting":
+			return "starting"
+		default:
+			status = "failing"
+		}
+	}
+
+	return status
+}
+
+func (n *nativeJobSupervisor) Processes() ([]Process, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	processes := []Process{}
+	usages := map[string]*CgroupVitals{}
+
+	for _, name := range n.processNames() {
+		process := n.processes[name]
+
+		result := Process{Name: name, Job: process.jobName, State: process.state}
+
+		if process.state == "running" {
+			result.PID = process.pid
+			result.Uptime.Secs = int(n.timeService.Since(process.startedAt).Seconds())
+			result.Memory.Kb = n.residentMemoryKb(process.pid)
+		}
+
+		if process.cgroup != "" {
+			vitals, found := usages[process.cgroup]
+			if !found {
+				vitals = n.cgroupVitals(process.cgroup)
+				usages[process.cgroup] = vitals
+			}
+
+			result.Cgroup = vitals
+		}
+
+		processes = append(processes, result)
+	}
+
+	return processes, nil
+}
+
+func (n *nativeJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	// Additional *.monit files belong to the same job bundle
+	// whose processes are all listed in a single config
+	if filepath.Base(configPath) != "monit" {
+		return nil
+	}
+
+	processConfigPath := filepath.Join(filepath.Dir(configPath), nativeProcessConfigFileName)
+	if !n.fs.FileExists(processConfigPath) {
+		n.logger.Debug(nativeJobSupervisorLogTag, "Skipping job %s without %s", jobName, nativeProcessConfigFileName)
+		return nil
+	}
+
+	contents, err := n.fs.ReadFile(processConfigPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading job process config")
+	}
+
+	var config NativeProcessConfig
+
+	err = json.Unmarshal(contents, &config)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Unmarshalling %s", processConfigPath)
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for _, spec := range config.Processes {
+		if spec.Name == "" || spec.Executable == "" {
+			return bosherr.Errorf("Process of job %s must have a name and an executable", jobName)
+		}
+
+		if existing, found := n.processes[spec.Name]; found && existing.jobName != jobName {
+			return bosherr.Errorf("Process %s of job %s is already defined by job %s", spec.Name, jobName, existing.jobName)
+		}
+
+		var group string
+		if _, found := n.limits[jobName]; found {
+			group = jobName
+		}
+
+		n.processes[spec.Name] = &nativeProcess{
+			jobName: jobName,
+			spec:    spec,
+			cgroup:  group,
+			state:   "stopped",
+		}
+	}
+
+	return nil
+}
+
+func (n *nativeJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	if limits.IsEmpty() {
+		return nil
+	}
+
+	err := n.cgroupManager.Configure(jobName, limits)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Configuring cgroup of job %s", jobName)
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.limits[jobName] = limits
+
+	return nil
+}
+
+// SetStartDependencies is a no-op, see startOrderJobSupervisor
+func (n *nativeJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return nil
+}
+
+func (n *nativeJobSupervisor) RemoveAllJobs() error {
+	n.lock.Lock()
+	n.stopAll()
+	n.lock.Unlock()
+
+	n.waitForAll()
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.processes = map[string]*nativeProcess{}
+	n.limits = map[string]cgroup.Limits{}
+
+	return nil
+}
+
+func (n *nativeJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	for alert := range n.alerts {
+		err := handler(alert)
+		if err != nil {
+			n.logger.Error(nativeJobSupervisorLogTag, "Failed to handle failure of %s: %s", alert.Service, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (n *nativeJobSupervisor) HealthRecorder(status string) {
+}
+
+func (n *nativeJobSupervisor) supervise(process *nativeProcess) {
+	defer close(process.doneCh)
+
+	backoff := n.options.InitialBackoff
+
+	for {
+		startedAt := n.timeService.Now()
+
+		cmd, exitCh, err := n.startProcess(process)
+		if err != nil {
+			n.logger.Error(nativeJobSupervisorLogTag, "Failed to start process %s: %s", process.spec.Name, err.Error())
+			n.processExited(process, "execution failed", err.Error())
+		} else {
+			n.lock.Lock()
+			process.state = "running"
+			process.pid = cmd.Process.Pid
+			process.startedAt = startedAt
+			n.lock.Unlock()
+
+			select {
+			case exitErr := <-exitCh:
+				n.processExited(process, "does not exist", describeExit(exitErr))
+
+			case <-process.stopCh:
+				n.terminate(process, cmd, exitCh)
+				return
+			}
+
+			if n.timeService.Since(startedAt) >= n.options.BackoffResetAfter {
+				backoff = n.options.InitialBackoff
+			}
+		}
+
+		n.lock.Lock()
+		monitored := process.monitored
+		if !monitored {
+			process.state = "stopped"
+		}
+		n.lock.Unlock()
+
+		if !monitored {
+			return
+		}
+
+		timer := n.timeService.NewTimer(backoff)
+
+		select {
+		case <-timer.C():
+		case <-process.stopCh:
+			timer.Stop()
+			n.setState(process, "stopped")
+			return
+		}
+
+		backoff *= 2
+		if backoff > n.options.MaxBackoff {
+			backoff = n.options.MaxBackoff
+		}
+
+		n.lock.Lock()
+		process.restarts++
+		process.state = "starting"
+		n.lock.Unlock()
+	}
+}
+
+func (n *nativeJobSupervisor) startProcess(process *nativeProcess) (*exec.Cmd, chan error, error) {
+	spec := process.spec
+	logDir := n.logDir(process.jobName)
+
+	stdout, err := n.fs.OpenFile(filepath.Join(logDir, spec.Name+".stdout.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0640))
+	if err != nil {
+		return nil, nil, bosherr.WrapError(err, "Opening stdout log")
+	}
+
+	stderr, err := n.fs.OpenFile(filepath.Join(logDir, spec.Name+".stderr.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0640))
+	if err != nil {
+		stdout.Close()
+		return nil, nil, bosherr.WrapError(err, "Opening stderr log")
+	}
+
+	attrs, err := nativeProcessAttributes(spec.User)
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, nil, err
+	}
+
+	cmd := exec.Command(spec.Executable, spec.Args...)
+	cmd.Dir = spec.WorkingDir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = attrs
+	cmd.Env = os.Environ()
+
+	envNames := make([]string, 0, len(spec.Env))
+	for name := range spec.Env {
+		envNames = append(envNames, name)
+	}
+	sort.Strings(envNames)
+
+	for _, name := range envNames {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", name, spec.Env[name]))
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, nil, bosherr.WrapErrorf(err, "Starting %s", spec.Executable)
+	}
+
+	if process.cgroup != "" {
+		err = n.cgroupManager.AddProcess(process.cgroup, cmd.Process.Pid)
+		if err != nil {
+			// Leaving the process unlimited would silently defeat the limits
+			_ = signalProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
+			_ = cmd.Wait()
+			stdout.Close()
+			stderr.Close()
+			return nil, nil, bosherr.WrapErrorf(err, "Placing %s in cgroup", spec.Name)
+		}
+	}
+
+	exitCh := make(chan error, 1)
+
+	go func() {
+		exitCh <- cmd.Wait()
+		stdout.Close()
+		stderr.Close()
+	}()
+
+	return cmd, exitCh, nil
+}
+
+// terminate stops the whole process group with SIGTERM
+// and escalates to SIGKILL after the stop timeout
+func (n *nativeJobSupervisor) terminate(process *nativeProcess, cmd *exec.Cmd, exitCh chan error) {
+	n.setState(process, "stopping")
+
+	err := signalProcessGroup(cmd.Process.Pid, syscall.SIGTERM)
+	if err != nil {
+		n.logger.Warn(nativeJobSupervisorLogTag, "Failed to send SIGTERM to process %s: %s", process.spec.Name, err.Error())
+	}
+
+	timer := n.timeService.NewTimer(n.options.StopTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-exitCh:
+	case <-timer.C():
+		n.logger.Warn(nativeJobSupervisorLogTag, "Process %s did not stop after %s, sending SIGKILL", process.spec.Name, n.options.StopTimeout)
+
+		err = signalProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
+		if err != nil {
+			n.logger.Warn(nativeJobSupervisorLogTag, "Failed to send SIGKILL to process %s: %s", process.spec.Name, err.Error())
+		}
+
+		n.lock.Lock()
+		n.forceKilled = append(n.forceKilled, ForceKilledProcess{
+			Job:     process.jobName,
+			Process: process.spec.Name,
+			PID:     cmd.Process.Pid,
+			Signal:  "SIGKILL",
+		})
+		n.lock.Unlock()
+
+		<-exitCh
+	}
+
+	n.setState(process, "stopped")
+}
+
+func (n *nativeJobSupervisor) processExited(process *nativeProcess, event, description string) {
+	n.lock.Lock()
+	process.state = "failing"
+	process.pid = 0
+	monitored := process.monitored
+	n.lock.Unlock()
+
+	if !monitored {
+		return
+	}
+
+	alert := boshalert.MonitAlert{
+		Service:     process.spec.Name,
+		Event:       event,
+		Action:      "restart",
+		Date:        n.timeService.Now().Format(time.RFC1123Z),
+		Description: description,
+	}
+
+	select {
+	case n.alerts <- alert:
+	default:
+		n.logger.Warn(nativeJobSupervisorLogTag, "Dropping failure alert for %s", process.spec.Name)
+	}
+}
+
+func (n *nativeJobSupervisor) setState(process *nativeProcess, state string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	process.state = state
+	process.pid = 0
+}
+
+// startProcesses must be called with the lock held
+func (n *nativeJobSupervisor) startProcesses(names []string) error {
+	for _, name := range names {
+		process := n.processes[name]
+		process.monitored = true
+
+		if process.doneCh != nil {
+			select {
+			case <-process.doneCh:
+			default:
+				continue
+			}
+		}
+
+		err := n.fs.MkdirAll(n.logDir(process.jobName), os.FileMode(0750))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating log directory for process %s", name)
+		}
+
+		process.state = "starting"
+		process.stopCh = make(chan struct{})
+		process.doneCh = make(chan struct{})
+
+		go n.supervise(process)
+	}
+
+	return nil
+}
+
+// stopAll must be called with the lock held
+func (n *nativeJobSupervisor) stopAll() {
+	for _, process := range n.processes {
+		n.stopProcess(process)
+	}
+}
+
+// stopProcess must be called with the lock held
+func (n *nativeJobSupervisor) stopProcess(process *nativeProcess) {
+	process.monitored = false
+
+	if process.stopCh == nil {
+		return
+	}
+
+	select {
+	case <-process.stopCh:
+	default:
+		close(process.stopCh)
+	}
+}
+
+func (n *nativeJobSupervisor) waitForAll() {
+	n.lock.Lock()
+	var doneChs []chan struct{}
+	for _, process := range n.processes {
+		if process.doneCh != nil {
+			doneChs = append(doneChs, process.doneCh)
+		}
+	}
+	n.lock.Unlock()
+
+	for _, doneCh := range doneChs {
+		<-doneCh
+	}
+}
+
+// processNames must be called with the lock held
+func (n *nativeJobSupervisor) processNames() []string {
+	names := make([]string, 0, len(n.processes))
+	for name := range n.processes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// processNamesFor returns the processes of the job with the given name
+// or the process with that name; it must be called with the lock held
+func (n *nativeJobSupervisor) processNamesFor(name string) ([]string, error) {
+	var names []string
+
+	for _, processName := range n.processNames() {
+		if n.processes[processName].jobName == name {
+			names = append(names, processName)
+		}
+	}
+
+	if len(names) > 0 {
+		return names, nil
+	}
+
+	if _, found := n.processes[name]; found {
+		return []string{name}, nil
+	}
+
+	return nil, bosherr.Errorf("Unknown job or service '%s'", name)
+}
+
+func (n *nativeJobSupervisor) residentMemoryKb(pid int) int {
+	contents, err := n.fs.ReadFileString(filepath.Join("/proc", strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0
+	}
+
+	for _, line := range strings.Split(contents, "\n") {
+		if strings.HasPrefix(line, "VmRSS:") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				kb, _ := strconv.Atoi(fields[1])
+				return kb
+			}
+		}
+	}
+
+	return 0
+}
+
+func (n *nativeJobSupervisor) cgroupVitals(group string) *CgroupVitals {
+	usage, err := n.cgroupManager.Usage(group)
+	if err != nil {
+		n.logger.Warn(nativeJobSupervisorLogTag, "Failed to read usage of cgroup %s: %s", group, err.Error())
+		return nil
+	}
+
+	return &CgroupVitals{
+		MemoryKb:     int(usage.MemoryCurrent / 1024),
+		CPUUsageUsec: usage.CPUUsageUsec,
+		Pids:         usage.PidsCurrent,
+	}
+}
+
+func (n *nativeJobSupervisor) logDir(jobName string) string {
+	return filepath.Join(n.dirProvider.LogsDir(), jobName)
+}
+
+func (n *nativeJobSupervisor) stoppedFilePath() string {
+	return filepath.Join(n.dirProvider.BoshDir(), "native_stopped")
+}
+
+func describeExit(err error) string {
+	if err == nil {
+		return "exited with code 0"
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return fmt.Sprintf("killed by signal %s", status.Signal())
+		}
+
+		return fmt.Sprintf("exited with code %d", exitErr.ExitCode())
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,40 @@
+package jobsupervisor
+
+import (
+	"os/user"
+	"strconv"
+	"syscall"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func nativeProcessAttributes(username string) (*syscall.SysProcAttr, error) {
+	attrs := &syscall.SysProcAttr{Setpgid: true}
+
+	if username == "" {
+		return attrs, nil
+	}
+
+	u, err := user.Lookup(username)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Looking up user %s", username)
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing uid of user %s", username)
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing gid of user %s", username)
+	}
+
+	attrs.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+
+	return attrs, nil
+}
+
+func signalProcessGroup(pid int, signal syscall.Signal) error {
+	return syscall.Kill(-pid, signal)
+}
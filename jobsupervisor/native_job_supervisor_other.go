@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package jobsupervisor
+
+import (
+	"syscall"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func nativeProcessAttributes(username string) (*syscall.SysProcAttr, error) {
+	return nil, bosherr.Error("Native job supervision is only supported on Linux")
+}
+
+func signalProcessGroup(pid int, signal syscall.Signal) error {
+	return bosherr.Error("Native job supervision is only supported on Linux")
+}
@@ -0,0 +1,310 @@
+//go:build linux
+// +build linux
+
+package jobsupervisor_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup/cgroupfakes"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("nativeJobSupervisor", func() {
+	var (
+		baseDir       string
+		jobDir        string
+		fs            boshsys.FileSystem
+		dirProvider   boshdir.Provider
+		cgroupManager *cgroupfakes.FakeManager
+		handledAlerts chan boshalert.MonitAlert
+		supervisor    JobSupervisor
+	)
+
+	writeProcessConfig := func(config string) {
+		err := fs.WriteFileString(filepath.Join(jobDir, "processes.json"), config)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	readLog := func(name string) string {
+		contents, _ := fs.ReadFileString(filepath.Join(dirProvider.LogsDir(), "web", name))
+		return contents
+	}
+
+	processAlive := func(pidFile string) func() bool {
+		return func() bool {
+			contents, err := fs.ReadFileString(pidFile)
+			if err != nil {
+				return false
+			}
+
+			pid, err := strconv.Atoi(strings.TrimSpace(contents))
+			if err != nil {
+				return false
+			}
+
+			// Killed children are reparented and may linger as zombies
+			stat, err := fs.ReadFileString(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+			if err != nil {
+				return false
+			}
+
+			fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+			return len(fields) > 0 && fields[0] != "Z"
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		baseDir, err = ioutil.TempDir("", "native-job-supervisor")
+		Expect(err).ToNot(HaveOccurred())
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs = boshsys.NewOsFileSystem(logger)
+		dirProvider = boshdir.NewProvider(baseDir)
+		jobDir = filepath.Join(baseDir, "jobs", "web")
+
+		err = fs.WriteFileString(filepath.Join(jobDir, "monit"), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		handledAlerts = make(chan boshalert.MonitAlert, 10)
+		cgroupManager = &cgroupfakes.FakeManager{}
+
+		supervisor = NewNativeJobSupervisor(
+			fs,
+			dirProvider,
+			cgroupManager,
+			NativeOptions{
+				InitialBackoff:    10 * time.Millisecond,
+				MaxBackoff:        40 * time.Millisecond,
+				BackoffResetAfter: time.Minute,
+				StopTimeout:       200 * time.Millisecond,
+			},
+			clock.NewClock(),
+			logger,
+		)
+
+		go func() {
+			_ = supervisor.MonitorJobFailures(func(alert boshalert.MonitAlert) error {
+				handledAlerts <- alert
+				return nil
+			})
+		}()
+	})
+
+	AfterEach(func() {
+		Expect(supervisor.RemoveAllJobs()).To(Succeed())
+		Expect(os.RemoveAll(baseDir)).To(Succeed())
+	})
+
+	It("runs job processes and captures their output into the job log directory", func() {
+		writeProcessConfig(`{"processes": [{
+			"name": "web",
+			"executable": "/bin/sh",
+			"args": ["-c", "echo \"hello $GREETING\"; echo oops >&2; exec sleep 60"],
+			"env": {"GREETING": "world"}
+		}]}`)
+
+		Expect(supervisor.AddJob("web", "web", 0, filepath.Join(jobDir, "monit"))).To(Succeed())
+		Expect(supervisor.Start()).To(Succeed())
+
+		Eventually(supervisor.Status).Should(Equal("running"))
+		Eventually(func() string { return readLog("web.stdout.log") }).Should(Equal("hello world\n"))
+		Eventually(func() string { return readLog("web.stderr.log") }).Should(Equal("oops\n"))
+
+		processes, err := supervisor.Processes()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(processes).To(HaveLen(1))
+		Expect(processes[0].Name).To(Equal("web"))
+		Expect(processes[0].State).To(Equal("running"))
+	})
+
+	Context("when the job has resource limits", func() {
+		BeforeEach(func() {
+			writeProcessConfig(`{"processes": [{"name": "web", "executable": "/bin/sleep", "args": ["60"]}]}`)
+		})
+
+		It("configures the job's cgroup and places started processes in it", func() {
+			cgroupManager.UsageReturns(cgroup.Usage{MemoryCurrent: 4096, CPUUsageUsec: 20, PidsCurrent: 1}, nil)
+
+			Expect(supervisor.SetResourceLimits("web", cgroup.Limits{MemoryMax: "64M"})).To(Succeed())
+			Expect(supervisor.AddJob("web", "web", 0, filepath.Join(jobDir, "monit"))).To(Succeed())
+			Expect(supervisor.Start()).To(Succeed())
+
+			Eventually(supervisor.Status).Should(Equal("running"))
+
+			name, limits := cgroupManager.ConfigureArgsForCall(0)
+			Expect(name).To(Equal("web"))
+			Expect(limits).To(Equal(cgroup.Limits{MemoryMax: "64M"}))
+
+			Expect(cgroupManager.AddProcessCallCount()).To(Equal(1))
+			name, pid := cgroupManager.AddProcessArgsForCall(0)
+			Expect(name).To(Equal("web"))
+			Expect(pid).To(BeNumerically(">", 0))
+
+			processes, err := supervisor.Processes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processes[0].Cgroup).To(Equal(&CgroupVitals{MemoryKb: 4, CPUUsageUsec: 20, Pids: 1}))
+		})
+
+		It("does not run processes that cannot be placed in the cgroup", func() {
+			cgroupManager.AddProcessReturns(errors.New("fake-add-error"))
+
+			Expect(supervisor.SetResourceLimits("web", cgroup.Limits{PidsMax: 10})).To(Succeed())
+			Expect(supervisor.AddJob("web", "web", 0, filepath.Join(jobDir, "monit"))).To(Succeed())
+			Expect(supervisor.Start()).To(Succeed())
+
+			Consistently(supervisor.Status, 100*time.Millisecond).ShouldNot(Equal("running"))
+		})
+
+		It("returns an error when the cgroup cannot be configured", func() {
+			cgroupManager.ConfigureReturns(errors.New("fake-configure-error"))
+
+			err := supervisor.SetResourceLimits("web", cgroup.Limits{PidsMax: 10})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-configure-error"))
+		})
+
+		It("ignores empty limits", func() {
+			Expect(supervisor.SetResourceLimits("web", cgroup.Limits{})).To(Succeed())
+			Expect(cgroupManager.ConfigureCallCount()).To(Equal(0))
+		})
+	})
+
+	It("restarts crashed processes with backoff and reports the crash", func() {
+		writeProcessConfig(`{"processes": [{
+			"name": "web",
+			"executable": "/bin/sh",
+			"args": ["-c", "echo started; exit 3"]
+		}]}`)
+
+		Expect(supervisor.AddJob("web", "web", 0, filepath.Join(jobDir, "monit"))).To(Succeed())
+		Expect(supervisor.Start()).To(Succeed())
+
+		var alert boshalert.MonitAlert
+		Eventually(handledAlerts).Should(Receive(&alert))
+		Expect(alert.Service).To(Equal("web"))
+		Expect(alert.Event).To(Equal("does not exist"))
+		Expect(alert.Action).To(Equal("restart"))
+		Expect(alert.Description).To(Equal("exited with code 3"))
+
+		Eventually(func() int { return strings.Count(readLog("web.stdout.log"), "started") }).Should(BeNumerically(">=", 3))
+		Expect(supervisor.Status()).ToNot(Equal("running"))
+	})
+
+	It("stops the whole process group", func() {
+		childPidFile := filepath.Join(baseDir, "child.pid")
+
+		writeProcessConfig(`{"processes": [{
+			"name": "web",
+			"executable": "/bin/sh",
+			"args": ["-c", "sleep 60 & echo $! > ` + childPidFile + `; wait"]
+		}]}`)
+
+		Expect(supervisor.AddJob("web", "web", 0, filepath.Join(jobDir, "monit"))).To(Succeed())
+		Expect(supervisor.Start()).To(Succeed())
+		Eventually(processAlive(childPidFile)).Should(BeTrue())
+
+		result, err := supervisor.StopAndWait()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ForceKilled).To(BeEmpty())
+
+		Eventually(processAlive(childPidFile)).Should(BeFalse())
+		Expect(supervisor.Status()).To(Equal("stopped"))
+		Consistently(handledAlerts).ShouldNot(Receive())
+	})
+
+	It("kills processes that ignore SIGTERM after the stop timeout", func() {
+		writeProcessConfig(`{"processes": [{
+			"name": "web",
+			"executable": "/bin/sh",
+			"args": ["-c", "trap '' TERM; echo ready; while true; do sleep 0.1; done"]
+		}]}`)
+
+		Expect(supervisor.AddJob("web", "web", 0, filepath.Join(jobDir, "monit"))).To(Succeed())
+		Expect(supervisor.Start()).To(Succeed())
+		Eventually(func() string { return readLog("web.stdout.log") }).Should(Equal("ready\n"))
+
+		stopped := make(chan StopResult)
+		go func() {
+			defer GinkgoRecover()
+			result, err := supervisor.StopAndWait()
+			Expect(err).ToNot(HaveOccurred())
+			stopped <- result
+		}()
+
+		Consistently(stopped, 100*time.Millisecond).ShouldNot(Receive())
+
+		var result StopResult
+		Eventually(stopped).Should(Receive(&result))
+		Expect(result.ForceKilled).To(HaveLen(1))
+		Expect(result.ForceKilled[0].Job).To(Equal("web"))
+		Expect(result.ForceKilled[0].Process).To(Equal("web"))
+		Expect(result.ForceKilled[0].Signal).To(Equal("SIGKILL"))
+	})
+
+	It("starts and stops the processes of a single job", func() {
+		writeProcessConfig(`{"processes": [
+			{"name": "web", "executable": "/bin/sleep", "args": ["60"]},
+			{"name": "web-worker", "executable": "/bin/sleep", "args": ["60"]}
+		]}`)
+
+		Expect(supervisor.AddJob("web", "web", 0, filepath.Join(jobDir, "monit"))).To(Succeed())
+		Expect(supervisor.Start()).To(Succeed())
+		Eventually(supervisor.Status).Should(Equal("running"))
+
+		Expect(supervisor.StopService("web-worker")).To(Succeed())
+		processStates := func() map[string]string {
+			processes, err := supervisor.Processes()
+			Expect(err).ToNot(HaveOccurred())
+
+			states := map[string]string{}
+			for _, process := range processes {
+				states[process.Name] = process.State
+			}
+			return states
+		}
+		Expect(processStates()).To(Equal(map[string]string{"web": "running", "web-worker": "stopped"}))
+
+		Expect(supervisor.StopService("web")).To(Succeed())
+		Expect(processStates()).To(Equal(map[string]string{"web": "stopped", "web-worker": "stopped"}))
+
+		Expect(supervisor.StartService("web")).To(Succeed())
+		Eventually(processStates).Should(Equal(map[string]string{"web": "running", "web-worker": "running"}))
+
+		err := supervisor.StartService("unknown")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unknown job or service 'unknown'"))
+	})
+
+	It("ignores jobs without a process config", func() {
+		Expect(supervisor.AddJob("web", "web", 0, filepath.Join(jobDir, "monit"))).To(Succeed())
+
+		processes, err := supervisor.Processes()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(processes).To(BeEmpty())
+	})
+
+	It("returns an error for processes without an executable", func() {
+		writeProcessConfig(`{"processes": [{"name": "web"}]}`)
+
+		err := supervisor.AddJob("web", "web", 0, filepath.Join(jobDir, "monit"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("must have a name and an executable"))
+	})
+})
@@ -0,0 +1,39 @@
+package jobsupervisor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// signalJobProcess signals the process group of a job process so that its
+// children are signalled too, unless the process shares the agent's group
+func signalJobProcess(pid int, signal syscall.Signal) error {
+	pgid, err := syscall.Getpgid(pid)
+	if err == nil && pgid > 1 && pgid != syscall.Getpgrp() {
+		return syscall.Kill(-pgid, signal)
+	}
+
+	return syscall.Kill(pid, signal)
+}
+
+// processStartTime returns the start time of a running process (field 22
+// of /proc/<pid>/stat) which tells a reused pid apart from the original
+// process. Exited processes including zombies that have not been reaped
+// by their parent yet are not running.
+func processStartTime(pid int) (string, bool) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", false
+	}
+
+	// Fields following the command name which may contain spaces and parens
+	fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+
+	if len(fields) < 20 || fields[0] == "Z" || fields[0] == "X" {
+		return "", false
+	}
+
+	return fields[19], true
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package jobsupervisor
+
+import (
+	"syscall"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func signalJobProcess(pid int, signal syscall.Signal) error {
+	return bosherr.Error("Signalling job processes is only supported on Linux")
+}
+
+func processStartTime(pid int) (string, bool) {
+	return "", false
+}
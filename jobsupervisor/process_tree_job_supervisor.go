@@ -0,0 +1,298 @@
+package jobsupervisor
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	processTreeJobSupervisorLogTag = "processTreeJobSupervisor"
+
+	procDir = "/proc"
+
+	// USER_HZ, the unit of the start time in /proc/<pid>/stat. It is what
+	// sysconf(_SC_CLK_TCK) returns, which needs cgo; the kernel fixes it at
+	// 100 on all architectures the agent supports (gosigar assumes the same).
+	procClockTicksPerSecond = 100
+)
+
+type procStat struct {
+	pid        int
+	ppid       int
+	name       string
+	state      string
+	threads    int
+	startTicks uint64
+}
+
+type processTreeJobSupervisor struct {
+	delegate JobSupervisor
+	fs       boshsys.FileSystem
+	logger   boshlog.Logger
+
+	lock     sync.Mutex
+	restarts map[string]int
+}
+
+// NewProcessTreeJobSupervisor adds the parent, descendants, open file
+// descriptors, threads and start time read from /proc to every process
+// the delegate reports a PID for. It also counts the restarts the
+// delegate alerts about until all jobs are removed.
+func NewProcessTreeJobSupervisor(
+	delegate JobSupervisor,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+) JobSupervisor {
+	return &processTreeJobSupervisor{
+		delegate: delegate,
+		fs:       fs,
+		logger:   logger,
+		restarts: map[string]int{},
+	}
+}
+
+func (p *processTreeJobSupervisor) Reload() error {
+	return p.delegate.Reload()
+}
+
+func (p *processTreeJobSupervisor) Start() error {
+	return p.delegate.Start()
+}
+
+func (p *processTreeJobSupervisor) Stop() error {
+	return p.delegate.Stop()
+}
+
+func (p *processTreeJobSupervisor) StopAndWait() (StopResult, error) {
+	return p.delegate.StopAndWait()
+}
+
+func (p *processTreeJobSupervisor) Unmonitor() error {
+	return p.delegate.Unmonitor()
+}
+
+func (p *processTreeJobSupervisor) StartService(name string) error {
+	return p.delegate.StartService(name)
+}
+
+func (p *processTreeJobSupervisor) StopService(name string) error {
+	return p.delegate.StopService(name)
+}
+
+func (p *processTreeJobSupervisor) Status() string {
+	return p.delegate.Status()
+}
+
+// Processes reads /proc once per call. Processes that exited since the
+// delegate reported them keep the details of the delegate only.
+func (p *processTreeJobSupervisor) Processes() ([]Process, error) {
+	processes, err := p.delegate.Processes()
+	if err != nil {
+		return processes, err
+	}
+
+	p.lock.Lock()
+	for i := range processes {
+		processes[i].Restarts = p.restarts[processes[i].Name]
+	}
+	p.lock.Unlock()
+
+	if !p.anyRunning(processes) {
+		return processes, nil
+	}
+
+	stats, children := p.procStats()
+	bootTime := p.bootTime()
+
+	for i, process := range processes {
+		stat, found := stats[process.PID]
+		if process.PID <= 0 || !found {
+			continue
+		}
+
+		processes[i].ParentPID = stat.ppid
+		processes[i].Threads = stat.threads
+		processes[i].FDs = p.openFDs(stat.pid)
+		processes[i].Children = p.childProcesses(stat.pid, children, map[int]bool{stat.pid: true})
+
+		if bootTime > 0 {
+			processes[i].StartTime = bootTime + int64(stat.startTicks/procClockTicksPerSecond)
+		}
+	}
+
+	return processes, nil
+}
+
+func (p *processTreeJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	return p.delegate.AddJob(jobName, configName, jobIndex, configPath)
+}
+
+func (p *processTreeJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	return p.delegate.SetResourceLimits(jobName, limits)
+}
+
+func (p *processTreeJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return p.delegate.SetStartDependencies(jobName, dependencies)
+}
+
+func (p *processTreeJobSupervisor) RemoveAllJobs() error {
+	p.lock.Lock()
+	p.restarts = map[string]int{}
+	p.lock.Unlock()
+
+	return p.delegate.RemoveAllJobs()
+}
+
+func (p *processTreeJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	return p.delegate.MonitorJobFailures(func(alert boshalert.MonitAlert) error {
+		if strings.EqualFold(alert.Action, "restart") {
+			p.lock.Lock()
+			p.restarts[alert.Service]++
+			p.lock.Unlock()
+		}
+
+		return handler(alert)
+	})
+}
+
+func (p *processTreeJobSupervisor) HealthRecorder(status string) {
+	p.delegate.HealthRecorder(status)
+}
+
+func (p *processTreeJobSupervisor) anyRunning(processes []Process) bool {
+	for _, process := range processes {
+		if process.PID > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// procStats reads the stat of every process and groups
+// the processes by the PID of their parent
+func (p *processTreeJobSupervisor) procStats() (map[int]procStat, map[int][]procStat) {
+	stats := map[int]procStat{}
+	children := map[int][]procStat{}
+
+	paths, err := p.fs.Glob(filepath.Join(procDir, "[0-9]*", "stat"))
+	if err != nil {
+		p.logger.Warn(processTreeJobSupervisorLogTag, "Failed to list processes: %s", err.Error())
+		return stats, children
+	}
+
+	for _, path := range paths {
+		contents, err := p.fs.ReadFileString(path)
+		if err != nil {
+			// The process exited after it was listed
+			continue
+		}
+
+		stat, err := parseProcStat(contents)
+		if err != nil {
+			p.logger.Debug(processTreeJobSupervisorLogTag, "Failed to parse %s: %s", path, err.Error())
+			continue
+		}
+
+		stats[stat.pid] = stat
+		children[stat.ppid] = append(children[stat.ppid], stat)
+	}
+
+	for ppid := range children {
+		sort.Slice(children[ppid], func(i, j int) bool { return children[ppid][i].pid < children[ppid][j].pid })
+	}
+
+	return stats, children
+}
+
+func (p *processTreeJobSupervisor) childProcesses(pid int, children map[int][]procStat, visited map[int]bool) []ChildProcess {
+	var result []ChildProcess
+
+	for _, child := range children[pid] {
+		if visited[child.pid] {
+			continue
+		}
+		visited[child.pid] = true
+
+		result = append(result, ChildProcess{
+			PID:      child.pid,
+			Name:     child.name,
+			State:    child.state,
+			Threads:  child.threads,
+			Children: p.childProcesses(child.pid, children, visited),
+		})
+	}
+
+	return result
+}
+
+func (p *processTreeJobSupervisor) openFDs(pid int) int {
+	fds, err := p.fs.Glob(filepath.Join(procDir, strconv.Itoa(pid), "fd", "*"))
+	if err != nil {
+		p.logger.Debug(processTreeJobSupervisorLogTag, "Failed to list file descriptors of process %d: %s", pid, err.Error())
+		return 0
+	}
+
+	return len(fds)
+}
+
+// bootTime returns the boot time in seconds since the epoch
+func (p *processTreeJobSupervisor) bootTime() int64 {
+	contents, err := p.fs.ReadFileString(filepath.Join(procDir, "stat"))
+	if err != nil {
+		p.logger.Debug(processTreeJobSupervisorLogTag, "Failed to read boot time: %s", err.Error())
+		return 0
+	}
+
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			bootTime, _ := strconv.ParseInt(fields[1], 10, 64)
+			return bootTime
+		}
+	}
+
+	return 0
+}
+
+// parseProcStat parses /proc/<pid>/stat. The command name is enclosed in
+// parentheses and may itself contain spaces and parentheses.
+func parseProcStat(contents string) (procStat, error) {
+	open := strings.Index(contents, "(")
+	end := strings.LastIndex(contents, ")")
+	if open < 0 || end < open {
+		return procStat{}, bosherr.Error("Missing command name")
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(contents[:open]))
+	if err != nil {
+		return procStat{}, bosherr.WrapError(err, "Parsing pid")
+	}
+
+	// Fields following the command name start with the state (field 3)
+	fields := strings.Fields(contents[end+1:])
+	if len(fields) < 20 {
+		return procStat{}, bosherr.Errorf("Expected at least 22 fields, got %d", len(fields)+2)
+	}
+
+	stat := procStat{pid: pid, name: contents[open+1 : end], state: fields[0]}
+
+	stat.ppid, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return procStat{}, bosherr.WrapError(err, "Parsing parent pid")
+	}
+
+	stat.threads, _ = strconv.Atoi(fields[17])
+	stat.startTicks, _ = strconv.ParseUint(fields[19], 10, 64)
+
+	return stat, nil
+}
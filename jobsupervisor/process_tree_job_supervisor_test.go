@@ -0,0 +1,116 @@
+package jobsupervisor_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("processTreeJobSupervisor", func() {
+	var (
+		fs             *fakesys.FakeFileSystem
+		fakeSupervisor *alertingJobSupervisor
+		supervisor     JobSupervisor
+	)
+
+	writeStat := func(pid, name, state string, ppid string, threads string, startTicks string) {
+		Expect(fs.WriteFileString("/proc/"+pid+"/stat",
+			pid+" ("+name+") "+state+" "+ppid+" 100 100 0 -1 4194304 500 0 0 0 10 5 0 0 20 0 "+threads+" 0 "+startTicks+" 1000000 200\n",
+		)).To(Succeed())
+	}
+
+	processes := func() []Process {
+		processes, err := supervisor.Processes()
+		Expect(err).ToNot(HaveOccurred())
+		return processes
+	}
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		fakeSupervisor = &alertingJobSupervisor{FakeJobSupervisor: fakes.NewFakeJobSupervisor()}
+		fakeSupervisor.ProcessesStatus = []Process{
+			{Name: "web", State: "running", PID: 100},
+			{Name: "worker", State: "unknown"},
+		}
+
+		Expect(fs.WriteFileString("/proc/stat", "cpu  1 2 3 4\nbtime 1700000000\nprocesses 300\n")).To(Succeed())
+		writeStat("1", "init", "S", "0", "1", "1")
+		writeStat("100", "web server", "S", "1", "4", "50000")
+		writeStat("101", "(worker)", "S", "100", "2", "50100")
+		writeStat("102", "sh", "Z", "100", "1", "50200")
+		writeStat("103", "tail", "R", "101", "1", "50300")
+
+		fs.SetGlob("/proc/[0-9]*/stat", []string{"/proc/1/stat", "/proc/100/stat", "/proc/101/stat", "/proc/102/stat", "/proc/103/stat"})
+		fs.SetGlob("/proc/100/fd/*", []string{"/proc/100/fd/0", "/proc/100/fd/1", "/proc/100/fd/2"})
+
+		supervisor = NewProcessTreeJobSupervisor(fakeSupervisor, fs, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	It("adds the details read from /proc to running processes", func() {
+		Expect(processes()).To(Equal([]Process{
+			{
+				Name:      "web",
+				State:     "running",
+				PID:       100,
+				ParentPID: 1,
+				Threads:   4,
+				FDs:       3,
+				StartTime: 1700000500,
+				Children: []ChildProcess{
+					{
+						PID:      101,
+						Name:     "(worker)",
+						State:    "S",
+						Threads:  2,
+						Children: []ChildProcess{{PID: 103, Name: "tail", State: "R", Threads: 1}},
+					},
+					{PID: 102, Name: "sh", State: "Z", Threads: 1},
+				},
+			},
+			{Name: "worker", State: "unknown"},
+		}))
+	})
+
+	It("keeps the details of the delegate for processes that exited", func() {
+		fakeSupervisor.ProcessesStatus[0].PID = 200
+
+		Expect(processes()).To(Equal([]Process{
+			{Name: "web", State: "running", PID: 200},
+			{Name: "worker", State: "unknown"},
+		}))
+	})
+
+	It("counts the restarts the delegate alerts about until all jobs are removed", func() {
+		var handledAlerts []boshalert.MonitAlert
+		err := supervisor.MonitorJobFailures(func(alert boshalert.MonitAlert) error {
+			handledAlerts = append(handledAlerts, alert)
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeSupervisor.handler(boshalert.MonitAlert{Service: "worker", Action: "restart"})).To(Succeed())
+		Expect(fakeSupervisor.handler(boshalert.MonitAlert{Service: "worker", Action: "Restart"})).To(Succeed())
+		Expect(fakeSupervisor.handler(boshalert.MonitAlert{Service: "worker", Action: "alert"})).To(Succeed())
+
+		Expect(handledAlerts).To(HaveLen(3))
+		Expect(processes()[0].Restarts).To(Equal(0))
+		Expect(processes()[1].Restarts).To(Equal(2))
+
+		Expect(supervisor.RemoveAllJobs()).To(Succeed())
+		Expect(processes()[1].Restarts).To(Equal(0))
+	})
+
+	It("returns the error of the delegate", func() {
+		fakeSupervisor.ProcessesError = errors.New("fake-processes-error")
+
+		_, err := supervisor.Processes()
+		Expect(err).To(MatchError("fake-processes-error"))
+	})
+})
@@ -9,6 +9,7 @@ import (
 	"code.cloudfoundry.org/clock"
 
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 	boshmonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -16,7 +17,10 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
-const jobSupervisorListenPort = 2825
+const (
+	jobSupervisorListenPort       = 2825
+	jobSupervisorAlertsListenPort = 2826
+)
 
 type Provider struct {
 	supervisors map[string]JobSupervisor
@@ -39,6 +43,7 @@ func NewProvider(
 		logger,
 		dirProvider,
 		jobSupervisorListenPort,
+		jobSupervisorAlertsListenPort,
 		MonitReloadOptions{
 			MaxTries:               3,
 			MaxCheckTries:          10,
@@ -47,8 +52,139 @@ func NewProvider(
 		timeService,
 	)
 
+	cgroupManager := cgroup.NewManager(fs, "/sys/fs/cgroup/bosh")
+
+	cgroupJobSupervisor := NewCgroupJobSupervisor(
+		NewProcessTreeJobSupervisor(monitJobSupervisor, fs, logger),
+		cgroupManager,
+		fs,
+		dirProvider,
+		CgroupOptions{
+			PlacementInterval: 1 * time.Second,
+		},
+		timeService,
+		logger,
+	)
+
+	stopDeadlineJobSupervisor := NewStopDeadlineJobSupervisor(
+		cgroupJobSupervisor,
+		fs,
+		StopDeadlineOptions{
+			DefaultStopTimeout: 3 * time.Minute,
+			KillGracePeriod:    30 * time.Second,
+		},
+		timeService,
+		logger,
+	)
+
+	healthProbingJobSupervisor := NewHealthProbingJobSupervisor(
+		stopDeadlineJobSupervisor,
+		fs,
+		runner,
+		dirProvider,
+		HealthProbeOptions{
+			Interval: 30 * time.Second,
+			Timeout:  10 * time.Second,
+		},
+		timeService,
+		logger,
+	)
+
+	systemdJobSupervisor := NewHealthProbingJobSupervisor(
+		NewProcessTreeJobSupervisor(
+			NewSystemdJobSupervisor(
+				fs,
+				runner,
+				dirProvider,
+				SystemdOptions{
+					UnitsDir:        "/etc/systemd/system",
+					RuntimeUnitsDir: "/run/systemd/system",
+					Slice:           "bosh.slice",
+					PollInterval:    5 * time.Second,
+				},
+				timeService,
+				logger,
+			),
+			fs,
+			logger,
+		),
+		fs,
+		runner,
+		dirProvider,
+		HealthProbeOptions{
+			Interval: 30 * time.Second,
+			Timeout:  10 * time.Second,
+		},
+		timeService,
+		logger,
+	)
+
+	nativeJobSupervisor := NewHealthProbingJobSupervisor(
+		NewProcessTreeJobSupervisor(
+			NewNativeJobSupervisor(
+				fs,
+				dirProvider,
+				cgroupManager,
+				NativeOptions{
+					InitialBackoff:    1 * time.Second,
+					MaxBackoff:        1 * time.Minute,
+					BackoffResetAfter: 5 * time.Minute,
+					StopTimeout:       30 * time.Second,
+				},
+				timeService,
+				logger,
+			),
+			fs,
+			logger,
+		),
+		fs,
+		runner,
+		dirProvider,
+		HealthProbeOptions{
+			Interval: 30 * time.Second,
+			Timeout:  10 * time.Second,
+		},
+		timeService,
+		logger,
+	)
+
+	crashLoopProtected := func(delegate JobSupervisor) JobSupervisor {
+		return NewCrashLoopJobSupervisor(
+			delegate,
+			fs,
+			CrashLoopOptions{
+				Window:         10 * time.Minute,
+				Threshold:      5,
+				InitialBackoff: 30 * time.Second,
+				MaxBackoff:     5 * time.Minute,
+				MaxBackoffs:    3,
+			},
+			timeService,
+			logger,
+		)
+	}
+
+	// Jobs are started in order on top of the crash loop protection,
+	// waiting for dependencies to pass the probes of the health probing layer
+	decorated := func(delegate HealthProbingJobSupervisor) JobSupervisor {
+		return NewStartOrderJobSupervisor(
+			crashLoopProtected(delegate),
+			delegate,
+			fs,
+			StartOrderOptions{
+				ReadyTimeout: 5 * time.Minute,
+				StopTimeout:  2 * time.Minute,
+				PollInterval: 1 * time.Second,
+			},
+			timeService,
+			logger,
+		)
+	}
+
 	p.supervisors = map[string]JobSupervisor{
-		"monit":      NewWrapperJobSupervisor(monitJobSupervisor, fs, dirProvider, logger),
+		"monit":      NewWrapperJobSupervisor(decorated(healthProbingJobSupervisor), fs, dirProvider, logger),
+		"systemd":    NewWrapperJobSupervisor(decorated(systemdJobSupervisor), fs, dirProvider, logger),
+		"native":     NewWrapperJobSupervisor(decorated(nativeJobSupervisor), fs, dirProvider, logger),
 		"dummy":      NewDummyJobSupervisor(),
 		"dummy-nats": NewDummyNatsJobSupervisor(handler),
 	}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -36,6 +37,33 @@ func init() {
 			jobSupervisorName     string
 		)
 
+		decorated := func(delegate HealthProbingJobSupervisor) JobSupervisor {
+			return NewStartOrderJobSupervisor(
+				NewCrashLoopJobSupervisor(
+					delegate,
+					fileSystem,
+					CrashLoopOptions{
+						Window:         10 * time.Minute,
+						Threshold:      5,
+						InitialBackoff: 30 * time.Second,
+						MaxBackoff:     5 * time.Minute,
+						MaxBackoffs:    3,
+					},
+					timeService,
+					logger,
+				),
+				delegate,
+				fileSystem,
+				StartOrderOptions{
+					ReadyTimeout: 5 * time.Minute,
+					StopTimeout:  2 * time.Minute,
+					PollInterval: 1 * time.Second,
+				},
+				timeService,
+				logger,
+			)
+		}
+
 		BeforeEach(func() {
 			platform = &platformfakes.FakePlatform{}
 			client = fakemonit.NewFakeMonitClient()
@@ -77,6 +105,7 @@ func init() {
 					logger,
 					dirProvider,
 					jobFailuresServerPort,
+					2826,
 					MonitReloadOptions{
 						MaxTries:               3,
 						MaxCheckTries:          10,
@@ -85,8 +114,44 @@ func init() {
 					timeService,
 				)
 
+				cgroupSupervisor := NewCgroupJobSupervisor(
+					NewProcessTreeJobSupervisor(delegateSupervisor, fileSystem, logger),
+					cgroup.NewManager(fileSystem, "/sys/fs/cgroup/bosh"),
+					fileSystem,
+					dirProvider,
+					CgroupOptions{
+						PlacementInterval: 1 * time.Second,
+					},
+					timeService,
+					logger,
+				)
+
+				stopDeadlineSupervisor := NewStopDeadlineJobSupervisor(
+					cgroupSupervisor,
+					fileSystem,
+					StopDeadlineOptions{
+						DefaultStopTimeout: 3 * time.Minute,
+						KillGracePeriod:    30 * time.Second,
+					},
+					timeService,
+					logger,
+				)
+
+				healthProbingSupervisor := NewHealthProbingJobSupervisor(
+					stopDeadlineSupervisor,
+					fileSystem,
+					cmdRunner,
+					dirProvider,
+					HealthProbeOptions{
+						Interval: 30 * time.Second,
+						Timeout:  10 * time.Second,
+					},
+					timeService,
+					logger,
+				)
+
 				expectedSupervisor := NewWrapperJobSupervisor(
-					delegateSupervisor,
+					decorated(healthProbingSupervisor),
 					fileSystem,
 					dirProvider,
 					logger,
@@ -96,6 +161,60 @@ func init() {
 			}
 		})
 
+		It("provides a systemd job supervisor", func() {
+			if runtime.GOOS == "windows" {
+				Skip("systemd is only available on Linux")
+			}
+
+			actualSupervisor, err := provider.Get("systemd")
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedSupervisor := NewWrapperJobSupervisor(
+				decorated(NewHealthProbingJobSupervisor(
+					NewProcessTreeJobSupervisor(
+						NewSystemdJobSupervisor(
+							fileSystem,
+							cmdRunner,
+							dirProvider,
+							SystemdOptions{
+								UnitsDir:        "/etc/systemd/system",
+								RuntimeUnitsDir: "/run/systemd/system",
+								Slice:           "bosh.slice",
+								PollInterval:    5 * time.Second,
+							},
+							timeService,
+							logger,
+						),
+						fileSystem,
+						logger,
+					),
+					fileSystem,
+					cmdRunner,
+					dirProvider,
+					HealthProbeOptions{
+						Interval: 30 * time.Second,
+						Timeout:  10 * time.Second,
+					},
+					timeService,
+					logger,
+				)),
+				fileSystem,
+				dirProvider,
+				logger,
+			)
+			Expect(actualSupervisor).To(Equal(expectedSupervisor))
+		})
+
+		It("provides a native job supervisor", func() {
+			if runtime.GOOS == "windows" {
+				Skip("native job supervision is only available on Linux")
+			}
+
+			actualSupervisor, err := provider.Get("native")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actualSupervisor).ToNot(BeNil())
+		})
+
 		It("provides a dummy job supervisor", func() {
 			actualSupervisor, err := provider.Get("dummy")
 			Expect(err).ToNot(HaveOccurred())
@@ -0,0 +1,400 @@
+package jobsupervisor
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const startOrderJobSupervisorLogTag = "startOrderJobSupervisor"
+
+type StartOrderOptions struct {
+	// Time a job waits for its dependencies to be running
+	// and to pass their health probes before it fails to start
+	ReadyTimeout time.Duration
+
+	// Time a dependency waits for the jobs depending on it to stop
+	StopTimeout time.Duration
+
+	// Time between two checks whether a job is running or stopped
+	PollInterval time.Duration
+}
+
+type startOrderJobSupervisor struct {
+	delegate    JobSupervisor
+	prober      JobHealthProber
+	fs          boshsys.FileSystem
+	options     StartOrderOptions
+	timeService clock.Clock
+	logger      boshlog.Logger
+
+	lock         sync.RWMutex
+	jobs         []string
+	dependencies map[string][]string
+	services     map[string]string
+}
+
+// NewStartOrderJobSupervisor starts jobs after the jobs they depend on.
+// Each dependency has to be running and pass its optional bin/health probe,
+// which is run by the prober, before its required are started. Jobs are
+// stopped in reverse order. Without any dependencies all jobs are started
+// and stopped at once by the delegate.
+func NewStartOrderJobSupervisor(
+	delegate JobSupervisor,
+	prober JobHealthProber,
+	fs boshsys.FileSystem,
+	options StartOrderOptions,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) JobSupervisor {
+	return &startOrderJobSupervisor{
+		delegate:     delegate,
+		prober:       prober,
+		fs:           fs,
+		options:      options,
+		timeService:  timeService,
+		logger:       logger,
+		dependencies: map[string][]string{},
+		services:     map[string]string{},
+	}
+}
+
+func (s *startOrderJobSupervisor) Reload() error {
+	return s.delegate.Reload()
+}
+
+// Start starts the jobs one by one and lets the delegate start the
+// remaining services afterwards
+func (s *startOrderJobSupervisor) Start() error {
+	order, required, err := s.startOrder()
+	if err != nil {
+		return err
+	}
+
+	for _, job := range order {
+		s.logger.Debug(startOrderJobSupervisorLogTag, "Starting job %s", job)
+
+		err = s.delegate.StartService(job)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Starting job %s", job)
+		}
+
+		if required[job] {
+			err = s.waitUntilReady(job)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.delegate.Start()
+}
+
+func (s *startOrderJobSupervisor) Stop() error {
+	order, _, err := s.startOrder()
+	if err != nil {
+		return err
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		err = s.delegate.StopService(order[i])
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Stopping job %s", order[i])
+		}
+	}
+
+	return s.delegate.Stop()
+}
+
+// StopAndWait waits for every job that depends on other jobs to stop before
+// stopping its dependencies. Jobs that do not stop in time are left to the
+// delegate, which is asked to stop all jobs at the end.
+func (s *startOrderJobSupervisor) StopAndWait() (StopResult, error) {
+	order, _, err := s.startOrder()
+	if err != nil {
+		return StopResult{}, err
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		job := order[i]
+
+		s.logger.Debug(startOrderJobSupervisorLogTag, "Stopping job %s", job)
+
+		err = s.delegate.StopService(job)
+		if err != nil {
+			return StopResult{}, bosherr.WrapErrorf(err, "Stopping job %s", job)
+		}
+
+		if len(s.dependenciesOf(job)) > 0 {
+			s.waitUntilStopped(job)
+		}
+	}
+
+	return s.delegate.StopAndWait()
+}
+
+func (s *startOrderJobSupervisor) Unmonitor() error {
+	return s.delegate.Unmonitor()
+}
+
+func (s *startOrderJobSupervisor) StartService(name string) error {
+	return s.delegate.StartService(name)
+}
+
+func (s *startOrderJobSupervisor) StopService(name string) error {
+	return s.delegate.StopService(name)
+}
+
+func (s *startOrderJobSupervisor) Status() string {
+	return s.delegate.Status()
+}
+
+func (s *startOrderJobSupervisor) Processes() ([]Process, error) {
+	return s.delegate.Processes()
+}
+
+// AddJob remembers which job every process check belongs to. Jobs
+// without process checks are never started or stopped one by one.
+func (s *startOrderJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	err := s.delegate.AddJob(jobName, configName, jobIndex, configPath)
+	if err != nil {
+		return err
+	}
+
+	config, err := s.fs.ReadFileString(configPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading job config from file")
+	}
+
+	processes, err := parseMonitProcesses(config)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing job config %s", configPath)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, process := range processes {
+		s.services[process.Name] = jobName
+	}
+
+	return nil
+}
+
+func (s *startOrderJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	return s.delegate.SetResourceLimits(jobName, limits)
+}
+
+func (s *startOrderJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.dependencies[jobName]; !found {
+		s.jobs = append(s.jobs, jobName)
+	}
+
+	s.dependencies[jobName] = dependencies
+
+	return s.delegate.SetStartDependencies(jobName, dependencies)
+}
+
+func (s *startOrderJobSupervisor) RemoveAllJobs() error {
+	s.lock.Lock()
+	s.jobs = nil
+	s.dependencies = map[string][]string{}
+	s.services = map[string]string{}
+	s.lock.Unlock()
+
+	return s.delegate.RemoveAllJobs()
+}
+
+func (s *startOrderJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	return s.delegate.MonitorJobFailures(handler)
+}
+
+func (s *startOrderJobSupervisor) HealthRecorder(status string) {
+	s.delegate.HealthRecorder(status)
+}
+
+// startOrder sorts the jobs topologically, keeping the order in which they
+// were added among jobs that do not depend on each other. It returns no jobs
+// when no job has dependencies. Dependencies on jobs that are not colocated
+// are ignored. Jobs without services, which have nothing to start, are
+// left out of the order.
+func (s *startOrderJobSupervisor) startOrder() ([]string, map[string]bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	required := map[string]bool{}
+	pending := map[string]int{}
+
+	for _, job := range s.jobs {
+		for _, dependency := range s.dependencies[job] {
+			if _, found := s.dependencies[dependency]; !found {
+				s.logger.Warn(startOrderJobSupervisorLogTag, "Ignoring dependency of job %s on job %s which is not colocated", job, dependency)
+				continue
+			}
+
+			required[dependency] = true
+			pending[job]++
+		}
+	}
+
+	if len(required) == 0 {
+		return nil, required, nil
+	}
+
+	var order []string
+
+	started := map[string]bool{}
+
+	for len(order) < len(s.jobs) {
+		progressed := false
+
+		for _, job := range s.jobs {
+			if started[job] || pending[job] > 0 {
+				continue
+			}
+
+			order = append(order, job)
+			started[job] = true
+			progressed = true
+
+			for _, other := range s.jobs {
+				for _, dependency := range s.dependencies[other] {
+					if dependency == job {
+						pending[other]--
+					}
+				}
+			}
+
+			break
+		}
+
+		if !progressed {
+			var cycle []string
+			for _, job := range s.jobs {
+				if !started[job] {
+					cycle = append(cycle, job)
+				}
+			}
+
+			return nil, nil, bosherr.Errorf("Start dependencies of jobs '%s' form a cycle", strings.Join(cycle, ", "))
+		}
+	}
+
+	withServices := map[string]bool{}
+	for _, job := range s.services {
+		withServices[job] = true
+	}
+
+	var startable []string
+	for _, job := range order {
+		if withServices[job] {
+			startable = append(startable, job)
+		}
+	}
+
+	return startable, required, nil
+}
+
+// dependenciesOf returns the colocated jobs the job depends on
+func (s *startOrderJobSupervisor) dependenciesOf(job string) []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var dependencies []string
+
+	for _, dependency := range s.dependencies[job] {
+		if _, found := s.dependencies[dependency]; found {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+
+	return dependencies
+}
+
+// waitUntilReady waits for all processes of the job to be running
+// and for its health probe, if it has one, to pass
+func (s *startOrderJobSupervisor) waitUntilReady(job string) error {
+	timer := s.timeService.NewTimer(s.options.ReadyTimeout)
+	defer timer.Stop()
+
+	for {
+		running, err := s.jobIn(job, func(state string) bool { return state == "running" })
+		if err != nil {
+			return err
+		}
+
+		if running {
+			result, found := s.prober.ProbeJob(job)
+			if !found || result.Healthy {
+				return nil
+			}
+		}
+
+		select {
+		case <-timer.C():
+			return bosherr.Errorf("Timed out waiting for job %s to be running and healthy after %s", job, s.options.ReadyTimeout)
+		default:
+		}
+
+		s.logger.Debug(startOrderJobSupervisorLogTag, "Waiting for job %s to be running and healthy", job)
+		s.timeService.Sleep(s.options.PollInterval)
+	}
+}
+
+func (s *startOrderJobSupervisor) waitUntilStopped(job string) {
+	timer := s.timeService.NewTimer(s.options.StopTimeout)
+	defer timer.Stop()
+
+	for {
+		stopped, err := s.jobIn(job, func(state string) bool {
+			return state != "running" && state != "starting" && state != "stopping"
+		})
+		if err != nil {
+			s.logger.Warn(startOrderJobSupervisorLogTag, "Failed to check whether job %s stopped: %s", job, err.Error())
+			return
+		}
+
+		if stopped {
+			return
+		}
+
+		select {
+		case <-timer.C():
+			s.logger.Warn(startOrderJobSupervisorLogTag, "Job %s did not stop within %s, stopping its dependencies anyway", job, s.options.StopTimeout)
+			return
+		default:
+		}
+
+		s.timeService.Sleep(s.options.PollInterval)
+	}
+}
+
+// jobIn returns whether every process of the job is in a state accepted by
+// the given function
+func (s *startOrderJobSupervisor) jobIn(job string, accepted func(state string) bool) (bool, error) {
+	processes, err := s.delegate.Processes()
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Getting processes of job %s", job)
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, process := range processes {
+		if s.services[process.Name] == job && !accepted(process.State) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
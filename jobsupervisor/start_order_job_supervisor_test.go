@@ -0,0 +1,189 @@
+package jobsupervisor_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("startOrderJobSupervisor", func() {
+	const (
+		readyTimeout = 5 * time.Minute
+		stopTimeout  = 2 * time.Minute
+		pollInterval = 1 * time.Second
+	)
+
+	var (
+		fs             *fakesys.FakeFileSystem
+		prober         *fakes.FakeJobHealthProber
+		timeService    *fakeclock.FakeClock
+		fakeSupervisor *fakes.FakeJobSupervisor
+		supervisor     JobSupervisor
+	)
+
+	addJob := func(name string, dependencies ...string) {
+		configPath := "/var/vcap/jobs/" + name + "/monit"
+		Expect(fs.WriteFileString(configPath, "check process "+name+"\n  with pidfile /var/vcap/sys/run/"+name+".pid\n  start program \"/bin/"+name+"\"\n  group vcap\n")).To(Succeed())
+
+		Expect(supervisor.SetStartDependencies(name, dependencies)).To(Succeed())
+		Expect(supervisor.AddJob(name, name, 0, configPath)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		prober = &fakes.FakeJobHealthProber{}
+		timeService = fakeclock.NewFakeClock(time.Now())
+		fakeSupervisor = fakes.NewFakeJobSupervisor()
+
+		supervisor = NewStartOrderJobSupervisor(
+			fakeSupervisor,
+			prober,
+			fs,
+			StartOrderOptions{
+				ReadyTimeout: readyTimeout,
+				StopTimeout:  stopTimeout,
+				PollInterval: pollInterval,
+			},
+			timeService,
+			boshlog.NewLogger(boshlog.LevelNone),
+		)
+	})
+
+	It("delegates job management to the underlying job supervisor", func() {
+		addJob("web")
+
+		Expect(fakeSupervisor.StartDependencies).To(Equal(map[string][]string{"web": nil}))
+		Expect(fakeSupervisor.AddJobArgs).To(Equal([]fakes.AddJobArgs{
+			{Name: "web", ConfigName: "web", Index: 0, ConfigPath: "/var/vcap/jobs/web/monit"},
+		}))
+
+		Expect(supervisor.RemoveAllJobs()).To(Succeed())
+		Expect(fakeSupervisor.RemovedAllJobs).To(BeTrue())
+	})
+
+	It("lets the delegate start and stop all jobs at once when no job has dependencies", func() {
+		addJob("web")
+		addJob("worker", "not-colocated")
+
+		Expect(supervisor.Start()).To(Succeed())
+		Expect(fakeSupervisor.Started).To(BeTrue())
+		Expect(fakeSupervisor.StartedServices).To(BeEmpty())
+
+		_, err := supervisor.StopAndWait()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fakeSupervisor.StoppedAndWaited).To(BeTrue())
+		Expect(fakeSupervisor.StoppedServices).To(BeEmpty())
+	})
+
+	Context("when jobs have dependencies", func() {
+		BeforeEach(func() {
+			addJob("web", "proxy", "postgres")
+			addJob("proxy", "postgres")
+			addJob("postgres")
+			addJob("metrics")
+
+			fakeSupervisor.ProcessesStatus = []Process{
+				{Name: "web", State: "running"},
+				{Name: "proxy", State: "running"},
+				{Name: "postgres", State: "running"},
+				{Name: "metrics", State: "running"},
+			}
+		})
+
+		It("starts the jobs in dependency order before starting the remaining services", func() {
+			Expect(supervisor.Start()).To(Succeed())
+
+			Expect(fakeSupervisor.StartedServices).To(Equal([]string{"postgres", "proxy", "web", "metrics"}))
+			Expect(fakeSupervisor.Started).To(BeTrue())
+		})
+
+		It("does not start or stop colocated jobs without processes", func() {
+			Expect(fs.WriteFileString("/var/vcap/jobs/config-only/monit", "")).To(Succeed())
+			Expect(supervisor.SetStartDependencies("config-only", nil)).To(Succeed())
+			Expect(supervisor.AddJob("config-only", "config-only", 0, "/var/vcap/jobs/config-only/monit")).To(Succeed())
+			Expect(supervisor.SetStartDependencies("no-monit", []string{"postgres"})).To(Succeed())
+
+			Expect(supervisor.Start()).To(Succeed())
+			Expect(fakeSupervisor.StartedServices).To(Equal([]string{"postgres", "proxy", "web", "metrics"}))
+
+			fakeSupervisor.ProcessesStatus = nil
+
+			_, err := supervisor.StopAndWait()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeSupervisor.StoppedServices).To(Equal([]string{"metrics", "web", "proxy", "postgres"}))
+		})
+
+		It("stops the jobs in reverse dependency order", func() {
+			fakeSupervisor.ProcessesStatus = []Process{
+				{Name: "web", State: "unknown"},
+				{Name: "proxy", State: "unknown"},
+			}
+
+			_, err := supervisor.StopAndWait()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeSupervisor.StoppedServices).To(Equal([]string{"metrics", "web", "proxy", "postgres"}))
+			Expect(fakeSupervisor.StoppedAndWaited).To(BeTrue())
+		})
+
+		It("waits for a dependency to be running before starting its dependents", func() {
+			fakeSupervisor.ProcessesStatus[2].State = "starting"
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- supervisor.Start() }()
+
+			timeService.WaitForNWatchersAndIncrement(pollInterval, 2)
+			Consistently(errCh).ShouldNot(Receive())
+
+			timeService.WaitForNWatchersAndIncrement(readyTimeout, 2)
+
+			var err error
+			Eventually(errCh).Should(Receive(&err))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Timed out waiting for job postgres to be running and healthy after 5m0s"))
+			Expect(fakeSupervisor.StartedServices).To(Equal([]string{"postgres"}))
+			Expect(fakeSupervisor.Started).To(BeFalse())
+		})
+
+		It("waits for a dependency to pass its health probe", func() {
+			prober.ProbeJobStub = func(job string) (HealthProbeResult, bool) {
+				return HealthProbeResult{Healthy: job != "postgres" || prober.ProbeJobCallCount() > 1}, job == "postgres"
+			}
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- supervisor.Start() }()
+
+			timeService.WaitForNWatchersAndIncrement(pollInterval, 2)
+
+			Eventually(errCh).Should(Receive(BeNil()))
+			Expect(fakeSupervisor.StartedServices).To(Equal([]string{"postgres", "proxy", "web", "metrics"}))
+			Expect(prober.ProbeJobArgsForCall(0)).To(Equal("postgres"))
+			Expect(prober.ProbeJobArgsForCall(1)).To(Equal("postgres"))
+		})
+
+		It("returns an error when a job fails to start", func() {
+			fakeSupervisor.StartServiceErr = errors.New("fake-start-error")
+
+			err := supervisor.Start()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Starting job postgres: fake-start-error"))
+		})
+
+		It("returns an error when the dependencies form a cycle", func() {
+			addJob("postgres", "web")
+
+			err := supervisor.Start()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Start dependencies of jobs 'web, proxy, postgres' form a cycle"))
+			Expect(fakeSupervisor.StartedServices).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,328 @@
+package jobsupervisor
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const stopDeadlineJobSupervisorLogTag = "stopDeadlineJobSupervisor"
+
+type StopDeadlineOptions struct {
+	// Deadline of processes whose stop program does not declare a timeout
+	DefaultStopTimeout time.Duration
+
+	// Time between SIGTERM and SIGKILL once a deadline has passed
+	KillGracePeriod time.Duration
+}
+
+type stopDeadlineProcess struct {
+	job     string
+	name    string
+	pidFile string
+	timeout time.Duration
+}
+
+type stopDeadlineJobSupervisor struct {
+	delegate    JobSupervisor
+	fs          boshsys.FileSystem
+	options     StopDeadlineOptions
+	timeService clock.Clock
+	logger      boshlog.Logger
+
+	lock      sync.RWMutex
+	processes map[string]stopDeadlineProcess
+}
+
+// NewStopDeadlineJobSupervisor bounds StopAndWait of the delegate. Every
+// process gets the timeout of its monit stop program ('with timeout N
+// seconds') to stop. Afterwards its process group is sent SIGTERM and,
+// if it still runs after the grace period, SIGKILL. StopAndWait gives up
+// waiting for the delegate one grace period after the largest deadline,
+// so that processes without a pidfile cannot hold it up forever.
+func NewStopDeadlineJobSupervisor(
+	delegate JobSupervisor,
+	fs boshsys.FileSystem,
+	options StopDeadlineOptions,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) JobSupervisor {
+	return &stopDeadlineJobSupervisor{
+		delegate:    delegate,
+		fs:          fs,
+		options:     options,
+		timeService: timeService,
+		logger:      logger,
+		processes:   map[string]stopDeadlineProcess{},
+	}
+}
+
+func (s *stopDeadlineJobSupervisor) Reload() error {
+	return s.delegate.Reload()
+}
+
+func (s *stopDeadlineJobSupervisor) Start() error {
+	return s.delegate.Start()
+}
+
+func (s *stopDeadlineJobSupervisor) Stop() error {
+	return s.delegate.Stop()
+}
+
+type stopAndWaitOutcome struct {
+	result StopResult
+	err    error
+}
+
+func (s *stopDeadlineJobSupervisor) StopAndWait() (StopResult, error) {
+	processes := s.processesByDeadline()
+
+	deadline := s.options.DefaultStopTimeout
+	for _, process := range processes {
+		if process.timeout > deadline {
+			deadline = process.timeout
+		}
+	}
+
+	doneCh := make(chan stopAndWaitOutcome, 1)
+
+	go func() {
+		result, err := s.delegate.StopAndWait()
+		doneCh <- stopAndWaitOutcome{result: result, err: err}
+	}()
+
+	var forceKilled []ForceKilledProcess
+
+	startedAt := s.timeService.Now()
+
+	for len(processes) > 0 {
+		timer := s.timeService.NewTimer(processes[0].timeout - s.timeService.Since(startedAt))
+
+		select {
+		case outcome := <-doneCh:
+			timer.Stop()
+			return s.withForceKilled(outcome, forceKilled)
+
+		case <-timer.C():
+		}
+
+		var expired []stopDeadlineProcess
+
+		for len(processes) > 0 && s.timeService.Since(startedAt) >= processes[0].timeout {
+			expired = append(expired, processes[0])
+			processes = processes[1:]
+		}
+
+		forceKilled = append(forceKilled, s.kill(expired)...)
+	}
+
+	remaining := deadline - s.timeService.Since(startedAt)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	timer := s.timeService.NewTimer(remaining + s.options.KillGracePeriod)
+	defer timer.Stop()
+
+	select {
+	case outcome := <-doneCh:
+		return s.withForceKilled(outcome, forceKilled)
+
+	case <-timer.C():
+		s.logger.Error(stopDeadlineJobSupervisorLogTag, "Jobs did not stop within %s, giving up waiting", deadline+s.options.KillGracePeriod)
+
+		return StopResult{ForceKilled: forceKilled}, bosherr.Errorf("Timed out waiting for jobs to stop after %s", deadline+s.options.KillGracePeriod)
+	}
+}
+
+func (s *stopDeadlineJobSupervisor) withForceKilled(outcome stopAndWaitOutcome, forceKilled []ForceKilledProcess) (StopResult, error) {
+	outcome.result.ForceKilled = append(outcome.result.ForceKilled, forceKilled...)
+
+	return outcome.result, outcome.err
+}
+
+// kill sends SIGTERM to all processes that are still running and SIGKILL
+// to those that survive the grace period. A process whose pid has been
+// reused by another process in the meantime is not killed.
+func (s *stopDeadlineJobSupervisor) kill(processes []stopDeadlineProcess) []ForceKilledProcess {
+	var terminated []ForceKilledProcess
+	var startTimes []string
+
+	for _, process := range processes {
+		pid, startTime, running := s.runningPid(process)
+		if !running {
+			continue
+		}
+
+		s.logger.Warn(stopDeadlineJobSupervisorLogTag, "Process %s of job %s (pid %d) did not stop within %s, sending SIGTERM", process.name, process.job, pid, process.timeout)
+
+		err := signalJobProcess(pid, syscall.SIGTERM)
+		if err != nil {
+			s.logger.Error(stopDeadlineJobSupervisorLogTag, "Failed to send SIGTERM to process %s: %s", process.name, err.Error())
+		}
+
+		terminated = append(terminated, ForceKilledProcess{Job: process.job, Process: process.name, PID: pid, Signal: "SIGTERM"})
+		startTimes = append(startTimes, startTime)
+	}
+
+	if len(terminated) == 0 {
+		return nil
+	}
+
+	s.timeService.Sleep(s.options.KillGracePeriod)
+
+	for i, process := range terminated {
+		startTime, running := processStartTime(process.PID)
+		if !running || startTime != startTimes[i] {
+			continue
+		}
+
+		s.logger.Warn(stopDeadlineJobSupervisorLogTag, "Process %s of job %s (pid %d) survived SIGTERM, sending SIGKILL", process.Process, process.Job, process.PID)
+
+		err := signalJobProcess(process.PID, syscall.SIGKILL)
+		if err != nil {
+			s.logger.Error(stopDeadlineJobSupervisorLogTag, "Failed to send SIGKILL to process %s: %s", process.Process, err.Error())
+		}
+
+		terminated[i].Signal = "SIGKILL"
+	}
+
+	return terminated
+}
+
+func (s *stopDeadlineJobSupervisor) runningPid(process stopDeadlineProcess) (int, string, bool) {
+	contents, err := s.fs.ReadFileString(process.pidFile)
+	if err != nil {
+		return 0, "", false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(contents))
+	if err != nil || pid <= 1 {
+		return 0, "", false
+	}
+
+	startTime, running := processStartTime(pid)
+
+	return pid, startTime, running
+}
+
+func (s *stopDeadlineJobSupervisor) processesByDeadline() []stopDeadlineProcess {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	processes := make([]stopDeadlineProcess, 0, len(s.processes))
+	for _, process := range s.processes {
+		processes = append(processes, process)
+	}
+
+	sort.Slice(processes, func(i, j int) bool {
+		if processes[i].timeout != processes[j].timeout {
+			return processes[i].timeout < processes[j].timeout
+		}
+		return processes[i].name < processes[j].name
+	})
+
+	return processes
+}
+
+func (s *stopDeadlineJobSupervisor) Unmonitor() error {
+	return s.delegate.Unmonitor()
+}
+
+func (s *stopDeadlineJobSupervisor) StartService(name string) error {
+	return s.delegate.StartService(name)
+}
+
+func (s *stopDeadlineJobSupervisor) StopService(name string) error {
+	return s.delegate.StopService(name)
+}
+
+func (s *stopDeadlineJobSupervisor) Status() string {
+	return s.delegate.Status()
+}
+
+func (s *stopDeadlineJobSupervisor) Processes() ([]Process, error) {
+	return s.delegate.Processes()
+}
+
+// AddJob remembers the pidfile and stop timeout of every process check.
+// Processes without a pidfile cannot be killed and are left to the delegate.
+func (s *stopDeadlineJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	err := s.delegate.AddJob(jobName, configName, jobIndex, configPath)
+	if err != nil {
+		return err
+	}
+
+	config, err := s.fs.ReadFileString(configPath)
+	if err != nil {
+		s.logger.Warn(stopDeadlineJobSupervisorLogTag, "Failed to read job config %s: %s", configPath, err.Error())
+		return nil
+	}
+
+	processes, err := parseMonitProcesses(config)
+	if err != nil {
+		s.logger.Warn(stopDeadlineJobSupervisorLogTag, "Failed to parse job config %s: %s", configPath, err.Error())
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, process := range processes {
+		if process.PidFile == "" {
+			continue
+		}
+
+		timeout := s.options.DefaultStopTimeout
+
+		if process.StopProgram.TimeoutSeconds != "" {
+			seconds, err := strconv.Atoi(process.StopProgram.TimeoutSeconds)
+			if err == nil && seconds > 0 {
+				timeout = time.Duration(seconds) * time.Second
+			}
+		}
+
+		s.processes[process.Name] = stopDeadlineProcess{
+			job:     jobName,
+			name:    process.Name,
+			pidFile: process.PidFile,
+			timeout: timeout,
+		}
+	}
+
+	return nil
+}
+
+func (s *stopDeadlineJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	return s.delegate.SetResourceLimits(jobName, limits)
+}
+
+func (s *stopDeadlineJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return s.delegate.SetStartDependencies(jobName, dependencies)
+}
+
+func (s *stopDeadlineJobSupervisor) RemoveAllJobs() error {
+	s.lock.Lock()
+	s.processes = map[string]stopDeadlineProcess{}
+	s.lock.Unlock()
+
+	return s.delegate.RemoveAllJobs()
+}
+
+func (s *stopDeadlineJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	return s.delegate.MonitorJobFailures(handler)
+}
+
+func (s *stopDeadlineJobSupervisor) HealthRecorder(status string) {
+	s.delegate.HealthRecorder(status)
+}
@@ -0,0 +1,183 @@
+//go:build linux
+// +build linux
+
+package jobsupervisor_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// waitingJobSupervisor stops like monit does: it only returns once the
+// process it was asked to stop is gone
+type waitingJobSupervisor struct {
+	*fakes.FakeJobSupervisor
+	cmd *exec.Cmd
+}
+
+func (s *waitingJobSupervisor) StopAndWait() (StopResult, error) {
+	_, _ = s.FakeJobSupervisor.StopAndWait()
+	_ = s.cmd.Wait()
+	return s.StopAndWaitResult, s.StopErr
+}
+
+// hangingJobSupervisor never finishes stopping, like monit when it does
+// not report a process as stopped
+type hangingJobSupervisor struct {
+	*fakes.FakeJobSupervisor
+	release chan struct{}
+}
+
+func (s *hangingJobSupervisor) StopAndWait() (StopResult, error) {
+	<-s.release
+	return StopResult{}, nil
+}
+
+var _ = Describe("stopDeadlineJobSupervisor", func() {
+	var (
+		baseDir    string
+		cmd        *exec.Cmd
+		delegate   *waitingJobSupervisor
+		supervisor JobSupervisor
+	)
+
+	startProcess := func(script string) {
+		cmd = exec.Command("bash", "-c", script)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		Expect(cmd.Start()).To(Succeed())
+
+		pidFile := filepath.Join(baseDir, "web.pid")
+		Expect(os.WriteFile(pidFile, []byte(strconv.Itoa(cmd.Process.Pid)), 0644)).To(Succeed())
+
+		delegate = &waitingJobSupervisor{FakeJobSupervisor: fakes.NewFakeJobSupervisor(), cmd: cmd}
+
+		supervisor = NewStopDeadlineJobSupervisor(
+			delegate,
+			boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone)),
+			StopDeadlineOptions{
+				DefaultStopTimeout: 200 * time.Millisecond,
+				KillGracePeriod:    200 * time.Millisecond,
+			},
+			clock.NewClock(),
+			boshlog.NewLogger(boshlog.LevelNone),
+		)
+
+		configPath := filepath.Join(baseDir, "monit")
+		config := "check process web\n  with pidfile " + pidFile + "\n  start program \"/bin/web\"\n  group vcap\n"
+		Expect(os.WriteFile(configPath, []byte(config), 0644)).To(Succeed())
+		Expect(supervisor.AddJob("web", "web", 0, configPath)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		baseDir, err = os.MkdirTemp("", "stop-deadline-job-supervisor")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if cmd != nil && cmd.ProcessState == nil {
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		Expect(os.RemoveAll(baseDir)).To(Succeed())
+	})
+
+	It("sends SIGTERM to processes that did not stop within their deadline", func() {
+		startProcess("sleep 60 & wait")
+
+		result, err := supervisor.StopAndWait()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ForceKilled).To(Equal([]ForceKilledProcess{
+			{Job: "web", Process: "web", PID: cmd.Process.Pid, Signal: "SIGTERM"},
+		}))
+	})
+
+	It("sends SIGKILL to processes that ignore SIGTERM", func() {
+		startProcess("trap '' TERM; while true; do sleep 0.05; done")
+
+		result, err := supervisor.StopAndWait()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ForceKilled).To(Equal([]ForceKilledProcess{
+			{Job: "web", Process: "web", PID: cmd.Process.Pid, Signal: "SIGKILL"},
+		}))
+	})
+
+	It("does not kill processes that stop within their deadline", func() {
+		startProcess("sleep 0.05")
+
+		result, err := supervisor.StopAndWait()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ForceKilled).To(BeEmpty())
+	})
+
+	It("returns the force killed processes and error of the delegate", func() {
+		startProcess("sleep 0.05")
+		delegate.StopAndWaitResult = StopResult{
+			ForceKilled: []ForceKilledProcess{{Job: "db", Process: "db", PID: 10, Signal: "SIGKILL"}},
+		}
+		delegate.StopErr = errors.New("fake-stop-error")
+
+		result, err := supervisor.StopAndWait()
+		Expect(err).To(MatchError("fake-stop-error"))
+		Expect(result.ForceKilled).To(Equal([]ForceKilledProcess{
+			{Job: "db", Process: "db", PID: 10, Signal: "SIGKILL"},
+		}))
+	})
+
+	It("gives up waiting for the delegate one grace period after the largest deadline", func() {
+		startProcess("trap '' TERM; while true; do sleep 0.05; done")
+
+		hanging := &hangingJobSupervisor{FakeJobSupervisor: fakes.NewFakeJobSupervisor(), release: make(chan struct{})}
+		defer close(hanging.release)
+
+		supervisor = NewStopDeadlineJobSupervisor(
+			hanging,
+			boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone)),
+			StopDeadlineOptions{
+				DefaultStopTimeout: 200 * time.Millisecond,
+				KillGracePeriod:    200 * time.Millisecond,
+			},
+			clock.NewClock(),
+			boshlog.NewLogger(boshlog.LevelNone),
+		)
+
+		configPath := filepath.Join(baseDir, "monit")
+		Expect(supervisor.AddJob("web", "web", 0, configPath)).To(Succeed())
+
+		otherConfigPath := filepath.Join(baseDir, "other-monit")
+		Expect(os.WriteFile(otherConfigPath, []byte("check process worker\n  matching worker\n  start program \"/bin/worker\"\n  group vcap\n"), 0644)).To(Succeed())
+		Expect(supervisor.AddJob("worker", "worker", 1, otherConfigPath)).To(Succeed())
+
+		startedAt := time.Now()
+
+		result, err := supervisor.StopAndWait()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Timed out waiting for jobs to stop after 400ms"))
+		Expect(result.ForceKilled).To(Equal([]ForceKilledProcess{
+			{Job: "web", Process: "web", PID: cmd.Process.Pid, Signal: "SIGKILL"},
+		}))
+		Expect(time.Since(startedAt)).To(BeNumerically("<", 2*time.Second))
+	})
+
+	It("forgets the processes when all jobs are removed", func() {
+		startProcess("sleep 0.3")
+		Expect(supervisor.RemoveAllJobs()).To(Succeed())
+
+		result, err := supervisor.StopAndWait()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ForceKilled).To(BeEmpty())
+	})
+})
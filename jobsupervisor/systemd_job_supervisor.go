@@ -0,0 +1,761 @@
+package jobsupervisor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	systemdJobSupervisorLogTag = "systemdJobSupervisor"
+	systemdDropInFileName      = "bosh.conf"
+
+	// Written to the runtime drop-in directory of every unit while the jobs
+	// are unmonitored so that systemd stops restarting them
+	systemdUnmonitoredDropInFileName = "bosh-unmonitored.conf"
+	systemdUnmonitoredDropIn         = "[Service]\nRestart=no\n"
+)
+
+type SystemdOptions struct {
+	// Directory the generated units are written to
+	UnitsDir string
+
+	// Directory for drop-ins that must not outlive the current boot
+	RuntimeUnitsDir string
+
+	// Slice all job units are placed in
+	Slice string
+
+	// Interval between checks for failed or restarted units
+	PollInterval time.Duration
+}
+
+type systemdJobSupervisor struct {
+	fs          boshsys.FileSystem
+	runner      boshsys.CmdRunner
+	dirProvider boshdir.Provider
+	options     SystemdOptions
+	timeService clock.Clock
+	logger      boshlog.Logger
+
+	unmonitoredLock sync.Mutex
+	unmonitored     bool
+
+	limitsLock sync.Mutex
+	limits     map[string]cgroup.Limits
+	unitSlices map[string]string
+}
+
+type systemdUnitStatus struct {
+	Unit        string
+	ActiveState string
+	Restarts    int
+	MainPID     int
+	MemoryBytes uint64
+
+	CPUUsageNSec uint64
+	TasksCurrent uint64
+
+	// Microseconds since boot when the unit became active
+	ActiveEnterMonotonic uint64
+}
+
+// NewSystemdJobSupervisor supervises jobs as systemd services. Each monit
+// process check is translated into a unit; jobs can instead ship their own
+// unit as systemd/<process>.service next to their monit file.
+func NewSystemdJobSupervisor(
+	fs boshsys.FileSystem,
+	runner boshsys.CmdRunner,
+	dirProvider boshdir.Provider,
+	options SystemdOptions,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) JobSupervisor {
+	return &systemdJobSupervisor{
+		fs:          fs,
+		runner:      runner,
+		dirProvider: dirProvider,
+		options:     options,
+		timeService: timeService,
+		logger:      logger,
+		limits:      map[string]cgroup.Limits{},
+		unitSlices:  map[string]string{},
+	}
+}
+
+func (s *systemdJobSupervisor) Reload() error {
+	_, _, _, err := s.runner.RunCommand("systemctl", "daemon-reload")
+	if err != nil {
+		return bosherr.WrapError(err, "Reloading systemd units")
+	}
+
+	return nil
+}
+
+func (s *systemdJobSupervisor) Start() error {
+	units, err := s.units()
+	if err != nil {
+		return err
+	}
+
+	err = s.removeUnmonitoredDropIns(units)
+	if err != nil {
+		return err
+	}
+
+	if len(units) > 0 {
+		s.logger.Debug(systemdJobSupervisorLogTag, "Starting units %v", units)
+
+		_, _, _, err = s.runner.RunCommand("systemctl", append([]string{"start", "--no-block"}, units...)...)
+		if err != nil {
+			return bosherr.WrapError(err, "Starting units")
+		}
+	}
+
+	s.setUnmonitored(false)
+
+	err = s.fs.RemoveAll(s.stoppedFilePath())
+	if err != nil {
+		return bosherr.WrapError(err, "Removing stopped File")
+	}
+
+	return nil
+}
+
+func (s *systemdJobSupervisor) Stop() error {
+	return s.stop("--no-block")
+}
+
+// StopAndWait relies on systemd to kill units that do not stop within
+// their TimeoutStopSec, so it never reports force killed processes
+func (s *systemdJobSupervisor) StopAndWait() (StopResult, error) {
+	return StopResult{}, s.stop()
+}
+
+func (s *systemdJobSupervisor) stop(flags ...string) error {
+	units, err := s.units()
+	if err != nil {
+		return err
+	}
+
+	if len(units) > 0 {
+		s.logger.Debug(systemdJobSupervisorLogTag, "Stopping units %v", units)
+
+		args := append(append([]string{"stop"}, flags...), units...)
+
+		_, _, _, err = s.runner.RunCommand("systemctl", args...)
+		if err != nil {
+			return bosherr.WrapError(err, "Stopping units")
+		}
+	}
+
+	err = s.fs.WriteFileString(s.stoppedFilePath(), "")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating stopped File")
+	}
+
+	return nil
+}
+
+// Unmonitor stops restarting units and reporting their failures until the
+// next Start
+func (s *systemdJobSupervisor) Unmonitor() error {
+	units, err := s.units()
+	if err != nil {
+		return err
+	}
+
+	for _, unit := range units {
+		dropInDir := filepath.Join(s.options.RuntimeUnitsDir, unit+".d")
+
+		err = s.fs.MkdirAll(dropInDir, 0755)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating runtime drop-in directory of %s", unit)
+		}
+
+		err = s.fs.WriteFileString(filepath.Join(dropInDir, systemdUnmonitoredDropInFileName), systemdUnmonitoredDropIn)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Writing unmonitored drop-in of %s", unit)
+		}
+	}
+
+	s.setUnmonitored(true)
+
+	if len(units) > 0 {
+		err = s.Reload()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeUnmonitoredDropIns lets systemd restart failed units again
+func (s *systemdJobSupervisor) removeUnmonitoredDropIns(units []string) error {
+	removed := false
+
+	for _, unit := range units {
+		dropInPath := filepath.Join(s.options.RuntimeUnitsDir, unit+".d", systemdUnmonitoredDropInFileName)
+		if !s.fs.FileExists(dropInPath) {
+			continue
+		}
+
+		err := s.fs.RemoveAll(dropInPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing unmonitored drop-in of %s", unit)
+		}
+
+		removed = true
+	}
+
+	if removed {
+		return s.Reload()
+	}
+
+	return nil
+}
+
+func (s *systemdJobSupervisor) StartService(name string) error {
+	units, err := s.unitsFor(name)
+	if err != nil {
+		return err
+	}
+
+	_, _, _, err = s.runner.RunCommand("systemctl", append([]string{"start", "--no-block"}, units...)...)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Starting units of %s", name)
+	}
+
+	return nil
+}
+
+func (s *systemdJobSupervisor) StopService(name string) error {
+	units, err := s.unitsFor(name)
+	if err != nil {
+		return err
+	}
+
+	_, _, _, err = s.runner.RunCommand("systemctl", append([]string{"stop"}, units...)...)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Stopping units of %s", name)
+	}
+
+	return nil
+}
+
+// unitsFor returns the units of the job with the given name
+// or the unit of the process with that name
+func (s *systemdJobSupervisor) unitsFor(name string) ([]string, error) {
+	units, err := s.units()
+	if err != nil {
+		return nil, err
+	}
+
+	var jobUnits []string
+
+	for _, unit := range units {
+		if s.unitJob(unit) == name {
+			jobUnits = append(jobUnits, unit)
+		}
+	}
+
+	if len(jobUnits) > 0 {
+		return jobUnits, nil
+	}
+
+	for _, unit := range units {
+		if unit == systemdUnitName(name) {
+			return []string{unit}, nil
+		}
+	}
+
+	return nil, bosherr.Errorf("Unknown job or service '%s'", name)
+}
+
+// unitJob returns the job recorded in the unit or in its drop-in
+func (s *systemdJobSupervisor) unitJob(unit string) string {
+	unitPath := filepath.Join(s.options.UnitsDir, unit)
+	prefix := systemdJobKey + "="
+
+	for _, path := range []string{unitPath, filepath.Join(unitPath+".d", systemdDropInFileName)} {
+		contents, err := s.fs.ReadFileString(path)
+		if err != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(contents, "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, prefix) {
+				return strings.TrimPrefix(line, prefix)
+			}
+		}
+	}
+
+	return ""
+}
+
+func (s *systemdJobSupervisor) Status() string {
+	if s.fs.FileExists(s.stoppedFilePath()) {
+		return "stopped"
+	}
+
+	statuses, err := s.unitStatuses()
+	if err != nil {
+		s.logger.Error(systemdJobSupervisorLogTag, "Failed to get unit status: %s", err.Error())
+		return "unknown"
+	}
+
+	status := "running"
+
+	for _, unitStatus := range statuses {
+		switch unitStatus.ActiveState {
+		case "active":
+		case "activating", "reloading":
+			return "starting"
+		default:
+			status = "failing"
+		}
+	}
+
+	return status
+}
+
+func (s *systemdJobSupervisor) Processes() ([]Process, error) {
+	processes := []Process{}
+
+	statuses, err := s.unitStatuses()
+	if err != nil {
+		return processes, bosherr.WrapError(err, "Getting unit status")
+	}
+
+	uptime := s.systemUptime()
+	sliceUsages := s.sliceUsages()
+
+	for _, unitStatus := range statuses {
+		process := Process{
+			Name:  strings.TrimSuffix(strings.TrimPrefix(unitStatus.Unit, "bosh-"), ".service"),
+			Job:   s.unitJob(unitStatus.Unit),
+			State: processState(unitStatus.ActiveState),
+			PID:   unitStatus.MainPID,
+			Memory: MemoryVitals{
+				Kb: int(unitStatus.MemoryBytes / 1024),
+			},
+		}
+
+		if slice, found := s.unitSlice(unitStatus.Unit); found {
+			process.Cgroup = sliceUsages[slice]
+		}
+
+		if unitStatus.ActiveState == "active" && unitStatus.ActiveEnterMonotonic > 0 && uptime > 0 {
+			enteredAt := time.Duration(unitStatus.ActiveEnterMonotonic) * time.Microsecond
+			if uptime > enteredAt {
+				process.Uptime.Secs = int((uptime - enteredAt).Seconds())
+			}
+		}
+
+		processes = append(processes, process)
+	}
+
+	return processes, nil
+}
+
+func (s *systemdJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	config, err := s.fs.ReadFileString(configPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading job config from file")
+	}
+
+	processes, err := parseMonitProcesses(config)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing job config %s", configPath)
+	}
+
+	slice := s.options.Slice
+
+	s.limitsLock.Lock()
+	defer s.limitsLock.Unlock()
+
+	_, limited := s.limits[jobName]
+	if limited {
+		slice = s.jobSliceName(jobName)
+	}
+
+	for _, process := range processes {
+		unitName := systemdUnitName(process.Name)
+		unitPath := filepath.Join(s.options.UnitsDir, unitName)
+		shippedUnitPath := filepath.Join(filepath.Dir(configPath), "systemd", process.Name+".service")
+
+		if s.fs.FileExists(shippedUnitPath) {
+			err = s.addShippedUnit(jobName, shippedUnitPath, unitPath, slice)
+		} else {
+			err = s.fs.WriteFileString(unitPath, renderSystemdUnit(jobName, process, slice))
+		}
+
+		if limited {
+			s.unitSlices[unitName] = slice
+		}
+
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Writing unit for process %s", process.Name)
+		}
+	}
+
+	return nil
+}
+
+// addShippedUnit copies a unit shipped with the job and moves it into
+// the slice of all jobs with a drop-in
+func (s *systemdJobSupervisor) addShippedUnit(jobName, shippedUnitPath, unitPath, slice string) error {
+	err := s.fs.CopyFile(shippedUnitPath, unitPath)
+	if err != nil {
+		return err
+	}
+
+	dropInDir := unitPath + ".d"
+
+	err = s.fs.MkdirAll(dropInDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	dropIn := fmt.Sprintf("[Unit]\n%s=%s\n\n[Service]\nSlice=%s\n", systemdJobKey, jobName, slice)
+
+	return s.fs.WriteFileString(filepath.Join(dropInDir, systemdDropInFileName), dropIn)
+}
+
+// SetResourceLimits writes a slice for the job below the slice of all jobs,
+// which AddJob then places the job's units in
+func (s *systemdJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	if limits.IsEmpty() {
+		return nil
+	}
+
+	slice := s.jobSliceName(jobName)
+
+	unit, err := renderSystemdSlice(jobName, limits)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Rendering slice of job %s", jobName)
+	}
+
+	err = s.fs.WriteFileString(filepath.Join(s.options.UnitsDir, slice), unit)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing slice of job %s", jobName)
+	}
+
+	s.limitsLock.Lock()
+	defer s.limitsLock.Unlock()
+
+	s.limits[jobName] = limits
+
+	return nil
+}
+
+// SetStartDependencies is a no-op, see startOrderJobSupervisor
+func (s *systemdJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return nil
+}
+
+// RemoveAllJobs stops the units before removing them because systemd
+// cannot stop units it no longer knows about
+func (s *systemdJobSupervisor) RemoveAllJobs() error {
+	s.limitsLock.Lock()
+	s.limits = map[string]cgroup.Limits{}
+	s.unitSlices = map[string]string{}
+	s.limitsLock.Unlock()
+
+	units, err := s.units()
+	if err != nil {
+		return err
+	}
+
+	if len(units) > 0 {
+		s.logger.Debug(systemdJobSupervisorLogTag, "Stopping units %v before removing them", units)
+
+		_, _, _, err = s.runner.RunCommand("systemctl", append([]string{"stop"}, units...)...)
+		if err != nil {
+			return bosherr.WrapError(err, "Stopping units")
+		}
+	}
+
+	servicePaths, err := s.fs.Glob(filepath.Join(s.options.UnitsDir, "bosh-*.service*"))
+	if err != nil {
+		return bosherr.WrapError(err, "Listing units")
+	}
+
+	runtimeDropInPaths, err := s.fs.Glob(filepath.Join(s.options.RuntimeUnitsDir, "bosh-*.service.d"))
+	if err != nil {
+		return bosherr.WrapError(err, "Listing runtime drop-ins")
+	}
+
+	slicePaths, err := s.fs.Glob(filepath.Join(s.options.UnitsDir, strings.TrimSuffix(s.options.Slice, ".slice")+"-*.slice"))
+	if err != nil {
+		return bosherr.WrapError(err, "Listing slices")
+	}
+
+	paths := append(append(servicePaths, runtimeDropInPaths...), slicePaths...)
+
+	for _, path := range paths {
+		err = s.fs.RemoveAll(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing %s", path)
+		}
+	}
+
+	return s.Reload()
+}
+
+// MonitorJobFailures polls systemd and reports units that were restarted
+// or failed since the previous poll the same way monit reports them.
+func (s *systemdJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
+	previous := map[string]systemdUnitStatus{}
+
+	ticker := s.timeService.NewTicker(s.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		statuses, err := s.unitStatuses()
+		if err != nil {
+			s.logger.Error(systemdJobSupervisorLogTag, "Failed to get unit status: %s", err.Error())
+		} else {
+			current := map[string]systemdUnitStatus{}
+
+			for _, unitStatus := range statuses {
+				current[unitStatus.Unit] = unitStatus
+
+				if s.isUnmonitored() || s.fs.FileExists(s.stoppedFilePath()) {
+					continue
+				}
+
+				last, found := previous[unitStatus.Unit]
+				if !found {
+					continue
+				}
+
+				action := ""
+				if unitStatus.Restarts > last.Restarts {
+					action = "restart"
+				} else if unitStatus.ActiveState == "failed" && last.ActiveState != "failed" {
+					action = "alert"
+				}
+
+				if action == "" {
+					continue
+				}
+
+				err = handler(boshalert.MonitAlert{
+					Service:     strings.TrimSuffix(strings.TrimPrefix(unitStatus.Unit, "bosh-"), ".service"),
+					Event:       "does not exist",
+					Action:      action,
+					Date:        s.timeService.Now().Format(time.RFC1123Z),
+					Description: "process is not running",
+				})
+				if err != nil {
+					s.logger.Error(systemdJobSupervisorLogTag, "Failed to handle failure of %s: %s", unitStatus.Unit, err.Error())
+				}
+			}
+
+			previous = current
+		}
+
+		<-ticker.C()
+	}
+}
+
+func (s *systemdJobSupervisor) HealthRecorder(status string) {
+}
+
+func (s *systemdJobSupervisor) units() ([]string, error) {
+	paths, err := s.fs.Glob(filepath.Join(s.options.UnitsDir, "bosh-*.service"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing units")
+	}
+
+	units := []string{}
+	for _, path := range paths {
+		units = append(units, filepath.Base(path))
+	}
+
+	return units, nil
+}
+
+func (s *systemdJobSupervisor) unitStatuses() ([]systemdUnitStatus, error) {
+	units, err := s.units()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(units) == 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"show", "--property=Id,ActiveState,NRestarts,MainPID,MemoryCurrent,ActiveEnterTimestampMonotonic"}, units...)
+
+	stdout, _, _, err := s.runner.RunCommandQuietly("systemctl", args...)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Showing units")
+	}
+
+	return parseSystemctlShow(stdout), nil
+}
+
+// sliceUsages returns the usage of every job slice by slice name
+func (s *systemdJobSupervisor) sliceUsages() map[string]*CgroupVitals {
+	s.limitsLock.Lock()
+	var slices []string
+	for jobName := range s.limits {
+		slices = append(slices, s.jobSliceName(jobName))
+	}
+	s.limitsLock.Unlock()
+
+	usages := map[string]*CgroupVitals{}
+
+	if len(slices) == 0 {
+		return usages
+	}
+
+	sort.Strings(slices)
+
+	args := append([]string{"show", "--property=Id,MemoryCurrent,CPUUsageNSec,TasksCurrent"}, slices...)
+
+	stdout, _, _, err := s.runner.RunCommandQuietly("systemctl", args...)
+	if err != nil {
+		s.logger.Warn(systemdJobSupervisorLogTag, "Failed to show job slices: %s", err.Error())
+		return usages
+	}
+
+	for _, status := range parseSystemctlShow(stdout) {
+		usages[status.Unit] = &CgroupVitals{
+			MemoryKb:     int(status.MemoryBytes / 1024),
+			CPUUsageUsec: int64(status.CPUUsageNSec / 1000),
+			Pids:         int(status.TasksCurrent),
+		}
+	}
+
+	return usages
+}
+
+func (s *systemdJobSupervisor) unitSlice(unit string) (string, bool) {
+	s.limitsLock.Lock()
+	defer s.limitsLock.Unlock()
+
+	slice, found := s.unitSlices[unit]
+
+	return slice, found
+}
+
+// jobSliceName returns the name of a job's slice, which systemd nests
+// in the slice of all jobs because of the shared prefix
+func (s *systemdJobSupervisor) jobSliceName(jobName string) string {
+	return fmt.Sprintf("%s-%s.slice", strings.TrimSuffix(s.options.Slice, ".slice"), escapeSystemdName(jobName))
+}
+
+// systemUptime reads the monotonic clock that systemd uses for
+// *TimestampMonotonic properties
+func (s *systemdJobSupervisor) systemUptime() time.Duration {
+	contents, err := s.fs.ReadFileString("/proc/uptime")
+	if err != nil {
+		return 0
+	}
+
+	fields := strings.Fields(contents)
+	if len(fields) == 0 {
+		return 0
+	}
+
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (s *systemdJobSupervisor) stoppedFilePath() string {
+	return filepath.Join(s.dirProvider.BoshDir(), "systemd_stopped")
+}
+
+func (s *systemdJobSupervisor) setUnmonitored(unmonitored bool) {
+	s.unmonitoredLock.Lock()
+	defer s.unmonitoredLock.Unlock()
+
+	s.unmonitored = unmonitored
+}
+
+func (s *systemdJobSupervisor) isUnmonitored() bool {
+	s.unmonitoredLock.Lock()
+	defer s.unmonitoredLock.Unlock()
+
+	return s.unmonitored
+}
+
+// parseSystemctlShow parses the blank line separated property blocks
+// printed by 'systemctl show' for multiple units
+func parseSystemctlShow(output string) []systemdUnitStatus {
+	var statuses []systemdUnitStatus
+
+	for _, block := range strings.Split(strings.TrimSpace(output), "\n\n") {
+		var status systemdUnitStatus
+
+		for _, line := range strings.Split(block, "\n") {
+			parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			switch parts[0] {
+			case "Id":
+				status.Unit = parts[1]
+			case "ActiveState":
+				status.ActiveState = parts[1]
+			case "NRestarts":
+				status.Restarts, _ = strconv.Atoi(parts[1])
+			case "MainPID":
+				status.MainPID, _ = strconv.Atoi(parts[1])
+			case "MemoryCurrent":
+				// Reported as [not set] without memory accounting
+				status.MemoryBytes, _ = strconv.ParseUint(parts[1], 10, 64)
+			case "CPUUsageNSec":
+				status.CPUUsageNSec, _ = strconv.ParseUint(parts[1], 10, 64)
+			case "TasksCurrent":
+				status.TasksCurrent, _ = strconv.ParseUint(parts[1], 10, 64)
+			case "ActiveEnterTimestampMonotonic":
+				status.ActiveEnterMonotonic, _ = strconv.ParseUint(parts[1], 10, 64)
+			}
+		}
+
+		if status.Unit != "" {
+			statuses = append(statuses, status)
+		}
+	}
+
+	return statuses
+}
+
+func processState(activeState string) string {
+	switch activeState {
+	case "active":
+		return "running"
+	case "activating", "reloading":
+		return "starting"
+	case "deactivating":
+		return "stopping"
+	case "inactive":
+		return "stopped"
+	default:
+		return "failing"
+	}
+}
@@ -0,0 +1,494 @@
+package jobsupervisor_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("systemdJobSupervisor", func() {
+	const (
+		pollInterval = 5 * time.Second
+		showCommand  = "systemctl show --property=Id,ActiveState,NRestarts,MainPID,MemoryCurrent,ActiveEnterTimestampMonotonic bosh-web.service bosh-worker.service"
+	)
+
+	var (
+		fs          *fakesys.FakeFileSystem
+		runner      *fakesys.FakeCmdRunner
+		timeService *fakeclock.FakeClock
+		supervisor  JobSupervisor
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		runner = fakesys.NewFakeCmdRunner()
+		timeService = fakeclock.NewFakeClock(time.Date(2021, 5, 22, 20, 7, 41, 0, time.UTC))
+
+		supervisor = NewSystemdJobSupervisor(
+			fs,
+			runner,
+			boshdir.NewProvider("/var/vcap"),
+			SystemdOptions{
+				UnitsDir:        "/etc/systemd/system",
+				RuntimeUnitsDir: "/run/systemd/system",
+				Slice:           "bosh.slice",
+				PollInterval:    pollInterval,
+			},
+			timeService,
+			boshlog.NewLogger(boshlog.LevelNone),
+		)
+
+		fs.SetGlob("/etc/systemd/system/bosh-*.service", []string{
+			"/etc/systemd/system/bosh-web.service",
+			"/etc/systemd/system/bosh-worker.service",
+		})
+	})
+
+	addShowResult := func(stdout string) {
+		runner.AddCmdResult(showCommand, fakesys.FakeCmdResult{Stdout: stdout})
+	}
+
+	Describe("AddJob", func() {
+		It("translates monit process checks into units in the bosh slice", func() {
+			err := fs.WriteFileString("/var/vcap/jobs/web/monit", `
+check process web
+  with pidfile /var/vcap/sys/run/web/web.pid
+  start program "/var/vcap/jobs/web/bin/ctl start" as uid vcap and gid vcap with timeout 60 seconds
+  stop program = "/var/vcap/jobs/web/bin/ctl stop $PID"
+  group vcap
+  depends on worker
+  if totalmem > 500 Mb then alert  # resource rules are not translated
+
+check file web_config with path /var/vcap/jobs/web/config/web.yml
+  group vcap
+
+check process worker
+  matching "worker"
+  start program "/var/vcap/jobs/web/bin/worker_ctl start"
+  group vcap
+`)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")
+			Expect(err).ToNot(HaveOccurred())
+
+			webUnit, err := fs.ReadFileString("/etc/systemd/system/bosh-web.service")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webUnit).To(Equal(`[Unit]
+Description=BOSH job web process web
+X-BoshJob=web
+Requires=bosh-worker.service
+After=bosh-worker.service
+
+[Service]
+Type=forking
+PIDFile=/var/vcap/sys/run/web/web.pid
+ExecStart=/bin/sh -c "/var/vcap/jobs/web/bin/ctl start"
+ExecStop=/bin/sh -c "/var/vcap/jobs/web/bin/ctl stop $$PID"
+User=vcap
+Group=vcap
+TimeoutStartSec=60
+Slice=bosh.slice
+Restart=on-failure
+RestartSec=5
+`))
+
+			workerUnit, err := fs.ReadFileString("/etc/systemd/system/bosh-worker.service")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(workerUnit).To(ContainSubstring(`ExecStart=/bin/sh -c "/var/vcap/jobs/web/bin/worker_ctl start"`))
+			Expect(workerUnit).ToNot(ContainSubstring("ExecStop"))
+
+			Expect(fs.FileExists("/etc/systemd/system/bosh-web_config.service")).To(BeFalse())
+		})
+
+		It("uses units shipped with the job", func() {
+			err := fs.WriteFileString("/var/vcap/jobs/web/monit", `check process web
+  start program "/var/vcap/jobs/web/bin/ctl start"`)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = fs.WriteFileString("/var/vcap/jobs/web/systemd/web.service", "[Service]\nExecStart=/var/vcap/packages/web/bin/web\n")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")
+			Expect(err).ToNot(HaveOccurred())
+
+			unit, err := fs.ReadFileString("/etc/systemd/system/bosh-web.service")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unit).To(Equal("[Service]\nExecStart=/var/vcap/packages/web/bin/web\n"))
+
+			dropIn, err := fs.ReadFileString("/etc/systemd/system/bosh-web.service.d/bosh.conf")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dropIn).To(Equal("[Unit]\nX-BoshJob=web\n\n[Service]\nSlice=bosh.slice\n"))
+		})
+
+		It("returns an error when a process does not have a start program", func() {
+			err := fs.WriteFileString("/var/vcap/jobs/web/monit", "check process web\n  group vcap\n")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("does not have a start program"))
+		})
+
+		It("returns an error when the monit file cannot be read", func() {
+			err := supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading job config from file"))
+		})
+	})
+
+	Describe("SetResourceLimits", func() {
+		It("writes a slice for the job and places the job's units in it", func() {
+			err := supervisor.SetResourceLimits("web", cgroup.Limits{CPUWeight: 50, CPUMax: "150000 100000", MemoryMax: "512M", PidsMax: 100})
+			Expect(err).ToNot(HaveOccurred())
+
+			slice, err := fs.ReadFileString("/etc/systemd/system/bosh-web.slice")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(slice).To(Equal(`[Unit]
+Description=BOSH job web
+
+[Slice]
+CPUWeight=50
+CPUQuota=150%
+CPUQuotaPeriodSec=100000us
+MemoryMax=512M
+TasksMax=100
+`))
+
+			Expect(fs.WriteFileString("/var/vcap/jobs/web/monit", "check process web\n  start program \"/bin/web\"\n")).To(Succeed())
+			Expect(fs.WriteFileString("/var/vcap/jobs/web/worker.monit", "check process worker\n  start program \"/bin/worker\"\n")).To(Succeed())
+
+			Expect(supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")).To(Succeed())
+			Expect(supervisor.AddJob("web", "web_worker", 0, "/var/vcap/jobs/web/worker.monit")).To(Succeed())
+
+			Expect(fs.ReadFileString("/etc/systemd/system/bosh-web.service")).To(ContainSubstring("Slice=bosh-web.slice\n"))
+			Expect(fs.ReadFileString("/etc/systemd/system/bosh-worker.service")).To(ContainSubstring("Slice=bosh-web.slice\n"))
+		})
+
+		It("escapes dashes so that the job slice is not nested further", func() {
+			err := supervisor.SetResourceLimits("my-job", cgroup.Limits{MemoryMax: "max"})
+			Expect(err).ToNot(HaveOccurred())
+
+			slice, err := fs.ReadFileString(`/etc/systemd/system/bosh-my\x2djob.slice`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(slice).To(ContainSubstring("MemoryMax=infinity\n"))
+		})
+
+		It("does not write a slice without limits", func() {
+			err := supervisor.SetResourceLimits("web", cgroup.Limits{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists("/etc/systemd/system/bosh-web.slice")).To(BeFalse())
+		})
+
+		It("returns an error for an invalid cpu max", func() {
+			err := supervisor.SetResourceLimits("web", cgroup.Limits{CPUMax: "half"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid cpu_max quota 'half'"))
+		})
+	})
+
+	Describe("RemoveAllJobs", func() {
+		It("removes job slices", func() {
+			Expect(fs.WriteFileString("/etc/systemd/system/bosh-web.slice", "")).To(Succeed())
+			fs.SetGlob("/etc/systemd/system/bosh-*.slice", []string{"/etc/systemd/system/bosh-web.slice"})
+
+			err := supervisor.RemoveAllJobs()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists("/etc/systemd/system/bosh-web.slice")).To(BeFalse())
+		})
+
+		It("removes all generated units and drop-ins", func() {
+			Expect(fs.WriteFileString("/etc/systemd/system/bosh-web.service", "")).To(Succeed())
+			Expect(fs.WriteFileString("/etc/systemd/system/bosh-web.service.d/bosh.conf", "")).To(Succeed())
+			fs.SetGlob("/etc/systemd/system/bosh-*.service*", []string{
+				"/etc/systemd/system/bosh-web.service",
+				"/etc/systemd/system/bosh-web.service.d",
+			})
+
+			err := supervisor.RemoveAllJobs()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists("/etc/systemd/system/bosh-web.service")).To(BeFalse())
+			Expect(fs.FileExists("/etc/systemd/system/bosh-web.service.d/bosh.conf")).To(BeFalse())
+		})
+
+		It("stops the units before removing them and reloads systemd afterwards", func() {
+			Expect(fs.WriteFileString("/run/systemd/system/bosh-web.service.d/bosh-unmonitored.conf", "")).To(Succeed())
+			fs.SetGlob("/run/systemd/system/bosh-*.service.d", []string{"/run/systemd/system/bosh-web.service.d"})
+
+			err := supervisor.RemoveAllJobs()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(runner.RunCommands).To(Equal([][]string{
+				{"systemctl", "stop", "bosh-web.service", "bosh-worker.service"},
+				{"systemctl", "daemon-reload"},
+			}))
+			Expect(fs.FileExists("/run/systemd/system/bosh-web.service.d/bosh-unmonitored.conf")).To(BeFalse())
+		})
+
+		It("does not remove the units when they cannot be stopped", func() {
+			Expect(fs.WriteFileString("/etc/systemd/system/bosh-web.service", "")).To(Succeed())
+			fs.SetGlob("/etc/systemd/system/bosh-*.service*", []string{"/etc/systemd/system/bosh-web.service"})
+			runner.AddCmdResult("systemctl stop bosh-web.service bosh-worker.service", fakesys.FakeCmdResult{Error: errors.New("fake-stop-err")})
+
+			err := supervisor.RemoveAllJobs()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-stop-err"))
+			Expect(fs.FileExists("/etc/systemd/system/bosh-web.service")).To(BeTrue())
+		})
+	})
+
+	Describe("Unmonitor", func() {
+		It("stops systemd from restarting the units until they are started again", func() {
+			err := supervisor.Unmonitor()
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, unit := range []string{"bosh-web.service", "bosh-worker.service"} {
+				dropIn, err := fs.ReadFileString("/run/systemd/system/" + unit + ".d/bosh-unmonitored.conf")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(dropIn).To(Equal("[Service]\nRestart=no\n"))
+			}
+			Expect(runner.RunCommands).To(Equal([][]string{{"systemctl", "daemon-reload"}}))
+
+			err = supervisor.Start()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists("/run/systemd/system/bosh-web.service.d/bosh-unmonitored.conf")).To(BeFalse())
+			Expect(fs.FileExists("/run/systemd/system/bosh-worker.service.d/bosh-unmonitored.conf")).To(BeFalse())
+			Expect(runner.RunCommands).To(Equal([][]string{
+				{"systemctl", "daemon-reload"},
+				{"systemctl", "daemon-reload"},
+				{"systemctl", "start", "--no-block", "bosh-web.service", "bosh-worker.service"},
+			}))
+		})
+
+		It("returns an error when systemd cannot be reloaded", func() {
+			runner.AddCmdResult("systemctl daemon-reload", fakesys.FakeCmdResult{Error: errors.New("fake-reload-err")})
+
+			err := supervisor.Unmonitor()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-reload-err"))
+		})
+	})
+
+	Describe("Reload", func() {
+		It("reloads systemd", func() {
+			err := supervisor.Reload()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.RunCommands).To(Equal([][]string{{"systemctl", "daemon-reload"}}))
+		})
+	})
+
+	Describe("Start", func() {
+		It("starts all units and removes the stopped file", func() {
+			Expect(fs.WriteFileString("/var/vcap/bosh/systemd_stopped", "")).To(Succeed())
+
+			err := supervisor.Start()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(runner.RunCommands).To(Equal([][]string{
+				{"systemctl", "start", "--no-block", "bosh-web.service", "bosh-worker.service"},
+			}))
+			Expect(fs.FileExists("/var/vcap/bosh/systemd_stopped")).To(BeFalse())
+		})
+
+		It("returns an error when systemctl fails", func() {
+			runner.AddCmdResult("systemctl start --no-block bosh-web.service bosh-worker.service", fakesys.FakeCmdResult{Error: errors.New("fake-start-err")})
+
+			err := supervisor.Start()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-start-err"))
+		})
+	})
+
+	Describe("Stop", func() {
+		It("stops all units without waiting and reports the jobs as stopped", func() {
+			err := supervisor.Stop()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(runner.RunCommands).To(Equal([][]string{
+				{"systemctl", "stop", "--no-block", "bosh-web.service", "bosh-worker.service"},
+			}))
+			Expect(supervisor.Status()).To(Equal("stopped"))
+		})
+	})
+
+	Describe("StopAndWait", func() {
+		It("stops all units and waits for them to stop", func() {
+			_, err := supervisor.StopAndWait()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(runner.RunCommands).To(Equal([][]string{
+				{"systemctl", "stop", "bosh-web.service", "bosh-worker.service"},
+			}))
+			Expect(supervisor.Status()).To(Equal("stopped"))
+		})
+	})
+
+	Describe("StartService and StopService", func() {
+		BeforeEach(func() {
+			Expect(fs.WriteFileString("/etc/systemd/system/bosh-web.service", "[Unit]\nX-BoshJob=web\n")).To(Succeed())
+			Expect(fs.WriteFileString("/etc/systemd/system/bosh-worker.service", "[Service]\n")).To(Succeed())
+			Expect(fs.WriteFileString("/etc/systemd/system/bosh-worker.service.d/bosh.conf", "[Unit]\nX-BoshJob=web\n")).To(Succeed())
+		})
+
+		It("starts and stops all units of a job", func() {
+			Expect(supervisor.StartService("web")).To(Succeed())
+			Expect(supervisor.StopService("web")).To(Succeed())
+
+			Expect(runner.RunCommands).To(Equal([][]string{
+				{"systemctl", "start", "--no-block", "bosh-web.service", "bosh-worker.service"},
+				{"systemctl", "stop", "bosh-web.service", "bosh-worker.service"},
+			}))
+		})
+
+		It("starts and stops the unit of a single process", func() {
+			Expect(supervisor.StartService("worker")).To(Succeed())
+			Expect(supervisor.StopService("worker")).To(Succeed())
+
+			Expect(runner.RunCommands).To(Equal([][]string{
+				{"systemctl", "start", "--no-block", "bosh-worker.service"},
+				{"systemctl", "stop", "bosh-worker.service"},
+			}))
+		})
+
+		It("returns an error for unknown names", func() {
+			err := supervisor.StartService("unknown")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unknown job or service 'unknown'"))
+			Expect(runner.RunCommands).To(BeEmpty())
+		})
+	})
+
+	Describe("Status", func() {
+		It("is running when all units are active", func() {
+			addShowResult("Id=bosh-web.service\nActiveState=active\n\nId=bosh-worker.service\nActiveState=active\n")
+			Expect(supervisor.Status()).To(Equal("running"))
+		})
+
+		It("is starting when any unit is activating", func() {
+			addShowResult("Id=bosh-web.service\nActiveState=failed\n\nId=bosh-worker.service\nActiveState=activating\n")
+			Expect(supervisor.Status()).To(Equal("starting"))
+		})
+
+		It("is failing when any unit is not active", func() {
+			addShowResult("Id=bosh-web.service\nActiveState=active\n\nId=bosh-worker.service\nActiveState=failed\n")
+			Expect(supervisor.Status()).To(Equal("failing"))
+		})
+
+		It("is unknown when systemctl fails", func() {
+			runner.AddCmdResult(showCommand, fakesys.FakeCmdResult{Error: errors.New("fake-show-err")})
+			Expect(supervisor.Status()).To(Equal("unknown"))
+		})
+	})
+
+	Describe("Processes", func() {
+		It("reports the state, uptime and memory of every unit", func() {
+			Expect(fs.WriteFileString("/proc/uptime", "1000.50 3000.00\n")).To(Succeed())
+			addShowResult(`Id=bosh-web.service
+ActiveState=active
+NRestarts=0
+MainPID=4242
+MemoryCurrent=2097152
+ActiveEnterTimestampMonotonic=400500000
+
+Id=bosh-worker.service
+ActiveState=failed
+NRestarts=3
+MainPID=0
+MemoryCurrent=[not set]
+ActiveEnterTimestampMonotonic=0
+`)
+
+			processes, err := supervisor.Processes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processes).To(Equal([]Process{
+				{Name: "web", State: "running", PID: 4242, Uptime: UptimeVitals{Secs: 600}, Memory: MemoryVitals{Kb: 2048}},
+				{Name: "worker", State: "failing"},
+			}))
+		})
+
+		It("reports the usage of job slices", func() {
+			Expect(supervisor.SetResourceLimits("web", cgroup.Limits{PidsMax: 10})).To(Succeed())
+			Expect(fs.WriteFileString("/var/vcap/jobs/web/monit", "check process web\n  start program \"/bin/web\"\n")).To(Succeed())
+			Expect(supervisor.AddJob("web", "web", 0, "/var/vcap/jobs/web/monit")).To(Succeed())
+
+			addShowResult("Id=bosh-web.service\nActiveState=active\n\nId=bosh-worker.service\nActiveState=active\n")
+			runner.AddCmdResult(
+				"systemctl show --property=Id,MemoryCurrent,CPUUsageNSec,TasksCurrent bosh-web.slice",
+				fakesys.FakeCmdResult{Stdout: "Id=bosh-web.slice\nMemoryCurrent=4194304\nCPUUsageNSec=3000000\nTasksCurrent=4\n"},
+			)
+
+			processes, err := supervisor.Processes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processes[0].Cgroup).To(Equal(&CgroupVitals{MemoryKb: 4096, CPUUsageUsec: 3000, Pids: 4}))
+			Expect(processes[0].Job).To(Equal("web"))
+			Expect(processes[1].Cgroup).To(BeNil())
+		})
+	})
+
+	Describe("MonitorJobFailures", func() {
+		var handledAlerts chan boshalert.MonitAlert
+
+		BeforeEach(func() {
+			handledAlerts = make(chan boshalert.MonitAlert, 10)
+		})
+
+		startMonitoring := func() {
+			go func() {
+				defer GinkgoRecover()
+
+				_ = supervisor.MonitorJobFailures(func(alert boshalert.MonitAlert) error {
+					handledAlerts <- alert
+					return nil
+				})
+			}()
+		}
+
+		It("reports restarted and failed units", func() {
+			addShowResult("Id=bosh-web.service\nActiveState=active\nNRestarts=0\n\nId=bosh-worker.service\nActiveState=active\nNRestarts=0\n")
+			addShowResult("Id=bosh-web.service\nActiveState=active\nNRestarts=1\n\nId=bosh-worker.service\nActiveState=failed\nNRestarts=0\n")
+
+			startMonitoring()
+			timeService.WaitForWatcherAndIncrement(pollInterval)
+
+			var alert boshalert.MonitAlert
+			Eventually(handledAlerts).Should(Receive(&alert))
+			Expect(alert).To(Equal(boshalert.MonitAlert{
+				Service:     "web",
+				Event:       "does not exist",
+				Action:      "restart",
+				Date:        "Sat, 22 May 2021 20:07:46 +0000",
+				Description: "process is not running",
+			}))
+
+			Eventually(handledAlerts).Should(Receive(&alert))
+			Expect(alert.Service).To(Equal("worker"))
+			Expect(alert.Action).To(Equal("alert"))
+		})
+
+		It("does not report failures after the jobs were unmonitored", func() {
+			addShowResult("Id=bosh-web.service\nActiveState=active\nNRestarts=0\n")
+			addShowResult("Id=bosh-web.service\nActiveState=active\nNRestarts=1\n")
+
+			Expect(supervisor.Unmonitor()).To(Succeed())
+
+			startMonitoring()
+			timeService.WaitForWatcherAndIncrement(pollInterval)
+			Eventually(func() int { return len(runner.RunCommandsQuietly) }).Should(Equal(2))
+
+			Consistently(handledAlerts).ShouldNot(Receive())
+		})
+	})
+})
@@ -0,0 +1,361 @@
+package jobsupervisor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// monitProcess is the subset of a monit 'check process' definition
+// that can be expressed as a systemd service
+type monitProcess struct {
+	Name         string
+	PidFile      string
+	StartProgram monitProgram
+	StopProgram  monitProgram
+	DependsOn    []string
+}
+
+type monitProgram struct {
+	Command        string
+	UID            string
+	GID            string
+	TimeoutSeconds string
+}
+
+type monitToken struct {
+	value  string
+	quoted bool
+}
+
+// parseMonitProcesses extracts process checks from a job's monit file.
+// Other checks (files, hosts, ...) and resource rules are ignored.
+func parseMonitProcesses(config string) ([]monitProcess, error) {
+	tokens, err := tokenizeMonitConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []monitProcess
+	var current *monitProcess
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.quoted {
+			continue
+		}
+
+		switch strings.ToLower(token.value) {
+		case "check":
+			if current != nil {
+				processes = append(processes, *current)
+				current = nil
+			}
+
+			if i+2 < len(tokens) && strings.ToLower(tokens[i+1].value) == "process" {
+				current = &monitProcess{Name: tokens[i+2].value}
+				i += 2
+			}
+
+		case "pidfile":
+			if current != nil && i+1 < len(tokens) {
+				current.PidFile = tokens[i+1].value
+				i++
+			}
+
+		case "start", "stop":
+			if current == nil {
+				continue
+			}
+
+			program, consumed := parseMonitProgram(tokens[i+1:])
+			if program.Command == "" {
+				continue
+			}
+
+			if strings.ToLower(token.value) == "start" {
+				current.StartProgram = program
+			} else {
+				current.StopProgram = program
+			}
+
+			i += consumed
+
+		case "depends":
+			if current == nil {
+				continue
+			}
+
+			j := i + 1
+			if j < len(tokens) && strings.ToLower(tokens[j].value) == "on" {
+				j++
+			}
+
+			for ; j < len(tokens) && !tokens[j].quoted; j++ {
+				names := strings.Split(tokens[j].value, ",")
+				for _, name := range names {
+					if name != "" {
+						current.DependsOn = append(current.DependsOn, name)
+					}
+				}
+
+				if !strings.HasSuffix(tokens[j].value, ",") && (j+1 >= len(tokens) || tokens[j+1].value != ",") {
+					break
+				}
+			}
+
+			i = j
+		}
+	}
+
+	if current != nil {
+		processes = append(processes, *current)
+	}
+
+	for _, process := range processes {
+		if process.StartProgram.Command == "" {
+			return nil, fmt.Errorf("Process '%s' does not have a start program", process.Name)
+		}
+	}
+
+	return processes, nil
+}
+
+// parseMonitProgram parses '[program] [=] "command" [as uid U [and] gid G] [with timeout N seconds]'
+// and returns the number of tokens consumed
+func parseMonitProgram(tokens []monitToken) (monitProgram, int) {
+	var program monitProgram
+
+	i := 0
+	for i < len(tokens) && !tokens[i].quoted {
+		keyword := strings.TrimSuffix(strings.ToLower(tokens[i].value), "=")
+		if keyword != "program" && keyword != "" {
+			break
+		}
+		i++
+	}
+
+	if i >= len(tokens) || !tokens[i].quoted {
+		return program, 0
+	}
+
+	program.Command = tokens[i].value
+	i++
+
+	for i < len(tokens) && !tokens[i].quoted {
+		switch strings.ToLower(tokens[i].value) {
+		case "as", "and", "with", "seconds", "second":
+			i++
+		case "uid":
+			if i+1 < len(tokens) {
+				program.UID = tokens[i+1].value
+			}
+			i += 2
+		case "gid":
+			if i+1 < len(tokens) {
+				program.GID = tokens[i+1].value
+			}
+			i += 2
+		case "timeout":
+			if i+1 < len(tokens) {
+				program.TimeoutSeconds = tokens[i+1].value
+			}
+			i += 2
+		default:
+			return program, i
+		}
+	}
+
+	return program, i
+}
+
+func tokenizeMonitConfig(config string) ([]monitToken, error) {
+	var tokens []monitToken
+
+	runes := []rune(config)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			continue
+
+		case r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+
+			if end >= len(runes) {
+				return nil, fmt.Errorf("Unterminated quoted string in monit config")
+			}
+
+			tokens = append(tokens, monitToken{value: string(runes[i+1 : end]), quoted: true})
+			i = end
+
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' && runes[end] != '\'' {
+				end++
+			}
+
+			tokens = append(tokens, monitToken{value: string(runes[i:end])})
+			i = end - 1
+		}
+	}
+
+	return tokens, nil
+}
+
+// systemdJobKey records the job of a unit; systemd ignores keys prefixed with X-
+const systemdJobKey = "X-BoshJob"
+
+func systemdUnitName(processName string) string {
+	return "bosh-" + processName + ".service"
+}
+
+func renderSystemdUnit(jobName string, process monitProcess, slice string) string {
+	var unit strings.Builder
+
+	unit.WriteString("[Unit]\n")
+	fmt.Fprintf(&unit, "Description=BOSH job %s process %s\n", jobName, process.Name)
+	fmt.Fprintf(&unit, "%s=%s\n", systemdJobKey, jobName)
+
+	for _, dependency := range process.DependsOn {
+		fmt.Fprintf(&unit, "Requires=%s\n", systemdUnitName(dependency))
+		fmt.Fprintf(&unit, "After=%s\n", systemdUnitName(dependency))
+	}
+
+	unit.WriteString("\n[Service]\n")
+	unit.WriteString("Type=forking\n")
+
+	if process.PidFile != "" {
+		fmt.Fprintf(&unit, "PIDFile=%s\n", process.PidFile)
+	}
+
+	fmt.Fprintf(&unit, "ExecStart=/bin/sh -c %s\n", quoteSystemdArgument(process.StartProgram.Command))
+
+	if process.StopProgram.Command != "" {
+		fmt.Fprintf(&unit, "ExecStop=/bin/sh -c %s\n", quoteSystemdArgument(process.StopProgram.Command))
+	}
+
+	if process.StartProgram.UID != "" {
+		fmt.Fprintf(&unit, "User=%s\n", process.StartProgram.UID)
+	}
+
+	if process.StartProgram.GID != "" {
+		fmt.Fprintf(&unit, "Group=%s\n", process.StartProgram.GID)
+	}
+
+	if process.StartProgram.TimeoutSeconds != "" {
+		fmt.Fprintf(&unit, "TimeoutStartSec=%s\n", process.StartProgram.TimeoutSeconds)
+	}
+
+	if process.StopProgram.TimeoutSeconds != "" {
+		fmt.Fprintf(&unit, "TimeoutStopSec=%s\n", process.StopProgram.TimeoutSeconds)
+	}
+
+	fmt.Fprintf(&unit, "Slice=%s\n", slice)
+	unit.WriteString("Restart=on-failure\n")
+	unit.WriteString("RestartSec=5\n")
+
+	return unit.String()
+}
+
+// quoteSystemdArgument quotes a command line argument so that systemd
+// neither splits it nor expands specifiers or environment variables in it
+func quoteSystemdArgument(arg string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		`%`, `%%`,
+		`$`, `$$`,
+	)
+
+	return `"` + replacer.Replace(arg) + `"`
+}
+
+// renderSystemdSlice translates cgroup v2 limits into the equivalent
+// resource control settings of a slice
+func renderSystemdSlice(jobName string, limits cgroup.Limits) (string, error) {
+	var slice strings.Builder
+
+	slice.WriteString("[Unit]\n")
+	fmt.Fprintf(&slice, "Description=BOSH job %s\n", jobName)
+	slice.WriteString("\n[Slice]\n")
+
+	if limits.CPUWeight != 0 {
+		fmt.Fprintf(&slice, "CPUWeight=%d\n", limits.CPUWeight)
+	}
+
+	if limits.CPUMax != "" {
+		fields := strings.Fields(limits.CPUMax)
+		if len(fields) == 0 || len(fields) > 2 {
+			return "", bosherr.Errorf("Invalid cpu_max '%s'", limits.CPUMax)
+		}
+
+		period := 100000
+		if len(fields) == 2 {
+			var err error
+			period, err = strconv.Atoi(fields[1])
+			if err != nil || period <= 0 {
+				return "", bosherr.Errorf("Invalid cpu_max period '%s'", fields[1])
+			}
+		}
+
+		if fields[0] != "max" {
+			quota, err := strconv.Atoi(fields[0])
+			if err != nil || quota <= 0 {
+				return "", bosherr.Errorf("Invalid cpu_max quota '%s'", fields[0])
+			}
+
+			// systemd only accepts whole percentages
+			percent := (quota*100 + period - 1) / period
+			fmt.Fprintf(&slice, "CPUQuota=%d%%\n", percent)
+			fmt.Fprintf(&slice, "CPUQuotaPeriodSec=%dus\n", period)
+		}
+	}
+
+	if limits.MemoryMax != "" {
+		memoryMax := limits.MemoryMax
+		if memoryMax == "max" {
+			memoryMax = "infinity"
+		}
+		fmt.Fprintf(&slice, "MemoryMax=%s\n", memoryMax)
+	}
+
+	if limits.PidsMax != 0 {
+		fmt.Fprintf(&slice, "TasksMax=%d\n", limits.PidsMax)
+	}
+
+	return slice.String(), nil
+}
+
+// escapeSystemdName escapes a name for use in a unit name the way
+// systemd-escape does, so that dashes do not nest slices further
+func escapeSystemdName(name string) string {
+	var escaped strings.Builder
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+
+		isAlphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if isAlphanumeric || c == '_' || (c == '.' && i > 0) {
+			escaped.WriteByte(c)
+		} else {
+			fmt.Fprintf(&escaped, `\x%02x`, c)
+		}
+	}
+
+	return escaped.String()
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/cloudfoundry/bosh-agent/jobsupervisor/winsvc"
 
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -263,9 +264,9 @@ func (w *windowsJobSupervisor) Stop() error {
 	return nil
 }
 
-func (w *windowsJobSupervisor) StopAndWait() error {
+func (w *windowsJobSupervisor) StopAndWait() (StopResult, error) {
 	// Stop already does this for us
-	return w.Stop()
+	return StopResult{}, w.Stop()
 }
 
 func (w *windowsJobSupervisor) Unmonitor() error {
@@ -273,6 +274,30 @@ func (w *windowsJobSupervisor) Unmonitor() error {
 	return w.mgr.Unmonitor()
 }
 
+func (w *windowsJobSupervisor) StartService(name string) error {
+	return bosherr.Errorf("Starting individual service %s is not supported on Windows", name)
+}
+
+func (w *windowsJobSupervisor) StopService(name string) error {
+	return bosherr.Errorf("Stopping individual service %s is not supported on Windows", name)
+}
+
+func (w *windowsJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	if limits.IsEmpty() {
+		return nil
+	}
+
+	return bosherr.Errorf("Resource limits of job %s are not supported on Windows", jobName)
+}
+
+func (w *windowsJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	if len(dependencies) == 0 {
+		return nil
+	}
+
+	return bosherr.Errorf("Start dependencies of job %s are not supported on Windows", jobName)
+}
+
 func (w *windowsJobSupervisor) Status() (status string) {
 	if w.fs.FileExists(w.stoppedFilePath()) {
 		return "stopped"
@@ -333,7 +358,7 @@ func (w *windowsJobSupervisor) Processes() ([]Process, error) {
 	return procs, nil
 }
 
-func (w *windowsJobSupervisor) AddJob(jobName string, jobIndex int, configPath string) error {
+func (w *windowsJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
 	configFileContents, err := w.fs.ReadFile(configPath)
 	if err != nil {
 		return err
@@ -352,7 +377,7 @@ func (w *windowsJobSupervisor) AddJob(jobName string, jobIndex int, configPath s
 
 	var buf bytes.Buffer
 	for _, process := range processConfig.Processes {
-		logPath := path.Join(w.dirProvider.LogsDir(), jobName, process.Name)
+		logPath := path.Join(w.dirProvider.LogsDir(), configName, process.Name)
 		err := w.fs.MkdirAll(logPath, os.FileMode(0750))
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Creating log directory for service '%s'", process.Name)
@@ -287,7 +287,7 @@ func addFlappingJob(jobSupervisor JobSupervisor, jobDir string, fs boshsys.FileS
 		return &conf, err
 	}
 
-	err = jobSupervisor.AddJob(jobName, 0, confPath)
+	err = jobSupervisor.AddJob(jobName, jobName, 0, confPath)
 	if err != nil {
 		return nil, err
 	}
@@ -497,7 +497,7 @@ var _ = Describe("WindowsJobSupervisor", func() {
 			})
 
 			JustBeforeEach(func() {
-				Expect(jobSupervisor.AddJob(jobName, 0, confPath)).To(Succeed())
+				Expect(jobSupervisor.AddJob(jobName, jobName, 0, confPath)).To(Succeed())
 			})
 
 			Context("when the monit file is non-empty", func() {
@@ -555,7 +555,7 @@ var _ = Describe("WindowsJobSupervisor", func() {
 				confPath, err = writeJobConfig(jobDir, fs, conf)
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(jobSupervisor.AddJob(jobName, 0, confPath)).To(Succeed())
+				Expect(jobSupervisor.AddJob(jobName, jobName, 0, confPath)).To(Succeed())
 			})
 
 			Describe("Processes", func() {
@@ -741,7 +741,8 @@ var _ = Describe("WindowsJobSupervisor", func() {
 
 				It("waits for the services to be stopped", func() {
 					Expect(jobSupervisor.Start()).To(Succeed())
-					Expect(jobSupervisor.StopAndWait()).To(Succeed())
+					_, err := jobSupervisor.StopAndWait()
+					Expect(err).ToNot(HaveOccurred())
 
 					for _, proc := range conf.Processes {
 						st, err := GetServiceState(proc.Name)
@@ -895,7 +896,7 @@ var _ = Describe("WindowsJobSupervisor", func() {
 					confPath, err := writeJobConfig(jobDir, fs, conf)
 					Expect(err).ToNot(HaveOccurred())
 
-					Expect(jobSupervisor.AddJob("flap-start", 0, confPath)).To(Succeed())
+					Expect(jobSupervisor.AddJob("flap-start", "flap-start", 0, confPath)).To(Succeed())
 					Expect(jobSupervisor.Start()).To(Succeed())
 
 					for i := 0; i < 5; i++ {
@@ -937,7 +938,8 @@ var _ = Describe("WindowsJobSupervisor", func() {
 
 				Context("StopAndWait", func() {
 					It("stops flapping service", func() {
-						Expect(jobSupervisor.StopAndWait()).To(Succeed())
+						_, err := jobSupervisor.StopAndWait()
+						Expect(err).ToNot(HaveOccurred())
 
 						Consistently(func() bool {
 							stopped := true
@@ -991,7 +993,7 @@ var _ = Describe("WindowsJobSupervisor", func() {
 				confPath, err := writeJobConfig(jobDir, fs, conf)
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(jobSupervisor.AddJob("ConcurrentWait", 0, confPath)).To(Succeed())
+				Expect(jobSupervisor.AddJob("ConcurrentWait", "ConcurrentWait", 0, confPath)).To(Succeed())
 				Expect(jobSupervisor.Start()).To(Succeed())
 
 				// WARN WARN WARN
@@ -1,6 +1,7 @@
 package jobsupervisor
 
 import (
+	"github.com/cloudfoundry/bosh-agent/jobsupervisor/cgroup"
 
 	//boshmonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
 	//boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -49,7 +50,7 @@ func (w *wrapperJobSupervisor) Stop() error {
 
 	return err
 }
-func (w *wrapperJobSupervisor) StopAndWait() error {
+func (w *wrapperJobSupervisor) StopAndWait() (StopResult, error) {
 	return w.delegate.StopAndWait()
 }
 func (w *wrapperJobSupervisor) Unmonitor() error {
@@ -61,14 +62,34 @@ func (w *wrapperJobSupervisor) Unmonitor() error {
 	w.HealthRecorder(w.delegate.Status())
 	return err
 }
+func (w *wrapperJobSupervisor) StartService(name string) error {
+	err := w.delegate.StartService(name)
+	w.HealthRecorder(w.delegate.Status())
+
+	return err
+}
+func (w *wrapperJobSupervisor) StopService(name string) error {
+	err := w.delegate.StopService(name)
+	w.HealthRecorder(w.delegate.Status())
+
+	return err
+}
+
+func (w *wrapperJobSupervisor) SetResourceLimits(jobName string, limits cgroup.Limits) error {
+	return w.delegate.SetResourceLimits(jobName, limits)
+}
+
+func (w *wrapperJobSupervisor) SetStartDependencies(jobName string, dependencies []string) error {
+	return w.delegate.SetStartDependencies(jobName, dependencies)
+}
 func (w *wrapperJobSupervisor) Status() string {
 	return w.delegate.Status()
 }
 func (w *wrapperJobSupervisor) Processes() ([]Process, error) {
 	return w.delegate.Processes()
 }
-func (w *wrapperJobSupervisor) AddJob(jobName string, jobIndex int, configPath string) error {
-	return w.delegate.AddJob(jobName, jobIndex, configPath)
+func (w *wrapperJobSupervisor) AddJob(jobName string, configName string, jobIndex int, configPath string) error {
+	return w.delegate.AddJob(jobName, configName, jobIndex, configPath)
 }
 func (w *wrapperJobSupervisor) RemoveAllJobs() error {
 	return w.delegate.RemoveAllJobs()
@@ -83,7 +83,7 @@ var _ = Describe("WrapperJobSupervisor", func() {
 	It("StopAndWait should delegate to the underlying job supervisor", func() {
 		error := errors.New("BOOM")
 		fakeSupervisor.StopErr = error
-		err := wrapper.StopAndWait()
+		_, err := wrapper.StopAndWait()
 		Expect(fakeSupervisor.StoppedAndWaited).To(BeTrue())
 		Expect(err).To(Equal(error))
 	})
@@ -130,10 +130,11 @@ var _ = Describe("WrapperJobSupervisor", func() {
 	It("AddJob should delegate to the underlying job supervisor", func() {
 		error := errors.New("BOOM")
 		fakeSupervisor.StartErr = error
-		_ = wrapper.AddJob("name", 0, "path")
+		_ = wrapper.AddJob("name", "config-name", 0, "path")
 		Expect(fakeSupervisor.AddJobArgs).To(Equal([]fakes.AddJobArgs{
 			{
 				Name:       "name",
+				ConfigName: "config-name",
 				Index:      0,
 				ConfigPath: "path",
 			},
@@ -41,6 +41,20 @@ none on /tmp/warden/cgroup type tmpfs (rw)`,
 				}))
 			})
 
+			It("marks mounts with the ro option as read-only", func() {
+				runner.AddCmdResult("mount", fakesys.FakeCmdResult{
+					Stdout: `/dev/sdb2 on /var/vcap/store type ext4 (ro,relatime)
+/dev/sda1 on /boot type ext2 (rw)`,
+				})
+
+				mounts, err := searcher.SearchMounts()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(mounts).To(Equal([]Mount{
+					Mount{PartitionPath: "/dev/sdb2", MountPoint: "/var/vcap/store", ReadOnly: true},
+					Mount{PartitionPath: "/dev/sda1", MountPoint: "/boot"},
+				}))
+			})
+
 			It("ignores empty lines", func() {
 				runner.AddCmdResult("mount", fakesys.FakeCmdResult{
 					Stdout: `
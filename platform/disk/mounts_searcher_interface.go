@@ -5,6 +5,7 @@ import "strings"
 type Mount struct {
 	PartitionPath string
 	MountPoint    string
+	ReadOnly      bool
 }
 
 func (m Mount) IsRoot() bool {
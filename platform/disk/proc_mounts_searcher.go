@@ -30,11 +30,28 @@ func (s procMountsSearcher) SearchMounts() ([]Mount, error) {
 
 		mountFields := strings.Fields(mountEntry)
 
-		mounts = append(mounts, Mount{
+		mount := Mount{
 			PartitionPath: mountFields[0],
 			MountPoint:    mountFields[1],
-		})
+		}
+
+		// e.g. 'none /run/lock tmpfs ro,nosuid,nodev 0 0'
+		if len(mountFields) > 3 {
+			mount.ReadOnly = hasReadOnlyOption(mountFields[3])
+		}
+
+		mounts = append(mounts, mount)
 	}
 
 	return mounts, nil
 }
+
+func hasReadOnlyOption(options string) bool {
+	for _, option := range strings.Split(options, ",") {
+		if option == "ro" {
+			return true
+		}
+	}
+
+	return false
+}
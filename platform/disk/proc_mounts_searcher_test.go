@@ -42,6 +42,21 @@ none /tmp/warden/cgroup tmpfs rw,relatime 0 0`,
 				}))
 			})
 
+			It("marks mounts with the ro option as read-only", func() {
+				fs.WriteFileString(
+					"/proc/mounts",
+					`/dev/sdb2 /var/vcap/store ext4 ro,relatime,errors=remount-ro 0 0
+/dev/sda1 /boot ext2 rw,relatime,errors=continue 0 0`,
+				)
+
+				mounts, err := searcher.SearchMounts()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(mounts).To(Equal([]Mount{
+					Mount{PartitionPath: "/dev/sdb2", MountPoint: "/var/vcap/store", ReadOnly: true},
+					Mount{PartitionPath: "/dev/sda1", MountPoint: "/boot"},
+				}))
+			})
+
 			It("ignores empty lines", func() {
 				fs.WriteFileString("/proc/mounts", `
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/cloudfoundry/bosh-agent/platform/disk"
 )
@@ -278,25 +279,58 @@ func (e Env) GetParallel() *int {
 	return &result
 }
 
+// GetRetainedApplySpecs returns how many previously applied specs are
+// kept, together with their jobs and packages, to roll back to. None are
+// kept unless configured since they take up disk space.
+func (e Env) GetRetainedApplySpecs() int {
+	if e.Bosh.RetainedApplySpecs != nil {
+		return *e.Bosh.RetainedApplySpecs
+	}
+	return 0
+}
+
+// GetCompileCacheSizeInBytes returns how much disk space compiled packages
+// kept for reuse on compilation VMs may take up. The cache is disabled
+// unless a size is configured.
+func (e Env) GetCompileCacheSizeInBytes() int64 {
+	sizeInMB := uint64(0)
+	if e.Bosh.CompileCacheSizeInMB != nil {
+		sizeInMB = *e.Bosh.CompileCacheSizeInMB
+	}
+	return int64(sizeInMB * 1024 * 1024)
+}
+
+// GetDrainTimeout returns the time drain scripts of jobs without a drain
+// deadline in the apply spec may run, zero when they may run forever
+func (e Env) GetDrainTimeout() time.Duration {
+	return time.Duration(e.Bosh.DrainTimeout) * time.Second
+}
+
 func (e Env) IsNATSMutualTLSEnabled() bool {
 	return len(e.Bosh.Mbus.Cert.Certificate) > 0 && len(e.Bosh.Mbus.Cert.PrivateKey) > 0
 }
 
 type BoshEnv struct {
-	Agent                 AgentEnv    `json:"agent"`
-	Password              string      `json:"password"`
-	KeepRootPassword      bool        `json:"keep_root_password"`
-	RemoveDevTools        bool        `json:"remove_dev_tools"`
-	RemoveStaticLibraries bool        `json:"remove_static_libraries"`
-	AuthorizedKeys        []string    `json:"authorized_keys"`
-	SwapSizeInMB          *uint64     `json:"swap_size"`
-	Mbus                  MBus        `json:"mbus"`
-	IPv6                  IPv6        `json:"ipv6"`
-	JobDir                JobDir      `json:"job_dir"`
-	RunDir                RunDir      `json:"run_dir"`
-	Blobstores            []Blobstore `json:"blobstores"`
-	NTP                   []string    `json:"ntp"`
-	Parallel              *int        `json:"parallel"`
+	Agent                 AgentEnv       `json:"agent"`
+	Password              string         `json:"password"`
+	KeepRootPassword      bool           `json:"keep_root_password"`
+	RemoveDevTools        bool           `json:"remove_dev_tools"`
+	RemoveStaticLibraries bool           `json:"remove_static_libraries"`
+	AuthorizedKeys        []string       `json:"authorized_keys"`
+	SwapSizeInMB          *uint64        `json:"swap_size"`
+	Mbus                  MBus           `json:"mbus"`
+	IPv6                  IPv6           `json:"ipv6"`
+	JobDir                JobDir         `json:"job_dir"`
+	RunDir                RunDir         `json:"run_dir"`
+	Blobstores            []Blobstore    `json:"blobstores"`
+	NTP                   []string       `json:"ntp"`
+	Parallel              *int           `json:"parallel"`
+	DrainTimeout          int            `json:"drain_timeout"`
+	RetainedApplySpecs    *int           `json:"retained_apply_specs"`
+	CompileCacheSizeInMB  *uint64        `json:"compile_cache_size_mb"`
+	CompileSandbox        CompileSandbox `json:"compile_sandbox"`
+	Diagnostics           Diagnostics    `json:"diagnostics"`
+	Webhooks              []Webhook      `json:"webhooks"`
 }
 
 type AgentEnv struct {
@@ -329,6 +363,39 @@ type JobDir struct {
 	TmpFSSize string `json:"tmpfs_size"`
 }
 
+type Diagnostics struct {
+	// Capture a diagnostic snapshot when monit reports that a process is not running
+	Enabled bool `json:"enabled"`
+
+	// Number of lines kept from the end of every log file of the failing job
+	LogLines int `json:"log_lines"`
+}
+
+type CompileSandbox struct {
+	// Run packaging scripts in separate mount, PID and network namespaces
+	// with a read-only root file system
+	Enabled bool `json:"enabled"`
+
+	// Unprivileged user packaging scripts run as; vcap when empty
+	User string `json:"user"`
+
+	// Resource limits of packaging scripts; unlimited when zero
+	MaxMemoryInMB       uint64 `json:"max_memory"`
+	MaxProcesses        uint64 `json:"max_processes"`
+	MaxOpenFiles        uint64 `json:"max_open_files"`
+	MaxCPUTimeInSeconds uint64 `json:"max_cpu_time"`
+}
+
+type Webhook struct {
+	URL string `json:"url"`
+
+	// Used to sign the request body with HMAC-SHA256 when not empty
+	Secret string `json:"secret"`
+
+	// Types of events posted to the URL; all events when empty
+	Events []string `json:"events"`
+}
+
 type RunDir struct {
 	// Passed to mount directly
 	TmpFSSize string `json:"tmpfs_size"`
@@ -2,6 +2,7 @@ package settings_test
 
 import (
 	"encoding/json"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -975,6 +976,53 @@ var _ = Describe("Settings", func() {
 			Expect(env.Bosh.RunDir).To(Equal(RunDir{TmpFSSize: "37m"}))
 		})
 
+		It("can enable job failure diagnostics", func() {
+			env := Env{}
+			err := json.Unmarshal([]byte(`{"bosh": {} }`), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Bosh.Diagnostics).To(Equal(Diagnostics{}))
+
+			env = Env{}
+			err = json.Unmarshal([]byte(`{"bosh": {"diagnostics": {"enabled": true, "log_lines": 50} } }`), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Bosh.Diagnostics).To(Equal(Diagnostics{Enabled: true, LogLines: 50}))
+		})
+
+		It("can enable the compilation sandbox", func() {
+			env := Env{}
+			err := json.Unmarshal([]byte(`{"bosh": {} }`), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Bosh.CompileSandbox).To(Equal(CompileSandbox{}))
+
+			env = Env{}
+			err = json.Unmarshal([]byte(`{"bosh": {"compile_sandbox": {"enabled": true, "user": "compiler", "max_memory": 4096, "max_processes": 512, "max_open_files": 1024, "max_cpu_time": 3600} } }`), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Bosh.CompileSandbox).To(Equal(CompileSandbox{
+				Enabled:             true,
+				User:                "compiler",
+				MaxMemoryInMB:       4096,
+				MaxProcesses:        512,
+				MaxOpenFiles:        1024,
+				MaxCPUTimeInSeconds: 3600,
+			}))
+		})
+
+		It("can configure event webhooks", func() {
+			env := Env{}
+			err := json.Unmarshal([]byte(`{"bosh": {} }`), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Bosh.Webhooks).To(BeEmpty())
+
+			env = Env{}
+			err = json.Unmarshal([]byte(`{"bosh": {"webhooks": [{"url": "https://example.com/events", "secret": "s3cr3t", "events": ["alert", "shutdown"]}] } }`), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Bosh.Webhooks).To(Equal([]Webhook{{
+				URL:    "https://example.com/events",
+				Secret: "s3cr3t",
+				Events: []string{"alert", "shutdown"},
+			}}))
+		})
+
 		Context("when swap_size is not specified in the json", func() {
 			It("unmarshalls correctly", func() {
 				var env Env
@@ -987,6 +1035,48 @@ var _ = Describe("Settings", func() {
 			})
 		})
 
+		Context("#GetDrainTimeout", func() {
+			It("returns the drain timeout in seconds", func() {
+				var env Env
+				err := json.Unmarshal([]byte(`{"bosh": {"drain_timeout": 600}}`), &env)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(env.GetDrainTimeout()).To(Equal(10 * time.Minute))
+			})
+
+			It("returns zero when drain timeout is not specified", func() {
+				Expect(Env{}.GetDrainTimeout()).To(BeZero())
+			})
+		})
+
+		Context("#GetRetainedApplySpecs", func() {
+			It("returns the number of retained apply specs", func() {
+				var env Env
+				err := json.Unmarshal([]byte(`{"bosh": {"retained_apply_specs": 5}}`), &env)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(env.GetRetainedApplySpecs()).To(Equal(5))
+			})
+
+			It("retains no apply specs when not specified", func() {
+				Expect(Env{}.GetRetainedApplySpecs()).To(Equal(0))
+			})
+		})
+
+		Context("#GetCompileCacheSizeInBytes", func() {
+			It("returns the configured compile cache size", func() {
+				var env Env
+				err := json.Unmarshal([]byte(`{"bosh": {"compile_cache_size_mb": 512}}`), &env)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(env.GetCompileCacheSizeInBytes()).To(Equal(int64(512 * 1024 * 1024)))
+			})
+
+			It("disables the compile cache when not specified", func() {
+				Expect(Env{}.GetCompileCacheSizeInBytes()).To(BeZero())
+			})
+		})
+
 		Context("when parallel is not specified in the json", func() {
 			It("sets to the default value", func() {
 				var env Env
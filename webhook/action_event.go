@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"encoding/json"
+)
+
+type ActionEvent struct {
+	Action    string          `json:"action"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Value     interface{}     `json:"value,omitempty"`
+}
+
+type actionEventSpec struct {
+	eventType EventType
+
+	// Apply specs and settings contain credentials such as the mbus
+	// certificate key and blobstore secrets so they are not included in
+	// the event
+	includeArguments bool
+}
+
+var actionEventSpecs = map[string]actionEventSpec{
+	"apply":           {eventType: ApplyCompletedEvent},
+	"mount_disk":      {eventType: DiskMountedEvent, includeArguments: true},
+	"unmount_disk":    {eventType: DiskUnmountedEvent, includeArguments: true},
+	"update_settings": {eventType: SettingsUpdatedEvent},
+}
+
+// NewActionEvent builds the event published after the given action has
+// completed successfully. It returns false for actions without an event.
+func NewActionEvent(method string, payload []byte, value interface{}) (EventType, ActionEvent, bool) {
+	spec, found := actionEventSpecs[method]
+	if !found {
+		return "", ActionEvent{}, false
+	}
+
+	event := ActionEvent{Action: method, Value: value}
+
+	if spec.includeArguments {
+		var request struct {
+			Arguments json.RawMessage `json:"arguments"`
+		}
+
+		if err := json.Unmarshal(payload, &request); err == nil {
+			event.Arguments = request.Arguments
+		}
+	}
+
+	return spec.eventType, event, true
+}
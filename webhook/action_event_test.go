@@ -0,0 +1,42 @@
+package webhook_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/webhook"
+)
+
+var _ = Describe("NewActionEvent", func() {
+	It("includes the arguments of disk actions", func() {
+		eventType, event, found := NewActionEvent("unmount_disk", []byte(`{"arguments":["fake-disk-cid"]}`), "fake-value")
+		Expect(found).To(BeTrue())
+		Expect(eventType).To(Equal(DiskUnmountedEvent))
+		Expect(event).To(Equal(ActionEvent{
+			Action:    "unmount_disk",
+			Arguments: json.RawMessage(`["fake-disk-cid"]`),
+			Value:     "fake-value",
+		}))
+	})
+
+	It("does not include the apply spec", func() {
+		eventType, event, found := NewActionEvent("apply", []byte(`{"arguments":[{"deployment":"fake-deployment"}]}`), "applied")
+		Expect(found).To(BeTrue())
+		Expect(eventType).To(Equal(ApplyCompletedEvent))
+		Expect(event).To(Equal(ActionEvent{Action: "apply", Value: "applied"}))
+	})
+
+	It("does not include the updated settings", func() {
+		eventType, event, found := NewActionEvent("update_settings", []byte(`{"arguments":[{"mbus":{"cert":{"private_key":"fake-key"}}}]}`), "ok")
+		Expect(found).To(BeTrue())
+		Expect(eventType).To(Equal(SettingsUpdatedEvent))
+		Expect(event).To(Equal(ActionEvent{Action: "update_settings", Value: "ok"}))
+	})
+
+	It("returns false for actions without an event", func() {
+		_, _, found := NewActionEvent("ping", nil, "pong")
+		Expect(found).To(BeFalse())
+	})
+})
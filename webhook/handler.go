@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+)
+
+type handler struct {
+	boshhandler.Handler
+
+	publisher Publisher
+}
+
+// NewHandler publishes heartbeats, alerts and shutdown notifications sent
+// to the health monitor to the configured webhooks as well.
+func NewHandler(delegate boshhandler.Handler, publisher Publisher) boshhandler.Handler {
+	return handler{Handler: delegate, publisher: publisher}
+}
+
+func (h handler) Send(target boshhandler.Target, topic boshhandler.Topic, message interface{}) error {
+	err := h.Handler.Send(target, topic, message)
+
+	if target == boshhandler.HealthMonitor {
+		switch topic {
+		case boshhandler.Heartbeat:
+			h.publisher.Publish(HeartbeatEvent, message)
+		case boshhandler.Alert:
+			h.publisher.Publish(AlertEvent, message)
+		case boshhandler.Shutdown:
+			h.publisher.Publish(ShutdownEvent, message)
+		}
+	}
+
+	return err
+}
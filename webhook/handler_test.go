@@ -0,0 +1,68 @@
+package webhook_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	fakembus "github.com/cloudfoundry/bosh-agent/mbus/fakes"
+	. "github.com/cloudfoundry/bosh-agent/webhook"
+	"github.com/cloudfoundry/bosh-agent/webhook/webhookfakes"
+)
+
+var _ = Describe("handler", func() {
+	var (
+		delegate  *fakembus.FakeHandler
+		publisher *webhookfakes.FakePublisher
+		handler   boshhandler.Handler
+	)
+
+	BeforeEach(func() {
+		delegate = fakembus.NewFakeHandler()
+		publisher = &webhookfakes.FakePublisher{}
+		handler = NewHandler(delegate, publisher)
+	})
+
+	It("sends messages to the delegate and publishes them", func() {
+		err := handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, "fake-heartbeat")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(delegate.SendInputs()).To(Equal([]fakembus.SendInput{
+			{Target: boshhandler.HealthMonitor, Topic: boshhandler.Heartbeat, Message: "fake-heartbeat"},
+		}))
+
+		Expect(publisher.PublishCallCount()).To(Equal(1))
+		eventType, payload := publisher.PublishArgsForCall(0)
+		Expect(eventType).To(Equal(HeartbeatEvent))
+		Expect(payload).To(Equal("fake-heartbeat"))
+	})
+
+	It("publishes alerts and shutdown notifications", func() {
+		Expect(handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, "fake-alert")).To(Succeed())
+		Expect(handler.Send(boshhandler.HealthMonitor, boshhandler.Shutdown, nil)).To(Succeed())
+
+		Expect(publisher.PublishCallCount()).To(Equal(2))
+		eventType, _ := publisher.PublishArgsForCall(0)
+		Expect(eventType).To(Equal(AlertEvent))
+		eventType, _ = publisher.PublishArgsForCall(1)
+		Expect(eventType).To(Equal(ShutdownEvent))
+	})
+
+	It("publishes even when the delegate fails to send", func() {
+		delegate.SendErr = errors.New("fake-send-err")
+
+		err := handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, "fake-alert")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-send-err"))
+
+		Expect(publisher.PublishCallCount()).To(Equal(1))
+	})
+
+	It("does not publish messages to other targets", func() {
+		Expect(handler.Send(boshhandler.Director, boshhandler.Alert, "fake-alert")).To(Succeed())
+
+		Expect(publisher.PublishCallCount()).To(Equal(0))
+	})
+})
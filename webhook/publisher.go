@@ -0,0 +1,246 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+const (
+	publisherLogTag = "webhookPublisher"
+
+	EventTypeHeader = "X-Bosh-Agent-Event"
+	SignatureHeader = "X-Bosh-Agent-Signature"
+)
+
+type EventType string
+
+const (
+	HeartbeatEvent       = EventType("heartbeat")
+	AlertEvent           = EventType("alert")
+	ShutdownEvent        = EventType("shutdown")
+	ApplyCompletedEvent  = EventType("apply_completed")
+	DiskMountedEvent     = EventType("disk_mounted")
+	DiskUnmountedEvent   = EventType("disk_unmounted")
+	SettingsUpdatedEvent = EventType("settings_updated")
+)
+
+type Event struct {
+	ID        string      `json:"id"`
+	Type      EventType   `json:"type"`
+	AgentID   string      `json:"agent_id"`
+	Timestamp int64       `json:"timestamp"`
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . Publisher
+
+type Publisher interface {
+	// Publish queues an event for every configured webhook without blocking.
+	// Every webhook has its own queue and events are dropped for the
+	// webhooks whose queue is full.
+	Publish(eventType EventType, payload interface{})
+}
+
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type PublisherOptions struct {
+	QueueSize   int
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+var DefaultPublisherOptions = PublisherOptions{
+	QueueSize:   100,
+	MaxAttempts: 3,
+	RetryDelay:  2 * time.Second,
+}
+
+type concretePublisher struct {
+	settingsService boshsettings.Service
+	httpClient      HTTPClient
+	uuidGenerator   boshuuid.Generator
+	timeService     clock.Clock
+	options         PublisherOptions
+	logger          boshlog.Logger
+
+	lock   *sync.Mutex
+	queues map[string]chan delivery
+}
+
+type delivery struct {
+	webhook boshsettings.Webhook
+	event   Event
+	body    []byte
+}
+
+func NewPublisher(
+	settingsService boshsettings.Service,
+	httpClient HTTPClient,
+	uuidGenerator boshuuid.Generator,
+	timeService clock.Clock,
+	options PublisherOptions,
+	logger boshlog.Logger,
+) Publisher {
+	return concretePublisher{
+		settingsService: settingsService,
+		httpClient:      httpClient,
+		uuidGenerator:   uuidGenerator,
+		timeService:     timeService,
+		options:         options,
+		logger:          logger,
+
+		lock:   &sync.Mutex{},
+		queues: map[string]chan delivery{},
+	}
+}
+
+func (p concretePublisher) Publish(eventType EventType, payload interface{}) {
+	settings := p.settingsService.GetSettings()
+	if len(settings.Env.Bosh.Webhooks) == 0 {
+		return
+	}
+
+	id, err := p.uuidGenerator.Generate()
+	if err != nil {
+		p.logger.Error(publisherLogTag, "Failed to generate id for %s event: %s", eventType, err.Error())
+		return
+	}
+
+	event := Event{
+		ID:        id,
+		Type:      eventType,
+		AgentID:   settings.AgentID,
+		Timestamp: p.timeService.Now().Unix(),
+		Payload:   payload,
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		p.logger.Error(publisherLogTag, "Failed to marshal %s event %s: %s", eventType, id, err.Error())
+		return
+	}
+
+	for _, webhook := range settings.Env.Bosh.Webhooks {
+		if !subscribed(webhook, eventType) {
+			continue
+		}
+
+		select {
+		case p.queueFor(webhook.URL) <- delivery{webhook: webhook, event: event, body: body}:
+		default:
+			p.logger.Warn(publisherLogTag, "Dropping %s event %s for %s: queue is full", eventType, id, webhook.URL)
+		}
+	}
+}
+
+// queueFor returns the queue of the webhook with the given URL and starts
+// delivering its events on first use so that an unreachable webhook does
+// not hold up the others
+func (p concretePublisher) queueFor(url string) chan delivery {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	queue, found := p.queues[url]
+	if !found {
+		queue = make(chan delivery, p.options.QueueSize)
+		p.queues[url] = queue
+
+		go p.deliverEvents(queue)
+	}
+
+	return queue
+}
+
+func (p concretePublisher) deliverEvents(queue chan delivery) {
+	for d := range queue {
+		err := p.deliverWithRetry(d.webhook, d.event, d.body)
+		if err != nil {
+			p.logger.Error(publisherLogTag, "Failed to deliver %s event %s to %s: %s", d.event.Type, d.event.ID, d.webhook.URL, err.Error())
+		}
+	}
+}
+
+func (p concretePublisher) deliverWithRetry(webhook boshsettings.Webhook, event Event, body []byte) error {
+	var err error
+
+	delay := p.options.RetryDelay
+
+	for attempt := 1; attempt <= p.options.MaxAttempts; attempt++ {
+		err = p.deliver(webhook, event, body)
+		if err == nil {
+			return nil
+		}
+
+		if attempt < p.options.MaxAttempts {
+			p.logger.Debug(publisherLogTag, "Retrying %s event %s to %s in %s: %s", event.Type, event.ID, webhook.URL, delay, err.Error())
+			<-p.timeService.After(delay)
+			delay *= 2
+		}
+	}
+
+	return err
+}
+
+func (p concretePublisher) deliver(webhook boshsettings.Webhook, event Event, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return bosherr.WrapError(err, "Building request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventTypeHeader, string(event.Type))
+
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return bosherr.WrapError(err, "Posting event")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return bosherr.Errorf("Unexpected response status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the value of the signature header for the given body so
+// that receivers can verify that events originate from the agent.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func subscribed(webhook boshsettings.Webhook, eventType EventType) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+
+	for _, subscribedType := range webhook.Events {
+		if subscribedType == string(eventType) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,229 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	. "github.com/cloudfoundry/bosh-agent/webhook"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+)
+
+var _ = Describe("concretePublisher", func() {
+	var (
+		server          *ghttp.Server
+		settingsService *fakesettings.FakeSettingsService
+		uuidGenerator   *fakeuuid.FakeGenerator
+		timeService     *fakeclock.FakeClock
+		options         PublisherOptions
+		publisher       Publisher
+		received        chan *http.Request
+		receivedBodies  chan []byte
+	)
+
+	recordRequest := func(status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			body, err := io.ReadAll(req.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			received <- req
+			receivedBodies <- body
+
+			w.WriteHeader(status)
+		}
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		server.SetAllowUnhandledRequests(true)
+		server.SetUnhandledRequestStatusCode(http.StatusInternalServerError)
+
+		settingsService = &fakesettings.FakeSettingsService{}
+		settingsService.Settings.AgentID = "fake-agent-id"
+		settingsService.Settings.Env.Bosh.Webhooks = []boshsettings.Webhook{{URL: server.URL() + "/events"}}
+
+		uuidGenerator = &fakeuuid.FakeGenerator{GeneratedUUID: "fake-uuid"}
+		timeService = fakeclock.NewFakeClock(time.Unix(1306076861, 0))
+		options = PublisherOptions{QueueSize: 10, MaxAttempts: 3, RetryDelay: time.Millisecond}
+
+		received = make(chan *http.Request, 10)
+		receivedBodies = make(chan []byte, 10)
+	})
+
+	JustBeforeEach(func() {
+		publisher = NewPublisher(settingsService, http.DefaultClient, uuidGenerator, timeService, options, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("posts events as JSON to the configured webhooks", func() {
+		server.AppendHandlers(recordRequest(http.StatusOK))
+
+		publisher.Publish(AlertEvent, map[string]string{"title": "fake-title"})
+
+		var req *http.Request
+		Eventually(received).Should(Receive(&req))
+		Expect(req.Method).To(Equal("POST"))
+		Expect(req.URL.Path).To(Equal("/events"))
+		Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(req.Header.Get(EventTypeHeader)).To(Equal("alert"))
+		Expect(req.Header.Get(SignatureHeader)).To(BeEmpty())
+
+		var body []byte
+		Eventually(receivedBodies).Should(Receive(&body))
+		Expect(body).To(MatchJSON(`{
+			"id": "fake-uuid",
+			"type": "alert",
+			"agent_id": "fake-agent-id",
+			"timestamp": 1306076861,
+			"payload": {"title": "fake-title"}
+		}`))
+	})
+
+	It("signs the body when the webhook has a secret", func() {
+		settingsService.Settings.Env.Bosh.Webhooks[0].Secret = "fake-secret"
+		server.AppendHandlers(recordRequest(http.StatusOK))
+
+		publisher.Publish(ShutdownEvent, nil)
+
+		var req *http.Request
+		var body []byte
+		Eventually(received).Should(Receive(&req))
+		Eventually(receivedBodies).Should(Receive(&body))
+		Expect(req.Header.Get(SignatureHeader)).To(Equal(Sign("fake-secret", body)))
+		Expect(Sign("fake-secret", body)).To(HavePrefix("sha256="))
+	})
+
+	It("retries failed deliveries", func() {
+		server.AppendHandlers(
+			recordRequest(http.StatusServiceUnavailable),
+			recordRequest(http.StatusServiceUnavailable),
+			recordRequest(http.StatusAccepted),
+		)
+
+		publisher.Publish(HeartbeatEvent, nil)
+
+		Eventually(server.ReceivedRequests).Should(HaveLen(1))
+		Consistently(server.ReceivedRequests, 50*time.Millisecond).Should(HaveLen(1))
+
+		timeService.WaitForWatcherAndIncrement(time.Millisecond)
+		Eventually(server.ReceivedRequests).Should(HaveLen(2))
+
+		By("doubling the delay between attempts")
+		timeService.WaitForWatcherAndIncrement(time.Millisecond)
+		Consistently(server.ReceivedRequests, 50*time.Millisecond).Should(HaveLen(2))
+
+		timeService.Increment(time.Millisecond)
+		Eventually(server.ReceivedRequests).Should(HaveLen(3))
+		Consistently(server.ReceivedRequests, 50*time.Millisecond).Should(HaveLen(3))
+	})
+
+	It("gives up after the maximum number of attempts", func() {
+		publisher.Publish(HeartbeatEvent, nil)
+
+		Eventually(server.ReceivedRequests).Should(HaveLen(1))
+		timeService.WaitForWatcherAndIncrement(time.Millisecond)
+		Eventually(server.ReceivedRequests).Should(HaveLen(2))
+		timeService.WaitForWatcherAndIncrement(2 * time.Millisecond)
+		Eventually(server.ReceivedRequests).Should(HaveLen(3))
+
+		Consistently(server.ReceivedRequests, 50*time.Millisecond).Should(HaveLen(3))
+		Expect(timeService.WatcherCount()).To(Equal(0))
+	})
+
+	It("only posts events the webhook subscribed to", func() {
+		settingsService.Settings.Env.Bosh.Webhooks[0].Events = []string{"shutdown"}
+		server.AppendHandlers(recordRequest(http.StatusOK))
+
+		publisher.Publish(HeartbeatEvent, nil)
+		publisher.Publish(ShutdownEvent, nil)
+
+		var req *http.Request
+		Eventually(received).Should(Receive(&req))
+		Expect(req.Header.Get(EventTypeHeader)).To(Equal("shutdown"))
+		Consistently(server.ReceivedRequests, 50*time.Millisecond).Should(HaveLen(1))
+	})
+
+	Context("when one of the webhooks is unreachable", func() {
+		var healthyServer *ghttp.Server
+
+		BeforeEach(func() {
+			healthyServer = ghttp.NewServer()
+			healthyServer.SetAllowUnhandledRequests(true)
+			healthyServer.SetUnhandledRequestStatusCode(http.StatusOK)
+
+			settingsService.Settings.Env.Bosh.Webhooks = []boshsettings.Webhook{
+				{URL: server.URL() + "/events"},
+				{URL: healthyServer.URL() + "/events"},
+			}
+		})
+
+		AfterEach(func() {
+			healthyServer.Close()
+		})
+
+		It("keeps delivering events to the other webhooks while retrying", func() {
+			publisher.Publish(HeartbeatEvent, nil)
+			publisher.Publish(AlertEvent, nil)
+			publisher.Publish(ShutdownEvent, nil)
+
+			Eventually(healthyServer.ReceivedRequests).Should(HaveLen(3))
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("when the queue is full", func() {
+		var release chan struct{}
+
+		BeforeEach(func() {
+			options.QueueSize = 1
+			release = make(chan struct{})
+
+			blocking := func(w http.ResponseWriter, req *http.Request) {
+				<-release
+			}
+			server.AppendHandlers(blocking, recordRequest(http.StatusOK))
+		})
+
+		It("drops new events", func() {
+			publisher.Publish(AlertEvent, "first")
+			Eventually(server.ReceivedRequests).Should(HaveLen(1))
+
+			publisher.Publish(AlertEvent, "second")
+			publisher.Publish(AlertEvent, "third")
+
+			close(release)
+
+			var body []byte
+			Eventually(receivedBodies).Should(Receive(&body))
+
+			var event Event
+			Expect(json.Unmarshal(body, &event)).To(Succeed())
+			Expect(event.Payload).To(Equal("second"))
+			Consistently(server.ReceivedRequests, 50*time.Millisecond).Should(HaveLen(2))
+		})
+	})
+
+	Context("when no webhooks are configured", func() {
+		BeforeEach(func() {
+			settingsService.Settings.Env.Bosh.Webhooks = nil
+		})
+
+		It("does not post anything", func() {
+			publisher.Publish(AlertEvent, nil)
+
+			Consistently(server.ReceivedRequests, 50*time.Millisecond).Should(BeEmpty())
+		})
+	})
+})
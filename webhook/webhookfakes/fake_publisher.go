@@ -0,0 +1,78 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package webhookfakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/bosh-agent/webhook"
+)
+
+type FakePublisher struct {
+	PublishStub        func(webhook.EventType, interface{})
+	publishMutex       sync.RWMutex
+	publishArgsForCall []struct {
+		arg1 webhook.EventType
+		arg2 interface{}
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakePublisher) Publish(arg1 webhook.EventType, arg2 interface{}) {
+	fake.publishMutex.Lock()
+	fake.publishArgsForCall = append(fake.publishArgsForCall, struct {
+		arg1 webhook.EventType
+		arg2 interface{}
+	}{arg1, arg2})
+	stub := fake.PublishStub
+	fake.recordInvocation("Publish", []interface{}{arg1, arg2})
+	fake.publishMutex.Unlock()
+	if stub != nil {
+		fake.PublishStub(arg1, arg2)
+	}
+}
+
+func (fake *FakePublisher) PublishCallCount() int {
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	return len(fake.publishArgsForCall)
+}
+
+func (fake *FakePublisher) PublishCalls(stub func(webhook.EventType, interface{})) {
+	fake.publishMutex.Lock()
+	defer fake.publishMutex.Unlock()
+	fake.PublishStub = stub
+}
+
+func (fake *FakePublisher) PublishArgsForCall(i int) (webhook.EventType, interface{}) {
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	argsForCall := fake.publishArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakePublisher) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakePublisher) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ webhook.Publisher = new(FakePublisher)